@@ -5,13 +5,17 @@
 
 ENV_FILE ?= .env.local
 
-.PHONY: run-local run-all-local
+.PHONY: run-local run-all-local check-config
 
 run-local: ## Run one service locally (SERVICE=...)
 	@echo "$(SERVICE) service running in dev mode"
 	@set -a; [ -f "$(ENV_FILE)" ] && . "$(ENV_FILE)"; set +a; \
 	go run ./services/$(SERVICE)/cmd/main.go
 
+check-config: ## Validate and print the configuration of one service (SERVICE=...)
+	@set -a; [ -f "$(ENV_FILE)" ] && . "$(ENV_FILE)"; set +a; \
+	go run ./services/$(SERVICE)/cmd/main.go --check-config
+
 run-all-local: ## Run all services locally (background). Stop with Ctrl+C
 	@echo "Running all services in dev mode (background)"
 	@set -e; \
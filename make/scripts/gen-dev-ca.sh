@@ -86,10 +86,42 @@ openssl x509 -req \
   -days 825 -sha256 \
   -extfile v3_server.cnf
 
-# 4) Verify
+# 4) Generate auth client key + cert for mTLS towards the user service (always re-generate)
+echo "==> Generating auth client key/cert..."
+rm -f auth-client.key auth-client.csr auth-client.crt
+
+cat > v3_client.cnf <<'CNF'
+authorityKeyIdentifier=keyid,issuer
+basicConstraints=CA:FALSE
+keyUsage = digitalSignature, keyEncipherment
+extendedKeyUsage = clientAuth
+subjectAltName = @alt_names
+
+[ alt_names ]
+DNS.1 = auth
+URI.1 = spiffe://dev.local/auth
+CNF
+
+openssl genrsa -out auth-client.key 2048
+openssl req -new \
+  -key auth-client.key \
+  -out auth-client.csr \
+  -subj "/C=JP/ST=Tokyo/L=Tokyo/O=Dev/CN=auth"
+
+openssl x509 -req \
+  -in auth-client.csr \
+  -CA dev-root-ca.crt \
+  -CAkey dev-root-ca.key \
+  -CAcreateserial \
+  -out auth-client.crt \
+  -days 825 -sha256 \
+  -extfile v3_client.cnf
+
+# 5) Verify
 echo "==> Verify subject & SAN"
 openssl x509 -in localhost.crt -noout -subject
 openssl x509 -in localhost.crt -noout -text | grep -A2 "Subject Alternative Name"
+openssl x509 -in auth-client.crt -noout -text | grep -A2 "Subject Alternative Name"
 
 echo "==> Files"
 ls -la
@@ -99,3 +131,5 @@ echo "==> Done"
 echo "CA (import into Insomnia as CA): $OUT_DIR/dev-root-ca.crt"
 echo "Server cert (Envoy):             $OUT_DIR/localhost.crt"
 echo "Server key  (Envoy):             $OUT_DIR/localhost.key"
+echo "Client cert (auth -> user mTLS):  $OUT_DIR/auth-client.crt"
+echo "Client key  (auth -> user mTLS):  $OUT_DIR/auth-client.key"
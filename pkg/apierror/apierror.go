@@ -0,0 +1,144 @@
+// Package apierror defines coded errors with a stable, client-facing error code and
+// message. Domain and gateway errors are built from them so that a single translation
+// layer can map any error onto an API response without leaking internals.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code is a stable, machine-readable error code returned to clients as error_code.
+type Code string
+
+const (
+	// CodeInvalidRequest is a malformed or invalid request.
+	CodeInvalidRequest Code = "invalid_request"
+	// CodeInvalidCredentials is a login with an unknown email or a wrong password.
+	CodeInvalidCredentials Code = "invalid_credentials"
+	// CodeUnauthorized is a request without valid authentication.
+	CodeUnauthorized Code = "unauthorized"
+	// CodeForbidden is an authenticated request that is not allowed.
+	CodeForbidden Code = "forbidden"
+	// CodeUserNotActive is a login with valid credentials of a disabled or locked user.
+	CodeUserNotActive Code = "user_not_active"
+	// CodeNotFound is a request for something that does not exist.
+	CodeNotFound Code = "not_found"
+	// CodeRateLimited is a request rejected by rate limiting.
+	CodeRateLimited Code = "rate_limited"
+	// CodeUnavailable is a request that failed because a dependency is unavailable.
+	CodeUnavailable Code = "service_unavailable"
+	// CodeInternal is any other failure. Its details are never returned to clients.
+	CodeInternal Code = "internal_error"
+)
+
+// HTTPStatus returns the HTTP status code of code.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeInvalidRequest:
+		return http.StatusBadRequest
+	case CodeInvalidCredentials, CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden, CodeUserNotActive:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GRPCCode returns the gRPC status code of code.
+func (c Code) GRPCCode() codes.Code {
+	switch c {
+	case CodeInvalidRequest:
+		return codes.InvalidArgument
+	case CodeInvalidCredentials, CodeUnauthorized:
+		return codes.Unauthenticated
+	case CodeForbidden, CodeUserNotActive:
+		return codes.PermissionDenied
+	case CodeNotFound:
+		return codes.NotFound
+	case CodeRateLimited:
+		return codes.ResourceExhausted
+	case CodeUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// Error is an error with a code and a message safe to return to clients. The wrapped
+// error carries the internal details.
+type Error struct {
+	Code    Code
+	Message string
+	// Status overrides the HTTP status of Code when set, e.g. 405 from the validator.
+	Status int
+	// Params are the request fields that failed validation.
+	Params []InvalidParam
+	Err    error
+}
+
+// InvalidParam is a request field that failed validation.
+type InvalidParam struct {
+	// Name is the parameter name, or the JSON pointer of a body field (e.g. /email).
+	Name string `json:"name"`
+	// In is where the field is: path, query, header, cookie or body.
+	In     string `json:"in"`
+	Reason string `json:"reason"`
+}
+
+// HTTPStatus returns the HTTP status of e.
+func (e *Error) HTTPStatus() int {
+	if e.Status != 0 {
+		return e.Status
+	}
+	return e.Code.HTTPStatus()
+}
+
+// New creates a new Error.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates a new Error wrapping err.
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return string(e.Code) + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code, so errors.Is matches
+// errors by code regardless of their message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// From returns the first *Error in err's chain, or an internal error with a generic
+// message when there is none.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(CodeInternal, "internal error", err)
+}
@@ -0,0 +1,88 @@
+package apierror_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+// TestUnitCode_Status tests the HTTP status and gRPC code of every error code.
+func TestUnitCode_Status(t *testing.T) {
+	tests := []struct {
+		code     apierror.Code
+		wantHTTP int
+		wantGRPC codes.Code
+	}{
+		{code: apierror.CodeInvalidRequest, wantHTTP: http.StatusBadRequest, wantGRPC: codes.InvalidArgument},
+		{code: apierror.CodeInvalidCredentials, wantHTTP: http.StatusUnauthorized, wantGRPC: codes.Unauthenticated},
+		{code: apierror.CodeUnauthorized, wantHTTP: http.StatusUnauthorized, wantGRPC: codes.Unauthenticated},
+		{code: apierror.CodeForbidden, wantHTTP: http.StatusForbidden, wantGRPC: codes.PermissionDenied},
+		{code: apierror.CodeUserNotActive, wantHTTP: http.StatusForbidden, wantGRPC: codes.PermissionDenied},
+		{code: apierror.CodeNotFound, wantHTTP: http.StatusNotFound, wantGRPC: codes.NotFound},
+		{code: apierror.CodeRateLimited, wantHTTP: http.StatusTooManyRequests, wantGRPC: codes.ResourceExhausted},
+		{code: apierror.CodeUnavailable, wantHTTP: http.StatusServiceUnavailable, wantGRPC: codes.Unavailable},
+		{code: apierror.CodeInternal, wantHTTP: http.StatusInternalServerError, wantGRPC: codes.Internal},
+		{code: "unknown", wantHTTP: http.StatusInternalServerError, wantGRPC: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.code), func(t *testing.T) {
+			assert.Equal(t, tt.wantHTTP, tt.code.HTTPStatus())
+			assert.Equal(t, tt.wantGRPC, tt.code.GRPCCode())
+			assert.Equal(t, tt.wantHTTP, apierror.New(tt.code, "message").HTTPStatus())
+		})
+	}
+}
+
+// TestUnitCodeForStatus tests the code of the error statuses raised by middleware.
+func TestUnitCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   apierror.Code
+	}{
+		{status: http.StatusBadRequest, want: apierror.CodeInvalidRequest},
+		{status: http.StatusUnauthorized, want: apierror.CodeUnauthorized},
+		{status: http.StatusForbidden, want: apierror.CodeForbidden},
+		{status: http.StatusNotFound, want: apierror.CodeNotFound},
+		{status: http.StatusMethodNotAllowed, want: apierror.CodeInvalidRequest},
+		{status: http.StatusTooManyRequests, want: apierror.CodeRateLimited},
+		{status: http.StatusInternalServerError, want: apierror.CodeInternal},
+		{status: http.StatusServiceUnavailable, want: apierror.CodeUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.want, apierror.CodeForStatus(tt.status))
+		})
+	}
+}
+
+// TestUnitError_IsAndFrom tests that errors match by code through wrapping, and From
+// finds the coded error of a chain or hides an uncoded one behind an internal error.
+func TestUnitError_IsAndFrom(t *testing.T) {
+	errNotFound := apierror.New(apierror.CodeNotFound, "user not found")
+	cause := errors.New("sql: no rows")
+	wrapped := fmt.Errorf("get user: %w", apierror.Wrap(apierror.CodeNotFound, "no such user", cause))
+
+	assert.ErrorIs(t, wrapped, errNotFound)
+	assert.ErrorIs(t, wrapped, cause)
+	assert.NotErrorIs(t, wrapped, apierror.New(apierror.CodeInternal, "user not found"))
+	assert.Equal(t, "not_found: no such user: sql: no rows", errors.Unwrap(wrapped).Error())
+
+	e := apierror.From(wrapped)
+	assert.Equal(t, apierror.CodeNotFound, e.Code)
+	assert.Equal(t, "no such user", e.Message)
+
+	e = apierror.From(cause)
+	assert.Equal(t, apierror.CodeInternal, e.Code)
+	assert.Equal(t, "internal error", e.Message)
+	assert.ErrorIs(t, e, cause)
+
+	e = &apierror.Error{Code: apierror.CodeInvalidRequest, Status: http.StatusMethodNotAllowed}
+	assert.Equal(t, http.StatusMethodNotAllowed, e.HTTPStatus(), "Status overrides the code")
+}
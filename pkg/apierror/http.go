@@ -0,0 +1,148 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentTypeProblem is the media type of RFC 7807 problem details.
+const ContentTypeProblem = "application/problem+json"
+
+// headerRequestID is the request ID header set by Envoy, used when the request
+// logging middleware has not put the request ID in the baggage.
+const headerRequestID = "X-Request-ID"
+
+// Format is the shape of error bodies.
+type Format string
+
+const (
+	// FormatProblem writes RFC 7807 problem details.
+	FormatProblem Format = "problem"
+	// FormatLegacy writes the {"error_code", "message"} body of the ErrorResponse schema.
+	FormatLegacy Format = "legacy"
+)
+
+// UnmarshalText parses a format, so Format can be loaded from config.
+func (f *Format) UnmarshalText(text []byte) error {
+	switch format := Format(strings.TrimSpace(string(text))); format {
+	case FormatProblem, FormatLegacy:
+		*f = format
+		return nil
+	default:
+		return fmt.Errorf("must be %q or %q", FormatProblem, FormatLegacy)
+	}
+}
+
+// Body is the legacy JSON error body, the ErrorResponse schema of the OpenAPI specs.
+type Body struct {
+	ErrorCode Code   `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details body. error_code, request_id, trace_id and
+// invalid_params are extension members.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	ErrorCode     Code           `json:"error_code"`
+	RequestID     string         `json:"request_id,omitempty"`
+	TraceID       string         `json:"trace_id,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// CodeForStatus returns the code of an HTTP error status, for errors raised by
+// middleware that only knows the status, e.g. the OpenAPI request validator.
+func CodeForStatus(status int) Code {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case status >= 400 && status < 500:
+		return CodeInvalidRequest
+	default:
+		return CodeInternal
+	}
+}
+
+type formatKey struct{}
+
+// Negotiate is a middleware setting the error format of requests that do not accept
+// application/problem+json. Existing clients keep the legacy body with FormatLegacy,
+// while clients asking for problem details get them either way.
+func Negotiate(format Format) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), formatKey{}, format)))
+		})
+	}
+}
+
+// negotiate returns the error format of r: problem details when the client accepts
+// them, otherwise the format set by Negotiate, FormatProblem by default.
+func negotiate(r *http.Request) Format {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == ContentTypeProblem {
+			return FormatProblem
+		}
+	}
+	if format, ok := r.Context().Value(formatKey{}).(Format); ok {
+		return format
+	}
+	return FormatProblem
+}
+
+// Write writes err as an error body in the format negotiated for r, with the status of
+// its code. Errors without a code are written as internal errors, without their details.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	status := e.HTTPStatus()
+
+	if negotiate(r) == FormatLegacy {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(Body{ErrorCode: e.Code, Message: e.Message})
+		return
+	}
+
+	problem := Problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        e.Message,
+		Instance:      r.URL.Path,
+		ErrorCode:     e.Code,
+		RequestID:     requestID(r),
+		InvalidParams: e.Params,
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		problem.TraceID = sc.TraceID().String()
+	}
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+func requestID(r *http.Request) string {
+	if id := baggage.FromContext(r.Context()).Member(string(correlation.BaggageRequestID)).Value(); id != "" {
+		return id
+	}
+	return r.Header.Get(headerRequestID)
+}
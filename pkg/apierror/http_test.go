@@ -0,0 +1,180 @@
+package apierror_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// write serves err through Write behind the Negotiate middleware when format is set.
+func write(t *testing.T, r *http.Request, format apierror.Format, err error) *httptest.ResponseRecorder {
+	t.Helper()
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, err)
+	})
+	if format != "" {
+		h = apierror.Negotiate(format)(h)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// TestUnitWrite_Negotiation tests that clients accepting problem details always get
+// them, and the others get the format set by Negotiate, problem details by default.
+func TestUnitWrite_Negotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		format          apierror.Format
+		wantContentType string
+	}{
+		{name: "default", wantContentType: apierror.ContentTypeProblem},
+		{name: "legacy", format: apierror.FormatLegacy, wantContentType: "application/json"},
+		{name: "legacy accepting json", accept: "application/json", format: apierror.FormatLegacy, wantContentType: "application/json"},
+		{name: "problem", format: apierror.FormatProblem, wantContentType: apierror.ContentTypeProblem},
+		{name: "legacy accepting problem", accept: "application/problem+json", format: apierror.FormatLegacy, wantContentType: apierror.ContentTypeProblem},
+		{
+			name:            "legacy accepting problem among others",
+			accept:          "application/json, application/problem+json;q=0.9",
+			format:          apierror.FormatLegacy,
+			wantContentType: apierror.ContentTypeProblem,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			rec := write(t, r, tt.format, apierror.New(apierror.CodeInvalidCredentials, "invalid email or password"))
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "invalid_credentials", body["error_code"])
+			if tt.wantContentType == apierror.ContentTypeProblem {
+				assert.Equal(t, "invalid email or password", body["detail"])
+				assert.NotContains(t, body, "message")
+			} else {
+				assert.Equal(t, map[string]any{"error_code": "invalid_credentials", "message": "invalid email or password"}, body)
+			}
+		})
+	}
+}
+
+// TestUnitWrite_Problem tests the RFC 7807 body written for errors, with the request and
+// trace IDs of the request, and without the details of uncoded errors.
+func TestUnitWrite_Problem(t *testing.T) {
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	member, err := baggage.NewMember(string(correlation.BaggageRequestID), "req-baggage")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		// header is the X-Request-ID of the request.
+		header string
+		err    error
+		want   apierror.Problem
+	}{
+		{
+			name:   "coded error",
+			header: "req-header",
+			err: &apierror.Error{
+				Code:    apierror.CodeInvalidRequest,
+				Message: "request validation failed",
+				Params:  []apierror.InvalidParam{{Name: "/email", In: "body", Reason: "invalid format"}},
+			},
+			want: apierror.Problem{
+				Type:          "about:blank",
+				Title:         "Bad Request",
+				Status:        http.StatusBadRequest,
+				Detail:        "request validation failed",
+				Instance:      "/v1/users",
+				ErrorCode:     apierror.CodeInvalidRequest,
+				RequestID:     "req-header",
+				InvalidParams: []apierror.InvalidParam{{Name: "/email", In: "body", Reason: "invalid format"}},
+			},
+		},
+		{
+			name:   "status override",
+			err:    &apierror.Error{Code: apierror.CodeInvalidRequest, Message: "method not allowed", Status: http.StatusMethodNotAllowed},
+			header: "req-header",
+			want: apierror.Problem{
+				Type:      "about:blank",
+				Title:     "Method Not Allowed",
+				Status:    http.StatusMethodNotAllowed,
+				Detail:    "method not allowed",
+				Instance:  "/v1/users",
+				ErrorCode: apierror.CodeInvalidRequest,
+				RequestID: "req-header",
+			},
+		},
+		{
+			name: "uncoded error with baggage and trace",
+			ctx: trace.ContextWithSpanContext(
+				baggage.ContextWithBaggage(context.Background(), bag),
+				trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID}),
+			),
+			header: "req-header",
+			err:    errors.New("dial tcp: connection refused"),
+			want: apierror.Problem{
+				Type:      "about:blank",
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Detail:    "internal error",
+				Instance:  "/v1/users",
+				ErrorCode: apierror.CodeInternal,
+				RequestID: "req-baggage",
+				TraceID:   traceID.String(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/users?page=1", nil)
+			if tt.ctx != nil {
+				r = r.WithContext(tt.ctx)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+
+			rec := write(t, r, "", tt.err)
+
+			assert.Equal(t, tt.want.Status, rec.Code)
+			assert.Equal(t, apierror.ContentTypeProblem, rec.Header().Get("Content-Type"))
+			var got apierror.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got)
+			assert.NotContains(t, rec.Body.String(), "connection refused")
+		})
+	}
+}
+
+// TestUnitFormat_UnmarshalText tests that only the known formats are loaded.
+func TestUnitFormat_UnmarshalText(t *testing.T) {
+	var f apierror.Format
+	require.NoError(t, f.UnmarshalText([]byte(" legacy ")))
+	assert.Equal(t, apierror.FormatLegacy, f)
+	require.NoError(t, f.UnmarshalText([]byte("problem")))
+	assert.Equal(t, apierror.FormatProblem, f)
+	assert.Error(t, f.UnmarshalText([]byte("xml")))
+}
@@ -0,0 +1,86 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// FromValidation converts an OpenAPI request validation error with the status suggested
+// by the validator into an Error listing the fields that failed validation.
+func FromValidation(err error, status int) *Error {
+	e := &Error{
+		Code:    CodeForStatus(status),
+		Message: "request validation failed",
+		Status:  status,
+		Params:  invalidParams(err),
+		Err:     err,
+	}
+	var securityErr *openapi3filter.SecurityRequirementsError
+	switch {
+	case errors.As(err, &securityErr):
+		e.Message = "authentication required"
+	case status == http.StatusNotFound:
+		e.Message = "no such operation"
+	case status == http.StatusMethodNotAllowed:
+		e.Message = "method not allowed"
+	}
+	return e
+}
+
+// invalidParams returns the fields a kin-openapi error reports as invalid.
+func invalidParams(err error) []InvalidParam {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var params []InvalidParam
+		for _, e := range multi {
+			params = append(params, invalidParams(e)...)
+		}
+		return params
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return nil
+	}
+	switch {
+	case reqErr.Parameter != nil:
+		reason := reqErr.Reason
+		if reason == "" && reqErr.Err != nil {
+			reason = reqErr.Err.Error()
+		}
+		return []InvalidParam{{Name: reqErr.Parameter.Name, In: reqErr.Parameter.In, Reason: reason}}
+	case reqErr.RequestBody != nil:
+		if params := schemaParams(reqErr.Err); len(params) > 0 {
+			return params
+		}
+		reason := reqErr.Reason
+		if reason == "" && reqErr.Err != nil {
+			reason = reqErr.Err.Error()
+		}
+		return []InvalidParam{{Name: "/", In: "body", Reason: reason}}
+	}
+	return nil
+}
+
+// schemaParams returns the body fields of schema validation errors.
+func schemaParams(err error) []InvalidParam {
+	if multi, ok := err.(openapi3.MultiError); ok {
+		var params []InvalidParam
+		for _, e := range multi {
+			params = append(params, schemaParams(e)...)
+		}
+		return params
+	}
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return nil
+	}
+	return []InvalidParam{{
+		Name:   "/" + strings.Join(schemaErr.JSONPointer(), "/"),
+		In:     "body",
+		Reason: schemaErr.Reason,
+	}}
+}
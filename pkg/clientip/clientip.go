@@ -0,0 +1,186 @@
+// Package clientip resolves the IP of the client behind trusted reverse proxies.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Headers read from trusted proxies, in order of preference.
+const (
+	// HeaderEnvoyExternalAddress is set by Envoy to the client address when the request
+	// comes from outside its internal_address_config ranges.
+	HeaderEnvoyExternalAddress = "X-Envoy-External-Address"
+	// HeaderForwarded is the RFC 7239 Forwarded header.
+	HeaderForwarded = "Forwarded"
+	// HeaderXForwardedFor is the de-facto X-Forwarded-For header.
+	HeaderXForwardedFor = "X-Forwarded-For"
+)
+
+// Prefixes are the networks of the trusted proxies.
+type Prefixes []netip.Prefix
+
+// ParsePrefixes parses comma-separated CIDRs, e.g. "10.0.0.0/8,172.16.0.0/12". A bare
+// address is a single-address prefix.
+func ParsePrefixes(s string) (Prefixes, error) {
+	var prefixes Prefixes
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// UnmarshalText parses prefixes in the ParsePrefixes format, so Prefixes can be loaded from config.
+func (p *Prefixes) UnmarshalText(text []byte) error {
+	prefixes, err := ParsePrefixes(string(text))
+	if err != nil {
+		return err
+	}
+	*p = prefixes
+	return nil
+}
+
+// MarshalText formats the prefixes in the ParsePrefixes format.
+func (p Prefixes) MarshalText() ([]byte, error) {
+	s := make([]string, len(p))
+	for i, prefix := range p {
+		s[i] = prefix.String()
+	}
+	return []byte(strings.Join(s, ",")), nil
+}
+
+// Contains reports whether addr is in one of the prefixes.
+func (p Prefixes) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver resolves the client IP of requests. Forwarding headers are only believed
+// when the request comes from a trusted proxy, and X-Forwarded-For and Forwarded are
+// walked from the right, skipping trusted proxies, so a client cannot spoof its address
+// by sending the headers itself.
+type Resolver struct {
+	trusted Prefixes
+}
+
+// NewResolver creates a Resolver trusting the proxies in trusted. With no trusted
+// proxies, the client IP is always the peer address.
+func NewResolver(trusted Prefixes) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// Trusted reports whether r comes directly from a trusted proxy, so the headers the
+// proxy sets can be believed.
+func (res *Resolver) Trusted(r *http.Request) bool {
+	peer, ok := parseAddr(r.RemoteAddr)
+	return ok && res.trusted.Contains(peer)
+}
+
+// ClientIP returns the client IP of r: the first untrusted address of, in order of
+// preference, X-Envoy-External-Address, Forwarded and X-Forwarded-For when the peer is
+// a trusted proxy, else the peer address. A peer address that is not an IP is returned
+// as is.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	peer, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.trusted.Contains(peer) {
+		return peer.String()
+	}
+
+	if addr, ok := parseAddr(r.Header.Get(HeaderEnvoyExternalAddress)); ok {
+		return addr.String()
+	}
+	if hops := forwardedFor(r.Header.Values(HeaderForwarded)); len(hops) > 0 {
+		return res.walk(peer, hops).String()
+	}
+	if hops := splitList(r.Header.Values(HeaderXForwardedFor)); len(hops) > 0 {
+		return res.walk(peer, hops).String()
+	}
+	return peer.String()
+}
+
+// walk returns the rightmost untrusted address of hops, the leftmost address if all
+// are trusted, or the last address read before a hop that is not an IP, e.g. "unknown".
+func (res *Resolver) walk(peer netip.Addr, hops []string) netip.Addr {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			return client
+		}
+		client = addr
+		if !res.trusted.Contains(addr) {
+			return client
+		}
+	}
+	return client
+}
+
+// forwardedFor returns the for= parameters of Forwarded header values, in order.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hops = append(hops, strings.Trim(value, `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// splitList splits comma-separated header values.
+func splitList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseAddr parses an IP with an optional port, IPv6 optionally in brackets.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,113 @@
+package clientip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/clientip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnitResolver_ClientIP tests that forwarding headers are only believed from trusted
+// proxies and are walked from the right.
+func TestUnitResolver_ClientIP(t *testing.T) {
+	trusted, err := clientip.ParsePrefixes("10.0.0.0/8, 172.16.0.0/12,192.168.0.0/16")
+	require.NoError(t, err)
+	resolver := clientip.NewResolver(trusted)
+
+	for _, tt := range []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "direct client spoofing XFF",
+			remote: "203.0.113.7:5555",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"1.2.3.4"},
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name:   "XFF through two trusted proxies",
+			remote: "10.0.0.2:5555",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"1.2.3.4, 198.51.100.9", "172.16.0.5"},
+			},
+			want: "198.51.100.9", // 1.2.3.4 was sent by the client itself
+		},
+		{
+			name:   "XFF of trusted proxies only",
+			remote: "10.0.0.2:5555",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.168.1.1,10.1.1.1"},
+			},
+			want: "192.168.1.1",
+		},
+		{
+			name:   "XFF with unknown hop",
+			remote: "10.0.0.2:5555",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.9, unknown, 10.1.1.1"},
+			},
+			want: "10.1.1.1",
+		},
+		{
+			name:   "Forwarded preferred over XFF",
+			remote: "10.0.0.2:5555",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"`},
+				"X-Forwarded-For": {"198.51.100.9"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:   "Envoy external address preferred",
+			remote: "10.0.0.2:5555",
+			headers: map[string][]string{
+				"X-Envoy-External-Address": {"192.0.2.61"},
+				"X-Forwarded-For":          {"198.51.100.9"},
+			},
+			want: "192.0.2.61",
+		},
+		{
+			name:   "IPv4-mapped trusted peer",
+			remote: "[::ffff:10.0.0.2]:5555",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.9:1234"},
+			},
+			want: "198.51.100.9",
+		},
+		{
+			name:   "peer without port",
+			remote: "not-a-hostport",
+			want:   "not-a-hostport",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			for k, values := range tt.headers {
+				for _, v := range values {
+					req.Header.Add(k, v)
+				}
+			}
+			assert.Equal(t, tt.want, resolver.ClientIP(req))
+		})
+	}
+}
+
+// TestUnitParsePrefixes tests the CIDR list format.
+func TestUnitParsePrefixes(t *testing.T) {
+	prefixes, err := clientip.ParsePrefixes("10.1.2.3/8,::1")
+	require.NoError(t, err)
+	text, err := prefixes.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8,::1/128", string(text))
+
+	_, err = clientip.ParsePrefixes("10.0.0.0/33")
+	assert.Error(t, err)
+}
@@ -0,0 +1,364 @@
+// Package config loads a service configuration described by struct tags. Every setting
+// is merged from its default, a YAML or JSON file, an env var and a command-line flag,
+// in increasing order of precedence, and all problems are reported at once.
+//
+// The tags of a setting are:
+//
+//	env:"AUTH_HTTP_PORT"   env var holding the value
+//	default:"8080"         value used when no other source sets one
+//	required:"true"        a missing value is an error
+//	secret:"true"          the value is redacted in dumps and may be a secret reference
+//	unit:"s"               a bare integer for a time.Duration is in this unit (ms, s, m, h)
+//	key:"allowed_sans"     name in the file instead of the snake_cased field name
+//
+// A nested struct is a group of settings. The file key of a setting is the dotted path
+// of its group and field names (e.g. server.http_port) and its flag is the same path
+// with dashes (e.g. --server.http-port). A non-zero value already in the struct when
+// Load is called is the default of its setting.
+//
+// When Options.Secrets is set, the value of a secret setting may be a reference such as
+// file:///run/secrets/redis_password, which is resolved before it is set. Settings are
+// resolved in declaration order, so a provider may depend on settings declared earlier.
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Source is where the value of a setting came from.
+type Source string
+
+const (
+	// SourceNone means no source set the setting.
+	SourceNone Source = ""
+	// SourceDefault is the default of the setting.
+	SourceDefault Source = "default"
+	// SourceFile is the config file.
+	SourceFile Source = "file"
+	// SourceEnv is the env var of the setting.
+	SourceEnv Source = "env"
+	// SourceFlag is the command-line flag of the setting.
+	SourceFlag Source = "flag"
+)
+
+const redacted = "[REDACTED]"
+
+// resolveTimeout bounds the resolution of a single secret reference.
+const resolveTimeout = 10 * time.Second
+
+// SecretResolver resolves secret references, e.g. a *secrets.Resolver.
+type SecretResolver interface {
+	IsReference(value string) bool
+	Resolve(ctx context.Context, value string) (string, error)
+}
+
+// errMissing is the error reported for a required setting no source sets.
+var errMissing = errors.New("missing value")
+
+// Options are the options for Load.
+type Options struct {
+	// Name is the program name shown in the flag usage.
+	Name string
+	// Args are the command-line arguments without the program name.
+	Args []string
+	// FileEnv is the env var holding the config file path. The --config flag takes precedence.
+	FileEnv string
+	// LookupEnv looks up env vars. It defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+	// Secrets resolves the references in secret settings. Nil takes every value literally.
+	Secrets SecretResolver
+}
+
+// Setting is the effective value of a setting.
+type Setting struct {
+	Key    string
+	Env    string
+	Flag   string
+	Value  string
+	Source Source
+	Secret bool
+	// Reference is set when Value is a secret reference rather than the secret itself.
+	Reference bool
+}
+
+// Result describes how a configuration was loaded.
+type Result struct {
+	// File is the config file read, if any.
+	File string
+	// CheckOnly is set by --check-config: validate the configuration and exit.
+	CheckOnly bool
+	// Settings are the effective settings in declaration order.
+	Settings []Setting
+}
+
+// Redacted returns the effective values by key, with secrets redacted.
+func (r *Result) Redacted() map[string]string {
+	out := make(map[string]string, len(r.Settings))
+	for _, s := range r.Settings {
+		out[s.Key] = s.redactedValue()
+	}
+	return out
+}
+
+// Reference returns the secret reference the setting key was loaded from, if any, so
+// the secret can be watched for rotation.
+func (r *Result) Reference(key string) (string, bool) {
+	for _, s := range r.Settings {
+		if s.Key == key && s.Reference {
+			return s.Value, true
+		}
+	}
+	return "", false
+}
+
+// Dump writes the effective settings, their sources and env vars to w, with secrets redacted.
+func (r *Result) Dump(w io.Writer) error {
+	if r.File != "" {
+		if _, err := fmt.Fprintf(w, "# file: %s\n", r.File); err != nil {
+			return err
+		}
+	}
+	for _, s := range r.Settings {
+		source := string(s.Source)
+		if source == "" {
+			source = "unset"
+		}
+		if s.Env != "" {
+			source += " (" + s.Env + ")"
+		}
+		if _, err := fmt.Fprintf(w, "%s = %q # %s\n", s.Key, s.redactedValue(), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Setting) redactedValue() string {
+	if s.Secret && !s.Reference && s.Value != "" {
+		return redacted
+	}
+	return s.Value
+}
+
+// Check implements --check-config: it writes the effective configuration and any errors
+// to w and returns the process exit code.
+func Check(w io.Writer, r *Result, err error) int {
+	if dumpErr := r.Dump(w); dumpErr != nil {
+		return 1
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "\nconfiguration is invalid:\n%v\n", err)
+		return 1
+	}
+	_, _ = fmt.Fprintln(w, "\nconfiguration is valid")
+	return 0
+}
+
+// field is a leaf setting of the configuration struct.
+type field struct {
+	value    reflect.Value
+	key      string
+	env      string
+	def      string
+	unit     string
+	required bool
+	secret   bool
+}
+
+func (f field) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// label names the setting in errors: its env var, or its file key.
+func (f field) label() string {
+	if f.env != "" {
+		return f.env
+	}
+	return f.key
+}
+
+// Load fills dst, a pointer to a struct, from the configured sources. The returned error
+// joins every problem found; the Result is nil only when the arguments cannot be parsed.
+func Load(dst any, opts Options) (*Result, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config: want a pointer to a struct, got %T", dst)
+	}
+	lookupEnv := opts.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	fields, err := collect(v.Elem(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	fs := flag.NewFlagSet(opts.Name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML or JSON config file")
+	checkOnly := fs.Bool("check-config", false, "validate the configuration, print it and exit")
+	for _, f := range fields {
+		usage := "setting " + f.key
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		fs.String(f.flagName(), "", usage)
+	}
+	if err := fs.Parse(opts.Args); err != nil {
+		return nil, err
+	}
+	flags := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		flags[fl.Name] = fl.Value.String()
+	})
+
+	res := &Result{File: *configFile, CheckOnly: *checkOnly}
+	if res.File == "" && opts.FileEnv != "" {
+		if path, ok := lookupEnv(opts.FileEnv); ok {
+			res.File = strings.TrimSpace(path)
+		}
+	}
+
+	var errs []error
+	fileValues := map[string]string{}
+	if res.File != "" {
+		fileValues, err = readFile(res.File)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+	}
+	for _, key := range slices.Sorted(maps.Keys(fileValues)) {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("%s: unknown setting in %s", key, res.File))
+		}
+	}
+
+	for _, f := range fields {
+		raw, source := f.def, SourceDefault
+		if raw == "" {
+			raw = formatValue(f.value)
+		}
+		if raw == "" {
+			source = SourceNone
+		}
+		if val, ok := fileValues[f.key]; ok && val != "" {
+			raw, source = val, SourceFile
+		}
+		if f.env != "" {
+			// An empty env var counts as unset, as compose files often declare them blank.
+			if val, ok := lookupEnv(f.env); ok && strings.TrimSpace(val) != "" {
+				raw, source = strings.TrimSpace(val), SourceEnv
+			}
+		}
+		if val, ok := flags[f.flagName()]; ok {
+			raw, source = val, SourceFlag
+		}
+
+		reference := f.secret && opts.Secrets != nil && opts.Secrets.IsReference(raw)
+		res.Settings = append(res.Settings, Setting{
+			Key:       f.key,
+			Env:       f.env,
+			Flag:      "--" + f.flagName(),
+			Value:     raw,
+			Source:    source,
+			Secret:    f.secret,
+			Reference: reference,
+		})
+
+		if source == SourceNone {
+			if f.required {
+				errs = append(errs, fmt.Errorf("%s: %w", f.label(), errMissing))
+			}
+			continue
+		}
+		if reference {
+			ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+			raw, err = opts.Secrets.Resolve(ctx, raw)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.label(), err))
+				continue
+			}
+		}
+		if err := setValue(f.value, raw, f.unit); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value from %s: %w", f.label(), source, err))
+		}
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// collect walks the struct v and returns its leaf settings.
+func collect(v reflect.Value, prefix string) ([]field, error) {
+	var fields []field
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := sf.Tag.Get("key")
+		if key == "" {
+			key = snakeCase(sf.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && !isLeaf(fv) {
+			nested, err := collect(fv, key)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+		if !isLeaf(fv) {
+			return nil, fmt.Errorf("config: %s: unsupported type %s", key, fv.Type())
+		}
+		fields = append(fields, field{
+			value:    fv,
+			key:      key,
+			env:      sf.Tag.Get("env"),
+			def:      sf.Tag.Get("default"),
+			unit:     sf.Tag.Get("unit"),
+			required: sf.Tag.Get("required") == "true",
+			secret:   sf.Tag.Get("secret") == "true",
+		})
+	}
+	return fields, nil
+}
+
+// snakeCase converts a Go field name to snake_case, keeping initialisms together
+// (HTTPPort -> http_port).
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
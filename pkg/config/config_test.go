@@ -0,0 +1,240 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfig is a configuration exercising the tags and name derivation.
+type testConfig struct {
+	HTTPPort int           `env:"TEST_HTTP_PORT" default:"8080"`
+	APIKey   string        `env:"TEST_API_KEY" secret:"true"`
+	Timeout  time.Duration `env:"TEST_TIMEOUT" default:"5" unit:"s"`
+	Redis    struct {
+		Addr         string   `env:"TEST_REDIS_ADDR" required:"true"`
+		MaxConns2    int      `default:"10"`
+		AllowedHosts []string `key:"hosts"`
+	}
+}
+
+// writeFile writes content to a config file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// env returns a LookupEnv serving vars.
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := vars[key]
+		return val, ok
+	}
+}
+
+// setting returns the effective setting key of res.
+func setting(t *testing.T, res *config.Result, key string) config.Setting {
+	t.Helper()
+	for _, s := range res.Settings {
+		if s.Key == key {
+			return s
+		}
+	}
+	t.Fatalf("setting %s not found", key)
+	return config.Setting{}
+}
+
+// TestUnitLoad_Precedence tests that a flag overrides an env var, which overrides the
+// file, which overrides the default, and that blank values do not override anything.
+func TestUnitLoad_Precedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		env        map[string]string
+		args       []string
+		wantPort   int
+		wantSource config.Source
+	}{
+		{name: "default", wantPort: 8080, wantSource: config.SourceDefault},
+		{name: "file over default", file: "http_port: 8081", wantPort: 8081, wantSource: config.SourceFile},
+		{
+			name:       "env over file",
+			file:       "http_port: 8081",
+			env:        map[string]string{"TEST_HTTP_PORT": "8082"},
+			wantPort:   8082,
+			wantSource: config.SourceEnv,
+		},
+		{
+			name:       "flag over env",
+			file:       "http_port: 8081",
+			env:        map[string]string{"TEST_HTTP_PORT": "8082"},
+			args:       []string{"--http-port=8083"},
+			wantPort:   8083,
+			wantSource: config.SourceFlag,
+		},
+		{
+			name:       "blank env is unset",
+			file:       "http_port: 8081",
+			env:        map[string]string{"TEST_HTTP_PORT": " "},
+			wantPort:   8081,
+			wantSource: config.SourceFile,
+		},
+		{name: "blank file value is unset", file: "http_port:", wantPort: 8080, wantSource: config.SourceDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{"TEST_REDIS_ADDR": "localhost:6379"}
+			for k, v := range tt.env {
+				vars[k] = v
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeFile(t, "config.yaml", tt.file)}, args...)
+			}
+
+			var cfg testConfig
+			res, err := config.Load(&cfg, config.Options{Args: args, LookupEnv: env(vars)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPort, cfg.HTTPPort)
+			assert.Equal(t, tt.wantSource, setting(t, res, "http_port").Source)
+		})
+	}
+}
+
+// TestUnitLoad_FileFromEnv tests that the file named by the file env var is read, and
+// the --config flag takes precedence over it.
+func TestUnitLoad_FileFromEnv(t *testing.T) {
+	fromEnv := writeFile(t, "env.json", `{"http_port": 9001, "redis": {"addr": "redis:6379"}}`)
+	fromFlag := writeFile(t, "flag.yaml", "http_port: 9002\nredis:\n  addr: redis:6379\n")
+	lookupEnv := env(map[string]string{"TEST_CONFIG_FILE": fromEnv})
+
+	var cfg testConfig
+	res, err := config.Load(&cfg, config.Options{FileEnv: "TEST_CONFIG_FILE", LookupEnv: lookupEnv})
+	require.NoError(t, err)
+	assert.Equal(t, fromEnv, res.File)
+	assert.Equal(t, 9001, cfg.HTTPPort)
+
+	cfg = testConfig{}
+	res, err = config.Load(&cfg, config.Options{Args: []string{"--config", fromFlag}, FileEnv: "TEST_CONFIG_FILE", LookupEnv: lookupEnv})
+	require.NoError(t, err)
+	assert.Equal(t, fromFlag, res.File)
+	assert.Equal(t, 9002, cfg.HTTPPort)
+}
+
+// TestUnitLoad_UnknownFileKeys tests that every key of the file that is not a setting
+// is reported, while the known keys are still loaded.
+func TestUnitLoad_UnknownFileKeys(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+http_port: 8081
+htp_port: 8082
+redis:
+  addr: redis:6379
+  max_conns: 20
+`)
+
+	var cfg testConfig
+	res, err := config.Load(&cfg, config.Options{Args: []string{"--config", path}, LookupEnv: env(nil)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "htp_port: unknown setting in "+path)
+	assert.Contains(t, err.Error(), "redis.max_conns: unknown setting in "+path)
+	assert.NotContains(t, err.Error(), "redis.addr")
+	require.NotNil(t, res)
+	assert.Equal(t, 8081, cfg.HTTPPort)
+	assert.Equal(t, "redis:6379", cfg.Redis.Addr)
+}
+
+// TestUnitLoad_Names tests that file keys are the snake_cased field names joined by group,
+// the key tag overrides them, and flags are the keys with dashes.
+func TestUnitLoad_Names(t *testing.T) {
+	var cfg testConfig
+	res, err := config.Load(&cfg, config.Options{
+		Args: []string{
+			"--api-key=secret",
+			"--redis.addr=redis:6379",
+			"--redis.max-conns2=20",
+			"--redis.hosts=a, b,",
+		},
+		LookupEnv: env(nil),
+	})
+	require.NoError(t, err)
+
+	var keys, flags []string
+	for _, s := range res.Settings {
+		keys = append(keys, s.Key)
+		flags = append(flags, s.Flag)
+	}
+	assert.Equal(t, []string{"http_port", "api_key", "timeout", "redis.addr", "redis.max_conns2", "redis.hosts"}, keys)
+	assert.Equal(t, []string{"--http-port", "--api-key", "--timeout", "--redis.addr", "--redis.max-conns2", "--redis.hosts"}, flags)
+	assert.Equal(t, "TEST_REDIS_ADDR", setting(t, res, "redis.addr").Env)
+	assert.Equal(t, "secret", cfg.APIKey)
+	assert.Equal(t, 20, cfg.Redis.MaxConns2)
+	assert.Equal(t, []string{"a", "b"}, cfg.Redis.AllowedHosts)
+}
+
+// TestUnitLoad_Errors tests that every invalid or missing setting is reported at once,
+// named by its env var.
+func TestUnitLoad_Errors(t *testing.T) {
+	var cfg testConfig
+	_, err := config.Load(&cfg, config.Options{
+		Args:      []string{"--timeout=soon"},
+		LookupEnv: env(map[string]string{"TEST_HTTP_PORT": "http"}),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TEST_HTTP_PORT: invalid value from env")
+	assert.Contains(t, err.Error(), "TEST_TIMEOUT: invalid value from flag")
+	assert.Contains(t, err.Error(), "TEST_REDIS_ADDR: missing value")
+
+	_, err = config.Load(cfg, config.Options{})
+	assert.Error(t, err, "not a pointer")
+}
+
+// TestUnitLoad_Units tests that a bare integer duration is read in the unit of its setting,
+// and a Go duration as is.
+func TestUnitLoad_Units(t *testing.T) {
+	vars := map[string]string{"TEST_REDIS_ADDR": "redis:6379"}
+
+	var cfg testConfig
+	_, err := config.Load(&cfg, config.Options{LookupEnv: env(vars)})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+
+	vars["TEST_TIMEOUT"] = "1m30s"
+	_, err = config.Load(&cfg, config.Options{LookupEnv: env(vars)})
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, cfg.Timeout)
+}
+
+// TestUnitCheck tests that --check-config is reported, and Check prints the effective
+// settings with secrets redacted followed by the verdict.
+func TestUnitCheck(t *testing.T) {
+	var cfg testConfig
+	res, err := config.Load(&cfg, config.Options{
+		Args:      []string{"--check-config", "--api-key=secret"},
+		LookupEnv: env(map[string]string{"TEST_REDIS_ADDR": "redis:6379"}),
+	})
+	require.NoError(t, err)
+	assert.True(t, res.CheckOnly)
+	assert.Equal(t, "[REDACTED]", res.Redacted()["api_key"])
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, config.Check(&out, res, nil))
+	assert.Contains(t, out.String(), `http_port = "8080" # default (TEST_HTTP_PORT)`)
+	assert.Contains(t, out.String(), `api_key = "[REDACTED]" # flag (TEST_API_KEY)`)
+	assert.Contains(t, out.String(), `redis.addr = "redis:6379" # env (TEST_REDIS_ADDR)`)
+	assert.Contains(t, out.String(), `redis.hosts = "" # unset`)
+	assert.NotContains(t, out.String(), "secret")
+	assert.Contains(t, out.String(), "configuration is valid")
+
+	out.Reset()
+	assert.Equal(t, 1, config.Check(&out, res, assert.AnError))
+	assert.Contains(t, out.String(), "configuration is invalid")
+}
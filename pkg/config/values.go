@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// units are the units of the unit tag.
+var units = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// isLeaf reports whether v holds a single setting rather than a group.
+func isLeaf(v reflect.Value) bool {
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return v.Type().Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setValue parses raw into v.
+func setValue(v reflect.Value, raw, unit string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := parseDuration(raw, unit)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseDuration parses a Go duration ("1m30s"), or a bare integer in unit.
+func parseDuration(raw, unit string) (time.Duration, error) {
+	if unit != "" {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			scale, ok := units[unit]
+			if !ok {
+				return 0, fmt.Errorf("unknown unit %q", unit)
+			}
+			return time.Duration(n) * scale, nil
+		}
+	}
+	return time.ParseDuration(raw)
+}
+
+// formatValue formats a value already in the struct as a default. Zero values format as "".
+func formatValue(v reflect.Value) string {
+	if v.IsZero() {
+		return ""
+	}
+	if v.Type().Implements(textMarshalerType) {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// readFile reads a YAML or JSON file into values keyed by dotted path.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	values := make(map[string]string)
+	flatten("", doc, values)
+	return values, nil
+}
+
+// flatten stores the scalars of doc under their dotted path. Lists are joined with commas,
+// the format list settings are parsed from.
+func flatten(prefix string, doc map[string]any, out map[string]string) {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := doc[k].(type) {
+		case map[string]any:
+			flatten(key, val, out)
+		case []any:
+			items := make([]string, len(val))
+			for i, item := range val {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package cookie builds hardened session cookies from configuration.
+package cookie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookie name prefixes enforced by browsers, see RFC 6265bis section 4.1.3.
+const (
+	// PrefixHost cookies must be Secure, have no Domain and the "/" Path, so they are
+	// bound to the exact host that set them.
+	PrefixHost = "__Host-"
+	// PrefixSecure cookies must be Secure.
+	PrefixSecure = "__Secure-"
+)
+
+// SameSite is the SameSite attribute of a cookie.
+type SameSite http.SameSite
+
+const (
+	// SameSiteLax sends the cookie on same-site requests and top-level navigations.
+	SameSiteLax = SameSite(http.SameSiteLaxMode)
+	// SameSiteStrict sends the cookie on same-site requests only.
+	SameSiteStrict = SameSite(http.SameSiteStrictMode)
+	// SameSiteNone sends the cookie on all requests; it requires Secure.
+	SameSiteNone = SameSite(http.SameSiteNoneMode)
+)
+
+// UnmarshalText parses "lax", "strict" or "none", so SameSite can be loaded from config.
+func (s *SameSite) UnmarshalText(text []byte) error {
+	switch strings.ToLower(strings.TrimSpace(string(text))) {
+	case "lax":
+		*s = SameSiteLax
+	case "strict":
+		*s = SameSiteStrict
+	case "none":
+		*s = SameSiteNone
+	default:
+		return fmt.Errorf("must be %q, %q or %q", "lax", "strict", "none")
+	}
+	return nil
+}
+
+// MarshalText formats the SameSite attribute in the UnmarshalText format.
+func (s SameSite) MarshalText() ([]byte, error) {
+	switch s {
+	case SameSiteStrict:
+		return []byte("strict"), nil
+	case SameSiteNone:
+		return []byte("none"), nil
+	default:
+		return []byte("lax"), nil
+	}
+}
+
+// Config is the configuration of a cookie.
+type Config struct {
+	// Name is the cookie name; the __Host- and __Secure- prefixes are validated.
+	Name string
+	// Domain makes the cookie sent to subdomains too; empty binds it to the host.
+	Domain string
+	// Path restricts the cookie to the requests under it.
+	Path     string
+	SameSite SameSite
+	Secure   bool
+	// Partitioned stores the cookie per top-level site (CHIPS); it requires Secure.
+	Partitioned bool
+}
+
+// Builder builds the Set-Cookie values of a cookie. Cookies are always HttpOnly.
+type Builder struct {
+	cfg Config
+}
+
+// NewBuilder validates cfg and creates a Builder, so a misconfigured cookie fails at
+// startup instead of being silently dropped by browsers.
+func NewBuilder(cfg Config) (*Builder, error) {
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = SameSiteLax
+	}
+
+	var errs []error
+	if err := (&http.Cookie{Name: cfg.Name}).Valid(); err != nil {
+		errs = append(errs, err)
+	}
+	if !strings.HasPrefix(cfg.Path, "/") {
+		errs = append(errs, fmt.Errorf("path %q must start with /", cfg.Path))
+	}
+	if err := (&http.Cookie{Name: "n", Domain: cfg.Domain}).Valid(); cfg.Domain != "" && err != nil {
+		errs = append(errs, err)
+	}
+	if !cfg.Secure {
+		switch {
+		case strings.HasPrefix(cfg.Name, PrefixHost), strings.HasPrefix(cfg.Name, PrefixSecure):
+			errs = append(errs, fmt.Errorf("cookie %q must be Secure", cfg.Name))
+		case cfg.SameSite == SameSiteNone:
+			errs = append(errs, errors.New("SameSite=None requires Secure"))
+		case cfg.Partitioned:
+			errs = append(errs, errors.New("Partitioned requires Secure"))
+		}
+	}
+	if strings.HasPrefix(cfg.Name, PrefixHost) && (cfg.Domain != "" || cfg.Path != "/") {
+		errs = append(errs, fmt.Errorf("cookie %q must have no domain and the / path", cfg.Name))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &Builder{cfg: cfg}, nil
+}
+
+// Name returns the cookie name.
+func (b *Builder) Name() string {
+	return b.cfg.Name
+}
+
+// Cookie returns the cookie holding value for maxAge.
+func (b *Builder) Cookie(value string, maxAge time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:        b.cfg.Name,
+		Value:       value,
+		Domain:      b.cfg.Domain,
+		Path:        b.cfg.Path,
+		MaxAge:      int(maxAge.Seconds()),
+		Secure:      b.cfg.Secure,
+		HttpOnly:    true,
+		SameSite:    http.SameSite(b.cfg.SameSite),
+		Partitioned: b.cfg.Partitioned,
+	}
+}
+
+// Expired returns the cookie deleting the cookie from the browser.
+func (b *Builder) Expired() *http.Cookie {
+	c := b.Cookie("", 0)
+	c.MaxAge = -1
+	return c
+}
+
+// PathMatch reports whether browsers send a cookie with path cookiePath along with
+// requests for requestPath, following the path-match rules of RFC 6265, section 5.1.4.
+func PathMatch(cookiePath, requestPath string) bool {
+	if cookiePath == requestPath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// Value returns the value of the cookie sent with r.
+func (b *Builder) Value(r *http.Request) (string, bool) {
+	c, err := r.Cookie(b.cfg.Name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
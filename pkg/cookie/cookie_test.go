@@ -0,0 +1,73 @@
+package cookie_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/cookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnitNewBuilder_Validates tests that cookies browsers would reject fail at startup.
+func TestUnitNewBuilder_Validates(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  cookie.Config
+		ok   bool
+	}{
+		{name: "host prefix", cfg: cookie.Config{Name: "__Host-rt", Secure: true}, ok: true},
+		{name: "shared with subdomains", cfg: cookie.Config{Name: "rt", Domain: "example.com", Path: "/v1"}, ok: true},
+		{name: "host prefix with domain", cfg: cookie.Config{Name: "__Host-rt", Domain: "example.com", Secure: true}},
+		{name: "host prefix with path", cfg: cookie.Config{Name: "__Host-rt", Path: "/v1", Secure: true}},
+		{name: "secure prefix not secure", cfg: cookie.Config{Name: "__Secure-rt"}},
+		{name: "same site none not secure", cfg: cookie.Config{Name: "rt", SameSite: cookie.SameSiteNone}},
+		{name: "partitioned not secure", cfg: cookie.Config{Name: "rt", Partitioned: true}},
+		{name: "invalid name", cfg: cookie.Config{Name: "r t"}},
+		{name: "relative path", cfg: cookie.Config{Name: "rt", Path: "v1"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cookie.NewBuilder(tt.cfg)
+			assert.Equal(t, tt.ok, err == nil, err)
+		})
+	}
+}
+
+// TestUnitBuilder_Cookie tests the Set-Cookie values of a configured cookie.
+func TestUnitBuilder_Cookie(t *testing.T) {
+	var sameSite cookie.SameSite
+	require.NoError(t, sameSite.UnmarshalText([]byte("None")))
+	builder, err := cookie.NewBuilder(cookie.Config{
+		Name:        "refresh_token",
+		Domain:      "example.com",
+		Path:        "/v1/refresh",
+		SameSite:    sameSite,
+		Secure:      true,
+		Partitioned: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "refresh_token=abc; Path=/v1/refresh; Domain=example.com; Max-Age=3600; HttpOnly; Secure; SameSite=None; Partitioned",
+		builder.Cookie("abc", time.Hour).String())
+	assert.Equal(t, "refresh_token=; Path=/v1/refresh; Domain=example.com; Max-Age=0; HttpOnly; Secure; SameSite=None; Partitioned",
+		builder.Expired().String())
+}
+
+// TestUnitPathMatch tests which request paths a cookie path is sent with.
+func TestUnitPathMatch(t *testing.T) {
+	for _, tt := range []struct {
+		cookiePath  string
+		requestPath string
+		want        bool
+	}{
+		{cookiePath: "/", requestPath: "/v1/refresh", want: true},
+		{cookiePath: "/v1", requestPath: "/v1/logout", want: true},
+		{cookiePath: "/v1/", requestPath: "/v1/logout", want: true},
+		{cookiePath: "/v1/refresh", requestPath: "/v1/refresh", want: true},
+		{cookiePath: "/v1/refresh", requestPath: "/v1/logout"},
+		{cookiePath: "/v1", requestPath: "/v10/logout"},
+		{cookiePath: "/v1/logout", requestPath: "/v1"},
+	} {
+		assert.Equal(t, tt.want, cookie.PathMatch(tt.cookiePath, tt.requestPath), "%s for %s", tt.cookiePath, tt.requestPath)
+	}
+}
@@ -0,0 +1,192 @@
+// Package cors answers CORS preflight requests and sets the CORS headers of responses.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS request and response headers.
+const (
+	HeaderOrigin           = "Origin"
+	HeaderRequestMethod    = "Access-Control-Request-Method"
+	HeaderRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderMaxAge           = "Access-Control-Max-Age"
+)
+
+// anyOrigin is the allowed origin matching every origin.
+const anyOrigin = "*"
+
+// preflightVary lists the request headers preflight responses depend on.
+const preflightVary = HeaderOrigin + ", " + HeaderRequestMethod + ", " + HeaderRequestHeaders
+
+// Config is the configuration of the CORS middleware.
+type Config struct {
+	// AllowedOrigins are exact origins such as "https://app.example.com", subdomain
+	// patterns such as "https://*.example.com", or "*" for any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed in cross-origin requests; GET, HEAD and
+	// POST when empty.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in cross-origin requests.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers readable by cross-origin scripts.
+	ExposedHeaders []string
+	// AllowCredentials lets cross-origin requests send cookies; it cannot be combined
+	// with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers cache preflight responses; zero leaves it to them.
+	MaxAge time.Duration
+}
+
+// originPattern matches an allowed origin.
+type originPattern struct {
+	scheme string
+	// host is the exact host, or the parent domain of a subdomain pattern.
+	host      string
+	subdomain bool
+}
+
+// Middleware returns the CORS middleware for cfg, or an error when an allowed origin
+// is not valid. Preflight requests are answered without calling the next handler, so
+// it must run before the handlers rejecting OPTIONS, such as the OpenAPI validator.
+// Requests from origins not allowed get no CORS headers, so browsers block them.
+func Middleware(cfg Config) (func(next http.Handler) http.Handler, error) {
+	allowAny := slices.Contains(cfg.AllowedOrigins, anyOrigin)
+	if allowAny && cfg.AllowCredentials {
+		return nil, errors.New("the * origin cannot allow credentials")
+	}
+	patterns, err := parseOrigins(cfg.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	var maxAge string
+	if seconds := int(cfg.MaxAge.Seconds()); seconds > 0 {
+		maxAge = strconv.Itoa(seconds)
+	}
+
+	allowedOrigin := func(origin string) bool {
+		return allowAny || matchOrigin(patterns, origin)
+	}
+	allowedMethod := func(method string) bool {
+		return slices.ContainsFunc(methods, func(m string) bool { return strings.EqualFold(m, method) })
+	}
+	allowedHeaders := func(requested string) bool {
+		for _, header := range strings.Split(requested, ",") {
+			header = strings.TrimSpace(header)
+			if header != "" && !slices.ContainsFunc(cfg.AllowedHeaders, func(h string) bool { return strings.EqualFold(h, header) }) {
+				return false
+			}
+		}
+		return true
+	}
+	setOrigin := func(h http.Header, origin string) {
+		if allowAny {
+			h.Set(HeaderAllowOrigin, anyOrigin)
+		} else {
+			h.Set(HeaderAllowOrigin, origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set(HeaderAllowCredentials, "true")
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get(HeaderOrigin)
+			h := w.Header()
+
+			if r.Method == http.MethodOptions && r.Header.Get(HeaderRequestMethod) != "" {
+				h.Add("Vary", preflightVary)
+				if allowedOrigin(origin) && allowedMethod(r.Header.Get(HeaderRequestMethod)) &&
+					allowedHeaders(r.Header.Get(HeaderRequestHeaders)) {
+					setOrigin(h, origin)
+					h.Set(HeaderAllowMethods, allowMethods)
+					if allowHeaders != "" {
+						h.Set(HeaderAllowHeaders, allowHeaders)
+					}
+					if maxAge != "" {
+						h.Set(HeaderMaxAge, maxAge)
+					}
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if !allowAny {
+				h.Add("Vary", HeaderOrigin)
+			}
+			if origin != "" && allowedOrigin(origin) {
+				setOrigin(h, origin)
+				if exposeHeaders != "" {
+					h.Set(HeaderExposeHeaders, exposeHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// parseOrigins parses the allowed origins other than "*".
+func parseOrigins(origins []string) ([]originPattern, error) {
+	var patterns []originPattern
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" || origin == anyOrigin {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid CORS origin %q: must be scheme://host[:port]", origin)
+		}
+		pattern := originPattern{scheme: strings.ToLower(u.Scheme), host: strings.ToLower(u.Host)}
+		if parent, ok := strings.CutPrefix(pattern.host, "*."); ok {
+			if parent == "" || strings.Contains(parent, "*") {
+				return nil, fmt.Errorf("invalid CORS origin %q: only a leading *. is allowed", origin)
+			}
+			pattern.host, pattern.subdomain = parent, true
+		} else if strings.Contains(pattern.host, "*") {
+			return nil, fmt.Errorf("invalid CORS origin %q: only a leading *. is allowed", origin)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchOrigin reports whether origin matches one of patterns. A subdomain pattern
+// matches subdomains at any depth but not the parent domain itself.
+func matchOrigin(patterns []originPattern, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	for _, p := range patterns {
+		if p.scheme != scheme {
+			continue
+		}
+		if p.subdomain && strings.HasSuffix(host, "."+p.host) || !p.subdomain && host == p.host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/cors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHandler(t *testing.T, cfg cors.Config) http.Handler {
+	t.Helper()
+	middleware, err := cors.Middleware(cfg)
+	require.NoError(t, err)
+	return middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusMethodNotAllowed) // like the OpenAPI validator
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// TestUnitMiddleware_Preflight tests that preflight requests are answered before the
+// next handler, with the CORS headers only for allowed origins, methods and headers.
+func TestUnitMiddleware_Preflight(t *testing.T) {
+	handler := newHandler(t, cors.Config{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	for _, tt := range []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{name: "exact origin", origin: "https://app.example.com", method: "POST", headers: "content-type, authorization", allowed: true},
+		{name: "subdomain", origin: "https://a.b.example.org", method: "GET", allowed: true},
+		{name: "parent of subdomain pattern", origin: "https://example.org", method: "GET"},
+		{name: "other scheme", origin: "http://app.example.com", method: "POST"},
+		{name: "suffix lookalike", origin: "https://evilexample.org", method: "GET"},
+		{name: "method not allowed", origin: "https://app.example.com", method: "DELETE"},
+		{name: "header not allowed", origin: "https://app.example.com", method: "POST", headers: "X-Debug"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/v1/login", nil)
+			req.Header.Set(cors.HeaderOrigin, tt.origin)
+			req.Header.Set(cors.HeaderRequestMethod, tt.method)
+			if tt.headers != "" {
+				req.Header.Set(cors.HeaderRequestHeaders, tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			h := rec.Header()
+			if !tt.allowed {
+				assert.Empty(t, h.Get(cors.HeaderAllowOrigin))
+				return
+			}
+			assert.Equal(t, tt.origin, h.Get(cors.HeaderAllowOrigin))
+			assert.Equal(t, "true", h.Get(cors.HeaderAllowCredentials))
+			assert.Equal(t, "GET, POST", h.Get(cors.HeaderAllowMethods))
+			assert.Equal(t, "Content-Type, Authorization", h.Get(cors.HeaderAllowHeaders))
+			assert.Equal(t, "600", h.Get(cors.HeaderMaxAge))
+		})
+	}
+}
+
+// TestUnitMiddleware_Request tests the CORS headers of actual requests.
+func TestUnitMiddleware_Request(t *testing.T) {
+	handler := newHandler(t, cors.Config{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		ExposedHeaders:   []string{"versionId", "X-Request-ID"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	req.Header.Set(cors.HeaderOrigin, "https://app.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get(cors.HeaderAllowOrigin))
+	assert.Equal(t, "true", rec.Header().Get(cors.HeaderAllowCredentials))
+	assert.Equal(t, "versionId, X-Request-ID", rec.Header().Get(cors.HeaderExposeHeaders))
+	assert.Equal(t, cors.HeaderOrigin, rec.Header().Get("Vary"))
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	req.Header.Set(cors.HeaderOrigin, "https://evil.example")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get(cors.HeaderAllowOrigin))
+}
+
+// TestUnitMiddleware_InvalidConfig tests that unsafe or malformed origins are rejected.
+func TestUnitMiddleware_InvalidConfig(t *testing.T) {
+	for _, cfg := range []cors.Config{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"app.example.com"}},
+		{AllowedOrigins: []string{"https://app.*.example.com"}},
+		{AllowedOrigins: []string{"https://example.com/path"}},
+	} {
+		_, err := cors.Middleware(cfg)
+		assert.Error(t, err, cfg.AllowedOrigins)
+	}
+}
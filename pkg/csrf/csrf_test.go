@@ -0,0 +1,53 @@
+package csrf_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/csrf"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUnitMiddleware tests that only cross-origin unsafe requests carrying the session
+// cookie are rejected.
+func TestUnitMiddleware(t *testing.T) {
+	handler := csrf.Middleware(csrf.Config{
+		Cookies:        []string{"refresh_token"},
+		TrustedOrigins: []string{"https://app.example.com/"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tt := range []struct {
+		name    string
+		method  string
+		cookie  bool
+		headers map[string]string
+		want    int
+	}{
+		{name: "cross-site", method: http.MethodPost, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, want: http.StatusForbidden},
+		{name: "same-site sibling", method: http.MethodPost, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "https://other.example.com"}, want: http.StatusForbidden},
+		{name: "cross-site without cookie", method: http.MethodPost, headers: map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, want: http.StatusNoContent},
+		{name: "cross-site safe method", method: http.MethodGet, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusNoContent},
+		{name: "same-origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "same-origin"}, want: http.StatusNoContent},
+		{name: "trusted origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "https://app.example.com"}, want: http.StatusNoContent},
+		{name: "origin of the API", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "https://api.example.com"}, want: http.StatusNoContent},
+		{name: "untrusted origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "null"}, want: http.StatusForbidden},
+		{name: "fetch metadata without origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+		{name: "not a browser", method: http.MethodPost, cookie: true, want: http.StatusNoContent},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://api.example.com/v1/refresh", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "rt"})
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package grpcchain builds the interceptor chains of gRPC servers and clients. Every
+// interceptor is registered as a unary and stream pair, so that streaming calls get the
+// same logging, recovery and authorization as unary ones.
+package grpcchain
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// ServerInterceptor is a pair of unary and stream server interceptors doing the same thing.
+type ServerInterceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Server is the interceptor chain and stats handlers of a gRPC server. Interceptors run
+// in the order they are added. The zero value is an empty chain.
+type Server struct {
+	interceptors []ServerInterceptor
+	stats        []stats.Handler
+}
+
+// Use appends interceptors to the chain. It panics if either half of a pair is missing,
+// which would leave unary or streaming calls unprotected.
+func (s *Server) Use(interceptors ...ServerInterceptor) *Server {
+	for _, i := range interceptors {
+		if i.Unary == nil || i.Stream == nil {
+			panic(fmt.Sprintf("grpcchain: server interceptor %d lacks a unary or stream half", len(s.interceptors)))
+		}
+		s.interceptors = append(s.interceptors, i)
+	}
+	return s
+}
+
+// StatsHandler adds a stats handler, such as the otelgrpc one recording spans and metrics.
+func (s *Server) StatsHandler(h stats.Handler) *Server {
+	s.stats = append(s.stats, h)
+	return s
+}
+
+// Unary returns the unary interceptors of the chain.
+func (s *Server) Unary() []grpc.UnaryServerInterceptor {
+	unary := make([]grpc.UnaryServerInterceptor, len(s.interceptors))
+	for i, ic := range s.interceptors {
+		unary[i] = ic.Unary
+	}
+	return unary
+}
+
+// Stream returns the stream interceptors of the chain.
+func (s *Server) Stream() []grpc.StreamServerInterceptor {
+	stream := make([]grpc.StreamServerInterceptor, len(s.interceptors))
+	for i, ic := range s.interceptors {
+		stream[i] = ic.Stream
+	}
+	return stream
+}
+
+// Options returns the server options registering the chain.
+func (s *Server) Options() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.Unary()...),
+		grpc.ChainStreamInterceptor(s.Stream()...),
+	}
+	for _, h := range s.stats {
+		opts = append(opts, grpc.StatsHandler(h))
+	}
+	return opts
+}
+
+// ClientInterceptor is a pair of unary and stream client interceptors doing the same thing.
+type ClientInterceptor struct {
+	Unary  grpc.UnaryClientInterceptor
+	Stream grpc.StreamClientInterceptor
+}
+
+// Client is the interceptor chain and stats handlers of a gRPC client. Interceptors run
+// in the order they are added. The zero value is an empty chain.
+type Client struct {
+	interceptors []ClientInterceptor
+	stats        []stats.Handler
+}
+
+// Use appends interceptors to the chain. It panics if either half of a pair is missing.
+func (c *Client) Use(interceptors ...ClientInterceptor) *Client {
+	for _, i := range interceptors {
+		if i.Unary == nil || i.Stream == nil {
+			panic(fmt.Sprintf("grpcchain: client interceptor %d lacks a unary or stream half", len(c.interceptors)))
+		}
+		c.interceptors = append(c.interceptors, i)
+	}
+	return c
+}
+
+// StatsHandler adds a stats handler, such as the otelgrpc one recording spans and metrics.
+func (c *Client) StatsHandler(h stats.Handler) *Client {
+	c.stats = append(c.stats, h)
+	return c
+}
+
+// DialOptions returns the dial options registering the chain.
+func (c *Client) DialOptions() []grpc.DialOption {
+	unary := make([]grpc.UnaryClientInterceptor, len(c.interceptors))
+	stream := make([]grpc.StreamClientInterceptor, len(c.interceptors))
+	for i, ic := range c.interceptors {
+		unary[i], stream[i] = ic.Unary, ic.Stream
+	}
+	opts := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}
+	for _, h := range c.stats {
+		opts = append(opts, grpc.WithStatsHandler(h))
+	}
+	return opts
+}
+
+// ServerStreamWithContext returns ss with its context replaced by ctx, for stream
+// interceptors that put values into the context.
+func ServerStreamWithContext(ctx context.Context, ss grpc.ServerStream) grpc.ServerStream {
+	return &contextStream{ServerStream: ss, ctx: ctx}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
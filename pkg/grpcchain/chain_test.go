@@ -0,0 +1,159 @@
+package grpcchain_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type ctxKey struct{}
+
+// calls records the interceptor calls in order.
+type calls struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *calls) add(call string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+func (c *calls) list() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// serverInterceptor records its calls in c as name. Its stream half puts name into the
+// stream context and records the value it found there.
+func serverInterceptor(c *calls, name string) grpcchain.ServerInterceptor {
+	return grpcchain.ServerInterceptor{
+		Unary: func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			c.add("server unary " + name)
+			return handler(ctx, req)
+		},
+		Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			prev, _ := ss.Context().Value(ctxKey{}).(string)
+			c.add("server stream " + name + " after " + prev)
+			ctx := context.WithValue(ss.Context(), ctxKey{}, name)
+			return handler(srv, grpcchain.ServerStreamWithContext(ctx, ss))
+		},
+	}
+}
+
+func clientInterceptor(c *calls, name string) grpcchain.ClientInterceptor {
+	return grpcchain.ClientInterceptor{
+		Unary: func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			c.add("client unary " + name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		},
+		Stream: func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			c.add("client stream " + name)
+			return streamer(ctx, desc, cc, method, opts...)
+		},
+	}
+}
+
+// TestUnitChain_Order tests that unary and streaming calls run the interceptors of both
+// chains in the order they were added, and a stream interceptor can replace the
+// stream context for the next ones.
+func TestUnitChain_Order(t *testing.T) {
+	c := &calls{}
+	server := new(grpcchain.Server).Use(serverInterceptor(c, "a"), serverInterceptor(c, "b"))
+	client := new(grpcchain.Client).Use(clientInterceptor(c, "a"), clientInterceptor(c, "b"))
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(server.Options()...)
+	healthpb.RegisterHealthServer(srv, grpchealth.NewServer())
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append(client.DialOptions(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	health := healthpb.NewHealthClient(conn)
+
+	_, err = health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"client unary a", "client unary b",
+		"server unary a", "server unary b",
+	}, c.list())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := health.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"client stream a", "client stream b",
+		"server stream a after ", "server stream b after a",
+	}, c.list()[4:])
+}
+
+// TestUnitChain_MissingHalf tests that a pair lacking its unary or stream half is
+// rejected when added.
+func TestUnitChain_MissingHalf(t *testing.T) {
+	c := &calls{}
+	full := serverInterceptor(c, "full")
+
+	assert.PanicsWithValue(t, "grpcchain: server interceptor 1 lacks a unary or stream half", func() {
+		new(grpcchain.Server).Use(full, grpcchain.ServerInterceptor{Unary: full.Unary})
+	})
+	assert.Panics(t, func() {
+		new(grpcchain.Server).Use(grpcchain.ServerInterceptor{Stream: full.Stream})
+	})
+
+	client := clientInterceptor(c, "full")
+	assert.PanicsWithValue(t, "grpcchain: client interceptor 0 lacks a unary or stream half", func() {
+		new(grpcchain.Client).Use(grpcchain.ClientInterceptor{Unary: client.Unary})
+	})
+	assert.Panics(t, func() {
+		new(grpcchain.Client).Use(grpcchain.ClientInterceptor{Stream: client.Stream})
+	})
+	assert.Empty(t, c.list())
+}
+
+// TestUnitServerStreamWithContext tests that the wrapped stream returns the new context
+// and delegates everything else to the original stream.
+func TestUnitServerStreamWithContext(t *testing.T) {
+	ss := &fakeServerStream{ctx: context.Background()}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "caller")
+
+	wrapped := grpcchain.ServerStreamWithContext(ctx, ss)
+
+	assert.Equal(t, "caller", wrapped.Context().Value(ctxKey{}))
+	assert.Nil(t, ss.Context().Value(ctxKey{}), "original stream unchanged")
+	require.NoError(t, wrapped.SendMsg("msg"))
+	assert.Equal(t, []any{"msg"}, ss.sent)
+}
+
+// fakeServerStream is a grpc.ServerStream recording the messages it sends.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []any
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func (s *fakeServerStream) SendMsg(m any) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisChecker pings rdb.
+func RedisChecker(rdb redis.UniversalClient) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+}
+
+// pinger is implemented by *sql.DB.
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// SQLChecker pings db, e.g. a *sql.DB.
+func SQLChecker(db pinger) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// HTTPChecker expects a 2xx response to a GET of url, e.g. the /readyz of another service.
+func HTTPChecker(client *http.Client, url string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
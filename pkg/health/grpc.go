@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// BindGRPC mirrors readiness onto srv for the overall server ("") and the given services.
+// srv reports NOT_SERVING until the monitor is first ready.
+func (m *Monitor) BindGRPC(srv *grpchealth.Server, services ...string) {
+	services = append([]string{""}, services...)
+	set := func(ready bool) {
+		status := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		if ready {
+			status = grpc_health_v1.HealthCheckResponse_SERVING
+		}
+		for _, svc := range services {
+			srv.SetServingStatus(svc, status)
+		}
+	}
+	set(m.Ready())
+	m.OnChange(set)
+}
+
+// GRPCChecker checks service through the gRPC health service of conn. An empty service
+// checks the server as a whole.
+func GRPCChecker(conn grpc.ClientConnInterface, service string) Checker {
+	client := grpc_health_v1.NewHealthClient(conn)
+	return CheckerFunc(func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+			return fmt.Errorf("status %s", resp.GetStatus())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,261 @@
+// Package health runs dependency checks periodically and reports liveness and readiness
+// over HTTP (/livez, /readyz) and the gRPC health service.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 2 * time.Second
+)
+
+// lifecycleCheck is the name the lifecycle gate is reported under.
+const lifecycleCheck = "lifecycle"
+
+// Status is the status of a check or of the service.
+type Status string
+
+const (
+	// StatusUp is reported for a passing check or a ready service.
+	StatusUp Status = "up"
+	// StatusDown is reported for a failing or not yet run check, or a service that is not ready.
+	StatusDown Status = "down"
+)
+
+// Checker checks a dependency. A nil error means the dependency is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Check is a registered dependency check.
+type Check struct {
+	Name    string
+	Checker Checker
+	// Optional checks are reported but do not make the service unready, e.g. a downstream
+	// service whose outage is already handled by degrading.
+	Optional bool
+}
+
+// CheckResult is the latest result of a check.
+type CheckResult struct {
+	Status     Status    `json:"status"`
+	Optional   bool      `json:"optional,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at,omitzero"`
+}
+
+// Report is the health report of the service.
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Config is the configuration for the Monitor.
+type Config struct {
+	// Interval is the time between two rounds of checks.
+	Interval time.Duration
+	// Timeout bounds a single check.
+	Timeout time.Duration
+}
+
+// Monitor runs the registered checks and keeps their latest results.
+// The service is ready when it accepts traffic and every required check passes.
+type Monitor struct {
+	cfg    Config
+	logger *zap.Logger
+
+	// notifyMu serializes readiness updates, so listeners see changes in order.
+	notifyMu sync.Mutex
+
+	mu        sync.RWMutex
+	checks    []Check
+	results   map[string]CheckResult
+	accepting bool
+	ready     bool
+	listeners []func(ready bool)
+}
+
+// New creates a new Monitor.
+func New(cfg Config, logger *zap.Logger) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Monitor{
+		cfg:     cfg,
+		logger:  logger,
+		results: make(map[string]CheckResult),
+	}
+}
+
+// Register adds checks. Register them before Run.
+func (m *Monitor) Register(checks ...Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range checks {
+		m.checks = append(m.checks, c)
+		m.results[c.Name] = CheckResult{Status: StatusDown, Optional: c.Optional, Error: "not checked yet"}
+	}
+}
+
+// SetAccepting sets whether the service accepts traffic at all. It is false while the
+// service starts and drains, whatever the checks report.
+func (m *Monitor) SetAccepting(accepting bool) {
+	m.mu.Lock()
+	m.accepting = accepting
+	m.mu.Unlock()
+	m.updateReady()
+}
+
+// OnChange registers fn to be called whenever readiness changes.
+func (m *Monitor) OnChange(fn func(ready bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Ready reports whether the service is ready to receive traffic.
+func (m *Monitor) Ready() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ready
+}
+
+// Report returns the latest results of the checks.
+func (m *Monitor) Report() Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	report := Report{
+		Status: StatusDown,
+		Checks: make(map[string]CheckResult, len(m.results)+1),
+	}
+	if m.ready {
+		report.Status = StatusUp
+	}
+	for name, res := range m.results {
+		report.Checks[name] = res
+	}
+	lifecycle := CheckResult{Status: StatusUp}
+	if !m.accepting {
+		lifecycle = CheckResult{Status: StatusDown, Error: "starting or draining"}
+	}
+	report.Checks[lifecycleCheck] = lifecycle
+	return report
+}
+
+// Run runs the checks immediately and then every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll runs every check concurrently, each bounded by the timeout, and records the results.
+func (m *Monitor) CheckAll(ctx context.Context) {
+	m.mu.RLock()
+	checks := append([]Check(nil), m.checks...)
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := m.runCheck(ctx, c)
+			if ctx.Err() != nil {
+				return // shutting down; keep the last real result
+			}
+			m.record(c, res)
+		}()
+	}
+	wg.Wait()
+	m.updateReady()
+}
+
+func (m *Monitor) runCheck(ctx context.Context, c Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Checker.Check(ctx)
+	res := CheckResult{
+		Status:     StatusUp,
+		Optional:   c.Optional,
+		DurationMS: time.Since(start).Milliseconds(),
+		CheckedAt:  start.UTC(),
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (m *Monitor) record(c Check, res CheckResult) {
+	m.mu.Lock()
+	prev := m.results[c.Name]
+	m.results[c.Name] = res
+	m.mu.Unlock()
+
+	if prev.Status == res.Status && prev.Error == res.Error {
+		return
+	}
+	if res.Status == StatusDown {
+		m.logger.Warn("Health check failing", zap.String("check", c.Name), zap.Bool("optional", c.Optional), zap.String("error", res.Error))
+		return
+	}
+	m.logger.Info("Health check passing", zap.String("check", c.Name))
+}
+
+// updateReady recomputes readiness and notifies the listeners when it changed.
+func (m *Monitor) updateReady() {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+
+	m.mu.Lock()
+	ready := m.accepting
+	for _, c := range m.checks {
+		if !c.Optional && m.results[c.Name].Status != StatusUp {
+			ready = false
+			break
+		}
+	}
+	changed := ready != m.ready
+	m.ready = ready
+	listeners := append([]func(bool){}, m.listeners...)
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	m.logger.Info("Readiness changed", zap.Bool("ready", ready))
+	for _, fn := range listeners {
+		fn(ready)
+	}
+}
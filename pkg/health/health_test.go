@@ -0,0 +1,146 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// switchChecker fails with its error while it is set.
+type switchChecker struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (c *switchChecker) Check(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *switchChecker) set(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// newMonitor returns an accepting monitor with a required "db" and an optional "user"
+// check, and the readiness changes it notified.
+func newMonitor(t *testing.T, db, user *switchChecker) (*health.Monitor, *[]bool) {
+	t.Helper()
+	m := health.New(health.Config{}, zap.NewNop())
+	m.Register(
+		health.Check{Name: "db", Checker: db},
+		health.Check{Name: "user", Checker: user, Optional: true},
+	)
+	var changes []bool
+	m.OnChange(func(ready bool) { changes = append(changes, ready) })
+	m.SetAccepting(true)
+	return m, &changes
+}
+
+// TestUnitMonitor_Readiness tests that readiness follows the required checks only.
+func TestUnitMonitor_Readiness(t *testing.T) {
+	db, user := &switchChecker{}, &switchChecker{}
+	m, changes := newMonitor(t, db, user)
+	ctx := context.Background()
+	assert.False(t, m.Ready(), "not ready before the first checks")
+
+	m.CheckAll(ctx)
+	assert.True(t, m.Ready())
+
+	user.set(errors.New("user service unavailable"))
+	m.CheckAll(ctx)
+	assert.True(t, m.Ready(), "optional check failing")
+	assert.Equal(t, health.StatusDown, m.Report().Checks["user"].Status)
+
+	db.set(errors.New("connection refused"))
+	m.CheckAll(ctx)
+	assert.False(t, m.Ready(), "required check failing")
+
+	db.set(nil)
+	m.CheckAll(ctx)
+	assert.True(t, m.Ready())
+	assert.Equal(t, []bool{true, false, true}, *changes)
+}
+
+// TestUnitMonitor_SetAccepting tests that a draining service is not ready whatever its
+// checks report.
+func TestUnitMonitor_SetAccepting(t *testing.T) {
+	m, changes := newMonitor(t, &switchChecker{}, &switchChecker{})
+	m.CheckAll(context.Background())
+	require.True(t, m.Ready())
+
+	m.SetAccepting(false)
+	assert.False(t, m.Ready())
+	assert.Equal(t, health.StatusDown, m.Report().Checks["lifecycle"].Status)
+	m.CheckAll(context.Background())
+	assert.False(t, m.Ready(), "passing checks while draining")
+	assert.Equal(t, []bool{true, false}, *changes)
+}
+
+// TestUnitMonitor_BindGRPC tests that readiness is mirrored to the gRPC health service
+// of the server and of the bound services.
+func TestUnitMonitor_BindGRPC(t *testing.T) {
+	db := &switchChecker{}
+	m, _ := newMonitor(t, db, &switchChecker{})
+	srv := grpchealth.NewServer()
+	m.BindGRPC(srv, "user.v1.UserServiceInternal")
+
+	statusOf := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := srv.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.GetStatus()
+	}
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, statusOf(""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, statusOf("user.v1.UserServiceInternal"))
+
+	m.CheckAll(context.Background())
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, statusOf(""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, statusOf("user.v1.UserServiceInternal"))
+
+	db.set(errors.New("connection refused"))
+	m.CheckAll(context.Background())
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, statusOf(""))
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, statusOf("user.v1.UserServiceInternal"))
+}
+
+// TestUnitReadyzHandler tests that /readyz reports the check statuses without their errors.
+func TestUnitReadyzHandler(t *testing.T) {
+	db := &switchChecker{err: errors.New("dial tcp 10.0.3.7:3306: access denied for user 'app'")}
+	m, _ := newMonitor(t, db, &switchChecker{})
+	m.CheckAll(context.Background())
+
+	rr := httptest.NewRecorder()
+	m.ReadyzHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "10.0.3.7")
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"status": "down",
+		"checks": map[string]any{
+			"db":        map[string]any{"status": "down"},
+			"user":      map[string]any{"status": "up", "optional": true},
+			"lifecycle": map[string]any{"status": "up"},
+		},
+	}, body)
+
+	db.set(nil)
+	m.CheckAll(context.Background())
+	rr = httptest.NewRecorder()
+	m.ReadyzHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
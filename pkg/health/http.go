@@ -0,0 +1,50 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// LivezHandler reports that the process is alive. It never checks dependencies, so an
+// outage of a dependency does not get the service restarted.
+func (m *Monitor) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]Status{"status": StatusUp})
+	})
+}
+
+// readyzBody is the body of /readyz. Check errors may reveal internal addresses or
+// credentials, so they are logged by the Monitor and not returned.
+type readyzBody struct {
+	Status Status                 `json:"status"`
+	Checks map[string]readyzCheck `json:"checks"`
+}
+
+type readyzCheck struct {
+	Status   Status `json:"status"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// ReadyzHandler reports the status of the service and of each check, with 503 Service
+// Unavailable while the service is not ready.
+func (m *Monitor) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		report := m.Report()
+		body := readyzBody{Status: report.Status, Checks: make(map[string]readyzCheck, len(report.Checks))}
+		for name, res := range report.Checks {
+			body.Checks[name] = readyzCheck{Status: res.Status, Optional: res.Optional}
+		}
+		code := http.StatusOK
+		if report.Status != StatusUp {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, body)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
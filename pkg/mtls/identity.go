@@ -0,0 +1,68 @@
+// Package mtls defines the peer identity taken from a verified client certificate.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// Identity is the identity of a peer authenticated by its client certificate.
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	URIs       []string
+}
+
+// SANs returns the DNS and URI subject alternative names of the peer.
+func (i Identity) SANs() []string {
+	sans := make([]string, 0, len(i.DNSNames)+len(i.URIs))
+	sans = append(sans, i.URIs...)
+	return append(sans, i.DNSNames...)
+}
+
+// String returns the most specific name of the peer: its first URI SAN
+// (e.g. a SPIFFE ID), then its first DNS SAN, then its common name.
+func (i Identity) String() string {
+	if len(i.URIs) > 0 {
+		return i.URIs[0]
+	}
+	if len(i.DNSNames) > 0 {
+		return i.DNSNames[0]
+	}
+	return i.CommonName
+}
+
+// IdentityFromConnectionState returns the identity of the verified peer of a TLS connection.
+func IdentityFromConnectionState(cs tls.ConnectionState) (Identity, bool) {
+	if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	return identityFromCertificate(cs.VerifiedChains[0][0]), true
+}
+
+func identityFromCertificate(cert *x509.Certificate) Identity {
+	uris := make([]string, 0, len(cert.URIs))
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+	return Identity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames:   cert.DNSNames,
+		URIs:       uris,
+	}
+}
+
+// identityKey is the context key for the peer identity.
+type identityKey struct{}
+
+// ContextWithIdentity adds the peer identity to the context.
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext gets the peer identity from the context.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,149 @@
+// Package mtls defines mutual TLS with hot-reloaded certificates for service-to-service calls.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultReloadInterval is how often the files are checked for changes when not configured.
+const defaultReloadInterval = 30 * time.Second
+
+// Config is the configuration for mutual TLS.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ReloadInterval is how often the files are checked for rotation.
+	ReloadInterval time.Duration
+}
+
+// Reloader holds the certificate and CA pool loaded from Config and reloads them
+// whenever one of the files changes, so rotated certificates are picked up without restart.
+type Reloader struct {
+	cfg    Config
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime time.Time
+}
+
+// NewReloader creates a new Reloader and loads the files once.
+func NewReloader(cfg Config, logger *zap.Logger) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return nil, errors.New("mtls: cert, key and CA files are required")
+	}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = defaultReloadInterval
+	}
+
+	r := &Reloader{cfg: cfg, logger: logger}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run checks the files for changes until ctx is done.
+// A failed reload keeps serving the previous certificate.
+func (r *Reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := r.changed()
+			if err != nil {
+				r.logger.Warn("Failed to stat TLS files", zap.Error(err))
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				r.logger.Error("Failed to reload TLS files", zap.Error(err))
+				continue
+			}
+			r.logger.Info("Reloaded TLS files", zap.String("cert", r.cfg.CertFile))
+		}
+	}
+}
+
+// certificate returns the current certificate.
+func (r *Reloader) certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// roots returns the current CA pool.
+func (r *Reloader) roots() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+func (r *Reloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: load key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(r.cfg.CAFile)
+	if err != nil {
+		return fmt.Errorf("mtls: read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("mtls: no certificates found in %s", r.cfg.CAFile)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+	return nil
+}
+
+func (r *Reloader) changed() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !modTime.Equal(r.modTime), nil
+}
+
+// latestModTime returns the newest modification time of the configured files.
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("mtls: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,75 @@
+// Package mtls defines the server and client TLS configurations.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrPeerNotAllowed is the error for when a client certificate matches none of the allowed SANs.
+var ErrPeerNotAllowed = errors.New("mtls: peer certificate SAN not allowed")
+
+// ServerConfig returns a TLS configuration that requires a client certificate signed by
+// the current CA and, if allowedSANs is not empty, with a DNS or URI SAN in allowedSANs.
+// The certificate and CA pool are read from r on every handshake.
+func (r *Reloader) ServerConfig(allowedSANs []string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate()},
+				ClientCAs:    r.roots(),
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				VerifyConnection: func(cs tls.ConnectionState) error {
+					if len(allowedSANs) == 0 || len(cs.PeerCertificates) == 0 {
+						return nil
+					}
+					identity := identityFromCertificate(cs.PeerCertificates[0])
+					for _, san := range identity.SANs() {
+						if slices.Contains(allowedSANs, san) {
+							return nil
+						}
+					}
+					return fmt.Errorf("%w: %v", ErrPeerNotAllowed, identity.SANs())
+				},
+			}, nil
+		},
+	}
+}
+
+// ClientConfig returns a TLS configuration that presents the current certificate and
+// verifies the server against the current CA pool and serverName.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		},
+		// RootCAs cannot change after the config is handed to the transport, so the
+		// default verification is replaced by VerifyConnection, which uses the current pool.
+		InsecureSkipVerify: true, //nolint:gosec // verified in VerifyConnection
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("mtls: server presented no certificate")
+			}
+			name := serverName
+			if name == "" {
+				name = cs.ServerName
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       name,
+				Roots:         r.roots(),
+				Intermediates: intermediates,
+			})
+			return err
+		},
+	}
+}
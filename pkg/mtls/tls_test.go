@@ -0,0 +1,254 @@
+package mtls_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// serial numbers the generated certificates.
+var serial atomic.Int64
+
+// authority is a generated CA.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// keyPair is a generated certificate and key in PEM.
+type keyPair struct {
+	serial  int64
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial.Add(1)),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate signed by a, valid for clients and servers, with sans as its
+// DNS SANs, or URI SANs when they parse as a URL with a scheme.
+func (a *authority) issue(t *testing.T, sans ...string) keyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial.Add(1)),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, san := range sans {
+		if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+			tmpl.URIs = append(tmpl.URIs, u)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, san)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return keyPair{
+		serial:  tmpl.SerialNumber.Int64(),
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFiles writes the key pair and CA to the files of cfg and moves their modification
+// time to modTime, so a rewrite is detected regardless of the file system's granularity.
+func writeFiles(t *testing.T, cfg mtls.Config, pair keyPair, caPEM []byte, modTime time.Time) {
+	t.Helper()
+	for name, data := range map[string][]byte{cfg.CertFile: pair.certPEM, cfg.KeyFile: pair.keyPEM, cfg.CAFile: caPEM} {
+		require.NoError(t, os.WriteFile(name, data, 0o600))
+		require.NoError(t, os.Chtimes(name, modTime, modTime))
+	}
+}
+
+// newReloader writes the key pair and CA to a temporary directory and loads them.
+func newReloader(t *testing.T, pair keyPair, caPEM []byte) (*mtls.Reloader, mtls.Config) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := mtls.Config{
+		CertFile:       filepath.Join(dir, "tls.crt"),
+		KeyFile:        filepath.Join(dir, "tls.key"),
+		CAFile:         filepath.Join(dir, "ca.crt"),
+		ReloadInterval: 10 * time.Millisecond,
+	}
+	writeFiles(t, cfg, pair, caPEM, time.Now())
+	r, err := mtls.NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+	return r, cfg
+}
+
+// handshake runs a TLS handshake between the configurations and returns the errors of
+// both ends and the certificate the server presented.
+func handshake(t *testing.T, server, client *tls.Config) (serverErr, clientErr error, presented *x509.Certificate) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		conn := tls.Server(serverConn, server)
+		err := conn.HandshakeContext(ctx)
+		if err == nil {
+			// The client verifies the server before the server sees its certificate;
+			// a read makes the server finish the handshake before it answers.
+			_, err = conn.Write([]byte("ok"))
+		}
+		// Closing the pipe rather than conn skips the close_notify, which nobody reads.
+		_ = serverConn.Close()
+		done <- err
+	}()
+
+	conn := tls.Client(clientConn, client)
+	clientErr = conn.HandshakeContext(ctx)
+	if clientErr == nil {
+		presented = conn.ConnectionState().PeerCertificates[0]
+		_, clientErr = conn.Read(make([]byte, 2))
+	}
+	_ = clientConn.Close()
+	return <-done, clientErr, presented
+}
+
+// TestUnitServerConfig_AllowedSANs tests that the server accepts only clients signed by
+// its CA whose certificate carries an allowed SAN, and the client only accepts a server
+// signed by its CA for the expected name.
+func TestUnitServerConfig_AllowedSANs(t *testing.T) {
+	ca := newAuthority(t)
+	other := newAuthority(t)
+	server, _ := newReloader(t, ca.issue(t, "user-service"), ca.pem)
+
+	tests := []struct {
+		name          string
+		allowedSANs   []string
+		client        keyPair
+		clientCA      []byte
+		serverName    string
+		wantServerErr error
+		wantFail      bool
+	}{
+		{name: "allowed DNS SAN", allowedSANs: []string{"auth-service"}, client: ca.issue(t, "auth-service")},
+		{
+			name:        "allowed URI SAN",
+			allowedSANs: []string{"spiffe://example.org/auth-service"},
+			client:      ca.issue(t, "spiffe://example.org/auth-service"),
+		},
+		{name: "any SAN when none are listed", client: ca.issue(t, "anything")},
+		{
+			name:          "SAN not allowed",
+			allowedSANs:   []string{"auth-service"},
+			client:        ca.issue(t, "other-service", "spiffe://example.org/other-service"),
+			wantServerErr: mtls.ErrPeerNotAllowed,
+			wantFail:      true,
+		},
+		{name: "client signed by another CA", allowedSANs: []string{"auth-service"}, client: other.issue(t, "auth-service"), wantFail: true},
+		{name: "server signed by another CA", client: ca.issue(t, "auth-service"), clientCA: other.pem, wantFail: true},
+		{name: "unexpected server name", client: ca.issue(t, "auth-service"), serverName: "order-service", wantFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCA := tt.clientCA
+			if clientCA == nil {
+				clientCA = ca.pem
+			}
+			serverName := tt.serverName
+			if serverName == "" {
+				serverName = "user-service"
+			}
+			client, _ := newReloader(t, tt.client, clientCA)
+
+			serverErr, clientErr, _ := handshake(t, server.ServerConfig(tt.allowedSANs), client.ClientConfig(serverName))
+			if !tt.wantFail {
+				require.NoError(t, serverErr)
+				require.NoError(t, clientErr)
+				return
+			}
+			assert.Error(t, serverErr)
+			assert.Error(t, clientErr)
+			if tt.wantServerErr != nil {
+				assert.ErrorIs(t, serverErr, tt.wantServerErr)
+			}
+		})
+	}
+}
+
+// TestUnitReloader_PicksUpNewKeyPair tests that a running reloader serves a rotated key
+// pair, and keeps serving the previous one while the files are invalid.
+func TestUnitReloader_PicksUpNewKeyPair(t *testing.T) {
+	ca := newAuthority(t)
+	first := ca.issue(t, "user-service")
+	server, cfg := newReloader(t, first, ca.pem)
+	client, _ := newReloader(t, ca.issue(t, "auth-service"), ca.pem)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	// presentedSerial returns the serial number the server presents, or 0 when the
+	// handshake fails.
+	presentedSerial := func() int64 {
+		serverErr, clientErr, presented := handshake(t, server.ServerConfig(nil), client.ClientConfig("user-service"))
+		if serverErr != nil || clientErr != nil {
+			return 0
+		}
+		return presented.SerialNumber.Int64()
+	}
+	require.Equal(t, first.serial, presentedSerial())
+
+	broken := keyPair{certPEM: first.certPEM, keyPEM: []byte("not a key")}
+	writeFiles(t, cfg, broken, ca.pem, time.Now().Add(time.Minute))
+	time.Sleep(5 * cfg.ReloadInterval)
+	assert.Equal(t, first.serial, presentedSerial(), "invalid files keep the previous key pair")
+
+	second := ca.issue(t, "user-service")
+	writeFiles(t, cfg, second, ca.pem, time.Now().Add(2*time.Minute))
+	assert.Eventually(t, func() bool {
+		return presentedSerial() == second.serial
+	}, 5*time.Second, cfg.ReloadInterval)
+}
@@ -1,6 +1,12 @@
 // Package config defines the configuration for the observability.
 package config
 
+import (
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+)
+
 // DefaultOTLPEndpoint is the default OpenTelemetry Collector endpoint.
 const DefaultOTLPEndpoint = "otel-collector:4317"
 
@@ -21,6 +27,9 @@ type TelemetryConfig struct {
 
 	// Metrics controls metrics collection.
 	Metrics MetricsConfig
+
+	// Redaction scrubs log fields and span attributes.
+	Redaction redact.Policy
 }
 
 // ResourceConfig describes service-level resource attributes.
@@ -40,15 +49,42 @@ type LoggingConfig struct {
 type OTLPConfig struct {
 	Endpoint string // Collector endpoint (host:port)
 	Insecure bool   // Disable TLS
+	TLS      TLSConfig
+}
+
+// TLSConfig configures TLS to the collector. An empty CAFile verifies against the
+// system roots; CertFile and KeyFile enable client certificates.
+type TLSConfig struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
 }
 
 // TracingConfig configures tracing behavior.
 type TracingConfig struct {
-	// SamplingRatio is a value between 0.0 and 1.0.
+	// SamplingRatio is a value between 0.0 and 1.0, for root spans no rule matches.
 	SamplingRatio float64
+	// Rules override SamplingRatio per HTTP path or gRPC method.
+	Rules SamplingRules
+	// SampleErrors exports spans ending with an error status even when their trace
+	// is not sampled.
+	SampleErrors bool
+	// Exporter selects where spans go. Empty means ExporterOTLPGRPC.
+	Exporter Exporter
+	// FilePath is the file ExporterFile appends spans to, one JSON object per line.
+	FilePath string
 }
 
 // MetricsConfig configures metrics collection.
 type MetricsConfig struct {
-	// Enabled bool
+	// Prometheus exposes the OpenTelemetry instruments on the service's Prometheus registry.
+	Prometheus bool
+	// Exporter pushes the instruments to the collector: ExporterOTLPGRPC, ExporterOTLPHTTP,
+	// or empty and ExporterNone to disable pushing.
+	Exporter Exporter
+	// ExportInterval is how often the instruments are pushed. Zero uses the SDK default.
+	ExportInterval time.Duration
+	// Exemplars attaches the trace of sampled spans to the measurements recorded in them.
+	Exemplars bool
 }
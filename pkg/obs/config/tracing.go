@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultOTLPHTTPEndpoint is the default OpenTelemetry Collector endpoint of ExporterOTLPHTTP.
+const DefaultOTLPHTTPEndpoint = "otel-collector:4318"
+
+// Exporter is a span exporter kind.
+type Exporter string
+
+// Span exporter kinds.
+const (
+	ExporterOTLPGRPC Exporter = "otlp-grpc"
+	ExporterOTLPHTTP Exporter = "otlp-http"
+	ExporterStdout   Exporter = "stdout"
+	ExporterFile     Exporter = "file"
+	ExporterNone     Exporter = "none"
+)
+
+// UnmarshalText parses an exporter kind, so Exporter can be loaded from config.
+func (e *Exporter) UnmarshalText(text []byte) error {
+	switch v := Exporter(strings.ToLower(strings.TrimSpace(string(text)))); v {
+	case ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterStdout, ExporterFile, ExporterNone:
+		*e = v
+		return nil
+	default:
+		return fmt.Errorf("invalid exporter %q, want one of otlp-grpc, otlp-http, stdout, file, none", text)
+	}
+}
+
+// SamplingRule samples the root spans it matches at Ratio, between 0 and 1.
+type SamplingRule struct {
+	// Match is "*", an HTTP path or a gRPC method such as /user.v1.UserService/GetUser,
+	// with an optional trailing "*" to match a prefix.
+	Match string
+	Ratio float64
+}
+
+// Matches reports whether the rule matches route.
+func (r SamplingRule) Matches(route string) bool {
+	if prefix, ok := strings.CutSuffix(r.Match, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return r.Match == route
+}
+
+// SamplingRules decide the sampling ratio of root spans. The first matching rule applies.
+type SamplingRules []SamplingRule
+
+// ParseSamplingRules parses rules of the form "/livez=0;/v1/auth/login=1;/grpc.health.v1.Health/*=0".
+func ParseSamplingRules(s string) (SamplingRules, error) {
+	var rules SamplingRules
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match, ratioRaw, ok := strings.Cut(entry, "=")
+		match = strings.TrimSpace(match)
+		if !ok || match == "" {
+			return nil, fmt.Errorf("invalid sampling rule %q, want match=ratio", entry)
+		}
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(ratioRaw), 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("sampling rule %q: ratio must be between 0 and 1", match)
+		}
+		rules = append(rules, SamplingRule{Match: match, Ratio: ratio})
+	}
+	return rules, nil
+}
+
+// UnmarshalText parses rules in the ParseSamplingRules format, so SamplingRules can be loaded from config.
+func (r *SamplingRules) UnmarshalText(text []byte) error {
+	rules, err := ParseSamplingRules(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// Ratio returns the ratio of the first rule matching route.
+func (r SamplingRules) Ratio(route string) (float64, bool) {
+	for _, rule := range r {
+		if rule.Matches(route) {
+			return rule.Ratio, true
+		}
+	}
+	return 0, false
+}
@@ -17,6 +17,8 @@ const (
 	BaggageRequestID BaggageKey = "request.id"
 	// BaggageTenantID is the tenant ID.
 	BaggageTenantID BaggageKey = "tenant.id"
+	// BaggageDebugLog is a signed token forcing debug logging for the request.
+	BaggageDebugLog BaggageKey = "debug.log"
 )
 
 // SetBaggage adds or overwrites a baggage key/value on ctx.
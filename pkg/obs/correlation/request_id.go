@@ -0,0 +1,125 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// HeaderRequestID is the HTTP header carrying the request ID, set by Envoy when it
+	// generates or preserves one.
+	HeaderRequestID = "X-Request-ID"
+	// MetadataRequestID is the gRPC metadata key carrying the request ID.
+	MetadataRequestID = "x-request-id"
+)
+
+// crockford is the ULID alphabet.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewRequestID returns a new request ID, a UUIDv7, so IDs sort by creation time.
+func NewRequestID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// ValidRequestID reports whether id is a ULID or a UUID; Envoy generates UUIDv4.
+func ValidRequestID(id string) bool {
+	if len(id) == 26 {
+		for i, c := range strings.ToUpper(id) {
+			if !strings.ContainsRune(crockford, c) || (i == 0 && c > '7') {
+				return false
+			}
+		}
+		return true
+	}
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// RequestID returns the request ID of ctx, or "".
+func RequestID(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(string(BaggageRequestID)).Value()
+}
+
+// WithRequestID makes id, or a new request ID if id is not valid, the request ID of ctx:
+// it is put in the baggage, propagated to downstream calls, and on the current span.
+func WithRequestID(ctx context.Context, id string) (context.Context, string) {
+	if !ValidRequestID(id) {
+		id = NewRequestID()
+	}
+	if withID, err := SetBaggage(ctx, BaggageRequestID, id); err == nil {
+		ctx = withID
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(string(BaggageRequestID), id))
+	return ctx, id
+}
+
+// HTTPRequestID gives each request the request ID of its X-Request-ID header, or a new
+// one, and echoes it in the response. It must run inside otelhttp.NewHandler.
+func HTTPRequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, id := WithRequestID(r.Context(), r.Header.Get(HeaderRequestID))
+			w.Header().Set(HeaderRequestID, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GRPCRequestID is HTTPRequestID for gRPC servers: the request ID comes from the
+// x-request-id metadata, else the baggage, and is echoed in the response headers.
+// It must run after the otelgrpc stats handler extracted the baggage.
+func GRPCRequestID() grpcchain.ServerInterceptor {
+	return grpcchain.ServerInterceptor{
+		Unary: func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, id := WithRequestID(ctx, incomingRequestID(ctx))
+			_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, id))
+			return handler(ctx, req)
+		},
+		Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, id := WithRequestID(ss.Context(), incomingRequestID(ss.Context()))
+			_ = ss.SetHeader(metadata.Pairs(MetadataRequestID, id))
+			return handler(srv, grpcchain.ServerStreamWithContext(ctx, ss))
+		},
+	}
+}
+
+func incomingRequestID(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataRequestID); len(values) > 0 {
+		return values[0]
+	}
+	return RequestID(ctx)
+}
+
+// GRPCClientRequestID sends the request ID of the calling context as x-request-id
+// metadata, so services reading metadata only get it too.
+func GRPCClientRequestID() grpcchain.ClientInterceptor {
+	return grpcchain.ClientInterceptor{
+		Unary: func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+		},
+		Stream: func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+		},
+	}
+}
+
+func outgoingRequestID(ctx context.Context) context.Context {
+	id := RequestID(ctx)
+	if id == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataRequestID, id)
+}
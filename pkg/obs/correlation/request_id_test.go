@@ -0,0 +1,95 @@
+package correlation_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestUnitHTTPRequestID tests that valid request IDs are kept, others replaced by a new
+// UUIDv7, and that the request ID is echoed and set in the baggage.
+func TestUnitHTTPRequestID(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "envoy uuid", header: "3f0c6b36-2f7e-4c57-9d0c-7e1b0a4c2f11", keep: true},
+		{name: "ulid", header: "01ARZ3NDEKTSV4RRFFQ69G5FAV", keep: true},
+		{name: "missing"},
+		{name: "invalid", header: "req-1; drop table"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := correlation.HTTPRequestID()(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				seen = correlation.RequestID(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(correlation.HeaderRequestID, tt.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(correlation.HeaderRequestID)
+			assert.Equal(t, seen, got)
+			assert.True(t, correlation.ValidRequestID(got))
+			if tt.keep {
+				assert.Equal(t, tt.header, got)
+			} else {
+				assert.NotEqual(t, tt.header, got)
+				assert.Equal(t, byte('7'), got[14], "UUID version")
+			}
+		})
+	}
+}
+
+// TestUnitGRPCRequestID tests that the request ID of the caller reaches the server
+// through metadata and is echoed in the response headers.
+func TestUnitGRPCRequestID(t *testing.T) {
+	seen := make(chan string, 1)
+	srv := grpc.NewServer(new(grpcchain.Server).Use(
+		correlation.GRPCRequestID(),
+		grpcchain.ServerInterceptor{
+			Unary: func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				seen <- correlation.RequestID(ctx)
+				return handler(ctx, req)
+			},
+			Stream: func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				return handler(srv, ss)
+			},
+		},
+	).Options()...)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		new(grpcchain.Client).Use(correlation.GRPCClientRequestID()).DialOptions()...)
+	conn, err := grpc.NewClient(lis.Addr().String(), opts...)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx, id := correlation.WithRequestID(ctx, "")
+	var header metadata.MD
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	assert.Equal(t, id, <-seen)
+	assert.Equal(t, []string{id}, header.Get(correlation.MetadataRequestID))
+}
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLogMessage is the message of access log lines, so the log pipeline can select
+// them with e.g. {service_name="auth"} | json | msg="access".
+const AccessLogMessage = "access"
+
+// SampleMatchError is the sample rule match of failed requests: HTTP status 400 and
+// above, or any gRPC code but OK.
+const SampleMatchError = "error"
+
+// SampleRule logs the requests it matches at Rate, between 0 and 1.
+type SampleRule struct {
+	// Match is SampleMatchError, "*", or a route or gRPC method, with an optional
+	// trailing "*" to match a prefix.
+	Match string
+	Rate  float64
+}
+
+// SampleRules decide which requests are logged. The first matching rule applies;
+// requests no rule matches are always logged.
+type SampleRules []SampleRule
+
+// ParseSampleRules parses rules of the form "error=1;/readyz=0.01;/grpc.health.v1.Health/*=0.01".
+func ParseSampleRules(s string) (SampleRules, error) {
+	var rules SampleRules
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		match, rateRaw, ok := strings.Cut(entry, "=")
+		match = strings.TrimSpace(match)
+		if !ok || match == "" {
+			return nil, fmt.Errorf("invalid sample rule %q, want match=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateRaw), 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("sample rule %q: rate must be between 0 and 1", match)
+		}
+		rules = append(rules, SampleRule{Match: match, Rate: rate})
+	}
+	return rules, nil
+}
+
+// UnmarshalText parses rules in the ParseSampleRules format, so SampleRules can be loaded from config.
+func (r *SampleRules) UnmarshalText(text []byte) error {
+	rules, err := ParseSampleRules(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// rate returns the sampling rate of a request to route that failed or not.
+func (r SampleRules) rate(route string, failed bool) float64 {
+	for _, rule := range r {
+		if rule.Match == SampleMatchError && failed || rule.Match != SampleMatchError && matchRoute(rule.Match, route) {
+			return rule.Rate
+		}
+	}
+	return 1
+}
+
+// matchRoute matches route against pattern, which may end with "*" to match a prefix.
+func matchRoute(pattern, route string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return pattern == route
+}
+
+// AccessLogConfig is the configuration of the access log.
+type AccessLogConfig struct {
+	Enabled bool
+	// Sampling decides which requests are logged. Nil logs every request.
+	Sampling SampleRules
+	// ExcludePaths are never logged: HTTP paths or gRPC methods, with an optional
+	// trailing "*" to match a prefix.
+	ExcludePaths []string
+}
+
+func (c AccessLogConfig) excluded(path string) bool {
+	for _, pattern := range c.ExcludePaths {
+		if matchRoute(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled reports whether a request to route is logged.
+func (c AccessLogConfig) sampled(route string, failed bool) bool {
+	rate := c.Sampling.rate(route, failed)
+	return rate >= 1 || rate > 0 && rand.Float64() < rate
+}
+
+// WithAccessLog writes one access log line per request, as configured by cfg.
+func WithAccessLog(cfg AccessLogConfig) Option {
+	return func(o *options) {
+		if cfg.Enabled {
+			o.accessLog = &cfg
+		}
+	}
+}
+
+// WithClientIP sets how the client IP of HTTP access log lines is resolved, e.g. from
+// X-Forwarded-For behind a proxy. It defaults to the remote address.
+func WithClientIP(clientIP func(*http.Request) string) Option {
+	return func(o *options) {
+		o.clientIP = clientIP
+	}
+}
+
+// accessInfo collects what inner handlers know about a request for its access log line.
+type accessInfo struct {
+	subject atomic.Pointer[string]
+}
+
+type accessInfoKey struct{}
+
+// SetSubject records the authenticated subject of the request in ctx for its access log
+// line. It is a no-op when the access log is disabled.
+func SetSubject(ctx context.Context, subject string) {
+	if info, ok := ctx.Value(accessInfoKey{}).(*accessInfo); ok {
+		info.subject.Store(&subject)
+	}
+}
+
+func withAccessInfo(ctx context.Context) (context.Context, *accessInfo) {
+	info := &accessInfo{}
+	return context.WithValue(ctx, accessInfoKey{}, info), info
+}
+
+func (i *accessInfo) subjectOr(fallback string) string {
+	if subject := i.subject.Load(); subject != nil {
+		return *subject
+	}
+	return fallback
+}
+
+// writeAccessLog writes the access log line. Server failures log at warn level.
+func writeAccessLog(l *zap.Logger, serverError bool, latency time.Duration, fields ...zap.Field) {
+	lvl := zapcore.InfoLevel
+	if serverError {
+		lvl = zapcore.WarnLevel
+	}
+	fields = append(fields, zap.Float64("latency_ms", float64(latency.Microseconds())/1000))
+	l.Log(lvl, AccessLogMessage, fields...)
+}
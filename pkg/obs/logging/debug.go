@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+)
+
+// HeaderDebugLog carries a signed debug token that turns on debug logging for one request.
+const HeaderDebugLog = "X-Debug-Log"
+
+// MaxDebugTokenLifetime is the longest a debug token may be valid for, counted from its
+// verification, so a leaked token cannot force debug logging for long.
+const MaxDebugTokenLifetime = time.Hour
+
+var (
+	errMalformedDebugToken = errors.New("malformed debug token")
+	errExpiredDebugToken   = errors.New("expired debug token")
+	errBadDebugSignature   = errors.New("invalid debug token signature")
+	errDebugTokenTooLong   = errors.New("debug token lifetime exceeds the maximum")
+)
+
+// gatedCore applies the runtime level in front of a core that accepts every level.
+type gatedCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+	force bool
+}
+
+func (c *gatedCore) Enabled(lvl zapcore.Level) bool {
+	return c.force || c.level.Enabled(lvl)
+}
+
+func (c *gatedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &gatedCore{Core: c.Core.With(fields), level: c.level, force: c.force}
+}
+
+func (c *gatedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// ForceDebug returns a logger that logs at debug level whatever the runtime level.
+// Loggers not created by New are returned unchanged.
+func ForceDebug(l *zap.Logger) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if g, ok := core.(*gatedCore); ok {
+			return &gatedCore{Core: g.Core, level: g.level, force: true}
+		}
+		return core
+	}))
+}
+
+// SignDebugToken creates a debug token valid until expiresAt, which must be within
+// MaxDebugTokenLifetime for the token to be accepted. The token is
+// "<unix expiry>.<hex HMAC-SHA256 of the expiry>", so operators can also mint one with
+//
+//	exp=$(($(date +%s)+600)); echo "$exp.$(printf %s "$exp" | openssl dgst -sha256 -hmac "$SECRET" -r | cut -d' ' -f1)"
+func SignDebugToken(secret []byte, expiresAt time.Time) string {
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	return exp + "." + debugSignature(secret, exp)
+}
+
+// VerifyDebugToken checks the signature and expiry of a debug token, and rejects tokens
+// expiring more than MaxDebugTokenLifetime after now.
+func VerifyDebugToken(secret []byte, token string, now time.Time) error {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return errMalformedDebugToken
+	}
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return errMalformedDebugToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(debugSignature(secret, exp))) {
+		return errBadDebugSignature
+	}
+	if now.Unix() > expiresAt {
+		return errExpiredDebugToken
+	}
+	if expiresAt > now.Add(MaxDebugTokenLifetime).Unix() {
+		return errDebugTokenTooLong
+	}
+	return nil
+}
+
+func debugSignature(secret []byte, exp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Option configures the request logging middleware and interceptor.
+type Option func(*options)
+
+type options struct {
+	debugSecret []byte
+	accessLog   *AccessLogConfig
+	clientIP    func(*http.Request) string
+}
+
+// WithDebugSecret enables per-request debug logging for requests carrying a debug token
+// signed with secret, in the X-Debug-Log header or the debug.log baggage member. The token
+// is propagated as baggage, so downstream services sharing the secret debug the request too.
+func WithDebugSecret(secret string) Option {
+	return func(o *options) {
+		if secret != "" {
+			o.debugSecret = []byte(secret)
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// debugRequest verifies the debug token of a request, taken from header or else from the
+// baggage in ctx. A valid token is put into the baggage and l is forced to debug level.
+func (o options) debugRequest(ctx context.Context, header string, l *zap.Logger) (context.Context, *zap.Logger) {
+	if o.debugSecret == nil {
+		return ctx, l
+	}
+	token := header
+	if token == "" {
+		token = baggage.FromContext(ctx).Member(string(correlation.BaggageDebugLog)).Value()
+	}
+	if token == "" {
+		return ctx, l
+	}
+	if err := VerifyDebugToken(o.debugSecret, token, time.Now()); err != nil {
+		l.Warn("Ignoring debug token", zap.Error(err))
+		return ctx, l
+	}
+	if header != "" {
+		if withBaggage, err := correlation.SetBaggage(ctx, correlation.BaggageDebugLog, token); err == nil {
+			ctx = withBaggage
+		}
+	}
+	l = ForceDebug(l)
+	l.Debug("Debug logging forced for request")
+	return ctx, l
+}
@@ -0,0 +1,39 @@
+package logging_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUnitVerifyDebugToken tests that only unexpired, correctly signed debug tokens of a
+// bounded lifetime are accepted.
+func TestUnitVerifyDebugToken(t *testing.T) {
+	secret := []byte("debug-secret")
+	now := time.Unix(1_700_000_000, 0)
+	valid := logging.SignDebugToken(secret, now.Add(10*time.Minute))
+
+	assert.NoError(t, logging.VerifyDebugToken(secret, valid, now))
+	assert.NoError(t, logging.VerifyDebugToken(secret, logging.SignDebugToken(secret, now.Add(logging.MaxDebugTokenLifetime)), now))
+
+	exp, sig, _ := strings.Cut(valid, ".")
+	for name, token := range map[string]string{
+		"expired":         logging.SignDebugToken(secret, now.Add(-time.Second)),
+		"too long-lived":  logging.SignDebugToken(secret, now.Add(logging.MaxDebugTokenLifetime+time.Minute)),
+		"other secret":    logging.SignDebugToken([]byte("other"), now.Add(time.Minute)),
+		"extended expiry": strconv.FormatInt(now.Add(20*time.Minute).Unix(), 10) + "." + sig,
+		"bad signature":   exp + "." + strings.Repeat("0", len(sig)),
+		"no signature":    exp + ".",
+		"no separator":    exp,
+		"bad expiry":      "tomorrow." + sig,
+		"empty":           "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, logging.VerifyDebugToken(secret, token, now))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ClientCallMessage is the message of the log lines of outgoing gRPC calls.
+const ClientCallMessage = "grpc client call"
+
+// GRPCClientLogging logs outgoing unary gRPC calls with the request-scoped logger of ctx,
+// falling back to base. Calls failing on the server side log at warn level, others at
+// debug level, so they show up for requests with a debug token.
+func GRPCClientLogging(base *zap.Logger) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logClientCall(ctx, base, method, err, time.Since(start))
+		return err
+	}
+}
+
+// GRPCStreamClientLogging is GRPCClientLogging for streams. The line is written when the
+// stream ends, i.e. when receiving fails or reaches the end of the stream, and counts the
+// messages sent and received.
+func GRPCStreamClientLogging(base *zap.Logger) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logClientCall(ctx, base, method, err, time.Since(start))
+			return nil, err
+		}
+		return &loggingClientStream{ClientStream: cs, ctx: ctx, base: base, method: method, start: start}, nil
+	}
+}
+
+// loggingClientStream logs a client stream once it ends.
+type loggingClientStream struct {
+	grpc.ClientStream
+	ctx     context.Context
+	base    *zap.Logger
+	method  string
+	start   time.Time
+	msgsIn  atomic.Int64
+	msgsOut atomic.Int64
+	once    sync.Once
+}
+
+func (s *loggingClientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.msgsOut.Add(1)
+	}
+	return err
+}
+
+func (s *loggingClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.msgsIn.Add(1)
+		return nil
+	}
+	s.once.Do(func() {
+		callErr := err
+		if errors.Is(err, io.EOF) {
+			callErr = nil
+		}
+		logClientCall(s.ctx, s.base, s.method, callErr, time.Since(s.start),
+			zap.Int64("msgs_in", s.msgsIn.Load()),
+			zap.Int64("msgs_out", s.msgsOut.Load()),
+		)
+	})
+	return err
+}
+
+// logClientCall writes the log line of an outgoing call to method that returned err.
+func logClientCall(ctx context.Context, base *zap.Logger, method string, err error, latency time.Duration, fields ...zap.Field) {
+	l := base
+	if ctxLogger, ok := correlation.LoggerFromContext(ctx); ok {
+		l = ctxLogger
+	}
+	code := status.Code(err)
+	lvl := zapcore.DebugLevel
+	if isServerError(code) {
+		lvl = zapcore.WarnLevel
+	}
+	if ce := l.Check(lvl, ClientCallMessage); ce != nil {
+		ce.Write(append([]zap.Field{
+			zap.String("grpc.method", method),
+			zap.String("grpc_code", code.String()),
+			zap.Float64("latency_ms", float64(latency.Microseconds())/1000),
+			zap.Error(err),
+		}, fields...)...)
+	}
+}
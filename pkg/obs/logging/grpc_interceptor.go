@@ -3,61 +3,194 @@ package logging
 
 import (
 	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/mtls"
 	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 )
 
 // GRPCRequestLogging logs gRPC unary requests and injects a request-scoped logger into ctx.
-func GRPCRequestLogging(base *zap.Logger) grpc.UnaryServerInterceptor {
+// A request with a valid debug token (see WithDebugSecret) logs at debug level.
+// With WithAccessLog, one access log line is written per request once it is handled;
+// its subject defaults to the mTLS peer identity.
+func GRPCRequestLogging(base *zap.Logger, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (resp any, err error) {
-		// start := time.Now()
+		call := o.startGRPCCall(ctx, base, info.FullMethod)
+		resp, err = handler(call.ctx, req)
+		call.finish(err, messageSize(req), messageSize(resp))
+		return resp, err
+	}
+}
+
+// GRPCStreamLogging is GRPCRequestLogging for streaming requests. The stream context
+// carries the request-scoped logger, and the access log line counts the messages
+// received and sent.
+func GRPCStreamLogging(base *zap.Logger, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		call := o.startGRPCCall(ss.Context(), base, info.FullMethod)
+		stream := &countingStream{ServerStream: grpcchain.ServerStreamWithContext(call.ctx, ss)}
+		err := handler(srv, stream)
+		call.finish(err, int(stream.bytesIn.Load()), int(stream.bytesOut.Load()),
+			zap.Int64("msgs_in", stream.msgsIn.Load()),
+			zap.Int64("msgs_out", stream.msgsOut.Load()),
+		)
+		return err
+	}
+}
+
+// grpcCall is a gRPC request being logged.
+type grpcCall struct {
+	ctx    context.Context
+	logger *zap.Logger
+	method string
+	start  time.Time
+	access *AccessLogConfig
+	info   *accessInfo
+}
 
-		// Build request-scoped logger
-		l := base.With(
-			zap.String("grpc.method", info.FullMethod),
-		).With(correlation.TraceFields(ctx)...)
+// startGRPCCall builds the request-scoped logger of a request to method and puts it into ctx.
+func (o *options) startGRPCCall(ctx context.Context, base *zap.Logger, method string) *grpcCall {
+	start := time.Now()
 
-		l = l.With(correlation.BaggageFields(ctx,
-			correlation.BaggageRequestID,
-			correlation.BaggageTenantID,
-		)...)
+	// Build request-scoped logger
+	l := base.With(
+		zap.String("grpc.method", method),
+	).With(correlation.TraceFields(ctx)...)
 
-		// Optional: remote peer address
-		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
-			l = l.With(zap.String("client_addr", p.Addr.String()))
+	l = l.With(correlation.BaggageFields(ctx,
+		correlation.BaggageRequestID,
+		correlation.BaggageTenantID,
+	)...)
+
+	// Same key as HTTP requests, so one ID finds the logs of both services.
+	if reqID := correlation.RequestID(ctx); reqID != "" {
+		l = l.With(zap.String("request_id", reqID))
+	}
+
+	// Optional: remote peer address
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		l = l.With(zap.String("client_addr", p.Addr.String()))
+	}
+
+	// Optional: mTLS peer identity
+	if identity, ok := mtls.IdentityFromContext(ctx); ok {
+		l = l.With(zap.String("peer.identity", identity.String()))
+	}
+
+	// The debug token travels as baggage from the calling service.
+	ctx, l = o.debugRequest(ctx, "", l)
+
+	call := &grpcCall{logger: l, method: method, start: start}
+	if o.accessLog != nil && !o.accessLog.excluded(method) {
+		call.access = o.accessLog
+		ctx, call.info = withAccessInfo(ctx)
+	}
+
+	// Put logger into ctx for handlers
+	call.ctx = correlation.ContextWithLogger(ctx, l)
+	return call
+}
+
+// finish writes the access log line of the request, if it is logged.
+func (c *grpcCall) finish(err error, bytesIn, bytesOut int, fields ...zap.Field) {
+	if c.access == nil {
+		return
+	}
+	code := status.Code(err)
+	if !c.access.sampled(c.method, code != codes.OK) {
+		return
+	}
+	var clientIP, subject string
+	if p, ok := peer.FromContext(c.ctx); ok && p.Addr != nil {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
 		}
+	}
+	if identity, ok := mtls.IdentityFromContext(c.ctx); ok {
+		subject = identity.String()
+	}
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(c.ctx); ok {
+		userAgent = strings.Join(md.Get("user-agent"), " ")
+	}
+	writeAccessLog(c.logger, isServerError(code), time.Since(c.start), append([]zap.Field{
+		zap.String("protocol", "grpc"),
+		zap.String("method", c.method),
+		zap.String("route", c.method),
+		zap.String("grpc_code", code.String()),
+		zap.Int("bytes_in", bytesIn),
+		zap.Int("bytes_out", bytesOut),
+		zap.String("user_agent", userAgent),
+		zap.String("client_ip", clientIP),
+		zap.String("subject", c.info.subjectOr(subject)),
+	}, fields...)...)
+}
 
-		// Put logger into ctx for handlers
-		ctx = correlation.ContextWithLogger(ctx, l)
+// countingStream counts the messages of a server stream. gRPC allows one goroutine to
+// send while another receives, so the counters are atomic.
+type countingStream struct {
+	grpc.ServerStream
+	msgsIn, msgsOut   atomic.Int64
+	bytesIn, bytesOut atomic.Int64
+}
 
-		resp, err = handler(ctx, req)
+func (s *countingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.msgsIn.Add(1)
+		s.bytesIn.Add(int64(messageSize(m)))
+	}
+	return err
+}
 
-		// latency := time.Since(start)
-		// st := status.Convert(err)
+func (s *countingStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.msgsOut.Add(1)
+		s.bytesOut.Add(int64(messageSize(m)))
+	}
+	return err
+}
 
-		// // Access log
-		// if err != nil {
-		// 	l.Warn("grpc request handled",
-		// 		zap.String("grpc.code", st.Code().String()),
-		// 		zap.Duration("latency", latency),
-		// 		zap.Error(err),
-		// 	)
-		// } else {
-		// 	l.Info("grpc request handled",
-		// 		zap.String("grpc.code", st.Code().String()),
-		// 		zap.Duration("latency", latency),
-		// 	)
-		// }
+// isServerError reports whether code is a failure of the server rather than of the call.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
 
-		return resp, err
+// messageSize returns the encoded size of a protobuf message, or 0.
+func messageSize(msg any) int {
+	if m, ok := msg.(proto.Message); ok && m != nil {
+		return proto.Size(m)
 	}
+	return 0
 }
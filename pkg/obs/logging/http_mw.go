@@ -2,8 +2,12 @@
 package logging
 
 import (
+	"io"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 
 	"github.com/incheat/go-production-backend/pkg/obs/correlation"
@@ -11,12 +15,13 @@ import (
 
 const (
 	// HeaderRequestID is the header name for the request ID. Envoy generated/inherited
-	HeaderRequestID = "X-Request-ID"
+	HeaderRequestID = correlation.HeaderRequestID
 )
 
 type responseWriter struct {
 	http.ResponseWriter
 	status int
+	bytes  int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -24,21 +29,45 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// countingBody counts the bytes of the request body read by the handler.
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
 // HTTPRequestLogging injects request-scoped logger into context.
-// It uses chi middleware.RequestID() (or middleware.GetReqID) for request_id.
+// request_id is the one set by correlation.HTTPRequestID, else the X-Request-ID header
+// if valid, else a new one.
 // Trace/span are pulled from OTel context via correlation.TraceFields.
-func HTTPRequestLogging(base *zap.Logger) func(http.Handler) http.Handler {
+// A request with a valid debug token (see WithDebugSecret) logs at debug level.
+// With WithAccessLog, one access log line is written per request once it is served.
+func HTTPRequestLogging(base *zap.Logger, opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 
-			reqID := r.Header.Get(HeaderRequestID) // Envoy generated/inherited
+			ctx := r.Context()
+			reqID := correlation.RequestID(ctx)
 			if reqID == "" {
-				reqID = "-"
-			}
-
-			ctx, err := correlation.SetBaggage(r.Context(), correlation.BaggageRequestID, reqID)
-			if err != nil {
-				base.Warn("failed to set request_id baggage", zap.Error(err))
+				ctx, reqID = correlation.WithRequestID(ctx, r.Header.Get(HeaderRequestID))
 			}
 
 			// request-scoped logger (request_id + trace/span + optional baggage)
@@ -51,12 +80,57 @@ func HTTPRequestLogging(base *zap.Logger) func(http.Handler) http.Handler {
 				correlation.BaggageRequestID,
 			)...)
 
+			ctx, l = o.debugRequest(ctx, r.Header.Get(HeaderDebugLog), l)
+
+			access := o.accessLog
+			if access != nil && access.excluded(r.URL.Path) {
+				access = nil
+			}
+			var info *accessInfo
+			if access != nil {
+				ctx, info = withAccessInfo(ctx)
+			}
+
 			// Put logger into context so handlers can use it
 			ctxWithLogger := correlation.ContextWithLogger(ctx, l)
 			r = r.WithContext(ctxWithLogger)
 
+			body := &countingBody{ReadCloser: r.Body}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+
 			ww := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 			next.ServeHTTP(ww, r)
+
+			if access == nil {
+				return
+			}
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			if !access.sampled(route, ww.status >= http.StatusBadRequest) {
+				return
+			}
+			clientIP := r.RemoteAddr
+			if o.clientIP != nil {
+				clientIP = o.clientIP(r)
+			} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				clientIP = host
+			}
+			writeAccessLog(l, ww.status >= http.StatusInternalServerError, time.Since(start),
+				zap.String("protocol", "http"),
+				zap.String("method", r.Method),
+				zap.String("route", route),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", ww.status),
+				zap.Int64("bytes_in", body.bytes),
+				zap.Int64("bytes_out", ww.bytes),
+				zap.String("user_agent", r.UserAgent()),
+				zap.String("client_ip", clientIP),
+				zap.String("subject", info.subjectOr("")),
+			)
 		})
 	}
 }
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// LevelPath is where LevelHandler is mounted on the admin listener.
+	LevelPath = "/admin/log/level"
+
+	defaultLevelTTL = 10 * time.Minute
+	maxLevelTTL     = 24 * time.Hour
+)
+
+// LevelController changes the level of a logger at runtime. A changed level reverts to
+// the configured one after a TTL, so a forgotten debug level does not flood the logs.
+type LevelController struct {
+	atomic   zap.AtomicLevel
+	base     zapcore.Level
+	logger   *zap.Logger
+	mu       sync.Mutex
+	timer    *time.Timer
+	revertAt time.Time
+	// gen identifies the latest change, so a revert racing a newer change is dropped.
+	gen uint64
+}
+
+func newLevelController(base zapcore.Level) *LevelController {
+	return &LevelController{
+		atomic: zap.NewAtomicLevelAt(base),
+		base:   base,
+		logger: zap.NewNop(),
+	}
+}
+
+// Level returns the current level.
+func (c *LevelController) Level() zapcore.Level {
+	return c.atomic.Level()
+}
+
+// SetLevel sets the level until ttl elapses, then reverts to the configured level.
+// Setting the configured level cancels a pending revert.
+func (c *LevelController) SetLevel(lvl zapcore.Level, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.gen++
+	c.revertAt = time.Time{}
+	c.atomic.SetLevel(lvl)
+	c.logger.Warn("Log level changed", zap.Stringer("level", lvl), zap.Duration("ttl", ttl))
+
+	if lvl == c.base {
+		return
+	}
+	c.revertAt = time.Now().Add(ttl)
+	gen := c.gen
+	c.timer = time.AfterFunc(ttl, func() { c.revert(gen) })
+}
+
+func (c *LevelController) revert(gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	c.timer = nil
+	c.revertAt = time.Time{}
+	c.atomic.SetLevel(c.base)
+	c.logger.Warn("Log level reverted", zap.Stringer("level", c.base))
+}
+
+// levelState is the body of the level endpoint.
+type levelState struct {
+	Level    string     `json:"level"`
+	Default  string     `json:"default"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// levelRequest is the body of a level change. TTL is a Go duration, e.g. "15m".
+type levelRequest struct {
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+func (c *LevelController) state() levelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := levelState{Level: c.atomic.Level().String(), Default: c.base.String()}
+	if !c.revertAt.IsZero() {
+		revertAt := c.revertAt.UTC()
+		s.RevertAt = &revertAt
+	}
+	return s
+}
+
+// Handler serves the level: GET returns it, PUT sets it from {"level":"debug","ttl":"15m"}.
+// Requests must carry "Authorization: Bearer <token>". The TTL defaults to 10 minutes.
+func (c *LevelController) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !svcauth.MatchToken(r, token) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, c.state())
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+				return
+			}
+			lvl, ttl, err := parseLevelRequest(req)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			c.SetLevel(lvl, ttl)
+			writeJSON(w, http.StatusOK, c.state())
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+}
+
+func parseLevelRequest(req levelRequest) (zapcore.Level, time.Duration, error) {
+	lvl, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl := defaultLevelTTL
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			return 0, 0, fmt.Errorf("invalid ttl: %w", err)
+		}
+	}
+	if ttl <= 0 || ttl > maxLevelTTL {
+		return 0, 0, fmt.Errorf("ttl must be between 0 and %s", maxLevelTTL)
+	}
+	return lvl, ttl, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
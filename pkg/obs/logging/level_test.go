@@ -0,0 +1,130 @@
+package logging_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+const adminToken = "admin-token"
+
+func newLevelController(t *testing.T) *logging.LevelController {
+	t.Helper()
+	_, level, err := logging.New(logging.Config{Service: "test", Env: "prod", Level: "info"})
+	require.NoError(t, err)
+	return level
+}
+
+func levelRequest(method, token, body string) *http.Request {
+	r := httptest.NewRequest(method, logging.LevelPath, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// TestUnitLevelHandler_Auth tests that the level endpoint requires the bearer token, and
+// is closed when no token is configured.
+func TestUnitLevelHandler_Auth(t *testing.T) {
+	level := newLevelController(t)
+
+	for name, tt := range map[string]struct {
+		configured string
+		header     string
+		want       int
+	}{
+		"no token":        {configured: adminToken, want: http.StatusUnauthorized},
+		"wrong token":     {configured: adminToken, header: "Bearer nope", want: http.StatusUnauthorized},
+		"not bearer":      {configured: adminToken, header: adminToken, want: http.StatusUnauthorized},
+		"none configured": {header: "Bearer ", want: http.StatusUnauthorized},
+		"valid token":     {configured: adminToken, header: "Bearer " + adminToken, want: http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, logging.LevelPath, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			level.Handler(tt.configured).ServeHTTP(rr, r)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
+
+// TestUnitLevelHandler_SetLevel tests level changes and the bounds of their TTL.
+func TestUnitLevelHandler_SetLevel(t *testing.T) {
+	level := newLevelController(t)
+	handler := level.Handler(adminToken)
+
+	for _, body := range []string{
+		`{"level":"debug","ttl":"0s"}`,
+		`{"level":"debug","ttl":"-1m"}`,
+		`{"level":"debug","ttl":"25h"}`,
+		`{"level":"debug","ttl":"soon"}`,
+		`{"level":"verbose"}`,
+		`not json`,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, levelRequest(http.MethodPut, adminToken, body))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+	assert.Equal(t, zapcore.InfoLevel, level.Level(), "unchanged by invalid requests")
+
+	before := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, levelRequest(http.MethodPut, adminToken, `{"level":"debug","ttl":"24h"}`))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var state struct {
+		Level    string     `json:"level"`
+		Default  string     `json:"default"`
+		RevertAt *time.Time `json:"revert_at"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &state))
+	assert.Equal(t, "debug", state.Level)
+	assert.Equal(t, "info", state.Default)
+	require.NotNil(t, state.RevertAt)
+	assert.WithinDuration(t, before.Add(24*time.Hour), *state.RevertAt, time.Minute)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, levelRequest(http.MethodPut, adminToken, `{"level":"info"}`))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "revert_at", "configured level cancels the revert")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, levelRequest(http.MethodPost, adminToken, ""))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, "GET, PUT", rr.Header().Get("Allow"))
+}
+
+// TestUnitLevelController_Revert tests that a changed level reverts after its TTL.
+func TestUnitLevelController_Revert(t *testing.T) {
+	level := newLevelController(t)
+
+	level.SetLevel(zapcore.DebugLevel, 10*time.Millisecond)
+	assert.Equal(t, zapcore.DebugLevel, level.Level())
+	assert.Eventually(t, func() bool { return level.Level() == zapcore.InfoLevel }, time.Second, time.Millisecond)
+}
+
+// TestUnitLevelController_RevertRace tests that the revert of a replaced change, even one
+// whose timer already fired, does not undo the newer change.
+func TestUnitLevelController_RevertRace(t *testing.T) {
+	level := newLevelController(t)
+
+	for range 50 {
+		level.SetLevel(zapcore.DebugLevel, time.Microsecond)
+		level.SetLevel(zapcore.WarnLevel, time.Hour)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, zapcore.WarnLevel, level.Level())
+
+	level.SetLevel(zapcore.InfoLevel, 0)
+}
@@ -2,6 +2,7 @@
 package logging
 
 import (
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -11,10 +12,15 @@ type Config struct {
 	Service string // "auth-service"
 	Env     string // "dev" / "prod"
 	Level   string // "debug" / "info" / "warn" / "error"
+	// Redaction scrubs the fields of every entry; its zero value masks the credentials
+	// in redact.Always.
+	Redaction redact.Policy
 }
 
-// New creates a new logger.
-func New(cfg Config) (*zap.Logger, error) {
+// New creates a new logger and the controller of its level.
+// The level can be changed at runtime through the controller; loggers derived with
+// ForceDebug log at debug level whatever the controller says.
+func New(cfg Config) (*zap.Logger, *LevelController, error) {
 	var zcfg zap.Config
 
 	if cfg.Env == "prod" {
@@ -26,17 +32,25 @@ func New(cfg Config) (*zap.Logger, error) {
 	lvl := zapcore.InfoLevel
 	_ = lvl.Set(cfg.Level) // if invalid, stays Info
 
-	zcfg.Level = zap.NewAtomicLevelAt(lvl)
+	level := newLevelController(lvl)
+
+	// The core itself accepts everything; the gate in front of it applies the level,
+	// so a forced-debug logger can bypass it.
+	zcfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
 	zcfg.EncoderConfig.TimeKey = "ts"
 
-	logger, err := zcfg.Build()
+	logger, err := zcfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &gatedCore{Core: redact.Core(core, cfg.Redaction), level: level.atomic}
+	}))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Add stable service fields once
-	return logger.With(
+	logger = logger.With(
 		zap.String("service", cfg.Service),
 		zap.String("env", cfg.Env),
-	), nil
+	)
+	level.logger = logger.Named("logging")
+	return logger, level, nil
 }
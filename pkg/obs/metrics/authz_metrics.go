@@ -0,0 +1,18 @@
+// Package metrics defines the authorization metrics for the observability.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// AuthzDenialsTotal is the total number of requests denied by service-to-service authorization.
+var AuthzDenialsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "authz_denials_total",
+		Help: "Total number of requests denied by service-to-service authorization",
+	},
+	[]string{"method", "caller", "reason"},
+)
+
+// RegisterAuthz registers the authorization metrics into the provided registry.
+func RegisterAuthz(reg prometheus.Registerer) {
+	reg.MustRegister(AuthzDenialsTotal)
+}
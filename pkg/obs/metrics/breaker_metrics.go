@@ -0,0 +1,60 @@
+// Package metrics defines the circuit breaker metrics for the observability.
+package metrics
+
+import (
+	"github.com/incheat/go-production-backend/pkg/resilience"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// CircuitBreakerState is the current state of the circuit breaker (0 closed, 1 half-open, 2 open).
+	CircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "Current circuit breaker state (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"name"},
+	)
+
+	// CircuitBreakerTransitionsTotal is the total number of circuit breaker state transitions.
+	CircuitBreakerTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions",
+		},
+		[]string{"name", "from", "to"},
+	)
+
+	// CircuitBreakerRejectionsTotal is the total number of calls rejected by the circuit breaker.
+	CircuitBreakerRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_rejections_total",
+			Help: "Total number of calls rejected by the circuit breaker",
+		},
+		[]string{"name"},
+	)
+)
+
+// RegisterCircuitBreaker registers the circuit breaker metrics into the provided registry.
+func RegisterCircuitBreaker(reg prometheus.Registerer) {
+	reg.MustRegister(CircuitBreakerState, CircuitBreakerTransitionsTotal, CircuitBreakerRejectionsTotal)
+}
+
+// _ is a placeholder to ensure that BreakerObserver implements the resilience.BreakerObserver interface.
+var _ resilience.BreakerObserver = BreakerObserver{}
+
+// BreakerObserver exports circuit breaker activity as Prometheus metrics.
+type BreakerObserver struct{}
+
+// StateChanged records a state transition of the breaker.
+func (BreakerObserver) StateChanged(name string, from, to resilience.State) {
+	CircuitBreakerState.WithLabelValues(name).Set(float64(to))
+	if from != to {
+		CircuitBreakerTransitionsTotal.WithLabelValues(name, from.String(), to.String()).Inc()
+	}
+}
+
+// Rejected records a call rejected by the breaker.
+func (BreakerObserver) Rejected(name string) {
+	CircuitBreakerRejectionsTotal.WithLabelValues(name).Inc()
+}
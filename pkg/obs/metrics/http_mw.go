@@ -2,33 +2,30 @@ package metrics
 
 import (
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// PromHTTPMetrics adds Prometheus metrics to the HTTP request.
-func PromHTTPMetrics() func(http.Handler) http.Handler {
+// HTTPRoute labels the http.server.* metrics otelhttp records for the request, and its
+// span, with the chi route pattern as http.route. It must run inside otelhttp.NewHandler.
+func HTTPRoute() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-			start := time.Now()
-
-			next.ServeHTTP(ww, r)
+			next.ServeHTTP(w, r)
 
 			routePattern := chi.RouteContext(r.Context()).RoutePattern()
 			if routePattern == "" {
 				routePattern = "unknown"
 			}
 
-			status := strconv.Itoa(ww.Status())
-			method := r.Method
-			duration := time.Since(start).Seconds()
-
-			HTTPRequestsTotal.WithLabelValues(method, routePattern, status).Inc()
-			HTTPRequestDuration.WithLabelValues(method, routePattern, status).Observe(duration)
+			route := semconv.HTTPRoute(routePattern)
+			trace.SpanFromContext(r.Context()).SetAttributes(route)
+			if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+				labeler.Add(route)
+			}
 		})
 	}
 }
@@ -0,0 +1,52 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	obsmetrics "github.com/incheat/go-production-backend/pkg/obs/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// TestUnitHTTPRoute_LabelsOtelhttpMetrics tests that the semantic-convention request duration
+// recorded by otelhttp carries the chi route pattern rather than the raw path.
+func TestUnitHTTPRoute_LabelsOtelhttpMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	router := chi.NewRouter()
+	router.Use(obsmetrics.HTTPRoute())
+	router.Get("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := otelhttp.NewHandler(router, "test", otelhttp.WithMeterProvider(mp))
+
+	for _, id := range []string{"1", "2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	var points []metricdata.HistogramDataPoint[float64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "http.server.request.duration" {
+				points = m.Data.(metricdata.Histogram[float64]).DataPoints
+			}
+		}
+	}
+	require.Len(t, points, 1, "both requests share one series")
+	route, ok := points[0].Attributes.Value(semconv.HTTPRouteKey)
+	require.True(t, ok)
+	assert.Equal(t, "/users/{id}", route.AsString())
+	assert.Equal(t, uint64(2), points[0].Count)
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/config"
+	obsotel "github.com/incheat/go-production-backend/pkg/obs/otel"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+)
+
+// Shutdown is the function to shutdown the meter provider.
+type Shutdown func(context.Context) error
+
+// InitMeterProvider initializes the global OpenTelemetry meter provider created by
+// NewMeterProvider.
+func InitMeterProvider(ctx context.Context, cfg config.TelemetryConfig, reg prometheus.Registerer) (Shutdown, error) {
+	mp, err := NewMeterProvider(ctx, cfg, reg)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetMeterProvider(mp)
+
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return mp.Shutdown(ctx)
+	}, nil
+}
+
+// NewMeterProvider creates a meter provider whose instruments, such as the otelhttp and
+// otelgrpc ones, are exposed on reg through the Prometheus bridge when
+// cfg.Metrics.Prometheus, and pushed to the collector per cfg.Metrics.Exporter.
+// With cfg.Metrics.Exemplars, measurements recorded in a sampled span carry its
+// trace_id and span_id as exemplars, exposed by NewServer in the OpenMetrics format.
+func NewMeterProvider(ctx context.Context, cfg config.TelemetryConfig, reg prometheus.Registerer) (*sdkmetric.MeterProvider, error) {
+	res, err := obsotel.NewResource(ctx, obsotel.ResourceConfig{
+		ServiceName:    cfg.Resource.ServiceName,
+		ServiceVersion: cfg.Resource.ServiceVersion,
+		Environment:    cfg.Resource.Environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filter := exemplar.AlwaysOffFilter
+	if cfg.Metrics.Exemplars {
+		filter = exemplar.TraceBasedFilter
+	}
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res), sdkmetric.WithExemplarFilter(filter)}
+
+	if cfg.Metrics.Prometheus {
+		bridge, err := otelprom.New(otelprom.WithRegisterer(reg))
+		if err != nil {
+			return nil, fmt.Errorf("prometheus bridge: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(bridge))
+	}
+
+	exp, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		var readerOpts []sdkmetric.PeriodicReaderOption
+		if cfg.Metrics.ExportInterval > 0 {
+			readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.Metrics.ExportInterval))
+		}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, readerOpts...)))
+	}
+
+	return sdkmetric.NewMeterProvider(opts...), nil
+}
+
+// newExporter creates the OTLP exporter selected by cfg.Metrics.Exporter, or nil.
+func newExporter(ctx context.Context, cfg config.TelemetryConfig) (sdkmetric.Exporter, error) {
+	switch cfg.Metrics.Exporter {
+	case "", config.ExporterNone:
+		return nil, nil
+	case config.ExporterOTLPGRPC:
+		otlp, err := obsotel.NewOTLPConfig(cfg.OTLP, config.DefaultOTLPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		return obsotel.NewMetricExporter(ctx, otlp)
+	case config.ExporterOTLPHTTP:
+		otlp, err := obsotel.NewOTLPConfig(cfg.OTLP, config.DefaultOTLPHTTPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		return obsotel.NewHTTPMetricExporter(ctx, otlp)
+	default:
+		return nil, fmt.Errorf("unsupported metrics exporter %q, want otlp-grpc, otlp-http or none", cfg.Metrics.Exporter)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/obs/config"
+	obsmetrics "github.com/incheat/go-production-backend/pkg/obs/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestUnitMeterProvider_Exemplars tests that the request durations recorded in a sampled
+// span are exposed with its trace_id as exemplar when scraped as OpenMetrics, and only then.
+func TestUnitMeterProvider_Exemplars(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		exemplars bool
+		sampler   sdktrace.Sampler
+		want      bool
+	}{
+		{name: "sampled", exemplars: true, sampler: sdktrace.AlwaysSample(), want: true},
+		{name: "not sampled", exemplars: true, sampler: sdktrace.NeverSample(), want: false},
+		{name: "disabled", exemplars: false, sampler: sdktrace.AlwaysSample(), want: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := obsmetrics.NewRegistry()
+			mp, err := obsmetrics.NewMeterProvider(context.Background(), config.TelemetryConfig{
+				Metrics: config.MetricsConfig{Prometheus: true, Exemplars: tt.exemplars},
+			}, reg)
+			require.NoError(t, err)
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(tt.sampler))
+
+			var traceID string
+			handler := otelhttp.NewHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				traceID = trace.SpanContextFromContext(r.Context()).TraceID().String()
+			}), "test", otelhttp.WithMeterProvider(mp), otelhttp.WithTracerProvider(tp))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/auth/login", nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+			rec := httptest.NewRecorder()
+			obsmetrics.NewServer("", reg).Handler.ServeHTTP(rec, req)
+			body, err := io.ReadAll(rec.Body)
+			require.NoError(t, err)
+
+			assert.Contains(t, string(body), "http_server_request_duration_seconds_bucket")
+			assert.Equal(t, tt.want, strings.Contains(string(body), `trace_id="`+traceID+`"`))
+		})
+	}
+}
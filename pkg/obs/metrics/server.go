@@ -1,31 +1,22 @@
 package metrics
 
 import (
-	"context"
 	"net/http"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.uber.org/zap"
 )
 
-// StartServer starts the metrics server.
-func StartServer(addr string, reg *prometheus.Registry, logger *zap.Logger) (shutdown func(context.Context) error) {
+// NewServer creates the metrics server serving reg on /metrics. Scrapers asking for
+// OpenMetrics get the exemplars too.
+func NewServer(addr string, reg *prometheus.Registry) *http.Server {
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 
-	srv := &http.Server{
+	return &http.Server{
 		Addr:              addr,
 		Handler:           mux,
 		ReadHeaderTimeout: 2 * time.Second,
 	}
-
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("failed to start metrics server", zap.Error(err))
-		}
-	}()
-
-	return srv.Shutdown
 }
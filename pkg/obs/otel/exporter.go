@@ -3,15 +3,28 @@ package otel
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 
+	"github.com/incheat/go-production-backend/pkg/obs/config"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
 )
 
 // OTLPConfig is the configuration for the OTLP exporter.
 type OTLPConfig struct {
 	Endpoint string
 	Insecure bool
+	// TLS is used unless Insecure. Nil verifies the collector against the system roots.
+	TLS *tls.Config
 }
 
 // NewTraceExporter creates a new trace exporter.
@@ -23,7 +36,102 @@ func NewTraceExporter(ctx context.Context, cfg OTLPConfig) (*otlptrace.Exporter,
 
 	if cfg.Insecure {
 		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if cfg.TLS != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLS)))
 	}
 
 	return otlptracegrpc.New(ctx, opts...)
 }
+
+// NewHTTPTraceExporter creates a new trace exporter sending OTLP over HTTP/protobuf.
+func NewHTTPTraceExporter(ctx context.Context, cfg OTLPConfig) (*otlptrace.Exporter, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.Endpoint),
+	}
+
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if cfg.TLS != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.TLS))
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// NewWriterTraceExporter creates a trace exporter writing spans to w, one JSON object per line.
+func NewWriterTraceExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// NewFileTraceExporter creates a trace exporter appending spans to the file at path.
+// The file is closed on shutdown.
+func NewFileTraceExporter(path string) (sdktrace.SpanExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open span file: %w", err)
+	}
+	exp, err := NewWriterTraceExporter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return fileExporter{SpanExporter: exp, file: f}, nil
+}
+
+// fileExporter closes its file after the exporter shuts down.
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// TLSConfig builds the client TLS config to a collector. An empty caFile verifies
+// against the system roots; certFile and keyFile, when set, present a client certificate.
+func TLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s: no certificates found", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// NewOTLPConfig returns the exporter config of cfg, building its TLS config unless
+// cfg.Insecure. An empty cfg.Endpoint is replaced by defaultEndpoint.
+func NewOTLPConfig(cfg config.OTLPConfig, defaultEndpoint string) (OTLPConfig, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	otlp := OTLPConfig{Endpoint: endpoint, Insecure: cfg.Insecure}
+	if !cfg.Insecure {
+		t := cfg.TLS
+		tlsConfig, err := TLSConfig(t.CAFile, t.CertFile, t.KeyFile, t.ServerName)
+		if err != nil {
+			return OTLPConfig{}, fmt.Errorf("OTLP TLS: %w", err)
+		}
+		otlp.TLS = tlsConfig
+	}
+	return otlp, nil
+}
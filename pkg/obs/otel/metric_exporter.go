@@ -0,0 +1,40 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc/credentials"
+)
+
+// NewMetricExporter creates a new metric exporter sending OTLP over gRPC.
+func NewMetricExporter(ctx context.Context, cfg OTLPConfig) (sdkmetric.Exporter, error) {
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(cfg.Endpoint),
+	}
+
+	if cfg.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else if cfg.TLS != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLS)))
+	}
+
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// NewHTTPMetricExporter creates a new metric exporter sending OTLP over HTTP/protobuf.
+func NewHTTPMetricExporter(ctx context.Context, cfg OTLPConfig) (sdkmetric.Exporter, error) {
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(cfg.Endpoint),
+	}
+
+	if cfg.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if cfg.TLS != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.TLS))
+	}
+
+	return otlpmetrichttp.New(ctx, opts...)
+}
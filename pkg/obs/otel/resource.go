@@ -3,21 +3,33 @@ package otel
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 // ResourceConfig is the configuration for the resource.
 type ResourceConfig struct {
-	ServiceName string
-	// add more later (version/env/instance) if needed.
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
 }
 
-// NewResource creates a new resource.
+// NewResource creates a new resource. Attributes from OTEL_RESOURCE_ATTRIBUTES are
+// merged in, but the service name, version and environment of cfg take precedence.
 func NewResource(ctx context.Context, cfg ResourceConfig) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceName(cfg.ServiceName)}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentName(cfg.Environment))
+	}
 	return resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(cfg.ServiceName),
-		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(attrs...),
 	)
 }
@@ -0,0 +1,295 @@
+package profiling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/metrics"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CapturePath is where the profiling server triggers a capture, see Capturer.Handler.
+const CapturePath = "/debug/pprof/capture"
+
+// Default values of CaptureConfig.
+const (
+	defaultCPUDuration   = 10 * time.Second
+	defaultCooldown      = 5 * time.Minute
+	defaultCheckInterval = 10 * time.Second
+)
+
+// heapMetric is the runtime metric compared with CaptureConfig.HeapThreshold.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// captureTimeLayout names the capture directories, so they sort by time.
+const captureTimeLayout = "20060102T150405Z"
+
+var errCaptureSkipped = errors.New("a capture is running or cooling down")
+
+// CaptureConfig configures the triggered captures.
+type CaptureConfig struct {
+	// Dir receives one directory per capture, holding cpu.pprof, heap.pprof and goroutine.pprof.
+	Dir string
+	// LatencyThreshold triggers a capture when a request takes longer. Zero disables it.
+	LatencyThreshold time.Duration
+	// HeapThreshold triggers a capture when the live heap grows larger, in bytes. Zero disables it.
+	HeapThreshold uint64
+	// CheckInterval is how often the heap is checked.
+	CheckInterval time.Duration
+	// CPUDuration is how long the CPU is profiled.
+	CPUDuration time.Duration
+	// Cooldown is the minimum time between the start of two captures.
+	Cooldown time.Duration
+	// Retention is how long captures are kept. Zero keeps them.
+	Retention time.Duration
+	// MaxCaptures is how many captures are kept. Zero keeps them all.
+	MaxCaptures int
+}
+
+// Capturer records CPU, heap and goroutine profiles to CaptureConfig.Dir when triggered,
+// by a threshold or through its handler. One capture runs at a time; triggers during a
+// capture or its cooldown are ignored. The CPU profile is skipped while another one is
+// running, e.g. a Pyroscope push or a /debug/pprof/profile request.
+// ObserveLatency and Trigger do nothing on a nil Capturer.
+type Capturer struct {
+	cfg    CaptureConfig
+	logger *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+}
+
+// NewCapturer creates a Capturer and its directory.
+func NewCapturer(cfg CaptureConfig, logger *zap.Logger) (*Capturer, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("capture directory is empty")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create capture directory: %w", err)
+	}
+	cfg.CPUDuration = orDefault(cfg.CPUDuration, defaultCPUDuration)
+	cfg.Cooldown = orDefault(cfg.Cooldown, defaultCooldown)
+	cfg.CheckInterval = orDefault(cfg.CheckInterval, defaultCheckInterval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Capturer{cfg: cfg, logger: logger, ctx: ctx, cancel: cancel}, nil
+}
+
+// ObserveLatency triggers a capture if d crosses the latency threshold.
+func (c *Capturer) ObserveLatency(d time.Duration) {
+	if c == nil || c.cfg.LatencyThreshold <= 0 || d < c.cfg.LatencyThreshold {
+		return
+	}
+	_, _ = c.Trigger(fmt.Sprintf("latency %s", d.Round(time.Millisecond)))
+}
+
+// Trigger starts a capture in the background and returns the directory it writes to.
+func (c *Capturer) Trigger(reason string) (string, error) {
+	if c == nil {
+		return "", errCaptureSkipped
+	}
+	c.mu.Lock()
+	now := time.Now()
+	if c.running || (!c.last.IsZero() && now.Sub(c.last) < c.cfg.Cooldown) {
+		c.mu.Unlock()
+		return "", errCaptureSkipped
+	}
+	c.running, c.last = true, now
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	dir := filepath.Join(c.cfg.Dir, now.UTC().Format(captureTimeLayout))
+	go func() {
+		defer c.wg.Done()
+		c.capture(dir, reason)
+		c.mu.Lock()
+		c.running = false
+		c.mu.Unlock()
+	}()
+	return dir, nil
+}
+
+// Run checks the heap against the threshold until ctx is done.
+func (c *Capturer) Run(ctx context.Context) error {
+	if c.cfg.HeapThreshold == 0 {
+		<-ctx.Done()
+		return nil
+	}
+	sample := []metrics.Sample{{Name: heapMetric}}
+	ticker := time.NewTicker(c.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			metrics.Read(sample)
+			if heap := sample[0].Value.Uint64(); heap >= c.cfg.HeapThreshold {
+				_, _ = c.Trigger(fmt.Sprintf("heap %d bytes", heap))
+			}
+		}
+	}
+}
+
+// Stop cuts a running CPU profile short and waits for the capture to be written.
+func (c *Capturer) Stop(ctx context.Context) error {
+	c.cancel()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handler triggers a capture on POST, answering 202 with its directory, or 409 during
+// another capture or its cooldown.
+func (c *Capturer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dir, err := c.Trigger("manual")
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"dir": dir})
+	})
+}
+
+func (c *Capturer) capture(dir, reason string) {
+	logger := c.logger.With(zap.String("dir", dir), zap.String("reason", reason))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		logger.Error("Failed to create profile capture directory", zap.Error(err))
+		return
+	}
+
+	err := errors.Join(
+		c.writeCPU(filepath.Join(dir, "cpu.pprof")),
+		writeProfile(filepath.Join(dir, "heap.pprof"), "heap"),
+		writeProfile(filepath.Join(dir, "goroutine.pprof"), "goroutine"),
+	)
+	if err != nil {
+		logger.Warn("Profiles partially captured", zap.Error(err))
+	} else {
+		logger.Info("Profiles captured")
+	}
+
+	if err := c.prune(); err != nil {
+		logger.Warn("Failed to prune profile captures", zap.Error(err))
+	}
+}
+
+func (c *Capturer) writeCPU(path string) (err error) {
+	f, err := os.Create(path) //nolint:gosec // path is built from the configured directory
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("cpu profile: %w", err)
+	}
+	timer := time.NewTimer(c.cfg.CPUDuration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.ctx.Done():
+	}
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func writeProfile(path, name string) (err error) {
+	f, err := os.Create(path) //nolint:gosec // path is built from the configured directory
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		return fmt.Errorf("%s profile: %w", name, err)
+	}
+	return nil
+}
+
+// prune removes the captures older than the retention or beyond the maximum count.
+func (c *Capturer) prune() error {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	var captures []string
+	for _, e := range entries {
+		if _, err := time.Parse(captureTimeLayout, e.Name()); e.IsDir() && err == nil {
+			captures = append(captures, e.Name())
+		}
+	}
+	slices.Sort(captures)
+	slices.Reverse(captures) // newest first
+
+	var errs []error
+	for i, name := range captures {
+		taken, _ := time.Parse(captureTimeLayout, name)
+		expired := c.cfg.Retention > 0 && time.Since(taken) > c.cfg.Retention
+		if expired || (c.cfg.MaxCaptures > 0 && i >= c.cfg.MaxCaptures) {
+			errs = append(errs, os.RemoveAll(filepath.Join(c.cfg.Dir, name)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// String describes the thresholds, for logs.
+func (c CaptureConfig) String() string {
+	var parts []string
+	if c.LatencyThreshold > 0 {
+		parts = append(parts, "latency>="+c.LatencyThreshold.String())
+	}
+	if c.HeapThreshold > 0 {
+		parts = append(parts, fmt.Sprintf("heap>=%dB", c.HeapThreshold))
+	}
+	if len(parts) == 0 {
+		return "manual only"
+	}
+	return strings.Join(parts, ",")
+}
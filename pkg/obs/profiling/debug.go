@@ -2,33 +2,76 @@
 package profiling
 
 import (
-	"context"
+	"crypto/tls"
 	"net/http"
 	"time"
 
-	//nolint:gosec // pprof is used for debugging
-	_ "net/http/pprof"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
 
-	"go.uber.org/zap"
+	//nolint:gosec // pprof is used for debugging, behind authentication
+	_ "net/http/pprof"
 )
 
-// StartServer starts the profiling server.
-func StartServer(_ context.Context, addr string, logger *zap.Logger) (shutdown func(context.Context) error) {
+// Option configures the profiling server.
+type Option func(*serverOptions)
 
-	mux := http.NewServeMux()
-	mux.Handle("/debug/pprof/", http.DefaultServeMux)
+type serverOptions struct {
+	mux   *http.ServeMux
+	token string
+	tls   *tls.Config
+}
+
+// WithHandler serves handler on pattern next to pprof, e.g. an admin endpoint.
+func WithHandler(pattern string, handler http.Handler) Option {
+	return func(o *serverOptions) {
+		o.mux.Handle(pattern, handler)
+	}
+}
+
+// WithToken lets requests in with token as bearer token.
+func WithToken(token string) Option {
+	return func(o *serverOptions) {
+		o.token = token
+	}
+}
 
-	srv := &http.Server{
+// WithTLS serves over TLS with config, which should require and verify client
+// certificates, e.g. mtls.Reloader.ServerConfig. Requests with a verified client
+// certificate need no token.
+func WithTLS(config *tls.Config) Option {
+	return func(o *serverOptions) {
+		o.tls = config
+	}
+}
+
+// NewServer creates the profiling server serving /debug/pprof/. Every request must be
+// authenticated with a verified client certificate or the bearer token; without
+// either configured, /debug/pprof/ is not served.
+func NewServer(addr string, opts ...Option) *http.Server {
+	o := &serverOptions{mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.token != "" || o.tls != nil {
+		o.mux.Handle("/debug/pprof/", http.DefaultServeMux)
+	}
+
+	return &http.Server{
 		Addr:              addr,
-		Handler:           mux,
+		Handler:           authenticate(o.mux, o.token),
+		TLSConfig:         o.tls,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("failed to start profiling server", zap.Error(err))
+// authenticate lets requests with a verified client certificate or token through.
+func authenticate(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) || svcauth.MatchToken(r, token) {
+			next.ServeHTTP(w, r)
+			return
 		}
-	}()
-
-	return srv.Shutdown
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
 }
@@ -0,0 +1,82 @@
+package profiling
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+)
+
+// Profile labels set on the goroutines serving a request, so CPU and goroutine profiles
+// can be broken down by route.
+const (
+	LabelRoute  = "route"  // chi route pattern, or gRPC full method
+	LabelMethod = "method" // HTTP method; POST for gRPC
+	LabelTenant = "tenant" // tenant.id baggage, if any
+)
+
+// HTTPMiddleware runs each request with the pprof labels of its chi route, and reports
+// its duration to capturer, which may be nil. It must run inside a chi router, after
+// the baggage is extracted.
+func HTTPMiddleware(capturer *Capturer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			pprof.Do(r.Context(), labels(r.Context(), httpRoute(r), r.Method), func(ctx context.Context) {
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+			capturer.ObserveLatency(time.Since(start))
+		})
+	}
+}
+
+// GRPCInterceptor is HTTPMiddleware for RPCs, labeled with their full method. Only
+// unary RPCs report their duration: a stream lasts as long as its client wants.
+func GRPCInterceptor(capturer *Capturer) grpcchain.ServerInterceptor {
+	return grpcchain.ServerInterceptor{
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+			start := time.Now()
+			pprof.Do(ctx, labels(ctx, info.FullMethod, http.MethodPost), func(ctx context.Context) {
+				resp, err = handler(ctx, req)
+			})
+			capturer.ObserveLatency(time.Since(start))
+			return resp, err
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			pprof.Do(ss.Context(), labels(ss.Context(), info.FullMethod, http.MethodPost), func(ctx context.Context) {
+				err = handler(srv, grpcchain.ServerStreamWithContext(ctx, ss))
+			})
+			return err
+		},
+	}
+}
+
+func labels(ctx context.Context, route, method string) pprof.LabelSet {
+	tenant := baggage.FromContext(ctx).Member(string(correlation.BaggageTenantID)).Value()
+	if tenant == "" {
+		return pprof.Labels(LabelRoute, route, LabelMethod, method)
+	}
+	return pprof.Labels(LabelRoute, route, LabelMethod, method, LabelTenant, tenant)
+}
+
+// httpRoute looks up the chi route pattern r will be routed to.
+func httpRoute(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return "unknown"
+	}
+	path := rctx.RoutePath
+	if path == "" {
+		path = r.URL.Path
+	}
+	if route := rctx.Routes.Find(chi.NewRouteContext(), r.Method, path); route != "" {
+		return route
+	}
+	return "unknown"
+}
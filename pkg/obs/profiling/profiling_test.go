@@ -0,0 +1,97 @@
+package profiling_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/incheat/go-production-backend/pkg/obs/profiling"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestUnitNewServer_RequiresToken tests that the profiling listener answers only
+// requests with the bearer token, and serves no pprof without one configured.
+func TestUnitNewServer_RequiresToken(t *testing.T) {
+	get := func(srv *http.Server, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	srv := profiling.NewServer("", profiling.WithToken("s3cret-admin-token"))
+	assert.Equal(t, http.StatusUnauthorized, get(srv, ""))
+	assert.Equal(t, http.StatusUnauthorized, get(srv, "wrong"))
+	assert.Equal(t, http.StatusOK, get(srv, "s3cret-admin-token"))
+
+	open := profiling.NewServer("")
+	assert.Equal(t, http.StatusUnauthorized, get(open, ""))
+}
+
+// TestUnitHTTPMiddleware_SetsLabels tests that handlers run with the route, method and
+// tenant pprof labels.
+func TestUnitHTTPMiddleware_SetsLabels(t *testing.T) {
+	got := map[string]string{}
+	router := chi.NewRouter()
+	router.Use(profiling.HTTPMiddleware(nil))
+	router.Get("/v1/users/{id}", func(_ http.ResponseWriter, r *http.Request) {
+		pprof.ForLabels(r.Context(), func(key, value string) bool {
+			got[key] = value
+			return true
+		})
+	})
+
+	ctx, err := correlation.SetBaggage(context.Background(), correlation.BaggageTenantID, "acme")
+	require.NoError(t, err)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users/42", nil).WithContext(ctx))
+
+	assert.Equal(t, map[string]string{
+		profiling.LabelRoute:  "/v1/users/{id}",
+		profiling.LabelMethod: http.MethodGet,
+		profiling.LabelTenant: "acme",
+	}, got)
+}
+
+// TestUnitCapturer_CapturesOnLatency tests that a slow request captures the profiles once
+// per cooldown, and that expired captures are pruned.
+func TestUnitCapturer_CapturesOnLatency(t *testing.T) {
+	dir := t.TempDir()
+	expired := filepath.Join(dir, "20200101T000000Z")
+	require.NoError(t, os.Mkdir(expired, 0o750))
+
+	capturer, err := profiling.NewCapturer(profiling.CaptureConfig{
+		Dir:              dir,
+		LatencyThreshold: 100 * time.Millisecond,
+		CPUDuration:      50 * time.Millisecond,
+		Retention:        time.Hour,
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	capturer.ObserveLatency(10 * time.Millisecond) // below the threshold
+	capturer.ObserveLatency(time.Second)
+	_, err = capturer.Trigger("manual") // running, then cooling down
+	assert.Error(t, err)
+	require.NoError(t, capturer.Stop(context.Background()))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	for _, name := range []string{"cpu.pprof", "heap.pprof", "goroutine.pprof"} {
+		info, err := os.Stat(filepath.Join(dir, entries[0].Name(), name))
+		require.NoError(t, err, name)
+		assert.Positive(t, info.Size(), name)
+	}
+	assert.NoDirExists(t, expired)
+}
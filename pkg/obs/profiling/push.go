@@ -0,0 +1,61 @@
+package profiling
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/pyroscope-go"
+	"go.uber.org/zap"
+)
+
+// PushConfig configures the continuous push of profiles to a Pyroscope-compatible server.
+type PushConfig struct {
+	// ServerAddress is the server URL, e.g. http://pyroscope:4040. Empty disables the push.
+	ServerAddress string
+	// ApplicationName is the service name profiles are stored under.
+	ApplicationName string
+	// Tags are added to every profile, e.g. env and version. The pprof labels set by
+	// HTTPMiddleware and GRPCInterceptor are pushed as tags too.
+	Tags map[string]string
+	// AuthToken is sent as bearer token, if not empty.
+	AuthToken string
+	// TenantID is sent as X-Scope-OrgID to multi-tenant servers, if not empty.
+	TenantID string
+	// UploadRate is how often profiles are pushed. Zero uses the client default.
+	UploadRate time.Duration
+}
+
+// Shutdown is the function to stop the push.
+type Shutdown func(context.Context) error
+
+// StartPush starts pushing CPU, memory and goroutine profiles. The CPU profiler is busy
+// while pushing, so the CPU profile of Capturer and /debug/pprof/profile fail meanwhile.
+func StartPush(cfg PushConfig, logger *zap.Logger) (Shutdown, error) {
+	if cfg.ServerAddress == "" {
+		return nil, errors.New("pyroscope server address is empty")
+	}
+	profiler, err := pyroscope.Start(pyroscope.Config{
+		ApplicationName: cfg.ApplicationName,
+		ServerAddress:   cfg.ServerAddress,
+		Tags:            cfg.Tags,
+		AuthToken:       cfg.AuthToken,
+		TenantID:        cfg.TenantID,
+		UploadRate:      cfg.UploadRate,
+		Logger:          logger.Sugar(),
+		ProfileTypes: []pyroscope.ProfileType{
+			pyroscope.ProfileCPU,
+			pyroscope.ProfileAllocObjects,
+			pyroscope.ProfileAllocSpace,
+			pyroscope.ProfileInuseObjects,
+			pyroscope.ProfileInuseSpace,
+			pyroscope.ProfileGoroutines,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(context.Context) error {
+		return profiler.Stop()
+	}, nil
+}
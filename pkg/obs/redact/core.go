@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Core wraps core so the fields the policy matches, including those added with
+// With, reach it redacted. Values that are not strings are hashed from their
+// formatted value.
+func Core(core zapcore.Core, p Policy) zapcore.Core {
+	return &redactingCore{Core: core, policy: p}
+}
+
+type redactingCore struct {
+	zapcore.Core
+	policy Policy
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.redact(fields)), policy: c.policy}
+}
+
+// Check adds c rather than the wrapped core, so Write goes through c.
+func (c *redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.redact(fields))
+}
+
+// redact returns fields with the matching ones replaced, copying only when needed.
+func (c *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		action, ok := c.policy.Action(f.Key)
+		if !ok {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		out = append(out, zap.String(f.Key, action.Apply(fieldValue(f))))
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// fieldValue formats the value of f.
+func fieldValue(f zapcore.Field) string {
+	if f.Type == zapcore.StringType {
+		return f.String
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return fmt.Sprint(enc.Fields[f.Key])
+}
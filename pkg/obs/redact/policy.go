@@ -0,0 +1,155 @@
+// Package redact keeps personal data and secrets out of logs and traces.
+//
+// A Policy names the log fields and span attributes to scrub and how: masked values
+// are replaced by Mask, hashed values by a digest that still correlates equal values.
+// The credential names in Always are masked whatever the policy says.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Mask replaces masked values.
+const Mask = "[REDACTED]"
+
+// Action is what a Policy does with a matching value.
+type Action string
+
+const (
+	// ActionMask replaces the value with Mask.
+	ActionMask Action = "mask"
+	// ActionHash replaces the value with "sha256:" and the first 16 hex digits of its
+	// SHA-256, so equal values can still be correlated. It is not anonymization:
+	// low-entropy values such as emails can be guessed back.
+	ActionHash Action = "hash"
+)
+
+// Always lists the keys masked by every Policy, the zero Policy included.
+var Always = []string{
+	"password",
+	"token",
+	"access_token",
+	"refresh_token",
+	"authorization",
+	"cookie",
+	"set-cookie",
+}
+
+// Policy maps keys to actions. Keys are matched case-insensitively against the whole
+// field name or attribute key, or its last dot-separated segment, so "authorization"
+// also covers "http.request.header.authorization".
+type Policy struct {
+	rules map[string]Action
+}
+
+// ParsePolicy parses "key=action" rules separated by ";", e.g. "email=hash;client_ip=mask".
+// A rule cannot weaken the masking of the keys in Always.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{rules: map[string]Action{}}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, action, ok := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return Policy{}, fmt.Errorf("invalid redaction rule %q, want key=action", entry)
+		}
+		switch a := Action(strings.ToLower(strings.TrimSpace(action))); a {
+		case ActionMask, ActionHash:
+			p.rules[key] = a
+		default:
+			return Policy{}, fmt.Errorf("redaction rule %q: action must be %q or %q", key, ActionMask, ActionHash)
+		}
+	}
+	return p, nil
+}
+
+// MustParsePolicy is ParsePolicy panicking on invalid rules.
+func MustParsePolicy(s string) Policy {
+	p, err := ParsePolicy(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// UnmarshalText parses rules in the ParsePolicy format, so a Policy can be loaded from config.
+func (p *Policy) UnmarshalText(text []byte) error {
+	policy, err := ParsePolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// MarshalText formats the rules in the ParsePolicy format, sorted by key.
+func (p Policy) MarshalText() ([]byte, error) {
+	keys := make([]string, 0, len(p.rules))
+	for k := range p.rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	rules := make([]string, len(keys))
+	for i, k := range keys {
+		rules[i] = k + "=" + string(p.rules[k])
+	}
+	return []byte(strings.Join(rules, ";")), nil
+}
+
+// Action returns the action for key, and false if key is not redacted.
+func (p Policy) Action(key string) (Action, bool) {
+	key = strings.ToLower(key)
+	if a, ok := p.lookup(key); ok {
+		return a, true
+	}
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return p.lookup(key[i+1:])
+	}
+	return "", false
+}
+
+func (p Policy) lookup(key string) (Action, bool) {
+	for _, k := range Always {
+		if k == key {
+			return ActionMask, true
+		}
+	}
+	a, ok := p.rules[key]
+	return a, ok
+}
+
+// Apply returns value with action applied.
+func (a Action) Apply(value string) string {
+	if a == ActionHash {
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return Mask
+}
+
+// Redacted is a string that prints, logs and marshals to JSON as Mask, for model fields
+// holding personal data. Reveal returns the value itself.
+type Redacted string
+
+// String returns Mask.
+func (Redacted) String() string { return Mask }
+
+// GoString returns Mask, for %#v.
+func (Redacted) GoString() string { return Mask }
+
+// MarshalJSON encodes Mask, so structs logged with zap.Any don't leak the value.
+// Storage must copy the value out with Reveal.
+func (Redacted) MarshalJSON() ([]byte, error) { return []byte(`"` + Mask + `"`), nil }
+
+// MarshalText returns Mask.
+func (Redacted) MarshalText() ([]byte, error) { return []byte(Mask), nil }
+
+// Reveal returns the value.
+func (r Redacted) Reveal() string { return string(r) }
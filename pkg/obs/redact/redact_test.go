@@ -0,0 +1,113 @@
+package redact_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	obsotel "github.com/incheat/go-production-backend/pkg/obs/otel"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	password = "hunter2-correct-horse"
+	email    = "alice@example.com"
+)
+
+type session struct {
+	MemberID  string
+	IPAddress redact.Redacted
+	Password  redact.Redacted
+}
+
+// TestUnitCore_PasswordsNeverReachTheSink tests that passwords logged as fields, With
+// fields, Redacted struct fields, formatted values or header fields never reach the
+// encoded output, and that hashed fields still correlate.
+func TestUnitCore_PasswordsNeverReachTheSink(t *testing.T) {
+	var buf bytes.Buffer
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	policy := redact.MustParsePolicy("email=hash")
+	logger := zap.New(redact.Core(zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel), policy))
+
+	logger.With(zap.String("password", password)).Info("with")
+	logger.Info("fields",
+		zap.String("Password", password),
+		zap.ByteString("token", []byte(password)),
+		zap.Any("session", session{MemberID: "m-1", IPAddress: "203.0.113.10", Password: password}),
+		zap.Stringer("secret", redact.Redacted(password)),
+		zap.String("http.request.header.authorization", "Bearer "+password),
+		zap.Strings("set-cookie", []string{"refresh_token=" + password}),
+		zap.String("email", email),
+	)
+	logger.Info(fmt.Sprintf("%v %+v %#v", redact.Redacted(password), session{Password: password}, redact.Redacted(password)))
+	require.NoError(t, logger.Sync())
+
+	out := buf.String()
+	assert.NotContains(t, out, password)
+	assert.NotContains(t, out, "203.0.113.10")
+	assert.NotContains(t, out, email)
+	assert.Contains(t, out, `"email":"`+redact.ActionHash.Apply(email)+`"`)
+	assert.Contains(t, out, `"password":"`+redact.Mask+`"`)
+}
+
+// TestUnitSpanProcessor_PasswordsNeverReachTheExporter tests that span and event
+// attributes matching the policy are redacted before the exporter sees them.
+func TestUnitSpanProcessor_PasswordsNeverReachTheExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp, err := obsotel.NewWriterTraceExporter(&buf)
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		redact.SpanProcessor(sdktrace.NewSimpleSpanProcessor(exp), redact.MustParsePolicy("user.email=hash")),
+	))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "auth.login", trace.WithAttributes(
+		attribute.String("user.email", email),
+		attribute.String("password", password),
+		attribute.StringSlice("http.request.header.authorization", []string{"Bearer " + password}),
+		attribute.String("http.route", "/v1/auth/login"),
+	))
+	span.AddEvent("retry", trace.WithAttributes(attribute.String("refresh_token", password)))
+	span.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	out := buf.String()
+	assert.NotContains(t, out, password)
+	assert.NotContains(t, out, email)
+	assert.Contains(t, out, redact.ActionHash.Apply(email))
+	assert.Contains(t, out, "/v1/auth/login")
+}
+
+// TestUnitParsePolicy tests the rule format and that credentials are masked whatever
+// the rules say.
+func TestUnitParsePolicy(t *testing.T) {
+	p, err := redact.ParsePolicy(" email=HASH ; client_ip=mask;password=hash")
+	require.NoError(t, err)
+
+	for key, want := range map[string]redact.Action{
+		"email":            redact.ActionHash,
+		"user.Email":       redact.ActionHash,
+		"client_ip":        redact.ActionMask,
+		"password":         redact.ActionMask,
+		"Set-Cookie":       redact.ActionMask,
+		"zero.policy.auth": "",
+	} {
+		got, _ := p.Action(key)
+		assert.Equal(t, want, got, key)
+	}
+	got, ok := redact.Policy{}.Action("Authorization")
+	assert.True(t, ok)
+	assert.Equal(t, redact.ActionMask, got)
+
+	for _, bad := range []string{"email", "=hash", "email=drop"} {
+		_, err := redact.ParsePolicy(bad)
+		assert.Error(t, err, bad)
+	}
+}
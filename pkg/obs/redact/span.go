@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanProcessor wraps next so the span and event attributes the policy matches reach
+// it redacted when the span ends.
+func SpanProcessor(next sdktrace.SpanProcessor, p Policy) sdktrace.SpanProcessor {
+	return spanProcessor{next: next, policy: p}
+}
+
+type spanProcessor struct {
+	next   sdktrace.SpanProcessor
+	policy Policy
+}
+
+func (p spanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p spanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(redactedSpan{ReadOnlySpan: s, policy: p.policy})
+}
+
+func (p spanProcessor) Shutdown(ctx context.Context) error { return p.next.Shutdown(ctx) }
+
+func (p spanProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
+
+// redactedSpan is an ended span whose attributes are redacted.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	policy Policy
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.policy.attributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	out := make([]sdktrace.Event, len(events))
+	for i, e := range events {
+		e.Attributes = s.policy.attributes(e.Attributes)
+		out[i] = e
+	}
+	return out
+}
+
+// attributes returns attrs with the matching ones replaced, copying only when needed.
+func (p Policy) attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		action, ok := p.Action(string(kv.Key))
+		if !ok {
+			if out != nil {
+				out = append(out, kv)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]attribute.KeyValue, 0, len(attrs)), attrs[:i]...)
+		}
+		out = append(out, kv.Key.String(action.Apply(kv.Value.Emit())))
+	}
+	if out == nil {
+		return attrs
+	}
+	return out
+}
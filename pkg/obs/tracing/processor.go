@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errorProcessor hands sampled spans to next, and also the spans that are only
+// recorded but ended with an error, marked as sampled so next exports them.
+// Such a span is exported without its unsampled parent and siblings.
+type errorProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+func (p errorProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnStart(ctx, s)
+	}
+}
+
+func (p errorProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	switch {
+	case s.SpanContext().IsSampled():
+		p.next.OnEnd(s)
+	case s.Status().Code == codes.Error:
+		p.next.OnEnd(sampledSpan{s})
+	}
+}
+
+func (p errorProcessor) Shutdown(ctx context.Context) error { return p.next.Shutdown(ctx) }
+
+func (p errorProcessor) ForceFlush(ctx context.Context) error { return p.next.ForceFlush(ctx) }
+
+// sampledSpan is a recorded span whose span context reports it as sampled.
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/incheat/go-production-backend/pkg/obs/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewSampler returns the sampler of cfg. Spans with a parent follow the parent's
+// decision; root spans are sampled at the ratio of the first rule matching their
+// route, or at cfg.SamplingRatio.
+//
+// With cfg.SampleErrors, spans that are not sampled are still recorded, so that
+// NewTracerProvider can export those ending with an error.
+func NewSampler(cfg config.TracingConfig) sdktrace.Sampler {
+	root := ruleSampler{
+		rules:    cfg.Rules,
+		samplers: make([]sdktrace.Sampler, len(cfg.Rules)),
+		fallback: sdktrace.TraceIDRatioBased(cfg.SamplingRatio),
+		record:   cfg.SampleErrors,
+	}
+	for i, rule := range cfg.Rules {
+		root.samplers[i] = sdktrace.TraceIDRatioBased(rule.Ratio)
+	}
+	if !cfg.SampleErrors {
+		return sdktrace.ParentBased(root)
+	}
+	return sdktrace.ParentBased(root,
+		sdktrace.WithRemoteParentNotSampled(recordOnly{}),
+		sdktrace.WithLocalParentNotSampled(recordOnly{}),
+	)
+}
+
+// ruleSampler samples root spans at the ratio of the first rule matching their route.
+type ruleSampler struct {
+	rules    config.SamplingRules
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+	// record turns drop decisions into record-only ones.
+	record bool
+}
+
+func (s ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	sampler := s.fallback
+	route := spanRoute(p)
+	for i, rule := range s.rules {
+		if rule.Matches(route) {
+			sampler = s.samplers[i]
+			break
+		}
+	}
+	res := sampler.ShouldSample(p)
+	if s.record && res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s ruleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{rules=%d,fallback=%s}", len(s.rules), s.fallback.Description())
+}
+
+// spanRoute returns the HTTP path of a span started by otelhttp, or the gRPC method
+// of one started by otelgrpc, whose span name is the method without the leading "/".
+func spanRoute(p sdktrace.SamplingParameters) string {
+	for _, attr := range p.Attributes {
+		if attr.Key == semconv.URLPathKey && attr.Value.Type() == attribute.STRING {
+			return attr.Value.AsString()
+		}
+	}
+	return "/" + strings.TrimPrefix(p.Name, "/")
+}
+
+// recordOnly records spans without sampling them.
+type recordOnly struct{}
+
+func (recordOnly) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordOnly,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (recordOnly) Description() string { return "RecordOnly" }
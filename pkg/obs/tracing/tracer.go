@@ -3,14 +3,18 @@ package tracing
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/incheat/go-production-backend/pkg/obs/config"
 	obsotel "github.com/incheat/go-production-backend/pkg/obs/otel"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
 
 	"go.opentelemetry.io/contrib/propagators/b3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
@@ -19,39 +23,25 @@ type Shutdown func(context.Context) error
 
 // InitTracer initializes the tracer.
 func InitTracer(ctx context.Context, cfg config.TelemetryConfig) (Shutdown, error) {
-	// endpoint default
-	endpoint := cfg.OTLP.Endpoint
-	if endpoint == "" {
-		// Prefer shared default:
-		endpoint = config.DefaultOTLPEndpoint
-	}
-
-	exp, err := obsotel.NewTraceExporter(ctx, obsotel.OTLPConfig{
-		Endpoint: endpoint,
-		Insecure: cfg.OTLP.Insecure,
-	})
+	exp, err := NewExporter(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	res, err := obsotel.NewResource(ctx, obsotel.ResourceConfig{
-		ServiceName: cfg.Resource.ServiceName,
+		ServiceName:    cfg.Resource.ServiceName,
+		ServiceVersion: cfg.Resource.ServiceVersion,
+		Environment:    cfg.Resource.Environment,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// sampler
-	sampler := sdktrace.ParentBased(sdktrace.AlwaysSample())
-	// if cfg.Tracing.SamplingRatio < 1.0 && cfg.Tracing.SamplingRatio >= 0.0{
-	// 	sampler = sdktrace.TraceIDRatioBased(cfg.Tracing.SamplingRatio)
-	// }
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(res),
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithSampler(sampler),
-	)
+	var processor sdktrace.SpanProcessor
+	if exp != nil {
+		processor = redact.SpanProcessor(sdktrace.NewBatchSpanProcessor(exp), cfg.Redaction)
+	}
+	tp := NewTracerProvider(cfg.Tracing, res, processor)
 
 	otel.SetTracerProvider(tp)
 
@@ -70,3 +60,52 @@ func InitTracer(ctx context.Context, cfg config.TelemetryConfig) (Shutdown, erro
 		return tp.Shutdown(ctx)
 	}, nil
 }
+
+// NewTracerProvider creates a tracer provider sampling with NewSampler(cfg) and handing
+// spans to processor. With cfg.SampleErrors, processor also gets the unsampled spans
+// that ended with an error. A nil processor exports nothing.
+func NewTracerProvider(cfg config.TracingConfig, res *resource.Resource, processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(NewSampler(cfg)),
+	}
+	if processor != nil {
+		if cfg.SampleErrors {
+			processor = errorProcessor{next: processor}
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+	}
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// NewExporter creates the span exporter selected by cfg.Tracing.Exporter.
+// ExporterNone returns a nil exporter.
+func NewExporter(ctx context.Context, cfg config.TelemetryConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Tracing.Exporter {
+	case "", config.ExporterOTLPGRPC, config.ExporterOTLPHTTP:
+		// Prefer shared default:
+		endpoint := config.DefaultOTLPEndpoint
+		if cfg.Tracing.Exporter == config.ExporterOTLPHTTP {
+			endpoint = config.DefaultOTLPHTTPEndpoint
+		}
+		otlp, err := obsotel.NewOTLPConfig(cfg.OTLP, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Tracing.Exporter == config.ExporterOTLPHTTP {
+			return obsotel.NewHTTPTraceExporter(ctx, otlp)
+		}
+		return obsotel.NewTraceExporter(ctx, otlp)
+	case config.ExporterStdout:
+		return obsotel.NewWriterTraceExporter(os.Stdout)
+	case config.ExporterFile:
+		if cfg.Tracing.FilePath == "" {
+			return nil, fmt.Errorf("file exporter requires a file path")
+		}
+		return obsotel.NewFileTraceExporter(cfg.Tracing.FilePath)
+	case config.ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.Tracing.Exporter)
+	}
+}
@@ -0,0 +1,82 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestUnitTracerProvider_SamplesByRule tests that root spans are sampled at the ratio of
+// the first rule matching their route, and children follow their parent.
+func TestUnitTracerProvider_SamplesByRule(t *testing.T) {
+	rules, err := config.ParseSamplingRules("/livez=0;/user.v1.UserServiceInternal/*=1")
+	require.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	tracer := tracing.NewTracerProvider(config.TracingConfig{SamplingRatio: 0, Rules: rules}, resource.Empty(), recorder).Tracer("test")
+
+	start := func(ctx context.Context, name, path string) (context.Context, trace.Span) {
+		var opts []trace.SpanStartOption
+		if path != "" {
+			opts = append(opts, trace.WithAttributes(semconv.URLPath(path)))
+		}
+		return tracer.Start(ctx, name, opts...)
+	}
+
+	_, span := start(context.Background(), "auth.http", "/livez") // rule ratio 0
+	span.End()
+	ctx, span := start(context.Background(), "user.v1.UserServiceInternal/VerifyUserCredentials", "") // rule ratio 1
+	_, child := start(ctx, "mysql.query", "")
+	child.End()
+	span.End()
+	_, span = start(context.Background(), "auth.http", "/v1/auth/login") // no rule: ratio 0
+	span.End()
+
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	assert.Equal(t, []string{"mysql.query", "user.v1.UserServiceInternal/VerifyUserCredentials"}, names)
+}
+
+// TestUnitTracerProvider_SamplesErrors tests that spans ending with an error are exported
+// even when their trace is not sampled.
+func TestUnitTracerProvider_SamplesErrors(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := tracing.NewTracerProvider(config.TracingConfig{SamplingRatio: 0, SampleErrors: true}, resource.Empty(), recorder).Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "auth.http")
+	_, ok := tracer.Start(ctx, "redis.get")
+	ok.End()
+	_, failed := tracer.Start(ctx, "user.v1.UserServiceInternal/VerifyUserCredentials")
+	failed.SetStatus(codes.Error, "unavailable")
+	failed.End()
+	root.End()
+
+	ended := recorder.Ended()
+	require.Len(t, ended, 1)
+	assert.Equal(t, "user.v1.UserServiceInternal/VerifyUserCredentials", ended[0].Name())
+	assert.True(t, ended[0].SpanContext().IsSampled(), "exported error spans are marked sampled")
+	assert.False(t, root.SpanContext().IsSampled(), "the trace itself stays unsampled")
+}
+
+// TestUnitParseSamplingRules_RejectsInvalid tests that malformed rules fail at load time.
+func TestUnitParseSamplingRules_RejectsInvalid(t *testing.T) {
+	for _, s := range []string{"/livez", "=1", "/livez=2", "/livez=x"} {
+		_, err := config.ParseSamplingRules(s)
+		assert.Error(t, err, s)
+	}
+
+	var exporter config.Exporter
+	assert.Error(t, exporter.UnmarshalText([]byte("jaeger")))
+	require.NoError(t, exporter.UnmarshalText([]byte("OTLP-HTTP")))
+	assert.Equal(t, config.ExporterOTLPHTTP, exporter)
+}
@@ -0,0 +1,29 @@
+// Package ratelimit defines the limiter falling back to memory when the primary fails.
+package ratelimit
+
+import "context"
+
+// FallbackLimiter uses primary and switches to secondary for any call primary fails.
+type FallbackLimiter struct {
+	primary   Limiter
+	secondary Limiter
+	onError   func(error)
+}
+
+// NewFallbackLimiter creates a new FallbackLimiter. onError, if not nil, is called
+// with every error of primary.
+func NewFallbackLimiter(primary, secondary Limiter, onError func(error)) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, secondary: secondary, onError: onError}
+}
+
+// Allow implements Limiter.
+func (l *FallbackLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := l.primary.Allow(ctx, key, limit)
+	if err == nil {
+		return res, nil
+	}
+	if l.onError != nil {
+		l.onError(err)
+	}
+	return l.secondary.Allow(ctx, key, limit)
+}
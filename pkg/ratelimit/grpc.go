@@ -0,0 +1,112 @@
+// Package ratelimit defines the gRPC interceptor.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCConfig is the configuration for the gRPC interceptor.
+type GRPCConfig struct {
+	Limiter Limiter
+	// Rules are keyed by full method name, e.g. "/user.v1.UserServiceInternal/VerifyUserCredentials".
+	Rules Rules
+	// Subject returns the authenticated caller of a request, or "". Optional.
+	Subject func(ctx context.Context) string
+	// OnError is called when the limiter fails; the request is let through. Optional.
+	OnError func(ctx context.Context, err error)
+}
+
+// UnaryServerInterceptor limits requests per method and rejects requests over the limit
+// with ResourceExhausted. The same RateLimit-* and Retry-After values as the HTTP
+// middleware are sent as response header metadata.
+func UnaryServerInterceptor(cfg GRPCConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		md, err := cfg.limit(ctx, info.FullMethod)
+		if md != nil {
+			_ = grpc.SetHeader(ctx, md)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming requests. Opening a
+// stream takes one request from the limit, whatever the number of messages.
+func StreamServerInterceptor(cfg GRPCConfig) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		md, err := cfg.limit(ss.Context(), info.FullMethod)
+		if md != nil {
+			_ = ss.SetHeader(md)
+		}
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// limit takes a request to method from its limit. It returns the rate limit header
+// metadata, nil for methods without a rule or when the limiter fails, and a
+// ResourceExhausted error when the request is over the limit.
+func (cfg GRPCConfig) limit(ctx context.Context, method string) (metadata.MD, error) {
+	rule, ok := cfg.Rules[method]
+	if !ok {
+		return nil, nil
+	}
+
+	var subject string
+	if cfg.Subject != nil {
+		subject = cfg.Subject(ctx)
+	}
+	res, err := cfg.Limiter.Allow(ctx, bucketKey(method, rule, peerIP(ctx), subject), rule.Limit)
+	if err != nil {
+		if cfg.OnError != nil {
+			cfg.OnError(ctx, err)
+		}
+		return nil, nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", strconv.Itoa(seconds(res.ResetAfter)),
+	)
+	if !res.Allowed {
+		md.Set("retry-after", strconv.Itoa(max(1, seconds(res.RetryAfter))))
+		return md, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s", res.RetryAfter)
+	}
+	return md, nil
+}
+
+// peerIP returns the IP of the gRPC peer.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,178 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const limitedMethod = "/user.v1.UserServiceInternal/VerifyUserCredentials"
+
+// recordingLimiter records the bucket keys and delegates to a memory limiter.
+type recordingLimiter struct {
+	keys  []string
+	inner ratelimit.Limiter
+	err   error
+}
+
+func (l *recordingLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil {
+		return ratelimit.Result{}, l.err
+	}
+	return l.inner.Allow(ctx, key, limit)
+}
+
+// headerStream records the header metadata set by unary interceptors.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return limitedMethod }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// fakeServerStream is a server stream recording its header metadata.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5555}})
+}
+
+func newGRPCConfig(limiter ratelimit.Limiter, key ratelimit.KeyKind) ratelimit.GRPCConfig {
+	return ratelimit.GRPCConfig{
+		Limiter: limiter,
+		Rules: ratelimit.Rules{
+			limitedMethod: {Limit: ratelimit.Limit{Requests: 2, Period: time.Minute}, Key: key},
+		},
+		Subject: func(ctx context.Context) string {
+			subject, _ := ctx.Value(subjectKey{}).(string)
+			return subject
+		},
+	}
+}
+
+type subjectKey struct{}
+
+// TestUnitUnaryServerInterceptor tests that calls over the limit are rejected with
+// ResourceExhausted and the rate limit headers, and that methods without a rule are not
+// limited.
+func TestUnitUnaryServerInterceptor(t *testing.T) {
+	limiter := &recordingLimiter{inner: ratelimit.NewMemoryLimiter()}
+	interceptor := ratelimit.UnaryServerInterceptor(newGRPCConfig(limiter, ratelimit.KeyByIP))
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	call := func(method string) (*headerStream, error) {
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(peerContext("198.51.100.7"), stream)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return stream, err
+	}
+
+	for want := 1; want >= 0; want-- {
+		stream, err := call(limitedMethod)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2"}, stream.header.Get("ratelimit-limit"))
+		assert.Equal(t, []string{strconv.Itoa(want)}, stream.header.Get("ratelimit-remaining"))
+		assert.Empty(t, stream.header.Get("retry-after"))
+	}
+
+	stream, err := call(limitedMethod)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"30"}, stream.header.Get("retry-after"))
+	assert.Equal(t, []string{"60"}, stream.header.Get("ratelimit-reset"))
+
+	stream, err = call("/user.v1.UserServiceInternal/Other")
+	require.NoError(t, err)
+	assert.Empty(t, stream.header)
+
+	assert.Equal(t, []string{
+		limitedMethod + ":ip:198.51.100.7",
+		limitedMethod + ":ip:198.51.100.7",
+		limitedMethod + ":ip:198.51.100.7",
+	}, limiter.keys)
+}
+
+// TestUnitUnaryServerInterceptor_SubjectKey tests that subject rules key buckets by the
+// caller, falling back to the peer IP for unauthenticated calls.
+func TestUnitUnaryServerInterceptor_SubjectKey(t *testing.T) {
+	limiter := &recordingLimiter{inner: ratelimit.NewMemoryLimiter()}
+	interceptor := ratelimit.UnaryServerInterceptor(newGRPCConfig(limiter, ratelimit.KeyBySubject))
+	handler := func(context.Context, any) (any, error) { return nil, nil }
+	info := &grpc.UnaryServerInfo{FullMethod: limitedMethod}
+
+	_, err := interceptor(context.WithValue(peerContext("10.0.0.1"), subjectKey{}, "auth"), nil, info, handler)
+	require.NoError(t, err)
+	_, err = interceptor(peerContext("10.0.0.1"), nil, info, handler)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{limitedMethod + ":sub:auth", limitedMethod + ":ip:10.0.0.1"}, limiter.keys)
+}
+
+// TestUnitUnaryServerInterceptor_LimiterError tests that calls are let through when the
+// limiter fails.
+func TestUnitUnaryServerInterceptor_LimiterError(t *testing.T) {
+	limiter := &recordingLimiter{err: errors.New("redis down")}
+	cfg := newGRPCConfig(limiter, ratelimit.KeyByIP)
+	var reported error
+	cfg.OnError = func(_ context.Context, err error) { reported = err }
+	called := false
+
+	_, err := ratelimit.UnaryServerInterceptor(cfg)(peerContext("10.0.0.1"), nil, &grpc.UnaryServerInfo{FullMethod: limitedMethod},
+		func(context.Context, any) (any, error) {
+			called = true
+			return nil, nil
+		})
+	require.NoError(t, err)
+	assert.True(t, called)
+	assert.EqualError(t, reported, "redis down")
+}
+
+// TestUnitStreamServerInterceptor tests that opening a stream takes one request from
+// the limit.
+func TestUnitStreamServerInterceptor(t *testing.T) {
+	interceptor := ratelimit.StreamServerInterceptor(newGRPCConfig(&recordingLimiter{inner: ratelimit.NewMemoryLimiter()}, ratelimit.KeyByRoute))
+	info := &grpc.StreamServerInfo{FullMethod: limitedMethod}
+	opened := 0
+	handler := func(any, grpc.ServerStream) error {
+		opened++
+		return nil
+	}
+
+	var stream *fakeServerStream
+	var err error
+	for range 3 {
+		stream = &fakeServerStream{ctx: peerContext("10.0.0.1")}
+		err = interceptor(nil, stream, info, handler)
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 2, opened)
+	assert.Equal(t, []string{"0"}, stream.header.Get("ratelimit-remaining"))
+}
@@ -0,0 +1,75 @@
+// Package ratelimit defines the HTTP middleware.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+)
+
+// HTTPConfig is the configuration for the HTTP middleware.
+type HTTPConfig struct {
+	Limiter Limiter
+	Rules   Rules
+	// Operation resolves the operation of a request; requests without one are not limited.
+	Operation func(r *http.Request) (string, bool)
+	// ClientIP returns the client IP of a request.
+	ClientIP func(r *http.Request) string
+	// Subject returns the authenticated subject of a request, or "". Optional.
+	Subject func(r *http.Request) string
+	// OnError is called when the limiter fails; the request is let through. Optional.
+	OnError func(r *http.Request, err error)
+}
+
+// Middleware limits requests per operation. It sets the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers on limited operations, and rejects requests over the limit
+// with 429 and a Retry-After header.
+func Middleware(cfg HTTPConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			operation, ok := cfg.Operation(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rule, ok := cfg.Rules[operation]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var subject string
+			if cfg.Subject != nil {
+				subject = cfg.Subject(r)
+			}
+			res, err := cfg.Limiter.Allow(r.Context(), bucketKey(operation, rule, cfg.ClientIP(r), subject), rule.Limit)
+			if err != nil {
+				if cfg.OnError != nil {
+					cfg.OnError(r, err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.ResetAfter)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(max(1, seconds(res.RetryAfter))))
+				apierror.Write(w, r, apierror.New(apierror.CodeRateLimited, "too many requests"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,78 @@
+// Package ratelimit defines the in-memory limiter.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of calls between sweeps of full buckets.
+const sweepEvery = 1024
+
+// bucket is the state of a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// MemoryLimiter is a Limiter keeping buckets in process memory.
+// Limits are per process, so with N replicas a client may get up to N times the limit.
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// NewMemoryLimiter creates a new MemoryLimiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow implements Limiter.
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	rate := capacity / float64(limit.Period) // tokens per nanosecond
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.period = limit.Period
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+
+	res := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	res.Remaining = int(b.tokens)
+	res.ResetAfter = time.Duration(math.Ceil((capacity - b.tokens) / rate))
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely; they are equivalent to a new bucket.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= b.period {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package ratelimit defines token-bucket rate limiting shared by the HTTP and gRPC servers.
+// Buckets live in Redis, updated atomically by a Lua script, or in memory; a Fallback
+// limiter uses the in-memory buckets while Redis is unreachable.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, with bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// String returns the limit in the "requests/period" form accepted by ParseLimit.
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseLimit parses a limit of the form "10/1m".
+func ParseLimit(s string) (Limit, error) {
+	requestsRaw, periodRaw, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, want requests/period", s)
+	}
+	requests, err := strconv.Atoi(requestsRaw)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: requests must be a positive integer", s)
+	}
+	period, err := time.ParseDuration(periodRaw)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: period must be a positive duration", s)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before a request would be allowed; zero if allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Limiter takes one token from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// KeyKind is what a rule's buckets are keyed by.
+type KeyKind string
+
+const (
+	// KeyByIP gives each client IP its own bucket.
+	KeyByIP KeyKind = "ip"
+	// KeyBySubject gives each authenticated subject its own bucket; unauthenticated
+	// requests fall back to their client IP.
+	KeyBySubject KeyKind = "subject"
+	// KeyByRoute shares one bucket between all clients of the operation.
+	KeyByRoute KeyKind = "route"
+)
+
+// Rule is the limit of one operation.
+type Rule struct {
+	Limit Limit
+	Key   KeyKind
+}
+
+// Rules maps operations (OpenAPI operation IDs or full gRPC method names) to their rule.
+type Rules map[string]Rule
+
+// ParseRules parses rules of the form "Login=10/1m:ip;Logout=30/1m:subject".
+// The key kind is optional and defaults to KeyByIP.
+func ParseRules(s string) (Rules, error) {
+	rules := make(Rules)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		operation, spec, ok := strings.Cut(entry, "=")
+		operation = strings.TrimSpace(operation)
+		if !ok || operation == "" {
+			return nil, fmt.Errorf("invalid rule %q, want operation=requests/period[:key]", entry)
+		}
+		limitRaw, keyRaw, _ := strings.Cut(spec, ":")
+		limit, err := ParseLimit(limitRaw)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", operation, err)
+		}
+		key := KeyKind(strings.TrimSpace(keyRaw))
+		switch key {
+		case "":
+			key = KeyByIP
+		case KeyByIP, KeyBySubject, KeyByRoute:
+		default:
+			return nil, fmt.Errorf("rule %q: key must be %q, %q or %q", operation, KeyByIP, KeyBySubject, KeyByRoute)
+		}
+		rules[operation] = Rule{Limit: limit, Key: key}
+	}
+	return rules, nil
+}
+
+// UnmarshalText parses rules in the ParseRules format, so Rules can be loaded from config.
+func (r *Rules) UnmarshalText(text []byte) error {
+	rules, err := ParseRules(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// bucketKey builds the bucket key of a request.
+func bucketKey(operation string, rule Rule, ip, subject string) string {
+	switch rule.Key {
+	case KeyByRoute:
+		return operation
+	case KeyBySubject:
+		if subject != "" {
+			return operation + ":sub:" + subject
+		}
+	}
+	return operation + ":ip:" + ip
+}
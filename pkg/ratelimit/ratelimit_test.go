@@ -0,0 +1,51 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnitParseRules tests the rules format and its validation.
+func TestUnitParseRules(t *testing.T) {
+	rules, err := ratelimit.ParseRules(" Login=10/1m ; Logout=30/1s:subject;;/pkg.Svc/Method=5/1h:route")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Rules{
+		"Login":           {Limit: ratelimit.Limit{Requests: 10, Period: time.Minute}, Key: ratelimit.KeyByIP},
+		"Logout":          {Limit: ratelimit.Limit{Requests: 30, Period: time.Second}, Key: ratelimit.KeyBySubject},
+		"/pkg.Svc/Method": {Limit: ratelimit.Limit{Requests: 5, Period: time.Hour}, Key: ratelimit.KeyByRoute},
+	}, rules)
+
+	rules, err = ratelimit.ParseRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, invalid := range []string{
+		"Login",
+		"=10/1m",
+		"Login=10",
+		"Login=0/1m",
+		"Login=-1/1m",
+		"Login=ten/1m",
+		"Login=10/0s",
+		"Login=10/soon",
+		"Login=10/1m:user",
+	} {
+		_, err := ratelimit.ParseRules(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestUnitRules_UnmarshalText tests that rules load from config and limits print back
+// in the parsed format.
+func TestUnitRules_UnmarshalText(t *testing.T) {
+	var rules ratelimit.Rules
+	require.NoError(t, rules.UnmarshalText([]byte("Login=10/1m0s")))
+	assert.Equal(t, "10/1m0s", rules["Login"].Limit.String())
+
+	assert.Error(t, rules.UnmarshalText([]byte("Login=10")))
+	assert.Len(t, rules, 1, "unchanged on error")
+}
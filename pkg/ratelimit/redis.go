@@ -0,0 +1,74 @@
+// Package ratelimit defines the Redis limiter.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes a token atomically, using the Redis clock so that
+// all replicas agree on time.
+// KEYS[1] = bucket key
+// ARGV[1] = capacity, ARGV[2] = period in milliseconds
+// Returns {allowed, remaining, retry_after_ms, reset_after_ms}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local rate = capacity / period
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+// RedisLimiter is a Limiter keeping buckets in Redis, shared by all replicas.
+type RedisLimiter struct {
+	rdb    redis.Scripter
+	prefix string
+}
+
+// NewRedisLimiter creates a new RedisLimiter storing buckets under prefix.
+func NewRedisLimiter(rdb redis.Scripter, prefix string) *RedisLimiter {
+	return &RedisLimiter{rdb: rdb, prefix: prefix}
+}
+
+// Allow implements Limiter.
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	vals, err := tokenBucketScript.Run(ctx, l.rdb, []string{l.prefix + key},
+		limit.Requests, limit.Period.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: %w", err)
+	}
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected script result %v", vals)
+	}
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		ResetAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
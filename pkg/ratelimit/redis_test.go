@@ -0,0 +1,103 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedis starts an in-process Redis whose clock is set by the test.
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+// TestUnitRedisLimiter_TokenBucket tests that the Lua script allows a burst, refills at
+// the limit rate on the Redis clock, and expires idle buckets.
+func TestUnitRedisLimiter_TokenBucket(t *testing.T) {
+	mr, rdb := newRedis(t)
+	limiter := ratelimit.NewRedisLimiter(rdb, "test:")
+	limit := ratelimit.Limit{Requests: 3, Period: 3 * time.Second}
+	ctx := context.Background()
+
+	for want := 2; want >= 0; want-- {
+		res, err := limiter.Allow(ctx, "k", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, want, res.Remaining)
+	}
+
+	res, err := limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 3*time.Second, res.ResetAfter)
+
+	res, err = limiter.Allow(ctx, "other", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "buckets are per key")
+
+	mr.SetTime(time.Unix(1_700_000_001, 0))
+	res, err = limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "one token refilled")
+	assert.Equal(t, 0, res.Remaining)
+
+	assert.True(t, mr.Exists("test:k"))
+	mr.FastForward(limit.Period)
+	assert.False(t, mr.Exists("test:k"), "idle bucket expired")
+}
+
+// TestUnitRedisLimiter_Error tests that Redis failures are returned.
+func TestUnitRedisLimiter_Error(t *testing.T) {
+	mr, rdb := newRedis(t)
+	mr.Close()
+
+	_, err := ratelimit.NewRedisLimiter(rdb, "test:").Allow(context.Background(), "k", ratelimit.Limit{Requests: 1, Period: time.Second})
+	assert.Error(t, err)
+}
+
+// TestUnitFallbackLimiter tests that the memory limiter takes over while Redis is down
+// and Redis is used again once it is back.
+func TestUnitFallbackLimiter(t *testing.T) {
+	mr, rdb := newRedis(t)
+	var errs int
+	limiter := ratelimit.NewFallbackLimiter(
+		ratelimit.NewRedisLimiter(rdb, "test:"),
+		ratelimit.NewMemoryLimiter(),
+		func(error) { errs++ },
+	)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	res, err := limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, errs)
+
+	mr.SetError("LOADING")
+	res, err = limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed, "memory bucket is full")
+	res, err = limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed, "memory bucket enforces the limit")
+	assert.Equal(t, 2, errs)
+
+	mr.SetError("")
+	res, err = limiter.Allow(ctx, "k", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed, "Redis bucket is empty")
+	assert.Equal(t, 2, errs)
+}
@@ -0,0 +1,186 @@
+// Package resilience defines the circuit breaker and retry helpers for remote calls.
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error returned when the breaker rejects a call.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed lets every call through.
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of probe calls through.
+	StateHalfOpen
+	// StateOpen rejects every call until the open timeout elapses.
+	StateOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerObserver is notified about breaker activity, e.g. to export metrics.
+type BreakerObserver interface {
+	StateChanged(name string, from, to State)
+	Rejected(name string)
+}
+
+// BreakerConfig is the configuration for the circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing again.
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of concurrent probe calls allowed while half-open.
+	HalfOpenMaxCalls int
+	// Observer is optional.
+	Observer BreakerObserver
+}
+
+// Breaker is a consecutive-failure circuit breaker.
+type Breaker struct {
+	name string
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	inFlight int
+}
+
+// NewBreaker creates a new Breaker.
+func NewBreaker(name string, cfg BreakerConfig) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	b := &Breaker{name: name, cfg: cfg, now: time.Now}
+	if cfg.Observer != nil {
+		cfg.Observer.StateChanged(name, StateClosed, StateClosed)
+	}
+	return b
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by exactly one call to Done or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case StateOpen:
+		b.reject()
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.inFlight >= b.cfg.HalfOpenMaxCalls {
+			b.reject()
+			return ErrCircuitOpen
+		}
+	}
+	b.inFlight++
+	return nil
+}
+
+// Done records the outcome of a call allowed by Allow.
+func (b *Breaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+
+	if success {
+		b.failures = 0
+		if b.state != StateClosed {
+			b.transition(StateClosed)
+		}
+		return
+	}
+
+	b.failures++
+	switch b.state {
+	case StateHalfOpen:
+		b.trip()
+	case StateClosed:
+		if b.failures >= b.cfg.FailureThreshold {
+			b.trip()
+		}
+	}
+}
+
+// Release ends a call allowed by Allow without recording an outcome, e.g. because the
+// caller gave up on it. A released probe leaves the breaker half-open for the next one.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.inFlight > 0 {
+		b.inFlight--
+	}
+}
+
+// advance moves an open breaker to half-open once the open timeout has elapsed.
+func (b *Breaker) advance() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.transition(StateHalfOpen)
+	}
+}
+
+func (b *Breaker) trip() {
+	b.openedAt = b.now()
+	b.transition(StateOpen)
+}
+
+func (b *Breaker) transition(to State) {
+	from := b.state
+	b.state = to
+	if to == StateClosed {
+		b.failures = 0
+	}
+	if b.cfg.Observer != nil && from != to {
+		b.cfg.Observer.StateChanged(b.name, from, to)
+	}
+}
+
+func (b *Breaker) reject() {
+	if b.cfg.Observer != nil {
+		b.cfg.Observer.Rejected(b.name)
+	}
+}
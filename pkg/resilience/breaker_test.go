@@ -0,0 +1,161 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outcome is how a step ends a call the breaker allowed.
+type outcome int
+
+const (
+	succeed outcome = iota
+	fail
+	release
+	hold
+)
+
+// step advances the clock by elapse, asks the breaker for a call and ends an allowed
+// call with outcome.
+type step struct {
+	elapse    time.Duration
+	outcome   outcome
+	wantErr   error
+	wantState State
+}
+
+// recorder is a BreakerObserver recording the transitions and rejections.
+type recorder struct {
+	transitions []string
+	rejections  int
+}
+
+func (r *recorder) StateChanged(_ string, from, to State) {
+	r.transitions = append(r.transitions, from.String()+"->"+to.String())
+}
+
+func (r *recorder) Rejected(string) {
+	r.rejections++
+}
+
+// TestUnitBreaker_States tests the transitions of the breaker between closed, open and
+// half-open, and the outcome of the probes while half-open.
+func TestUnitBreaker_States(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "failures below the threshold keep it closed",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: succeed, wantState: StateClosed},
+				{outcome: fail, wantState: StateClosed},
+			},
+		},
+		{
+			name: "consecutive failures open it",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+				{elapse: 9 * time.Second, wantErr: ErrCircuitOpen, wantState: StateOpen},
+			},
+		},
+		{
+			name: "successful probe closes it",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+				{elapse: 10 * time.Second, outcome: succeed, wantState: StateClosed},
+				{outcome: fail, wantState: StateClosed},
+			},
+		},
+		{
+			name: "failed probe opens it again",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+				{elapse: 10 * time.Second, outcome: fail, wantState: StateOpen},
+				{elapse: 9 * time.Second, wantErr: ErrCircuitOpen, wantState: StateOpen},
+				{elapse: time.Second, outcome: succeed, wantState: StateClosed},
+			},
+		},
+		{
+			name: "half-open limits the concurrent probes",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+				{elapse: 10 * time.Second, outcome: hold, wantState: StateHalfOpen},
+				{wantErr: ErrCircuitOpen, wantState: StateHalfOpen},
+			},
+		},
+		{
+			name: "released probe makes room for the next one",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+				{elapse: 10 * time.Second, outcome: release, wantState: StateHalfOpen},
+				{outcome: succeed, wantState: StateClosed},
+			},
+		},
+		{
+			name: "released calls do not count",
+			steps: []step{
+				{outcome: fail, wantState: StateClosed},
+				{outcome: release, wantState: StateClosed},
+				{outcome: release, wantState: StateClosed},
+				{outcome: fail, wantState: StateOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			b := NewBreaker("test", BreakerConfig{FailureThreshold: 2, OpenTimeout: 10 * time.Second})
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.elapse)
+				err := b.Allow()
+				if s.wantErr != nil {
+					require.ErrorIs(t, err, s.wantErr, "step %d", i)
+				} else {
+					require.NoError(t, err, "step %d", i)
+					switch s.outcome {
+					case succeed:
+						b.Done(true)
+					case fail:
+						b.Done(false)
+					case release:
+						b.Release()
+					}
+				}
+				assert.Equal(t, s.wantState, b.State(), "step %d", i)
+			}
+		})
+	}
+}
+
+// TestUnitBreaker_Observer tests that the observer sees the initial state, every
+// transition and every rejected call.
+func TestUnitBreaker_Observer(t *testing.T) {
+	now := time.Unix(0, 0)
+	obs := &recorder{}
+	b := NewBreaker("test", BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, Observer: obs})
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.Allow())
+	b.Done(false)
+	require.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+	now = now.Add(time.Second)
+	require.NoError(t, b.Allow())
+	require.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+	b.Done(true)
+
+	assert.Equal(t, []string{"closed->closed", "closed->open", "open->half_open", "half_open->closed"}, obs.transitions)
+	assert.Equal(t, 2, obs.rejections)
+}
@@ -0,0 +1,57 @@
+// Package resilience defines the retry helper for remote calls.
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy is the configuration for retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt; it doubles on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff.
+	MaxDelay time.Duration
+}
+
+// Retry calls fn until it succeeds, returns an error that retryable rejects,
+// the attempts are exhausted or ctx is done. It returns the last error of fn.
+// Backoff uses "full jitter": each delay is uniformly random in [0, backoff).
+func Retry(ctx context.Context, policy RetryPolicy, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(Backoff(policy, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Backoff returns the jittered delay before retry number attempt (starting at 1).
+func Backoff(policy RetryPolicy, attempt int) time.Duration {
+	if policy.BaseDelay <= 0 {
+		return 0
+	}
+	backoff := policy.BaseDelay << (attempt - 1)
+	if policy.MaxDelay > 0 && (backoff > policy.MaxDelay || backoff <= 0) {
+		backoff = policy.MaxDelay
+	}
+	//nolint:gosec // jitter does not need a cryptographic source
+	return time.Duration(rand.Int64N(int64(backoff)))
+}
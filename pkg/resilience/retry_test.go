@@ -0,0 +1,117 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/resilience"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+// TestUnitRetry tests that Retry stops at the first success, a non-retryable error, the
+// last attempt or the end of the context, and returns the last error.
+func TestUnitRetry(t *testing.T) {
+	policy := resilience.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		policy    resilience.RetryPolicy
+		errs      []error
+		cancel    bool
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "first attempt succeeds", policy: policy, wantCalls: 1},
+		{name: "retries until success", policy: policy, errs: []error{errTransient, errTransient}, wantCalls: 3},
+		{name: "attempts exhausted", policy: policy, errs: []error{errTransient, errTransient, errTransient, errTransient}, wantErr: errTransient, wantCalls: 3},
+		{name: "non-retryable error", policy: policy, errs: []error{errTransient, errPermanent, errTransient}, wantErr: errPermanent, wantCalls: 2},
+		{name: "no attempts configured", errs: []error{errTransient}, wantErr: errTransient, wantCalls: 1},
+		{
+			name:      "context canceled during backoff",
+			policy:    resilience.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour},
+			errs:      []error{errTransient, errTransient},
+			cancel:    true,
+			wantErr:   errTransient,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errs := tt.errs
+			calls := 0
+			err := resilience.Retry(ctx, tt.policy, isTransient, func(context.Context) error {
+				calls++
+				if tt.cancel {
+					cancel()
+				}
+				if len(errs) == 0 {
+					return nil
+				}
+				err := errs[0]
+				errs = errs[1:]
+				return err
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+// TestUnitBackoff tests that the jittered backoff stays below the doubled base delay and
+// never exceeds the maximum delay.
+func TestUnitBackoff(t *testing.T) {
+	policy := resilience.RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name    string
+		policy  resilience.RetryPolicy
+		attempt int
+		wantMax time.Duration
+	}{
+		{name: "first retry", policy: policy, attempt: 1, wantMax: 100 * time.Millisecond},
+		{name: "second retry", policy: policy, attempt: 2, wantMax: 200 * time.Millisecond},
+		{name: "fourth retry", policy: policy, attempt: 4, wantMax: 800 * time.Millisecond},
+		{name: "capped", policy: policy, attempt: 5, wantMax: time.Second},
+		{name: "capped on overflow", policy: policy, attempt: 100, wantMax: time.Second},
+		{name: "no base delay", attempt: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var longest time.Duration
+			for range 1000 {
+				d := resilience.Backoff(tt.policy, tt.attempt)
+				assert.GreaterOrEqual(t, d, time.Duration(0))
+				if tt.wantMax == 0 {
+					assert.Zero(t, d)
+					continue
+				}
+				assert.Less(t, d, tt.wantMax)
+				longest = max(longest, d)
+			}
+			if tt.wantMax > 0 {
+				assert.Greater(t, longest, tt.wantMax/2, "delays are spread over the whole range")
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileProvider reads file:// references, e.g. Kubernetes secrets mounted as files.
+// A trailing newline is trimmed.
+type FileProvider struct{}
+
+// Scheme implements Provider.
+func (FileProvider) Scheme() string { return "file" }
+
+// Resolve implements Provider.
+func (FileProvider) Resolve(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvProvider reads env:// references to another env var.
+type EnvProvider struct{}
+
+// Scheme implements Provider.
+func (EnvProvider) Scheme() string { return "env" }
+
+// Static implements staticProvider: the environment does not change at runtime.
+func (EnvProvider) Static() bool { return true }
+
+// Resolve implements Provider.
+func (EnvProvider) Resolve(_ context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env %s: %w", name, ErrNotFound)
+	}
+	return v, nil
+}
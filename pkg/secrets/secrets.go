@@ -0,0 +1,105 @@
+// Package secrets resolves secret references in configuration values, such as
+// file:///run/secrets/jwt.pem, env://OTHER_VAR or vault://secret/data/auth#jwt_key,
+// and watches them for rotation.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrNotFound is returned when a reference points at nothing.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider resolves the references of one scheme.
+type Provider interface {
+	// Scheme is the reference scheme, e.g. "file" for file:///path.
+	Scheme() string
+	// Resolve returns the secret behind ref, the reference without "scheme://".
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// staticProvider is implemented by providers whose secrets cannot change at runtime.
+type staticProvider interface {
+	Static() bool
+}
+
+// Resolver resolves references with the registered providers. Values without a
+// registered scheme are literals and resolve to themselves.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver creates a new Resolver.
+func NewResolver(providers ...Provider) *Resolver {
+	r := &Resolver{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Scheme()] = p
+	}
+	return r
+}
+
+// split returns the provider and reference of value, or ok=false for a literal.
+func (r *Resolver) split(value string) (Provider, string, bool) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return nil, "", false
+	}
+	p, ok := r.providers[scheme]
+	return p, ref, ok
+}
+
+// IsReference reports whether value is a reference to a registered provider.
+func (r *Resolver) IsReference(value string) bool {
+	_, _, ok := r.split(value)
+	return ok
+}
+
+// Resolve returns the secret value references, or value itself when it is a literal.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	p, ref, ok := r.split(value)
+	if !ok {
+		return value, nil
+	}
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s reference: %w", p.Scheme(), err)
+	}
+	return secret, nil
+}
+
+// isStatic reports whether the secret behind value cannot change at runtime.
+func (r *Resolver) isStatic(value string) bool {
+	p, _, ok := r.split(value)
+	if !ok {
+		return true
+	}
+	s, ok := p.(staticProvider)
+	return ok && s.Static()
+}
+
+// Value holds a secret that may be rotated while it is read, e.g. a password read on
+// every new connection.
+type Value struct {
+	v atomic.Pointer[string]
+}
+
+// NewValue creates a Value holding secret.
+func NewValue(secret string) *Value {
+	v := &Value{}
+	v.Set(secret)
+	return v
+}
+
+// Get returns the current secret.
+func (v *Value) Get() string {
+	return *v.v.Load()
+}
+
+// Set replaces the secret.
+func (v *Value) Set(secret string) {
+	v.v.Store(&secret)
+}
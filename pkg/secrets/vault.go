@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultConfig is the configuration for the VaultProvider.
+type VaultConfig struct {
+	// Address is the Vault address, e.g. https://vault:8200.
+	Address string
+	// Token is sent as X-Vault-Token.
+	Token string
+	// Namespace is sent as X-Vault-Namespace when set (Vault Enterprise).
+	Namespace string
+}
+
+// VaultProvider reads vault:// references from a Vault-compatible HTTP API. A reference
+// is "<path>#<field>", e.g. vault://secret/data/auth#jwt_private_key for a KV v2 mount;
+// KV v1 responses are understood as well.
+type VaultProvider struct {
+	config func() VaultConfig
+	client *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider. config is called on every resolution, so the
+// provider can be registered before its own settings are loaded.
+func NewVaultProvider(config func() VaultConfig, client *http.Client) *VaultProvider {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &VaultProvider{config: config, client: client}
+}
+
+// Scheme implements Provider.
+func (p *VaultProvider) Scheme() string { return "vault" }
+
+// vaultResponse is the response of a secret read. KV v2 nests the secret in data.data.
+type vaultResponse struct {
+	Data map[string]json.RawMessage `json:"data"`
+}
+
+// Resolve implements Provider.
+func (p *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	cfg := p.config()
+	if cfg.Address == "" {
+		return "", errors.New("vault address is not configured")
+	}
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("invalid reference %q, want path#field", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", cfg.Token)
+	if cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", cfg.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%s: %w", path, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("%s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("%s: decode response: %w", path, err)
+	}
+	data := body.Data
+	if nested, ok := data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			data = kv2
+		}
+	}
+
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("%s#%s: %w", path, field, ErrNotFound)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("%s#%s: not a string", path, field)
+	}
+	return value, nil
+}
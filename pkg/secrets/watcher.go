@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultWatchInterval = 30 * time.Second
+
+// watch is a watched reference.
+type watch struct {
+	name     string
+	ref      string
+	current  string
+	onChange func(secret string) error
+}
+
+// Watcher re-resolves references periodically and reports rotated secrets.
+type Watcher struct {
+	resolver *Resolver
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	watches []*watch
+}
+
+// NewWatcher creates a new Watcher. A non-positive interval defaults to 30s.
+func NewWatcher(resolver *Resolver, interval time.Duration, logger *zap.Logger) *Watcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return &Watcher{resolver: resolver, interval: interval, logger: logger}
+}
+
+// Watch calls onChange whenever the secret behind ref differs from current, the value
+// already in use. Literals and static references are not watched. When onChange fails,
+// the old secret stays current and the change is retried on the next poll.
+func (w *Watcher) Watch(name, ref, current string, onChange func(secret string) error) {
+	if w.resolver.isStatic(ref) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watches = append(w.watches, &watch{name: name, ref: ref, current: current, onChange: onChange})
+}
+
+// Run polls the watched references until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, wt := range w.watches {
+		secret, err := w.resolver.Resolve(ctx, wt.ref)
+		if err != nil {
+			w.logger.Warn("Failed to resolve watched secret", zap.String("secret", wt.name), zap.Error(err))
+			continue
+		}
+		if secret == wt.current {
+			continue
+		}
+		if err := wt.onChange(secret); err != nil {
+			w.logger.Error("Failed to apply rotated secret", zap.String("secret", wt.name), zap.Error(err))
+			continue
+		}
+		wt.current = secret
+		w.logger.Info("Secret rotated", zap.String("secret", wt.name))
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// HTTPTimeouts are the timeouts of an HTTP server. Zero values use the defaults.
+type HTTPTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// NewHTTPServer creates an HTTP server with timeouts, so slow or idle clients cannot hold
+// connections forever.
+func NewHTTPServer(addr string, handler http.Handler, timeouts HTTPTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(timeouts.ReadHeader, 5*time.Second),
+		ReadTimeout:       orDefault(timeouts.Read, 10*time.Second),
+		WriteTimeout:      orDefault(timeouts.Write, 15*time.Second),
+		IdleTimeout:       orDefault(timeouts.Idle, 60*time.Second),
+	}
+}
+
+// HTTPServer returns the component serving srv. The listener is bound on start, so a
+// port already in use is reported as a start failure of this component. On stop the
+// server stops accepting connections and waits for in-flight requests.
+func HTTPServer(name string, srv *http.Server) Component {
+	var lis net.Listener
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			var err error
+			lis, err = net.Listen("tcp", srv.Addr)
+			return err
+		},
+		Run: func(context.Context) error {
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(lis, "", "")
+			} else {
+				err = srv.Serve(lis)
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		},
+		Stop: srv.Shutdown,
+	}
+}
+
+// GRPCServer returns the component serving srv on addr. On stop in-flight RPCs are
+// allowed to finish; when the stop deadline passes, remaining RPCs are cancelled.
+func GRPCServer(name string, srv *grpc.Server, addr string) Component {
+	var lis net.Listener
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			var err error
+			lis, err = net.Listen("tcp", addr)
+			return err
+		},
+		Run: func(context.Context) error {
+			return srv.Serve(lis)
+		},
+		Stop: func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				srv.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Closer returns a component that only closes fn on stop, e.g. a database or client.
+func Closer(name string, fn func() error) Component {
+	return Component{
+		Name: name,
+		Stop: func(context.Context) error {
+			return fn()
+		},
+	}
+}
+
+// Runner returns a component running fn until the service stops, e.g. a background worker.
+func Runner(name string, fn func(ctx context.Context) error) Component {
+	return Component{
+		Name: name,
+		Run:  fn,
+	}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,225 @@
+// Package server defines the lifecycle shared by the services: components are started in
+// registration order, run until SIGTERM/SIGINT or until one of them fails, and are
+// stopped in reverse order after a drain period during which the service reports not ready.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDrainPeriod = 5 * time.Second
+	defaultStopTimeout = 20 * time.Second
+)
+
+// Component is a part of the service with start and stop hooks. Every hook is optional.
+type Component struct {
+	Name string
+	// Start prepares the component, e.g. binds its listener. A failing Start aborts startup.
+	Start func(ctx context.Context) error
+	// Run runs the component until ctx is done or Stop is called. Returning an error stops
+	// the service.
+	Run func(ctx context.Context) error
+	// Stop releases the component. ctx carries the stop deadline. It is called after ctx of
+	// Run is done, and Run is waited for before the components registered earlier stop.
+	Stop func(ctx context.Context) error
+}
+
+// StartError is the error returned by Run when a component fails to start.
+type StartError struct {
+	Component string
+	Err       error
+}
+
+// Error implements error.
+func (e *StartError) Error() string {
+	return fmt.Sprintf("start %s: %v", e.Component, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// Config is the configuration for the Lifecycle.
+type Config struct {
+	// DrainPeriod is how long the service reports not ready before its components are
+	// stopped, so load balancers stop sending new requests first.
+	DrainPeriod time.Duration
+	// StopTimeout bounds the time all stop hooks together may take.
+	StopTimeout time.Duration
+}
+
+// Lifecycle runs the registered components of a service.
+type Lifecycle struct {
+	cfg        Config
+	logger     *zap.Logger
+	components []Component
+
+	ready     atomic.Bool
+	mu        sync.Mutex
+	listeners []func(ready bool)
+}
+
+// New creates a new Lifecycle.
+func New(cfg Config, logger *zap.Logger) *Lifecycle {
+	if cfg.DrainPeriod < 0 {
+		cfg.DrainPeriod = 0
+	} else if cfg.DrainPeriod == 0 {
+		cfg.DrainPeriod = defaultDrainPeriod
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = defaultStopTimeout
+	}
+	return &Lifecycle{cfg: cfg, logger: logger}
+}
+
+// Register adds components; they start in registration order and stop in reverse order.
+func (l *Lifecycle) Register(components ...Component) {
+	l.components = append(l.components, components...)
+}
+
+// Ready reports whether every component has started and the service is not draining.
+func (l *Lifecycle) Ready() bool {
+	return l.ready.Load()
+}
+
+// OnReadinessChange registers fn to be called whenever readiness changes.
+func (l *Lifecycle) OnReadinessChange(fn func(ready bool)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+func (l *Lifecycle) setReady(ready bool) {
+	if l.ready.Swap(ready) == ready {
+		return
+	}
+	l.mu.Lock()
+	listeners := append([]func(bool){}, l.listeners...)
+	l.mu.Unlock()
+	for _, fn := range listeners {
+		fn(ready)
+	}
+}
+
+// Run starts the components and blocks until ctx is done, SIGTERM or SIGINT is received,
+// or a component fails. It then drains, stops the started components and returns the
+// failure, if any. A component failing to start is reported as a *StartError.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	sigCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	started := 0
+	for _, c := range l.components {
+		if c.Start != nil {
+			if err := c.Start(sigCtx); err != nil {
+				startErr := &StartError{Component: c.Name, Err: err}
+				l.logger.Error("Component failed to start", zap.String("component", c.Name), zap.Error(err))
+				return errors.Join(startErr, l.stop(l.components[:started], nil))
+			}
+		}
+		started++
+		l.logger.Debug("Component started", zap.String("component", c.Name))
+	}
+
+	// Each Run gets its own context, so it can be ended in the stop order of its component.
+	runCtx := context.WithoutCancel(ctx)
+	failed := make(chan error, len(l.components))
+	runs := make([]*run, len(l.components))
+	for i, c := range l.components {
+		if c.Run == nil {
+			continue
+		}
+		r := &run{done: make(chan struct{})}
+		var cctx context.Context
+		cctx, r.cancel = context.WithCancel(runCtx)
+		runs[i] = r
+		go func() {
+			defer close(r.done)
+			if err := c.Run(cctx); err != nil && !errors.Is(err, context.Canceled) {
+				r.err = fmt.Errorf("%s: %w", c.Name, err)
+				failed <- r.err
+			}
+		}()
+	}
+
+	l.setReady(true)
+	l.logger.Info("Service started", zap.Int("components", len(l.components)))
+
+	var runErr error
+	select {
+	case <-sigCtx.Done():
+		l.logger.Info("Shutdown requested, draining", zap.Duration("drain_period", l.cfg.DrainPeriod))
+		l.setReady(false)
+		time.Sleep(l.cfg.DrainPeriod)
+	case runErr = <-failed:
+		l.setReady(false)
+		l.logger.Error("Component failed, shutting down", zap.Error(runErr))
+	}
+
+	stopErr := l.stop(l.components, runs)
+	if runErr == nil {
+		// Report a Run that failed while the components were being stopped.
+		select {
+		case runErr = <-failed:
+		default:
+		}
+	}
+	return errors.Join(runErr, stopErr)
+}
+
+// run is the Run hook of a component running in the background.
+type run struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	// err is the error Run returned, set before done is closed.
+	err error
+}
+
+// stop stops components in reverse order within the stop timeout. A component is fully
+// stopped before the ones registered earlier, which it may depend on: its Run context is
+// cancelled, its Stop hook is called, which is what ends the Run of servers, and its Run
+// is waited for. runs holds the running Run hooks by component index and may be nil.
+func (l *Lifecycle) stop(components []Component, runs []*run) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.cfg.StopTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		var r *run
+		if i < len(runs) {
+			r = runs[i]
+		}
+		if r != nil {
+			r.cancel()
+		}
+		if c.Stop != nil {
+			if err := c.Stop(ctx); err != nil {
+				l.logger.Warn("Component failed to stop", zap.String("component", c.Name), zap.Error(err))
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, err))
+			}
+		}
+		if r != nil {
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				l.logger.Warn("Component still running at the stop deadline", zap.String("component", c.Name))
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, ctx.Err()))
+			}
+		}
+		l.logger.Debug("Component stopped", zap.String("component", c.Name))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,261 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// events records the lifecycle events of fake components in order.
+type events struct {
+	mu     sync.Mutex
+	events []string
+	at     map[string]time.Time
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	if e.at == nil {
+		e.at = make(map[string]time.Time)
+	}
+	e.at[event] = time.Now()
+}
+
+func (e *events) list() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.events...)
+}
+
+// fake returns a component recording its start, run and stop in ev. startErr fails its
+// start; runErr, if not nil, is returned by its run right away.
+func fake(ev *events, name string, startErr, runErr error) server.Component {
+	return server.Component{
+		Name: name,
+		Start: func(context.Context) error {
+			ev.add("start " + name)
+			return startErr
+		},
+		Run: func(ctx context.Context) error {
+			ev.add("run " + name)
+			if runErr != nil {
+				return runErr
+			}
+			<-ctx.Done()
+			return nil
+		},
+		Stop: func(context.Context) error {
+			ev.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+// withoutRuns drops the run events, which happen concurrently.
+func withoutRuns(events []string) []string {
+	var filtered []string
+	for _, event := range events {
+		if !strings.HasPrefix(event, "run ") {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+// TestUnitLifecycle_StartError tests that a component failing to start is reported as a
+// *StartError, and only the components started before it are stopped, in reverse order.
+func TestUnitLifecycle_StartError(t *testing.T) {
+	ev := &events{}
+	lifecycle := server.New(server.Config{DrainPeriod: -1}, zap.NewNop())
+	errBind := errors.New("address already in use")
+	lifecycle.Register(
+		fake(ev, "a", nil, nil),
+		fake(ev, "b", nil, nil),
+		fake(ev, "c", errBind, nil),
+		fake(ev, "d", nil, nil),
+	)
+
+	err := lifecycle.Run(context.Background())
+
+	var startErr *server.StartError
+	require.ErrorAs(t, err, &startErr)
+	assert.Equal(t, "c", startErr.Component)
+	assert.ErrorIs(t, err, errBind)
+	assert.False(t, lifecycle.Ready())
+	assert.Equal(t, []string{"start a", "start b", "start c", "stop b", "stop a"}, ev.list())
+}
+
+// TestUnitLifecycle_DrainThenStop tests that on shutdown the service reports not ready for
+// the drain period before its components are stopped in reverse order.
+func TestUnitLifecycle_DrainThenStop(t *testing.T) {
+	const drain = 50 * time.Millisecond
+	ev := &events{}
+	lifecycle := server.New(server.Config{DrainPeriod: drain}, zap.NewNop())
+	lifecycle.OnReadinessChange(func(ready bool) { ev.add(fmt.Sprintf("ready=%t", ready)) })
+	lifecycle.Register(fake(ev, "a", nil, nil), fake(ev, "b", nil, nil), fake(ev, "c", nil, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lifecycle.Run(ctx) }()
+	require.Eventually(t, lifecycle.Ready, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{
+		"start a", "start b", "start c",
+		"ready=true", "ready=false",
+		"stop c", "stop b", "stop a",
+	}, withoutRuns(ev.list()))
+	assert.GreaterOrEqual(t, ev.at["stop c"].Sub(ev.at["ready=false"]), drain)
+}
+
+// TestUnitLifecycle_RunError tests that a failing component stops the service without
+// draining, and its error is returned.
+func TestUnitLifecycle_RunError(t *testing.T) {
+	ev := &events{}
+	lifecycle := server.New(server.Config{DrainPeriod: time.Hour}, zap.NewNop())
+	errCrash := errors.New("crash")
+	lifecycle.Register(fake(ev, "a", nil, nil), fake(ev, "b", nil, errCrash))
+
+	err := lifecycle.Run(context.Background())
+
+	assert.ErrorIs(t, err, errCrash)
+	assert.False(t, lifecycle.Ready())
+	got := withoutRuns(ev.list())
+	assert.Equal(t, []string{"stop b", "stop a"}, got[len(got)-2:])
+}
+
+// TestUnitLifecycle_StopsRunsInOrder tests that a component is fully stopped, its Run
+// included, before the components registered earlier are stopped, so runners never
+// outlive the dependencies they use.
+func TestUnitLifecycle_StopsRunsInOrder(t *testing.T) {
+	ev := &events{}
+	lifecycle := server.New(server.Config{DrainPeriod: -1}, zap.NewNop())
+	closed := make(chan struct{})
+	dbClosed := make(chan struct{})
+	lifecycle.Register(
+		server.Closer("db", func() error {
+			ev.add("close db")
+			close(dbClosed)
+			return nil
+		}),
+		server.Runner("relay", func(ctx context.Context) error {
+			<-ctx.Done()
+			select {
+			case <-dbClosed:
+				t.Error("relay ran after the db was closed")
+			default:
+			}
+			ev.add("relay exited")
+			return ctx.Err()
+		}),
+		server.Component{
+			Name: "server",
+			// Like a server, runs until its Stop hook is called, whatever its context.
+			Run: func(context.Context) error {
+				<-closed
+				ev.add("server exited")
+				return nil
+			},
+			Stop: func(context.Context) error {
+				ev.add("stop server")
+				close(closed)
+				return nil
+			},
+		},
+		server.Runner("monitor", func(ctx context.Context) error {
+			<-ctx.Done()
+			ev.add("monitor exited")
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lifecycle.Run(ctx) }()
+	require.Eventually(t, lifecycle.Ready, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"monitor exited", "stop server", "server exited", "relay exited", "close db"}, ev.list())
+}
+
+// TestUnitLifecycle_RunStopTimeout tests that a Run ignoring its context is abandoned at
+// the stop deadline, and the components registered earlier are still stopped.
+func TestUnitLifecycle_RunStopTimeout(t *testing.T) {
+	ev := &events{}
+	lifecycle := server.New(server.Config{DrainPeriod: -1, StopTimeout: 50 * time.Millisecond}, zap.NewNop())
+	stuck := make(chan struct{})
+	t.Cleanup(func() { close(stuck) })
+	lifecycle.Register(
+		server.Closer("db", func() error {
+			ev.add("close db")
+			return nil
+		}),
+		server.Runner("stuck", func(context.Context) error {
+			<-stuck
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lifecycle.Run(ctx) }()
+	require.Eventually(t, lifecycle.Ready, time.Second, time.Millisecond)
+	cancel()
+
+	err := <-done
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stop stuck")
+	assert.Equal(t, []string{"close db"}, ev.list())
+}
+
+// TestUnitGRPCServer_ForcedStop tests that in-flight RPCs still running at the stop
+// deadline are cancelled.
+func TestUnitGRPCServer_ForcedStop(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, grpchealth.NewServer())
+	component := server.GRPCServer("grpc", srv, addr)
+	require.NoError(t, component.Start(context.Background()))
+	go func() { _ = component.Run(context.Background()) }()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	// Watch streams until cancelled, so it blocks a graceful stop.
+	watch, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = component.Stop(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	_, err = watch.Recv()
+	assert.Error(t, err, "in-flight stream cancelled")
+}
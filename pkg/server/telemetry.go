@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	obsconfig "github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	obsmetrics "github.com/incheat/go-production-backend/pkg/obs/metrics"
+	"github.com/incheat/go-production-backend/pkg/obs/profiling"
+	obstracing "github.com/incheat/go-production-backend/pkg/obs/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// TelemetryConfig is the configuration for the telemetry of a service.
+type TelemetryConfig struct {
+	obsconfig.TelemetryConfig
+	// MetricsAddr is where /metrics is served.
+	MetricsAddr string
+	// ProfilingAddr is where /debug/pprof/ is served.
+	ProfilingAddr string
+	// AdminToken is the bearer token of the profiling listener and its admin endpoints,
+	// e.g. the runtime log level. Empty disables them.
+	AdminToken string
+	// ProfilingTLS serves the profiling listener over mutual TLS when its files are set;
+	// clients with a certificate signed by its CA, and one of ProfilingAllowedSANs if not
+	// empty, need no token.
+	ProfilingTLS         mtls.Config
+	ProfilingAllowedSANs []string
+	// Capture records profiles when thresholds are crossed; empty Dir disables it.
+	Capture profiling.CaptureConfig
+	// Push pushes profiles to Pyroscope; empty ServerAddress disables it.
+	Push profiling.PushConfig
+}
+
+// Telemetry is the logger, tracer and metrics registry of a service.
+type Telemetry struct {
+	Logger   *zap.Logger
+	Level    *logging.LevelController
+	Registry *prometheus.Registry
+	// Capturer is nil when captures are disabled, see profiling.HTTPMiddleware.
+	Capturer *profiling.Capturer
+
+	components []Component
+}
+
+// NewTelemetry creates the logger and the metrics registry and initializes the tracer
+// and the meter provider. A tracer, meter provider, profile capturer or push that cannot
+// be initialized is logged and stays disabled; profiling TLS files that cannot be loaded
+// fail.
+// The metrics and profiling servers are returned by Components.
+func NewTelemetry(ctx context.Context, cfg TelemetryConfig) (*Telemetry, error) {
+	logger, level, err := logging.New(logging.Config{
+		Service:   cfg.Resource.ServiceName,
+		Env:       cfg.Resource.Environment,
+		Level:     cfg.Logging.Level,
+		Redaction: cfg.Redaction,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Telemetry{
+		Logger:   logger,
+		Level:    level,
+		Registry: obsmetrics.NewRegistry(),
+	}
+	t.components = append(t.components, Closer("logger", func() error {
+		_ = logger.Sync() // fails on stderr/stdout on some platforms
+		return nil
+	}))
+
+	otelShutdown, err := obstracing.InitTracer(ctx, cfg.TelemetryConfig)
+	if err != nil {
+		logger.Error("Error initializing OpenTelemetry tracer", zap.Error(err))
+	} else {
+		logger.Info("OpenTelemetry tracer initialized", zap.String("exporter", string(cfg.Tracing.Exporter)), zap.String("endpoint", cfg.OTLP.Endpoint))
+		t.components = append(t.components, Component{Name: "tracer", Stop: otelShutdown})
+	}
+
+	meterShutdown, err := obsmetrics.InitMeterProvider(ctx, cfg.TelemetryConfig, t.Registry)
+	if err != nil {
+		logger.Error("Error initializing OpenTelemetry meter provider", zap.Error(err))
+	} else {
+		logger.Info("OpenTelemetry meter provider initialized",
+			zap.Bool("prometheus", cfg.Metrics.Prometheus), zap.String("exporter", string(cfg.Metrics.Exporter)))
+		t.components = append(t.components, Component{Name: "meter provider", Stop: meterShutdown})
+	}
+
+	profilingOpts := []profiling.Option{profiling.WithToken(cfg.AdminToken)}
+	if cfg.AdminToken != "" {
+		profilingOpts = append(profilingOpts, profiling.WithHandler(logging.LevelPath, level.Handler(cfg.AdminToken)))
+		logger.Info("Admin endpoints enabled", zap.String("addr", cfg.ProfilingAddr), zap.String("log_level_path", logging.LevelPath))
+	}
+	if cfg.ProfilingTLS.CertFile != "" {
+		reloader, err := mtls.NewReloader(cfg.ProfilingTLS, logger)
+		if err != nil {
+			return nil, fmt.Errorf("profiling TLS: %w", err)
+		}
+		profilingOpts = append(profilingOpts, profiling.WithTLS(reloader.ServerConfig(cfg.ProfilingAllowedSANs)))
+		t.components = append(t.components, Runner("profiling TLS reloader", reloader.Run))
+		logger.Info("Profiling mutual TLS enabled", zap.Strings("allowed_sans", cfg.ProfilingAllowedSANs))
+	}
+	if cfg.AdminToken == "" && cfg.ProfilingTLS.CertFile == "" {
+		logger.Warn("pprof endpoints disabled: set an admin token or profiling TLS to enable them")
+	}
+
+	if cfg.Capture.Dir != "" {
+		capturer, err := profiling.NewCapturer(cfg.Capture, logger.Named("profiling"))
+		if err != nil {
+			logger.Error("Error initializing profile capturer", zap.Error(err))
+		} else {
+			t.Capturer = capturer
+			profilingOpts = append(profilingOpts, profiling.WithHandler(profiling.CapturePath, capturer.Handler()))
+			t.components = append(t.components, Component{Name: "profile capturer", Run: capturer.Run, Stop: capturer.Stop})
+			logger.Info("Profile captures enabled", zap.String("dir", cfg.Capture.Dir), zap.Stringer("triggers", cfg.Capture))
+		}
+	}
+
+	if cfg.Push.ServerAddress != "" {
+		push := cfg.Push
+		if push.ApplicationName == "" {
+			push.ApplicationName = cfg.Resource.ServiceName
+		}
+		if push.Tags == nil {
+			push.Tags = map[string]string{"env": cfg.Resource.Environment, "version": cfg.Resource.ServiceVersion}
+		}
+		pushShutdown, err := profiling.StartPush(push, logger.Named("pyroscope"))
+		if err != nil {
+			logger.Error("Error starting profile push", zap.Error(err))
+		} else {
+			logger.Info("Profile push started", zap.String("address", push.ServerAddress))
+			t.components = append(t.components, Component{Name: "profile push", Stop: pushShutdown})
+		}
+	}
+
+	t.components = append(t.components,
+		HTTPServer("metrics server", obsmetrics.NewServer(cfg.MetricsAddr, t.Registry)),
+		HTTPServer("profiling server", profiling.NewServer(cfg.ProfilingAddr, profilingOpts...)),
+	)
+	return t, nil
+}
+
+// Components returns the telemetry components. Register them first, so that they stop
+// last and telemetry of the shutdown itself is still exported.
+func (t *Telemetry) Components() []Component {
+	return t.components
+}
@@ -0,0 +1,80 @@
+// Package svcauth defines the gRPC per-RPC credentials and the HTTP header attaching service tokens.
+package svcauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// refreshBefore is how long before expiry a cached token is replaced.
+const refreshBefore = 30 * time.Second
+
+// MintFunc mints a new service token and returns it with its expiry.
+type MintFunc func() (token string, expiresAt time.Time, err error)
+
+// _ is a placeholder to ensure that TokenSource implements the credentials.PerRPCCredentials interface.
+var _ credentials.PerRPCCredentials = (*TokenSource)(nil)
+
+// TokenSource caches a service token and attaches it to outgoing gRPC calls,
+// minting a new one shortly before the cached token expires.
+type TokenSource struct {
+	mint MintFunc
+	// requireTLS refuses to send the token over plaintext connections.
+	requireTLS bool
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewTokenSource creates a new TokenSource.
+func NewTokenSource(mint MintFunc, requireTLS bool) *TokenSource {
+	return &TokenSource{mint: mint, requireTLS: requireTLS}
+}
+
+// Token returns a valid service token.
+func (s *TokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expiresAt) > refreshBefore {
+		return s.token, nil
+	}
+	token, expiresAt, err := s.mint()
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiresAt = token, expiresAt
+	return token, nil
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (s *TokenSource) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	token, err := s.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{metadataAuthorization: bearerPrefix + token}, nil
+}
+
+// SetAuthorization sets the service token as the bearer token of an outgoing HTTP request.
+func (s *TokenSource) SetAuthorization(req *http.Request) error {
+	if s.requireTLS && req.URL.Scheme != "https" {
+		return errors.New("svcauth: service token requires https")
+	}
+	token, err := s.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", bearerPrefix+token)
+	return nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (s *TokenSource) RequireTransportSecurity() bool {
+	return s.requireTLS
+}
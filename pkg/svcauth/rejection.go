@@ -0,0 +1,60 @@
+package svcauth
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A rejection is the answer of a service refusing a call for the identity of the calling
+// service rather than for its arguments. It is tagged with ErrorReason, so the caller can
+// tell it apart from the answers of the method that share its status, e.g. a rejected
+// password, and treat it as a misconfiguration of its own.
+const (
+	// ErrorReason is the reason tagging rejections, in the google.rpc.ErrorInfo of a gRPC
+	// status and in the HeaderErrorReason of an HTTP response.
+	ErrorReason = "SERVICE_AUTH"
+	// ErrorDomain is the domain of the google.rpc.ErrorInfo of rejections.
+	ErrorDomain = "svcauth"
+	// HeaderErrorReason is the HTTP response header carrying the reason of an error.
+	HeaderErrorReason = "X-Error-Reason"
+)
+
+// Rejection returns the gRPC status error rejecting a call with code and msg.
+func Rejection(code codes.Code, msg string) error {
+	st, err := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason: ErrorReason,
+		Domain: ErrorDomain,
+	})
+	if err != nil {
+		return status.Error(code, msg)
+	}
+	return st.Err()
+}
+
+// IsRejection reports whether err is a gRPC status error returned by Rejection.
+func IsRejection(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return false
+	}
+	for _, detail := range se.GRPCStatus().Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == ErrorReason && info.GetDomain() == ErrorDomain {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRejection tags the HTTP response with header h as a rejection.
+func SetRejection(h http.Header) {
+	h.Set(HeaderErrorReason, ErrorReason)
+}
+
+// IsRejectionResponse reports whether the HTTP response with header h is a rejection.
+func IsRejectionResponse(h http.Header) bool {
+	return h.Get(HeaderErrorReason) == ErrorReason
+}
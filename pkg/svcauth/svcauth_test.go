@@ -0,0 +1,299 @@
+package svcauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksServer serves the public keys of keys, and counts the fetches.
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu     sync.Mutex
+	keys   map[string]*rsa.PrivateKey
+	status int
+	gate   chan struct{}
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+	s := &jwksServer{keys: map[string]*rsa.PrivateKey{}, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		gate, status := s.gate, s.status
+		keys := make([]map[string]string, 0, len(s.keys))
+		for kid, key := range s.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		s.mu.Unlock()
+		if gate != nil {
+			<-gate
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[kid] = key
+	return key
+}
+
+func (s *jwksServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// fakeClock is a settable clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestVerifier(url string) (*Verifier, *fakeClock) {
+	clock := &fakeClock{now: time.Now()}
+	v := NewVerifier(VerifierConfig{JWKSURL: url, Issuer: "auth", Audience: "user"})
+	v.now = clock.Now
+	return v, clock
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func serviceClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":         "auth",
+		"aud":         "user",
+		"sub":         "auth-service",
+		"exp":         time.Now().Add(time.Minute).Unix(),
+		ClaimTokenUse: TokenUseService,
+	}
+}
+
+// TestUnitVerifier_Verify tests that only unexpired service tokens for the audience are accepted.
+func TestUnitVerifier_Verify(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	v, _ := newTestVerifier(jwks.URL)
+	ctx := context.Background()
+
+	subject, err := v.Verify(ctx, sign(t, key, "k1", serviceClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "auth-service", subject)
+
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"other audience": func(c jwt.MapClaims) { c["aud"] = "billing" },
+		"other issuer":   func(c jwt.MapClaims) { c["iss"] = "evil" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no expiry":      func(c jwt.MapClaims) { delete(c, "exp") },
+		"user token":     func(c jwt.MapClaims) { delete(c, ClaimTokenUse) },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			claims := serviceClaims()
+			mutate(claims)
+			_, err := v.Verify(ctx, sign(t, key, "k1", claims))
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+// TestUnitVerifier_KeyRotation tests that a new key id is picked up by refetching the
+// JWKS, at most once per minRefreshInterval.
+func TestUnitVerifier_KeyRotation(t *testing.T) {
+	jwks := newJWKSServer(t)
+	k1 := jwks.addKey(t, "k1")
+	v, clock := newTestVerifier(jwks.URL)
+	ctx := context.Background()
+
+	_, err := v.Verify(ctx, sign(t, k1, "k1", serviceClaims()))
+	require.NoError(t, err)
+
+	k2 := jwks.addKey(t, "k2")
+	rotated := sign(t, k2, "k2", serviceClaims())
+	_, err = v.Verify(ctx, rotated)
+	assert.ErrorIs(t, err, ErrInvalidToken, "refetch within minRefreshInterval")
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+
+	clock.Advance(minRefreshInterval)
+	_, err = v.Verify(ctx, rotated)
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, sign(t, k1, "k1", serviceClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), jwks.fetches.Load())
+}
+
+// TestUnitVerifier_FailedFetchBacksOff tests that a failed JWKS fetch is not retried by
+// every request until minRefreshInterval has passed.
+func TestUnitVerifier_FailedFetchBacksOff(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	jwks.setStatus(http.StatusServiceUnavailable)
+	v, clock := newTestVerifier(jwks.URL)
+	token := sign(t, key, "k1", serviceClaims())
+
+	for range 5 {
+		_, err := v.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+
+	jwks.setStatus(http.StatusOK)
+	clock.Advance(minRefreshInterval)
+	_, err := v.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), jwks.fetches.Load())
+}
+
+// TestUnitVerifier_ConcurrentFetch tests that concurrent requests share one JWKS fetch.
+func TestUnitVerifier_ConcurrentFetch(t *testing.T) {
+	jwks := newJWKSServer(t)
+	key := jwks.addKey(t, "k1")
+	jwks.gate = make(chan struct{})
+	v, _ := newTestVerifier(jwks.URL)
+	token := sign(t, key, "k1", serviceClaims())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	require.Eventually(t, func() bool { return jwks.fetches.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond) // let the other requests join the fetch
+	close(jwks.gate)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), jwks.fetches.Load())
+}
+
+// TestUnitTokenSource tests that tokens are cached and minted again shortly before expiry.
+func TestUnitTokenSource(t *testing.T) {
+	var mints int
+	expiresAt := time.Now().Add(time.Hour)
+	source := NewTokenSource(func() (string, time.Time, error) {
+		mints++
+		return "token-" + string(rune('0'+mints)), expiresAt, nil
+	}, false)
+
+	token, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	token, err = source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token, "cached")
+
+	expiresAt = time.Now().Add(refreshBefore / 2)
+	source.expiresAt = expiresAt
+	token, err = source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token, "minted before expiry")
+
+	md, err := source.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer token-3"}, md)
+}
+
+// TestUnitTokenSource_SetAuthorization tests the HTTP bearer token, refused over plaintext
+// when TLS is required.
+func TestUnitTokenSource_SetAuthorization(t *testing.T) {
+	mint := func() (string, time.Time, error) { return "svc", time.Now().Add(time.Hour), nil }
+
+	req := httptest.NewRequest(http.MethodPost, "http://user:8080/v1/verify", nil)
+	require.NoError(t, NewTokenSource(mint, false).SetAuthorization(req))
+	token, err := TokenFromRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, "svc", token)
+
+	req = httptest.NewRequest(http.MethodPost, "http://user:8080/v1/verify", nil)
+	assert.Error(t, NewTokenSource(mint, true).SetAuthorization(req))
+	assert.Empty(t, req.Header.Get("Authorization"))
+
+	failing := NewTokenSource(func() (string, time.Time, error) { return "", time.Time{}, errors.New("no key") }, false)
+	assert.Error(t, failing.SetAuthorization(req))
+}
+
+// TestUnitMatchToken tests that only the configured bearer token matches, and nothing
+// matches an empty one.
+func TestUnitMatchToken(t *testing.T) {
+	for name, tt := range map[string]struct {
+		token  string
+		header string
+		want   bool
+	}{
+		"match":          {token: "admin-token", header: "Bearer admin-token", want: true},
+		"scheme case":    {token: "admin-token", header: "bearer admin-token", want: true},
+		"wrong token":    {token: "admin-token", header: "Bearer admin-token2"},
+		"not bearer":     {token: "admin-token", header: "admin-token"},
+		"no header":      {token: "admin-token"},
+		"no token":       {header: "Bearer "},
+		"empty and none": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			assert.Equal(t, tt.want, MatchToken(r, tt.token))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Package svcauth defines the verifier of service tokens against the auth service's JWKS.
+package svcauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefreshInterval limits how often an unknown key id, or a failed fetch, triggers a
+// JWKS fetch.
+const minRefreshInterval = 30 * time.Second
+
+// VerifierConfig is the configuration for the Verifier.
+type VerifierConfig struct {
+	// JWKSURL is the auth service's JWKS endpoint.
+	JWKSURL  string
+	Issuer   string
+	Audience string
+	// HTTPClient is optional.
+	HTTPClient *http.Client
+}
+
+// Verifier verifies service tokens signed by a key from a JWKS endpoint.
+// Keys are cached and refetched when a token names an unknown key id. Concurrent
+// refetches are merged into one, made without holding the cache lock.
+type Verifier struct {
+	cfg     VerifierConfig
+	client  *http.Client
+	now     func() time.Time
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	lastFetched time.Time
+	fetchErr    error
+}
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(cfg VerifierConfig) *Verifier {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Verifier{cfg: cfg, client: client, now: time.Now}
+}
+
+// Verify verifies a service token and returns the calling service, i.e. its subject.
+func (v *Verifier) Verify(ctx context.Context, token string) (string, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(v.cfg.Issuer),
+		jwt.WithAudience(v.cfg.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if use, _ := claims[ClaimTokenUse].(string); use != TokenUseService {
+		return "", fmt.Errorf("%w: not a service token", ErrInvalidToken)
+	}
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return "", fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	return subject, nil
+}
+
+// key returns the public key for kid, refetching the JWKS if kid is unknown.
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok, err := v.cached(kid); ok || err != nil {
+		return key, err
+	}
+
+	// The fetch is shared by concurrent callers, so it must not be cancelled with the
+	// context of the first one; the client timeout bounds it.
+	_, err, _ := v.fetches.Do("jwks", func() (any, error) {
+		keys, err := v.fetch(context.WithoutCancel(ctx))
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.lastFetched = v.now()
+		v.fetchErr = err
+		if err == nil {
+			v.keys = keys
+		}
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// cached returns the cached key for kid. It reports false with no error when the JWKS
+// should be refetched, and an error when kid is unknown but the last fetch is too recent.
+func (v *Verifier) cached(kid string) (*rsa.PublicKey, bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, true, nil
+	}
+	if v.lastFetched.IsZero() || v.now().Sub(v.lastFetched) >= minRefreshInterval {
+		return nil, false, nil
+	}
+	if v.fetchErr != nil {
+		return nil, false, v.fetchErr
+	}
+	return nil, false, fmt.Errorf("unknown key id %q", kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (v *Verifier) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if err := errors.Join(errN, errE); err != nil {
+			return nil, fmt.Errorf("decode JWK %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
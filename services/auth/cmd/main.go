@@ -3,102 +3,142 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/go-chi/chi/v5"
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/clientip"
+	"github.com/incheat/go-production-backend/pkg/config"
+	"github.com/incheat/go-production-backend/pkg/cookie"
+	"github.com/incheat/go-production-backend/pkg/cors"
+	"github.com/incheat/go-production-backend/pkg/csrf"
+	"github.com/incheat/go-production-backend/pkg/health"
+	"github.com/incheat/go-production-backend/pkg/mtls"
 	obsconfig "github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 	"github.com/incheat/go-production-backend/pkg/obs/logging"
 	obsmetrics "github.com/incheat/go-production-backend/pkg/obs/metrics"
 	"github.com/incheat/go-production-backend/pkg/obs/profiling"
-	obstracing "github.com/incheat/go-production-backend/pkg/obs/tracing"
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	"github.com/incheat/go-production-backend/pkg/resilience"
+	"github.com/incheat/go-production-backend/pkg/secrets"
+	"github.com/incheat/go-production-backend/pkg/server"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
 	servergen "github.com/incheat/go-production-backend/services/auth/internal/api/oapi/gen/public/server"
 	envconfig "github.com/incheat/go-production-backend/services/auth/internal/config/env"
 	"github.com/incheat/go-production-backend/services/auth/internal/constant"
-	usergateway "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/grpc"
+	usergatewaygrpc "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/grpc"
+	usergatewayhttp "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/http"
+	usergatewayresilient "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/resilient"
 	authhandler "github.com/incheat/go-production-backend/services/auth/internal/handler/http"
+	authmetrics "github.com/incheat/go-production-backend/services/auth/internal/metrics"
 	chimiddleware "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi"
+	chimiddlewareutils "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi/utils"
 	redisrepo "github.com/incheat/go-production-backend/services/auth/internal/repository/redis"
 	authservice "github.com/incheat/go-production-backend/services/auth/internal/service/auth"
 	"github.com/incheat/go-production-backend/services/auth/internal/token"
 	nethttpmiddleware "github.com/oapi-codegen/nethttp-middleware"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 
-	cfg, err := envconfig.Load()
+	cfg, loaded, err := envconfig.Load(os.Args[1:])
+	if loaded != nil && loaded.CheckOnly {
+		os.Exit(config.Check(os.Stdout, loaded, err))
+	}
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	telemetryConfig := obsconfig.TelemetryConfig{
-		Resource: obsconfig.ResourceConfig{
-			ServiceName:    constant.ServiceName,
-			Environment:    string(cfg.Env),
-			ServiceVersion: cfg.Version,
+	ctx := context.Background()
+
+	telemetry, err := server.NewTelemetry(ctx, server.TelemetryConfig{
+		TelemetryConfig: obsconfig.TelemetryConfig{
+			Resource: obsconfig.ResourceConfig{
+				ServiceName:    constant.ServiceName,
+				Environment:    string(cfg.Env),
+				ServiceVersion: cfg.Version,
+			},
+			Logging: obsconfig.LoggingConfig{
+				Level: cfg.Obs.Logging.Level,
+			},
+			OTLP: obsconfig.OTLPConfig{
+				Endpoint: cfg.Obs.OTLP.Endpoint,
+				Insecure: cfg.Obs.OTLP.Insecure,
+				TLS: obsconfig.TLSConfig{
+					CAFile:     cfg.Obs.OTLP.CAFile,
+					CertFile:   cfg.Obs.OTLP.CertFile,
+					KeyFile:    cfg.Obs.OTLP.KeyFile,
+					ServerName: cfg.Obs.OTLP.ServerName,
+				},
+			},
+			Tracing: obsconfig.TracingConfig{
+				SamplingRatio: cfg.Obs.Tracing.SamplingRatio,
+				Rules:         cfg.Obs.Tracing.SamplingRules,
+				SampleErrors:  cfg.Obs.Tracing.SampleErrors,
+				Exporter:      cfg.Obs.Tracing.Exporter,
+				FilePath:      cfg.Obs.Tracing.FilePath,
+			},
+			Metrics: obsconfig.MetricsConfig{
+				Prometheus:     cfg.Obs.Metrics.Prometheus,
+				Exporter:       cfg.Obs.Metrics.Exporter,
+				ExportInterval: cfg.Obs.Metrics.ExportInterval,
+				Exemplars:      cfg.Obs.Metrics.Exemplars,
+			},
+			Redaction: cfg.Obs.Redaction.Fields,
 		},
-		Logging: obsconfig.LoggingConfig{
-			Level: cfg.Obs.Logging.Level,
+		MetricsAddr:   fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port)),
+		ProfilingAddr: fmt.Sprintf(":%d", int(cfg.Obs.Profiling.Port)),
+		AdminToken:    cfg.Obs.Admin.Token,
+		ProfilingTLS: mtls.Config{
+			CertFile: cfg.Obs.Profiling.TLS.CertFile,
+			KeyFile:  cfg.Obs.Profiling.TLS.KeyFile,
+			CAFile:   cfg.Obs.Profiling.TLS.CAFile,
 		},
-		OTLP: obsconfig.OTLPConfig{
-			Endpoint: cfg.Obs.OTLP.Endpoint,
-			Insecure: true,
+		ProfilingAllowedSANs: cfg.Obs.Profiling.TLS.AllowedSANs,
+		Capture: profiling.CaptureConfig{
+			Dir:              cfg.Obs.Profiling.Capture.Dir,
+			LatencyThreshold: cfg.Obs.Profiling.Capture.LatencyThreshold,
+			HeapThreshold:    uint64(max(cfg.Obs.Profiling.Capture.HeapThresholdMB, 0)) << 20,
+			CPUDuration:      cfg.Obs.Profiling.Capture.CPUDuration,
+			Cooldown:         cfg.Obs.Profiling.Capture.Cooldown,
+			Retention:        cfg.Obs.Profiling.Capture.Retention,
+			MaxCaptures:      cfg.Obs.Profiling.Capture.MaxCaptures,
 		},
-		Tracing: obsconfig.TracingConfig{
-			SamplingRatio: cfg.Obs.Tracing.SamplingRatio,
+		Push: profiling.PushConfig{
+			ServerAddress: cfg.Obs.Profiling.Push.Address,
+			AuthToken:     cfg.Obs.Profiling.Push.AuthToken,
+			TenantID:      cfg.Obs.Profiling.Push.TenantID,
 		},
-	}
-
-	logger, err := logging.New(logging.Config{
-		Service: telemetryConfig.Resource.ServiceName,
-		Env:     telemetryConfig.Resource.Environment,
-		Level:   telemetryConfig.Logging.Level,
 	})
 	if err != nil {
-		log.Fatalf("Error creating logger: %v", err)
+		log.Fatalf("Error creating telemetry: %v", err)
 	}
+	logger := telemetry.Logger
 
 	logger.Info("Starting auth service", zap.String("env", string(cfg.Env)))
+	logger.Info("Effective configuration", zap.String("file", loaded.File), zap.Any("config", loaded.Redacted()))
 	logger.Info("Http server port", zap.Int("port", int(cfg.Server.HTTPPort)))
 
-	ctx := context.Background()
-
-	// Start profiling server
-	profiling.StartServer(ctx, fmt.Sprintf(":%d", int(cfg.Obs.Profiling.Port)), logger)
-
-	// Initialize OpenTelemetry tracer
-	otelShutdown, err := obstracing.InitTracer(ctx, telemetryConfig)
-	if err != nil {
-		logger.Error("Error initializing OpenTelemetry tracer", zap.Error(err))
-	} else {
-		logger.Info("OpenTelemetry tracer initialized", zap.String("endpoint", cfg.Obs.OTLP.Endpoint))
-	}
-	defer func() {
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Error shutting down OpenTelemetry tracer", zap.Error(err))
-		}
-	}()
+	lifecycle := server.New(server.Config{
+		DrainPeriod: cfg.Shutdown.DrainPeriod,
+		StopTimeout: cfg.Shutdown.Timeout,
+	}, logger)
+	lifecycle.Register(telemetry.Components()...)
 
 	// Initialize Prometheus metrics
-	reg := obsmetrics.NewRegistry()
-	obsmetrics.RegisterHTTP(reg)
-	shutdownMetrics := obsmetrics.StartServer(fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port)), reg, logger)
-	if err != nil {
-		logger.Error("Error initializing Prometheus metrics server", zap.Error(err))
-	} else {
-		logger.Info("Prometheus metrics server initialized", zap.String("port", fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port))))
-	}
-	defer func() {
-		if err := shutdownMetrics(ctx); err != nil {
-			logger.Error("Error shutting down Prometheus metrics server", zap.Error(err))
-		}
-	}()
+	reg := telemetry.Registry
+	obsmetrics.RegisterCircuitBreaker(reg)
 
 	// Get OpenAPI definition from embedded spec
 	openAPISpec, err := servergen.GetSpec()
@@ -106,23 +146,40 @@ func main() {
 		log.Fatalf("Error loading OpenAPI spec: %v", err)
 	}
 
-	// Initialize Redis client
+	// Secret references (file://, vault://) are polled and applied without a restart
+	secretWatcher := secrets.NewWatcher(envconfig.NewSecretResolver(cfg), cfg.Secrets.ReloadInterval, logger)
+	lifecycle.Register(server.Runner("secret watcher", secretWatcher.Run))
+
+	// Initialize Redis client. The password is read on every new connection, so a
+	// rotated password is used without dropping the pool.
+	redisPassword := secrets.NewValue(cfg.Redis.Password)
+	if ref, ok := loaded.Reference("redis.password"); ok {
+		secretWatcher.Watch("redis password", ref, cfg.Redis.Password, func(password string) error {
+			redisPassword.Set(password)
+			return nil
+		})
+	}
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Host,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
+		Addr: cfg.Redis.Host,
+		CredentialsProvider: func() (string, string) {
+			return "", redisPassword.Get()
+		},
+		DB: cfg.Redis.DB,
 	})
 
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		panic(fmt.Errorf("failed to connect to Redis: %w", err))
-	}
-
-	logger.Info("Connected to Redis", zap.String("addr", cfg.Redis.Host))
-	defer func() {
-		if err := redisClient.Close(); err != nil {
-			logger.Warn("Failed to close Redis client", zap.Error(err))
-		}
-	}()
+	lifecycle.Register(server.Component{
+		Name: "redis",
+		Start: func(ctx context.Context) error {
+			if err := redisClient.Ping(ctx).Err(); err != nil {
+				return err
+			}
+			logger.Info("Connected to Redis", zap.String("addr", cfg.Redis.Host))
+			return nil
+		},
+		Stop: func(context.Context) error {
+			return redisClient.Close()
+		},
+	})
 
 	// Auth components
 	refreshTokenRepository := redisrepo.NewRefreshTokenRepository(redisClient)
@@ -131,44 +188,96 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error creating JWT token maker: %v", err)
 	}
+	if ref, ok := loaded.Reference("jwt.private_key_pem"); ok {
+		secretWatcher.Watch("JWT private key", ref, cfg.JWT.PrivateKeyPEM, jwtTokenMaker.Rotate)
+	}
 	opaqueTokenMaker := token.NewOpaqueMaker(
 		cfg.Refresh.NumBytes,
 		cfg.Refresh.MaxAge,
 		cfg.Refresh.EndPoint,
 	)
 
-	logger.Info("Creating user gateway", zap.String("address", cfg.UserGateway.InternalAddress))
-	userGateway, err := usergateway.New(cfg.UserGateway.InternalAddress)
+	var userGatewayTLS *tls.Config
+	if cfg.UserGateway.TLS.Enabled {
+		tlsReloader, err := mtls.NewReloader(mtls.Config{
+			CertFile:       cfg.UserGateway.TLS.CertFile,
+			KeyFile:        cfg.UserGateway.TLS.KeyFile,
+			CAFile:         cfg.UserGateway.TLS.CAFile,
+			ReloadInterval: cfg.UserGateway.TLS.ReloadInterval,
+		}, logger)
+		if err != nil {
+			log.Fatalf("Error loading user gateway TLS files: %v", err)
+		}
+		userGatewayTLS = tlsReloader.ClientConfig(cfg.UserGateway.TLS.ServerName)
+
+		lifecycle.Register(server.Runner("user gateway TLS reloader", tlsReloader.Run))
+	}
+
+	var (
+		grpcGatewayOpts []usergatewaygrpc.Option
+		httpGatewayOpts []usergatewayhttp.Option
+	)
+	if userGatewayTLS != nil {
+		grpcGatewayOpts = append(grpcGatewayOpts, usergatewaygrpc.WithTLS(userGatewayTLS))
+	}
+	if cfg.UserGateway.ServiceToken.Enabled {
+		serviceToken := cfg.UserGateway.ServiceToken
+		tokenSource := svcauth.NewTokenSource(func() (string, time.Time, error) {
+			return jwtTokenMaker.CreateServiceToken(serviceToken.Subject, serviceToken.Audience, serviceToken.TTL)
+		}, userGatewayTLS != nil)
+		grpcGatewayOpts = append(grpcGatewayOpts, usergatewaygrpc.WithPerRPCCredentials(tokenSource))
+		httpGatewayOpts = append(httpGatewayOpts, usergatewayhttp.WithServiceToken(tokenSource))
+		logger.Info("Service token enabled for user gateway", zap.String("subject", serviceToken.Subject), zap.String("audience", serviceToken.Audience))
+	}
+
+	userGateway, err := newUserGateway(cfg.UserGateway, grpcGatewayOpts, httpGatewayOpts, logger)
 	if err != nil {
 		log.Fatalf("Error creating user gateway: %v", err)
 	}
-	authService := authservice.New(jwtTokenMaker, opaqueTokenMaker, refreshTokenRepository, userGateway)
-	authImpl := authhandler.New(authService)
+	lifecycle.Register(server.Closer("user gateway", userGateway.Close))
+
+	// ---- Health checks ----
+	// Login degrades to 503 without the user service, so it is reported but does not make auth unready.
+	healthMonitor := health.New(health.Config{
+		Interval: cfg.Health.Interval,
+		Timeout:  cfg.Health.Timeout,
+	}, logger)
+	healthMonitor.Register(
+		health.Check{Name: "redis", Checker: health.RedisChecker(redisClient)},
+		health.Check{Name: "user_service", Checker: userGateway, Optional: true},
+	)
+	lifecycle.OnReadinessChange(healthMonitor.SetAccepting)
+	lifecycle.Register(server.Runner("health monitor", healthMonitor.Run))
+	businessMetrics, err := authmetrics.New(otel.Meter("auth.service"), refreshTokenRepository)
+	if err != nil {
+		logger.Fatal("Failed to create business metrics", zap.Error(err))
+	}
+	authService := authservice.New(jwtTokenMaker, opaqueTokenMaker, refreshTokenRepository, userGateway,
+		authservice.WithMetrics(businessMetrics))
+	refreshCookie, err := cookie.NewBuilder(cookie.Config{
+		Name:        cfg.Refresh.Cookie.Name,
+		Domain:      cfg.Refresh.Cookie.Domain,
+		Path:        cfg.Refresh.Cookie.Path,
+		SameSite:    cfg.Refresh.Cookie.SameSite,
+		Secure:      cfg.Refresh.Cookie.Secure,
+		Partitioned: cfg.Refresh.Cookie.Partitioned,
+	})
+	if err != nil {
+		logger.Fatal("Invalid refresh token cookie", zap.Error(err))
+	}
+	authImpl := authhandler.New(authService, refreshCookie)
 
-	strict := servergen.NewStrictHandler(authImpl, nil)
+	strict := servergen.NewStrictHandlerWithOptions(authImpl, []servergen.StrictMiddlewareFunc{authImpl.RefreshCookie()}, authhandler.StrictHTTPServerOptions())
 
 	// ---- HTTP Routers ----
 	rootRouter := chi.NewRouter()
+	rootRouter.Use(apierror.Negotiate(cfg.Server.ErrorFormat))
 
-	// ✅ Health check endpoint
-	rootRouter.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
-		defer cancel()
-
-		if err := redisClient.Ping(ctx).Err(); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			if _, err := w.Write([]byte("redis not ready")); err != nil {
-				logger.Error("Failed to write health check response", zap.Error(err))
-			}
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
-
-		if _, err := w.Write([]byte("ok")); err != nil {
-			logger.Error("Failed to write health check response", zap.Error(err))
-		}
-	})
+	// ✅ Health check endpoints
+	// /healthz is kept as an alias of /readyz for existing probes.
+	rootRouter.Method(http.MethodGet, "/livez", healthMonitor.LivezHandler())
+	rootRouter.Method(http.MethodGet, "/readyz", healthMonitor.ReadyzHandler())
+	rootRouter.Method(http.MethodGet, "/healthz", healthMonitor.ReadyzHandler())
 
 	// ✅ JWKS endpoint (NOT behind OpenAPI validator)
 	jwksPath := cfg.JWT.JWKSPath
@@ -180,12 +289,53 @@ func main() {
 	// ✅ Traced router
 	tracedRouter := chi.NewRouter()
 
-	tracedRouter.Use(obsmetrics.PromHTTPMetrics())
+	tracedRouter.Use(correlation.HTTPRequestID())
+	tracedRouter.Use(obsmetrics.HTTPRoute())
+	tracedRouter.Use(profiling.HTTPMiddleware(telemetry.Capturer))
 
 	// HTTP API router
 	apiRouter := chi.NewRouter()
-	apiRouter.Use(chimiddleware.RequestMeta())
-	apiRouter.Use(logging.HTTPRequestLogging(logger))
+	apiRouter.Use(chimiddleware.RequestMeta(clientip.NewResolver(cfg.Server.TrustedProxies)))
+	apiRouter.Use(logging.HTTPRequestLogging(logger,
+		logging.WithDebugSecret(cfg.Obs.Logging.DebugSecret),
+		logging.WithAccessLog(logging.AccessLogConfig{
+			Enabled:      cfg.Obs.AccessLog.Enabled,
+			Sampling:     cfg.Obs.AccessLog.Sampling,
+			ExcludePaths: cfg.Obs.AccessLog.ExcludePaths,
+		}),
+		logging.WithClientIP(func(r *http.Request) string {
+			meta, _ := chimiddlewareutils.GetRequestMeta(r.Context())
+			return meta.IPAddress
+		}),
+	))
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		// Before the OpenAPI validator, which rejects the OPTIONS preflight requests.
+		corsMiddleware, err := cors.Middleware(cors.Config{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.CORS.ExposedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		})
+		if err != nil {
+			logger.Fatal("Invalid CORS configuration", zap.Error(err))
+		}
+		apiRouter.Use(corsMiddleware)
+	}
+	if cfg.CSRF.Enabled {
+		apiRouter.Use(csrf.Middleware(csrf.Config{
+			Cookies:        []string{refreshCookie.Name()},
+			TrustedOrigins: cfg.CSRF.TrustedOrigins,
+		}))
+	}
+	if cfg.RateLimit.Enabled {
+		rateLimit, err := newRateLimitMiddleware(cfg.RateLimit, openAPISpec, redisClient, logger)
+		if err != nil {
+			log.Fatalf("Error creating rate limit middleware: %v", err)
+		}
+		apiRouter.Use(rateLimit)
+	}
 	apiRouter.Use(nethttpmiddleware.OapiRequestValidatorWithOptions(
 		openAPISpec,
 		chimiddleware.NewValidatorOptions(chimiddleware.ValidatorConfig{
@@ -203,23 +353,95 @@ func main() {
 		constant.SpanNameAuthHTTP,
 	))
 
-	var g errgroup.Group
+	httpServer := server.NewHTTPServer(
+		fmt.Sprintf(":%d", int(cfg.Server.HTTPPort)),
+		rootRouter,
+		server.HTTPTimeouts{
+			Read:  cfg.Server.ReadTimeout,
+			Write: cfg.Server.WriteTimeout,
+			Idle:  cfg.Server.IdleTimeout,
+		},
+	)
+	lifecycle.Register(server.HTTPServer("http server", httpServer))
+
+	if err := lifecycle.Run(ctx); err != nil {
+		logger.Fatal("Auth service stopped with error", zap.Error(err))
+	}
+	logger.Info("Auth service stopped")
 
-	listenAddr := fmt.Sprintf(":%d", int(cfg.Server.HTTPPort))
+}
+
+// userGateway is the user gateway used by the auth service, whichever transport it uses.
+type userGateway interface {
+	authservice.UserGateway
+	health.Checker
+	Close() error
+}
 
-	srv := &http.Server{
-		Addr:    listenAddr,
-		Handler: rootRouter,
+// newUserGateway creates the user gateway for the configured transport,
+// wrapped with the configured deadline, retries and circuit breaker.
+func newUserGateway(cfg envconfig.UserGateway, grpcOpts []usergatewaygrpc.Option, httpOpts []usergatewayhttp.Option, logger *zap.Logger) (userGateway, error) {
+	var (
+		transport usergatewayresilient.Next
+		err       error
+	)
+	switch cfg.Transport {
+	case envconfig.UserGatewayTransportHTTP:
+		logger.Info("Creating user gateway", zap.String("transport", string(cfg.Transport)), zap.String("address", cfg.HTTPBaseURL))
+		transport, err = usergatewayhttp.New(cfg.HTTPBaseURL, httpOpts...)
+	default:
+		logger.Info("Creating user gateway", zap.String("transport", string(cfg.Transport)), zap.String("address", cfg.InternalAddress), zap.Bool("mtls", cfg.TLS.Enabled))
+		transport, err = usergatewaygrpc.New(cfg.InternalAddress, append(grpcOpts, usergatewaygrpc.WithLogger(logger))...)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	g.Go(func() error {
-		return srv.ListenAndServe()
-	})
+	return usergatewayresilient.New(transport, usergatewayresilient.Config{
+		Timeout: cfg.Timeout,
+		Retry: resilience.RetryPolicy{
+			MaxAttempts: cfg.Retry.MaxAttempts,
+			BaseDelay:   cfg.Retry.BaseBackoff,
+			MaxDelay:    cfg.Retry.MaxBackoff,
+		},
+		Breaker: resilience.BreakerConfig{
+			FailureThreshold: cfg.Breaker.FailureThreshold,
+			OpenTimeout:      cfg.Breaker.OpenTimeout,
+			Observer:         obsmetrics.BreakerObserver{},
+		},
+	}), nil
+}
 
-	if err := g.Wait(); err != nil {
-		log.Fatal(err)
+// newRateLimitMiddleware creates the rate limit middleware, keeping buckets in Redis
+// and falling back to process memory while Redis is unreachable.
+func newRateLimitMiddleware(cfg envconfig.RateLimit, spec *openapi3.T, redisClient *redis.Client, logger *zap.Logger) (func(http.Handler) http.Handler, error) {
+	operation, err := chimiddleware.OperationResolver(spec)
+	if err != nil {
+		return nil, err
 	}
 
+	limiter := ratelimit.NewFallbackLimiter(
+		ratelimit.NewRedisLimiter(redisClient, "auth:ratelimit:"),
+		ratelimit.NewMemoryLimiter(),
+		func(err error) {
+			logger.Warn("Rate limiter falling back to memory", zap.Error(err))
+		},
+	)
+
+	logger.Info("Rate limiting enabled", zap.Any("rules", cfg.Rules))
+	return ratelimit.Middleware(ratelimit.HTTPConfig{
+		Limiter:   limiter,
+		Rules:     cfg.Rules,
+		Operation: operation,
+		ClientIP: func(r *http.Request) string {
+			meta, _ := chimiddlewareutils.GetRequestMeta(r.Context())
+			return meta.IPAddress
+		},
+		Subject: func(r *http.Request) string {
+			meta, _ := chimiddlewareutils.GetRequestMeta(r.Context())
+			return meta.Subject
+		},
+	}), nil
 }
 
 // func initLogger(env envconfig.EnvName) *zap.Logger {
@@ -1,7 +1,17 @@
 // Package envconfig defines the configuration for the auth service.
 package envconfig
 
-import "time"
+import (
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/clientip"
+	"github.com/incheat/go-production-backend/pkg/cookie"
+	obsconfig "github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+)
 
 // EnvName is the name of the environment.
 type EnvName string
@@ -17,24 +27,131 @@ const (
 
 // Config is the configuration for the application.
 type Config struct {
-	Env         EnvName
-	Version     string
+	Env         EnvName `env:"ENV"`
+	Version     string  `env:"AUTH_VERSION" required:"true"`
+	Secrets     Secrets
 	Server      Server
 	Redis       Redis
 	JWT         JWT
 	Refresh     Refresh
+	CSRF        CSRF
+	CORS        CORS
 	UserGateway UserGateway
+	RateLimit   RateLimit
+	Shutdown    Shutdown
+	Health      Health
 	Obs         Obs
 }
 
+// Secrets is the configuration for resolving secret references. It is declared before
+// the settings that may refer to Vault, so it is loaded first.
+type Secrets struct {
+	Vault Vault
+	// ReloadInterval is how often file and Vault references are polled for rotation.
+	ReloadInterval time.Duration `env:"AUTH_SECRETS_RELOAD_INTERVAL_SEC" default:"30" unit:"s"`
+}
+
+// Vault is the configuration for vault:// secret references.
+type Vault struct {
+	Address   string `env:"AUTH_VAULT_ADDR"`
+	Token     string `env:"AUTH_VAULT_TOKEN" secret:"true"`
+	Namespace string `env:"AUTH_VAULT_NAMESPACE"`
+}
+
+// Health is the configuration for the dependency health checks.
+type Health struct {
+	// Interval is the time between two rounds of checks.
+	Interval time.Duration `env:"AUTH_HEALTH_CHECK_INTERVAL_SEC" default:"10" unit:"s"`
+	// Timeout bounds a single check.
+	Timeout time.Duration `env:"AUTH_HEALTH_CHECK_TIMEOUT_MS" default:"1000" unit:"ms"`
+}
+
+// Shutdown is the configuration for the graceful shutdown.
+type Shutdown struct {
+	// DrainPeriod is how long /readyz fails before the server stops accepting requests.
+	DrainPeriod time.Duration `env:"AUTH_SHUTDOWN_DRAIN_SEC" default:"5" unit:"s"`
+	// Timeout bounds the time in-flight requests get to finish.
+	Timeout time.Duration `env:"AUTH_SHUTDOWN_TIMEOUT_SEC" default:"20" unit:"s"`
+}
+
+// RateLimit is the configuration for rate limiting.
+type RateLimit struct {
+	Enabled bool `env:"AUTH_RATE_LIMIT_ENABLED" default:"false"`
+	// Rules are keyed by OpenAPI operation ID.
+	Rules ratelimit.Rules `env:"AUTH_RATE_LIMITS" default:"Login=10/1m:ip;Refresh=30/1m:ip"`
+}
+
 // Server is the configuration for the server.
 type Server struct {
-	HTTPPort Port
+	HTTPPort     Port          `env:"AUTH_HTTP_PORT" required:"true"`
+	ReadTimeout  time.Duration `env:"AUTH_HTTP_READ_TIMEOUT_SEC" default:"10" unit:"s"`
+	WriteTimeout time.Duration `env:"AUTH_HTTP_WRITE_TIMEOUT_SEC" default:"15" unit:"s"`
+	IdleTimeout  time.Duration `env:"AUTH_HTTP_IDLE_TIMEOUT_SEC" default:"60" unit:"s"`
+	// ErrorFormat is the shape of error bodies for clients not accepting
+	// application/problem+json: "legacy" or "problem".
+	ErrorFormat apierror.Format `env:"AUTH_HTTP_ERROR_FORMAT" default:"legacy"`
+	// TrustedProxies are the proxies whose forwarding headers are believed when resolving
+	// client IPs; keep in sync with Envoy's internal_address_config.
+	TrustedProxies clientip.Prefixes `env:"AUTH_TRUSTED_PROXIES" default:"10.0.0.0/8,172.16.0.0/12,192.168.0.0/16"`
 }
 
+// UserGatewayTransport is the transport used to reach the user service.
+type UserGatewayTransport string
+
+const (
+	// UserGatewayTransportGRPC calls the user service over gRPC.
+	UserGatewayTransportGRPC UserGatewayTransport = "grpc"
+	// UserGatewayTransportHTTP calls the user service's internal OpenAPI over HTTP.
+	UserGatewayTransportHTTP UserGatewayTransport = "http"
+)
+
 // UserGateway is the configuration for the user gateway.
 type UserGateway struct {
-	InternalAddress string
+	Transport       UserGatewayTransport `env:"AUTH_USER_GATEWAY_TRANSPORT" default:"grpc"`
+	InternalAddress string               `env:"USER_GRPC_ADDR"`
+	HTTPBaseURL     string               `env:"USER_HTTP_ADDR"`
+	// Timeout is the deadline of a single call to the user service.
+	Timeout time.Duration `env:"AUTH_USER_GATEWAY_TIMEOUT_MS" default:"2000" unit:"ms"`
+	Retry   Retry
+	Breaker Breaker
+	// TLS enables mutual TLS towards the user service (gRPC transport only).
+	TLS TLS
+	// ServiceToken attaches a service JWT to every call, as gRPC metadata or as the HTTP
+	// bearer token depending on the transport.
+	ServiceToken ServiceToken
+}
+
+// ServiceToken is the configuration for the service tokens the auth service mints for itself.
+type ServiceToken struct {
+	Enabled bool `env:"AUTH_SERVICE_TOKEN_ENABLED" default:"false"`
+	// Subject is the caller name the user service's policies refer to. Defaults to the service name.
+	Subject  string        `env:"AUTH_SERVICE_TOKEN_SUBJECT"`
+	Audience string        `env:"AUTH_SERVICE_TOKEN_AUDIENCE" default:"user"`
+	TTL      time.Duration `env:"AUTH_SERVICE_TOKEN_TTL_SEC" default:"300" unit:"s"`
+}
+
+// TLS is the configuration for mutual TLS.
+type TLS struct {
+	Enabled  bool   `env:"AUTH_USER_GATEWAY_TLS_ENABLED" default:"false"`
+	CertFile string `env:"AUTH_USER_GATEWAY_TLS_CERT_FILE"`
+	KeyFile  string `env:"AUTH_USER_GATEWAY_TLS_KEY_FILE"`
+	CAFile   string `env:"AUTH_USER_GATEWAY_TLS_CA_FILE"`
+	// ServerName is verified against the user service certificate. Empty uses the dialed host.
+	ServerName     string        `env:"AUTH_USER_GATEWAY_TLS_SERVER_NAME"`
+	ReloadInterval time.Duration `env:"AUTH_USER_GATEWAY_TLS_RELOAD_INTERVAL_SEC" default:"30" unit:"s"`
+}
+
+// Retry is the configuration for retrying unavailable calls.
+type Retry struct {
+	MaxAttempts int           `env:"AUTH_USER_GATEWAY_RETRY_MAX_ATTEMPTS" default:"3"`
+	BaseBackoff time.Duration `env:"AUTH_USER_GATEWAY_RETRY_BASE_BACKOFF_MS" default:"50" unit:"ms"`
+	MaxBackoff  time.Duration `env:"AUTH_USER_GATEWAY_RETRY_MAX_BACKOFF_MS" default:"500" unit:"ms"`
+}
+
+// Breaker is the configuration for the circuit breaker.
+type Breaker struct {
+	FailureThreshold int           `env:"AUTH_USER_GATEWAY_BREAKER_FAILURE_THRESHOLD" default:"5"`
+	OpenTimeout      time.Duration `env:"AUTH_USER_GATEWAY_BREAKER_OPEN_TIMEOUT_MS" default:"10000" unit:"ms"`
 }
 
 // Port is the port for the server.
@@ -42,58 +159,180 @@ type Port int
 
 // Redis is the configuration for the Redis.
 type Redis struct {
-	Host     string
-	Password string
-	DB       int
+	Host     string `env:"AUTH_REDIS_HOST"`
+	Password string `env:"AUTH_REDIS_PASSWORD" secret:"true"`
+	DB       int    `env:"AUTH_REDIS_DB" required:"true"`
 }
 
 // JWT is the configuration for the JWT.
 type JWT struct {
-	PrivateKeyPEM string
-	KeyID         string
-	Issuer        string
-	Audience      string
-	Expire        time.Duration
-	JWKSPath      string
+	PrivateKeyPEM string        `env:"AUTH_JWT_PRIVATE_KEY_PEM" required:"true" secret:"true"`
+	KeyID         string        `env:"AUTH_JWT_KEY_ID" required:"true"`
+	Issuer        string        `env:"AUTH_JWT_ISSUER" required:"true"`
+	Audience      string        `env:"AUTH_JWT_AUDIENCE" required:"true"`
+	Expire        time.Duration `env:"AUTH_JWT_EXPIRE" required:"true" unit:"m"`
+	// JWKSPath defaults to constant.JWKSPath.
+	JWKSPath string `env:"AUTH_JWT_JWKS_PATH"`
 }
 
 // Refresh is the configuration for the refresh.
 type Refresh struct {
-	NumBytes int
-	EndPoint string
-	MaxAge   int
+	NumBytes int    `env:"AUTH_REFRESH_NUM_BYTES" required:"true"`
+	EndPoint string `env:"AUTH_REFRESH_END_POINT"`
+	MaxAge   int    `env:"AUTH_REFRESH_MAX_AGE" required:"true"`
+	Cookie   RefreshCookie
+}
+
+// RefreshCookie is the configuration for the refresh token cookie. A "__Host-" name
+// requires Secure, no domain and the "/" path.
+type RefreshCookie struct {
+	Name   string `env:"AUTH_REFRESH_COOKIE_NAME" default:"refresh_token"`
+	Domain string `env:"AUTH_REFRESH_COOKIE_DOMAIN"`
+	// Path must cover both the refresh and the logout endpoints, which read the cookie.
+	Path        string          `env:"AUTH_REFRESH_COOKIE_PATH" default:"/v1"`
+	SameSite    cookie.SameSite `env:"AUTH_REFRESH_COOKIE_SAME_SITE" default:"lax"`
+	Secure      bool            `env:"AUTH_REFRESH_COOKIE_SECURE" default:"true"`
+	Partitioned bool            `env:"AUTH_REFRESH_COOKIE_PARTITIONED" default:"false"`
+}
+
+// CSRF is the configuration for the CSRF checks of cookie-authenticated requests.
+type CSRF struct {
+	Enabled bool `env:"AUTH_CSRF_ENABLED" default:"true"`
+	// TrustedOrigins are the browser origins, besides the API's own, allowed to send
+	// requests with the refresh token cookie.
+	TrustedOrigins []string `env:"AUTH_CSRF_TRUSTED_ORIGINS"`
+}
+
+// CORS is the configuration for the CORS headers of the public API. No allowed origins
+// disables CORS.
+type CORS struct {
+	// AllowedOrigins are exact origins, subdomain patterns such as https://*.example.com,
+	// or * when credentials are not allowed.
+	AllowedOrigins   []string      `env:"AUTH_CORS_PUBLIC_ALLOWED_ORIGINS"`
+	AllowCredentials bool          `env:"AUTH_CORS_PUBLIC_ALLOW_CREDENTIALS" default:"true"`
+	AllowedMethods   []string      `env:"AUTH_CORS_PUBLIC_ALLOWED_METHODS" default:"GET,POST"`
+	AllowedHeaders   []string      `env:"AUTH_CORS_PUBLIC_ALLOWED_HEADERS" default:"Content-Type,Authorization,X-Request-ID"`
+	ExposedHeaders   []string      `env:"AUTH_CORS_PUBLIC_EXPOSED_HEADERS" default:"versionId,X-Request-ID"`
+	MaxAge           time.Duration `env:"AUTH_CORS_PUBLIC_MAX_AGE_SEC" default:"600" unit:"s"`
 }
 
 // Obs is the configuration for the observability.
 type Obs struct {
+	Admin     Admin
 	Profiling Profiling
 	Logging   Logging
+	AccessLog AccessLog
 	Metrics   Metrics
 	Tracing   Tracing
 	OTLP      OTLP
+	Redaction Redaction
 }
 
 // Profiling is the configuration for the profiling.
 type Profiling struct {
-	Port Port
+	Port Port `env:"PROFILING_PORT" required:"true"`
+	// TLS serves the profiling port over mutual TLS; clients with a certificate signed by
+	// the CA need no admin token.
+	TLS     ProfilingTLS
+	Capture ProfilingCapture
+	Push    ProfilingPush
+}
+
+// ProfilingTLS is the configuration for mutual TLS on the profiling port.
+type ProfilingTLS struct {
+	CertFile string `env:"AUTH_PROFILING_TLS_CERT_FILE"`
+	KeyFile  string `env:"AUTH_PROFILING_TLS_KEY_FILE"`
+	CAFile   string `env:"AUTH_PROFILING_TLS_CA_FILE"`
+	// AllowedSANs restricts the client certificates accepted. Empty accepts any certificate signed by the CA.
+	AllowedSANs []string `env:"AUTH_PROFILING_TLS_ALLOWED_SANS" key:"allowed_sans"`
+}
+
+// ProfilingCapture is the configuration for the profiles captured on thresholds.
+type ProfilingCapture struct {
+	// Dir receives the captures. Empty disables them.
+	Dir string `env:"AUTH_PROFILING_CAPTURE_DIR"`
+	// LatencyThreshold captures when a request takes longer. Zero disables it.
+	LatencyThreshold time.Duration `env:"AUTH_PROFILING_CAPTURE_LATENCY_MS" default:"0" unit:"ms"`
+	// HeapThresholdMB captures when the live heap grows larger. Zero disables it.
+	HeapThresholdMB int           `env:"AUTH_PROFILING_CAPTURE_HEAP_MB" default:"0"`
+	CPUDuration     time.Duration `env:"AUTH_PROFILING_CAPTURE_CPU_SEC" default:"10" unit:"s"`
+	Cooldown        time.Duration `env:"AUTH_PROFILING_CAPTURE_COOLDOWN_SEC" default:"300" unit:"s"`
+	Retention       time.Duration `env:"AUTH_PROFILING_CAPTURE_RETENTION_HOURS" default:"72" unit:"h"`
+	MaxCaptures     int           `env:"AUTH_PROFILING_CAPTURE_MAX" default:"20"`
+}
+
+// ProfilingPush is the configuration for pushing profiles to Pyroscope.
+type ProfilingPush struct {
+	// Address is the Pyroscope URL, e.g. http://pyroscope:4040. Empty disables the push.
+	Address   string `env:"AUTH_PROFILING_PUSH_ADDRESS"`
+	AuthToken string `env:"AUTH_PROFILING_PUSH_TOKEN" secret:"true"`
+	TenantID  string `env:"AUTH_PROFILING_PUSH_TENANT_ID" key:"tenant_id"`
 }
 
 // Logging is the configuration for the logging.
 type Logging struct {
-	Level string
+	Level string `env:"AUTH_LOGGING_LEVEL"`
+	// DebugSecret verifies the tokens forcing debug logging for a single request. Empty disables them.
+	DebugSecret string `env:"AUTH_DEBUG_LOG_SECRET" secret:"true"`
+}
+
+// AccessLog is the configuration for the access log.
+type AccessLog struct {
+	Enabled bool `env:"AUTH_ACCESS_LOG_ENABLED" default:"true"`
+	// Sampling is the rate of logged requests per match, first match wins, e.g.
+	// "error=1;/readyz=0.01". Requests no rule matches are always logged.
+	Sampling logging.SampleRules `env:"AUTH_ACCESS_LOG_SAMPLING" default:"error=1;/livez=0.01;/readyz=0.01;/healthz=0.01"`
+	// ExcludePaths are never logged: HTTP paths or gRPC methods, "*" suffix for prefixes.
+	ExcludePaths []string `env:"AUTH_ACCESS_LOG_EXCLUDE_PATHS"`
+}
+
+// Admin is the configuration for the admin endpoints on the profiling listener.
+type Admin struct {
+	// Token is the bearer token of the admin endpoints. Empty disables them.
+	Token string `env:"AUTH_ADMIN_TOKEN" secret:"true"`
 }
 
 // Metrics is the configuration for the metrics.
 type Metrics struct {
-	Port Port
+	Port Port `env:"PROM_METRICS_PORT" required:"true"`
+	// Prometheus exposes the OpenTelemetry instruments on /metrics.
+	Prometheus bool `env:"AUTH_METRICS_PROMETHEUS" default:"true"`
+	// Exporter pushes the instruments to the collector: otlp-grpc, otlp-http or none.
+	Exporter       obsconfig.Exporter `env:"AUTH_METRICS_EXPORTER" default:"none"`
+	ExportInterval time.Duration      `env:"AUTH_METRICS_EXPORT_INTERVAL_SEC" default:"15" unit:"s"`
+	// Exemplars links histogram buckets to the trace of sampled requests.
+	Exemplars bool `env:"AUTH_METRICS_EXEMPLARS" default:"true"`
 }
 
 // Tracing is the configuration for the tracing.
 type Tracing struct {
-	SamplingRatio float64
+	SamplingRatio float64 `env:"AUTH_TRACING_SAMPLING_RATIO" required:"true"`
+	// SamplingRules override the ratio per route, first match wins, e.g. "/livez=0;/v1/*=0.5".
+	SamplingRules obsconfig.SamplingRules `env:"AUTH_TRACING_SAMPLING_RULES" default:"/livez=0;/readyz=0;/healthz=0"`
+	// SampleErrors exports spans ending with an error even when their trace is not sampled.
+	SampleErrors bool `env:"AUTH_TRACING_SAMPLE_ERRORS" default:"true"`
+	// Exporter is one of otlp-grpc, otlp-http, stdout, file or none.
+	Exporter obsconfig.Exporter `env:"AUTH_TRACING_EXPORTER" default:"otlp-grpc"`
+	// FilePath is where the file exporter appends spans.
+	FilePath string `env:"AUTH_TRACING_FILE_PATH"`
+}
+
+// Redaction is the configuration for the redaction of logs and spans.
+type Redaction struct {
+	// Fields are redacted from log fields and span attributes, e.g. "email=hash;client_ip=mask".
+	// Passwords, tokens, authorization and cookies are always masked.
+	Fields redact.Policy `env:"AUTH_REDACT_FIELDS" default:"email=hash;user.email=hash;client_ip=hash;user_agent=mask"`
 }
 
 // OTLP is the configuration for the OpenTelemetry.
 type OTLP struct {
-	Endpoint string
+	Endpoint string `env:"OTLP_ENDPOINT"`
+	// Insecure disables TLS to the collector.
+	Insecure bool `env:"OTLP_INSECURE" default:"true"`
+	// CAFile verifies the collector; empty uses the system roots.
+	CAFile string `env:"OTLP_CA_FILE"`
+	// CertFile and KeyFile are the client certificate, if the collector requires one.
+	CertFile   string `env:"OTLP_CERT_FILE"`
+	KeyFile    string `env:"OTLP_KEY_FILE"`
+	ServerName string `env:"OTLP_SERVER_NAME"`
 }
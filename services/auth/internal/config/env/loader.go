@@ -3,176 +3,117 @@ package envconfig
 import (
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/incheat/go-production-backend/pkg/config"
+	"github.com/incheat/go-production-backend/pkg/cookie"
+	"github.com/incheat/go-production-backend/pkg/secrets"
 	"github.com/incheat/go-production-backend/services/auth/internal/constant"
 )
 
-// errMissingEnv is the error returned when a required environment variable is missing.
-var errMissingEnv = errors.New("missing env var")
-
-// Load loads the configuration from the environment variables.
-func Load() (*Config, error) {
-	env := getString("ENV") // if missing, it will be ""
-
-	authVersion := getString("AUTH_VERSION")
-	if authVersion == "" {
-		return nil, fmt.Errorf("AUTH_VERSION: %w", errMissingEnv)
-	}
-	authHTTPPort, err := getIntRequired("AUTH_HTTP_PORT")
-	if err != nil {
-		return nil, err
-	}
-
-	authRedisHost := getString("AUTH_REDIS_HOST")
-	authRedisPassword := getString("AUTH_REDIS_PASSWORD")
-	authRedisDB, err := getIntRequired("AUTH_REDIS_DB")
-	if err != nil {
-		return nil, err
-	}
-
-	authJWTPrivateKeyPEM := getString("AUTH_JWT_PRIVATE_KEY_PEM")
-	authJWTKeyID := getString("AUTH_JWT_KEY_ID")
-	authJWTIssuer := getString("AUTH_JWT_ISSUER")
-	authJWTAudience := getString("AUTH_JWT_AUDIENCE")
-	authJWKSPath := getString("AUTH_JWT_JWKS_PATH")
-	if authJWKSPath == "" {
-		authJWKSPath = constant.JWKSPath
-	}
-	authJWTExpireRaw, err := getIntRequired("AUTH_JWT_EXPIRE")
-	if err != nil {
-		return nil, err
-	}
-	authJWTExpire := time.Duration(authJWTExpireRaw) * time.Minute
-
-	authRefreshNumBytes, err := getIntRequired("AUTH_REFRESH_NUM_BYTES")
-	if err != nil {
-		return nil, err
-	}
-	authRefreshEndPoint := getString("AUTH_REFRESH_END_POINT")
-	authRefreshMaxAge, err := getIntRequired("AUTH_REFRESH_MAX_AGE")
-	if err != nil {
-		return nil, err
-	}
-
-	authUserGatewayInternalAddress := getString("USER_GRPC_ADDR")
-
-	authProfilingPort, err := getIntRequired("PROFILING_PORT")
-	if err != nil {
-		return nil, err
-	}
-	authMetricsPort, err := getIntRequired("PROM_METRICS_PORT")
-	if err != nil {
-		return nil, err
-	}
-	authLoggingLevel := getString("AUTH_LOGGING_LEVEL")
-	authTracingSamplingRatio, err := getFloat64Required("AUTH_TRACING_SAMPLING_RATIO")
-	if err != nil {
-		return nil, err
-	}
-	otlpEndpoint := getString("OTLP_ENDPOINT")
+// minSecretLength is the minimum length of the admin token and the debug log secret.
+const minSecretLength = 16
 
+// Load loads the configuration from its defaults, the file named by --config or
+// AUTH_CONFIG_FILE, the environment variables and the command-line args, in increasing
+// order of precedence. Secret settings may be file://, env:// or vault:// references. The
+// error reports every invalid setting at once.
+func Load(args []string) (*Config, *config.Result, error) {
 	cfg := &Config{
-		Env:     EnvName(env),
-		Version: authVersion,
-		Server: Server{
-			HTTPPort: Port(authHTTPPort),
-		},
-		UserGateway: UserGateway{
-			InternalAddress: authUserGatewayInternalAddress,
-		},
-		Redis: Redis{
-			Host:     authRedisHost,
-			Password: authRedisPassword,
-			DB:       authRedisDB,
-		},
 		JWT: JWT{
-			PrivateKeyPEM: authJWTPrivateKeyPEM,
-			KeyID:         authJWTKeyID,
-			Issuer:        authJWTIssuer,
-			Audience:      authJWTAudience,
-			Expire:        authJWTExpire,
-			JWKSPath:      authJWKSPath,
+			JWKSPath: constant.JWKSPath,
 		},
-		Refresh: Refresh{
-			NumBytes: authRefreshNumBytes,
-			EndPoint: authRefreshEndPoint,
-			MaxAge:   authRefreshMaxAge,
-		},
-		Obs: Obs{
-			Profiling: Profiling{
-				Port: Port(authProfilingPort),
-			},
-			Logging: Logging{
-				Level: authLoggingLevel,
-			},
-			Metrics: Metrics{
-				Port: Port(authMetricsPort),
-			},
-			Tracing: Tracing{
-				SamplingRatio: authTracingSamplingRatio,
-			},
-			OTLP: OTLP{
-				Endpoint: otlpEndpoint,
+		UserGateway: UserGateway{
+			ServiceToken: ServiceToken{
+				Subject: constant.ServiceName,
 			},
 		},
 	}
 
-	// Optional sanity checks (keep or remove as you like)
-	if err := validate(cfg); err != nil {
-		return nil, err
+	res, err := config.Load(cfg, config.Options{
+		Name:    constant.ServiceName,
+		Args:    args,
+		FileEnv: "AUTH_CONFIG_FILE",
+		Secrets: NewSecretResolver(cfg),
+	})
+	if res == nil {
+		return nil, nil, err
 	}
 
-	return cfg, nil
-}
-
-func getString(name string) string {
-	return strings.TrimSpace(os.Getenv(name))
-}
-
-func getIntRequired(name string) (int, error) {
-	raw := getString(name)
-	if raw == "" {
-		return 0, fmt.Errorf("%s: %w", name, errMissingEnv)
+	if err := errors.Join(err, validate(cfg)); err != nil {
+		return nil, res, err
 	}
-	v, err := strconv.Atoi(raw)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", name, err)
-	}
-	return v, nil
+	return cfg, res, nil
 }
 
-func getFloat64Required(name string) (float64, error) {
-	raw := getString(name)
-	if raw == "" {
-		return 0, fmt.Errorf("%s: %w", name, errMissingEnv)
-	}
-	v, err := strconv.ParseFloat(raw, 64)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", name, err)
-	}
-	return v, nil
+// NewSecretResolver returns the resolver of the secret references in cfg. The Vault
+// provider reads cfg.Secrets.Vault on every resolution, so it can be created before cfg
+// is loaded.
+func NewSecretResolver(cfg *Config) *secrets.Resolver {
+	return secrets.NewResolver(
+		secrets.FileProvider{},
+		secrets.EnvProvider{},
+		secrets.NewVaultProvider(func() secrets.VaultConfig {
+			return secrets.VaultConfig{
+				Address:   cfg.Secrets.Vault.Address,
+				Token:     cfg.Secrets.Vault.Token,
+				Namespace: cfg.Secrets.Vault.Namespace,
+			}
+		}, nil),
+	)
 }
 
 func validate(cfg *Config) error {
+	var errs []error
+
 	if cfg.Server.HTTPPort <= 0 || cfg.Server.HTTPPort > 65535 {
-		return fmt.Errorf("AUTH_HTTP_PORT: must be between 1 and 65535")
+		errs = append(errs, fmt.Errorf("AUTH_HTTP_PORT: must be between 1 and 65535"))
 	}
 
-	if cfg.JWT.PrivateKeyPEM == "" {
-		return fmt.Errorf("AUTH_JWT_PRIVATE_KEY_PEM is empty")
+	switch cfg.UserGateway.Transport {
+	case UserGatewayTransportGRPC:
+		if cfg.UserGateway.InternalAddress == "" {
+			errs = append(errs, fmt.Errorf("USER_GRPC_ADDR is empty"))
+		}
+	case UserGatewayTransportHTTP:
+		if cfg.UserGateway.HTTPBaseURL == "" {
+			errs = append(errs, fmt.Errorf("USER_HTTP_ADDR is empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_TRANSPORT: must be %q or %q", UserGatewayTransportGRPC, UserGatewayTransportHTTP))
+	}
+	if cfg.UserGateway.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_TIMEOUT_MS: must be positive"))
 	}
-	if cfg.JWT.KeyID == "" {
-		return fmt.Errorf("AUTH_JWT_KEY_ID is empty")
+	if cfg.UserGateway.Retry.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_RETRY_MAX_ATTEMPTS: must be at least 1"))
 	}
-	if cfg.JWT.Issuer == "" {
-		return fmt.Errorf("AUTH_JWT_ISSUER is empty")
+	if cfg.UserGateway.Breaker.FailureThreshold < 1 {
+		errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_BREAKER_FAILURE_THRESHOLD: must be at least 1"))
 	}
-	if cfg.JWT.Audience == "" {
-		return fmt.Errorf("AUTH_JWT_AUDIENCE is empty")
+	if cfg.UserGateway.TLS.Enabled {
+		if cfg.UserGateway.Transport != UserGatewayTransportGRPC {
+			errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_TLS_ENABLED: only supported with AUTH_USER_GATEWAY_TRANSPORT=%s", UserGatewayTransportGRPC))
+		}
+		if cfg.UserGateway.TLS.CertFile == "" || cfg.UserGateway.TLS.KeyFile == "" || cfg.UserGateway.TLS.CAFile == "" {
+			errs = append(errs, fmt.Errorf("AUTH_USER_GATEWAY_TLS_CERT_FILE, AUTH_USER_GATEWAY_TLS_KEY_FILE and AUTH_USER_GATEWAY_TLS_CA_FILE are required when AUTH_USER_GATEWAY_TLS_ENABLED=true"))
+		}
 	}
-	return nil
+	if cfg.UserGateway.ServiceToken.Enabled && cfg.UserGateway.ServiceToken.TTL < time.Minute {
+		errs = append(errs, fmt.Errorf("AUTH_SERVICE_TOKEN_TTL_SEC: must be at least 60"))
+	}
+	for _, path := range []string{constant.RefreshPath, constant.LogoutPath} {
+		if !cookie.PathMatch(cfg.Refresh.Cookie.Path, path) {
+			errs = append(errs, fmt.Errorf("AUTH_REFRESH_COOKIE_PATH: must cover %s and %s", constant.RefreshPath, constant.LogoutPath))
+			break
+		}
+	}
+	if token := cfg.Obs.Admin.Token; token != "" && len(token) < minSecretLength {
+		errs = append(errs, fmt.Errorf("AUTH_ADMIN_TOKEN: must be at least %d characters", minSecretLength))
+	}
+	if secret := cfg.Obs.Logging.DebugSecret; secret != "" && len(secret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("AUTH_DEBUG_LOG_SECRET: must be at least %d characters", minSecretLength))
+	}
+
+	return errors.Join(errs...)
 }
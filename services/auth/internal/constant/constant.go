@@ -6,6 +6,14 @@ const (
 	APIResponseVersionV1 = "v1"
 	// RedisRefreshTokenPrefix is the prefix for the refresh token in Redis.
 	RedisRefreshTokenPrefix = "refresh_token:"
+	// RedisRefreshFamilyPrefix is the prefix for the set of refresh tokens of a session in Redis.
+	RedisRefreshFamilyPrefix = "refresh_family:"
+	// RedisRefreshSessionIndex is the sorted set of active sessions by expiry in Redis.
+	RedisRefreshSessionIndex = "refresh_sessions"
+	// RefreshPath is the path of the Refresh endpoint, which reads the refresh token cookie.
+	RefreshPath = "/v1/refresh"
+	// LogoutPath is the path of the Logout endpoint, which revokes the refresh token cookie.
+	LogoutPath = "/v1/logout"
 	// JWKSPath is the path for the JWKS endpoint.
 	JWKSPath = "/.well-known/jwks.json"
 	// ServiceName is the name of the service for the auth.
@@ -0,0 +1,26 @@
+// Package gateway defines the errors shared by the gateways of the auth service.
+// Every transport of a gateway maps its failures onto these errors so that callers
+// see identical semantics regardless of the transport in use. They are coded
+// apierror errors, so errors.Is matches them by code and the handlers can return
+// their code and message to clients.
+package gateway
+
+import "github.com/incheat/go-production-backend/pkg/apierror"
+
+var (
+	// ErrInvalidCredentials is the error for when the user service rejects the credentials.
+	ErrInvalidCredentials = apierror.New(apierror.CodeInvalidCredentials, "invalid email or password")
+	// ErrInvalidRequest is the error for when the remote service rejects the request as invalid.
+	ErrInvalidRequest = apierror.New(apierror.CodeInvalidRequest, "invalid request")
+	// ErrForbidden is the error for when the remote service denies the call.
+	ErrForbidden = apierror.New(apierror.CodeForbidden, "forbidden")
+	// ErrUserNotActive is the error for when the user service accepts the credentials of a
+	// disabled or locked user.
+	ErrUserNotActive = apierror.New(apierror.CodeUserNotActive, "user is not active")
+	// ErrNotFound is the error for when the remote service does not know the resource.
+	ErrNotFound = apierror.New(apierror.CodeNotFound, "not found")
+	// ErrUnavailable is the error for when the remote service cannot be reached or timed out.
+	ErrUnavailable = apierror.New(apierror.CodeUnavailable, "service unavailable")
+	// ErrUnexpected is the error for any other failure of the remote service.
+	ErrUnexpected = apierror.New(apierror.CodeInternal, "unexpected gateway error")
+)
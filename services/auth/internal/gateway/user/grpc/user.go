@@ -3,13 +3,26 @@ package usergateway
 
 import (
 	"context"
-	"time"
+	"crypto/tls"
+	"fmt"
+	"strings"
 
 	userpb "github.com/incheat/go-production-backend/api/user/grpc/gen"
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/health"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
 	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 // UserGateway is the gateway for the user service.
@@ -18,14 +31,79 @@ type UserGateway struct {
 	client userpb.UserServiceInternalClient
 }
 
+// roundRobinServiceConfig spreads calls over every address the resolver returns.
+const roundRobinServiceConfig = `{"loadBalancingConfig":[{"round_robin":{}}]}`
+
+// options is the optional configuration of the UserGateway.
+type options struct {
+	tlsConfig *tls.Config
+	perRPC    credentials.PerRPCCredentials
+	logger    *zap.Logger
+}
+
+// Option configures the UserGateway.
+type Option func(*options)
+
+// WithTLS dials the user service with tlsConfig instead of plaintext.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithPerRPCCredentials attaches creds, e.g. a service token, to every call.
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) Option {
+	return func(o *options) {
+		o.perRPC = creds
+	}
+}
+
+// WithLogger logs the calls to the user service with the request-scoped logger of their
+// context, falling back to logger. Without it only request-scoped loggers are used.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
 // New creates a new UserGateway.
-func New(addr string) (*UserGateway, error) {
+// An addr without a scheme is resolved through DNS, and calls are balanced round-robin
+// over all resolved user service addresses.
+func New(addr string, opts ...Option) (*UserGateway, error) {
+
+	o := options{logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !strings.Contains(addr, ":///") {
+		addr = "dns:///" + addr
+	}
 
-	conn, err := grpc.NewClient(
-		addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
-	)
+	creds := insecure.NewCredentials()
+	if o.tlsConfig != nil {
+		creds = credentials.NewTLS(o.tlsConfig)
+	}
+
+	chain := new(grpcchain.Client).
+		StatsHandler(otelgrpc.NewClientHandler()).
+		Use(
+			correlation.GRPCClientRequestID(),
+			grpcchain.ClientInterceptor{
+				Unary:  logging.GRPCClientLogging(o.logger),
+				Stream: logging.GRPCStreamClientLogging(o.logger),
+			},
+		)
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(roundRobinServiceConfig),
+	}, chain.DialOptions()...)
+	if o.perRPC != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(o.perRPC))
+	}
+
+	conn, err := grpc.NewClient(addr, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -41,18 +119,20 @@ func (g *UserGateway) Close() error {
 	return g.conn.Close()
 }
 
+// Check checks the user service through its gRPC health service.
+func (g *UserGateway) Check(ctx context.Context) error {
+	return health.GRPCChecker(g.conn, "").Check(ctx)
+}
+
 // VerifyCredentials verifies a user's credentials.
+// The deadline of the call is taken from ctx.
 func (g *UserGateway) VerifyCredentials(ctx context.Context, email string, password string) (*usermodel.User, error) {
-
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
-
 	resp, err := g.client.VerifyUserCredentials(ctx, &userpb.VerifyUserCredentialsRequest{
 		Email:    email,
 		Password: password,
 	})
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &usermodel.User{
@@ -61,3 +141,40 @@ func (g *UserGateway) VerifyCredentials(ctx context.Context, email string, passw
 		Status: resp.GetStatus(),
 	}, nil
 }
+
+// mapError maps a gRPC status onto the gateway errors.
+// A rejection of the auth service's own identity is ErrUnexpected whatever its code:
+// it is a misconfiguration, not an answer about the credentials.
+func mapError(err error) error {
+	st := status.Convert(err)
+	if svcauth.IsRejection(err) {
+		return fmt.Errorf("%w: service authorization: %s: %s", gateway.ErrUnexpected, st.Code(), st.Message())
+	}
+	switch st.Code() {
+	case codes.Unauthenticated:
+		return gateway.ErrInvalidCredentials
+	case codes.InvalidArgument:
+		return fmt.Errorf("%w: %s", gateway.ErrInvalidRequest, st.Message())
+	case codes.PermissionDenied:
+		if reason(st) == usermodel.ErrorReasonUserNotActive {
+			return gateway.ErrUserNotActive
+		}
+		return fmt.Errorf("%w: %s", gateway.ErrForbidden, st.Message())
+	case codes.NotFound:
+		return fmt.Errorf("%w: %s", gateway.ErrNotFound, st.Message())
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return fmt.Errorf("%w: %s", gateway.ErrUnavailable, st.Message())
+	default:
+		return fmt.Errorf("%w: %s: %s", gateway.ErrUnexpected, st.Code(), st.Message())
+	}
+}
+
+// reason returns the reason of the user service's google.rpc.ErrorInfo of st, if any.
+func reason(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == usermodel.ErrorDomain {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
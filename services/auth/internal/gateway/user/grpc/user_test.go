@@ -0,0 +1,102 @@
+package usergateway_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	userpb "github.com/incheat/go-production-backend/api/user/grpc/gen"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	usergateway "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/grpc"
+	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeUserServer answers every call with err, or a user when err is nil.
+type fakeUserServer struct {
+	userpb.UnimplementedUserServiceInternalServer
+	err error
+}
+
+func (s *fakeUserServer) VerifyUserCredentials(context.Context, *userpb.VerifyUserCredentialsRequest) (*userpb.VerifyUserCredentialsResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &userpb.VerifyUserCredentialsResponse{Id: "1", Email: "user@example.com", Status: "active"}, nil
+}
+
+// userNotActive returns the status of the user service for valid credentials of a user
+// that is not active.
+func userNotActive(t *testing.T) error {
+	t.Helper()
+	st, err := status.New(codes.PermissionDenied, "user is not active").WithDetails(&errdetails.ErrorInfo{
+		Reason: usermodel.ErrorReasonUserNotActive,
+		Domain: usermodel.ErrorDomain,
+	})
+	require.NoError(t, err)
+	return st.Err()
+}
+
+// newGateway starts a user service answering with err and returns a gateway calling it.
+func newGateway(t *testing.T, err error) *usergateway.UserGateway {
+	t.Helper()
+	lis, listenErr := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, listenErr)
+	srv := grpc.NewServer()
+	userpb.RegisterUserServiceInternalServer(srv, &fakeUserServer{err: err})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	gw, gwErr := usergateway.New(lis.Addr().String())
+	require.NoError(t, gwErr)
+	t.Cleanup(func() { _ = gw.Close() })
+	return gw
+}
+
+// TestUnitVerifyCredentials_MapsStatuses tests that gRPC statuses map onto the gateway
+// errors, inactive users are told apart from other denials, and rejections of the
+// caller's service identity are never taken for rejected credentials.
+func TestUnitVerifyCredentials_MapsStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "ok"},
+		{name: "invalid credentials", err: status.Error(codes.Unauthenticated, "invalid email or password"), wantErr: gateway.ErrInvalidCredentials},
+		{name: "missing caller identity", err: svcauth.Rejection(codes.Unauthenticated, "caller identity required"), wantErr: gateway.ErrUnexpected},
+		{name: "invalid service token", err: svcauth.Rejection(codes.Unauthenticated, "invalid service token"), wantErr: gateway.ErrUnexpected},
+		{name: "caller not allowed", err: svcauth.Rejection(codes.PermissionDenied, "caller not allowed"), wantErr: gateway.ErrUnexpected},
+		{name: "user not active", err: userNotActive(t), wantErr: gateway.ErrUserNotActive},
+		{name: "forbidden", err: status.Error(codes.PermissionDenied, "forbidden"), wantErr: gateway.ErrForbidden},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), wantErr: gateway.ErrUnavailable},
+		{name: "internal", err: status.Error(codes.Internal, "internal error"), wantErr: gateway.ErrUnexpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := newGateway(t, tt.err)
+
+			user, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				if tt.wantErr != gateway.ErrInvalidCredentials {
+					assert.NotErrorIs(t, err, gateway.ErrInvalidCredentials)
+				}
+				if tt.wantErr != gateway.ErrUserNotActive {
+					assert.NotErrorIs(t, err, gateway.ErrUserNotActive)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "1", user.ID)
+			assert.Equal(t, "active", user.Status)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package usergateway defines the HTTP gateway for the user service.
+package usergateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	clientgen "github.com/incheat/go-production-backend/api/user/oapi/gen/private"
+	"github.com/incheat/go-production-backend/pkg/health"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// UserGateway is the HTTP gateway for the user service.
+type UserGateway struct {
+	httpClient *http.Client
+	client     *clientgen.ClientWithResponses
+	readyz     health.Checker
+}
+
+// Option configures the UserGateway.
+type Option func(*options)
+
+type options struct {
+	authorize func(*http.Request) error
+}
+
+// WithServiceToken sends the service token of source as the bearer token of every call.
+func WithServiceToken(source *svcauth.TokenSource) Option {
+	return func(o *options) {
+		o.authorize = source.SetAuthorization
+	}
+}
+
+// New creates a new UserGateway calling the user service's internal OpenAPI at baseURL.
+func New(baseURL string, opts ...Option) (*UserGateway, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	httpClient := &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+
+	clientOpts := []clientgen.ClientOption{clientgen.WithHTTPClient(httpClient)}
+	if o.authorize != nil {
+		clientOpts = append(clientOpts, clientgen.WithRequestEditorFn(func(_ context.Context, req *http.Request) error {
+			return o.authorize(req)
+		}))
+	}
+	client, err := clientgen.NewClientWithResponses(baseURL, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserGateway{
+		httpClient: httpClient,
+		client:     client,
+		readyz:     health.HTTPChecker(httpClient, strings.TrimSuffix(baseURL, "/")+"/readyz"),
+	}, nil
+}
+
+// Close releases idle connections to the user service.
+func (g *UserGateway) Close() error {
+	g.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Check checks the user service through its /readyz endpoint.
+func (g *UserGateway) Check(ctx context.Context) error {
+	return g.readyz.Check(ctx)
+}
+
+// VerifyCredentials verifies a user's credentials.
+// The deadline of the call is taken from ctx.
+func (g *UserGateway) VerifyCredentials(ctx context.Context, email string, password string) (*usermodel.User, error) {
+	resp, err := g.client.VerifyUserCredentialsWithResponse(ctx, clientgen.VerifyUserCredentialsJSONRequestBody{
+		Email:    openapi_types.Email(email),
+		Password: password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", gateway.ErrUnavailable, err)
+	}
+
+	switch {
+	case svcauth.IsRejectionResponse(resp.HTTPResponse.Header):
+		// A rejection of the auth service's own identity is a misconfiguration, not an
+		// answer about the credentials.
+		return nil, fmt.Errorf("%w: service authorization: user service returned %d", gateway.ErrUnexpected, resp.StatusCode())
+	case resp.JSON200 != nil:
+		return &usermodel.User{
+			ID:     resp.JSON200.Id,
+			Email:  string(resp.JSON200.Email),
+			Status: resp.JSON200.Status,
+		}, nil
+	case resp.StatusCode() == http.StatusUnauthorized:
+		return nil, gateway.ErrInvalidCredentials
+	case resp.StatusCode() == http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: user service returned %d", gateway.ErrInvalidRequest, resp.StatusCode())
+	case resp.JSON403 != nil && resp.JSON403.Reason != nil && *resp.JSON403.Reason == usermodel.ErrorReasonUserNotActive:
+		return nil, gateway.ErrUserNotActive
+	case resp.StatusCode() == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: user service returned %d", gateway.ErrForbidden, resp.StatusCode())
+	case resp.StatusCode() == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: user service returned %d", gateway.ErrNotFound, resp.StatusCode())
+	case resp.StatusCode() == http.StatusServiceUnavailable,
+		resp.StatusCode() == http.StatusTooManyRequests,
+		resp.StatusCode() == http.StatusBadGateway,
+		resp.StatusCode() == http.StatusGatewayTimeout:
+		return nil, fmt.Errorf("%w: user service returned %d", gateway.ErrUnavailable, resp.StatusCode())
+	default:
+		return nil, fmt.Errorf("%w: user service returned %d", gateway.ErrUnexpected, resp.StatusCode())
+	}
+}
@@ -0,0 +1,101 @@
+package usergateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	usergateway "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnitVerifyCredentials_MapsResponses tests that HTTP responses map onto the same
+// gateway errors as the gRPC transport, inactive users are told apart from other denials,
+// and rejections of the caller's service identity are never taken for rejected credentials.
+func TestUnitVerifyCredentials_MapsResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		rejection bool
+		wantErr   error
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"id":"1","email":"user@example.com","status":"active"}`},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error":"invalid credentials"}`, wantErr: gateway.ErrInvalidCredentials},
+		{name: "missing service token", status: http.StatusUnauthorized, body: `{"error":"caller identity required"}`, rejection: true, wantErr: gateway.ErrUnexpected},
+		{name: "caller not allowed", status: http.StatusForbidden, body: `{"error":"caller not allowed"}`, rejection: true, wantErr: gateway.ErrUnexpected},
+		{name: "user not active", status: http.StatusForbidden, body: `{"error":"user is not active","reason":"USER_NOT_ACTIVE"}`, wantErr: gateway.ErrUserNotActive},
+		{name: "forbidden", status: http.StatusForbidden, body: `{"error":"forbidden"}`, wantErr: gateway.ErrForbidden},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: `{"error":"down"}`, wantErr: gateway.ErrUnavailable},
+		{name: "internal", status: http.StatusInternalServerError, body: `{"error":"internal error"}`, wantErr: gateway.ErrUnexpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/internal/users/verify", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				if tt.rejection {
+					svcauth.SetRejection(w.Header())
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			gw, err := usergateway.New(srv.URL)
+			require.NoError(t, err)
+
+			user, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				if tt.rejection {
+					assert.NotErrorIs(t, err, gateway.ErrInvalidCredentials)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "1", user.ID)
+			assert.Equal(t, "active", user.Status)
+		})
+	}
+}
+
+// TestUnitVerifyCredentials_ConnectionRefused tests that transport failures map to ErrUnavailable.
+func TestUnitVerifyCredentials_ConnectionRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	gw, err := usergateway.New(url)
+	require.NoError(t, err)
+
+	_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrUnavailable)
+}
+
+// TestUnitCheck_FollowsReadyz tests that the health check follows the user service's /readyz.
+func TestUnitCheck_FollowsReadyz(t *testing.T) {
+	var ready atomic.Bool
+	ready.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/readyz", r.URL.Path)
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	gw, err := usergateway.New(srv.URL + "/")
+	require.NoError(t, err)
+
+	require.NoError(t, gw.Check(context.Background()))
+
+	ready.Store(false)
+	require.Error(t, gw.Check(context.Background()))
+}
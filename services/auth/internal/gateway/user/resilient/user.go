@@ -0,0 +1,118 @@
+// Package usergateway defines the resilient decorator for the user gateway transports.
+// It applies the per-attempt deadline, retries and circuit breaker identically to every
+// transport, so the gRPC and HTTP gateways only have to map their failures onto the
+// gateway errors.
+package usergateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/resilience"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
+)
+
+// BreakerName is the name of the circuit breaker guarding the user service.
+const BreakerName = "user_service"
+
+// Next is the user gateway transport being decorated.
+type Next interface {
+	VerifyCredentials(ctx context.Context, email string, password string) (*usermodel.User, error)
+	Check(ctx context.Context) error
+	Close() error
+}
+
+// Config is the configuration for the resilient user gateway.
+type Config struct {
+	// Timeout is the deadline of a single attempt.
+	Timeout time.Duration
+	Retry   resilience.RetryPolicy
+	Breaker resilience.BreakerConfig
+}
+
+// UserGateway decorates a user gateway transport with deadlines, retries and a circuit breaker.
+type UserGateway struct {
+	next    Next
+	timeout time.Duration
+	retry   resilience.RetryPolicy
+	breaker *resilience.Breaker
+}
+
+// New creates a new UserGateway.
+func New(next Next, cfg Config) *UserGateway {
+	return &UserGateway{
+		next:    next,
+		timeout: cfg.Timeout,
+		retry:   cfg.Retry,
+		breaker: resilience.NewBreaker(BreakerName, cfg.Breaker),
+	}
+}
+
+// Close closes the decorated transport.
+func (g *UserGateway) Close() error {
+	return g.next.Close()
+}
+
+// Check checks the health of the user service. It bypasses the retries and the circuit
+// breaker, so probes neither trip nor hide an open breaker.
+func (g *UserGateway) Check(ctx context.Context) error {
+	return g.next.Check(ctx)
+}
+
+// VerifyCredentials verifies a user's credentials.
+// Only gateway.ErrUnavailable is retried; rejected credentials are returned at once.
+// While the circuit breaker is open the call fails fast with gateway.ErrUnavailable.
+func (g *UserGateway) VerifyCredentials(ctx context.Context, email string, password string) (*usermodel.User, error) {
+	var user *usermodel.User
+	err := resilience.Retry(ctx, g.retry, isRetryable, func(ctx context.Context) error {
+		var err error
+		user, err = g.attempt(ctx, email, password)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// attempt makes a single call through the circuit breaker.
+func (g *UserGateway) attempt(ctx context.Context, email string, password string) (*usermodel.User, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%w: %w", gateway.ErrUnavailable, err)
+	}
+
+	callCtx := ctx
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	user, err := g.next.VerifyCredentials(callCtx, email, password)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up on the call; it says nothing about the user service.
+		g.breaker.Release()
+	} else {
+		g.breaker.Done(err == nil || isClientError(err))
+	}
+	return user, err
+}
+
+// isClientError reports whether err is the user service rejecting the request itself,
+// which is a healthy answer and does not count against the breaker.
+func isClientError(err error) bool {
+	return errors.Is(err, gateway.ErrInvalidCredentials) ||
+		errors.Is(err, gateway.ErrInvalidRequest) ||
+		errors.Is(err, gateway.ErrForbidden) ||
+		errors.Is(err, gateway.ErrUserNotActive) ||
+		errors.Is(err, gateway.ErrNotFound)
+}
+
+// isRetryable reports whether a failed attempt may be retried.
+// Attempts rejected by the open breaker are not retried; the breaker would reject them again.
+func isRetryable(err error) bool {
+	return errors.Is(err, gateway.ErrUnavailable) && !errors.Is(err, resilience.ErrCircuitOpen)
+}
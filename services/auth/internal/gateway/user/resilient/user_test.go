@@ -0,0 +1,186 @@
+package usergateway_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/resilience"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	usergateway "github.com/incheat/go-production-backend/services/auth/internal/gateway/user/resilient"
+	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransport returns the queued errors in order, then succeeds. When call is set, it
+// answers every call instead.
+type fakeTransport struct {
+	errs  []error
+	call  func(ctx context.Context) error
+	calls int
+}
+
+func (f *fakeTransport) VerifyCredentials(ctx context.Context, email string, _ string) (*usermodel.User, error) {
+	f.calls++
+	if f.call != nil {
+		if err := f.call(ctx); err != nil {
+			return nil, err
+		}
+	} else if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &usermodel.User{ID: "1", Email: email}, nil
+}
+
+func (f *fakeTransport) Check(context.Context) error { return nil }
+
+func (f *fakeTransport) Close() error { return nil }
+
+func newGateway(next usergateway.Next, failureThreshold int) *usergateway.UserGateway {
+	return newGatewayWithTimeout(next, failureThreshold, time.Second)
+}
+
+func newGatewayWithTimeout(next usergateway.Next, failureThreshold int, timeout time.Duration) *usergateway.UserGateway {
+	return usergateway.New(next, usergateway.Config{
+		Timeout: timeout,
+		Retry: resilience.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    time.Millisecond,
+		},
+		Breaker: resilience.BreakerConfig{
+			FailureThreshold: failureThreshold,
+			OpenTimeout:      time.Hour,
+		},
+	})
+}
+
+// TestUnitVerifyCredentials_RetriesUnavailable tests that unavailable attempts are retried.
+func TestUnitVerifyCredentials_RetriesUnavailable(t *testing.T) {
+	next := &fakeTransport{errs: []error{gateway.ErrUnavailable, gateway.ErrUnavailable}}
+	gw := newGateway(next, 10)
+
+	user, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.NoError(t, err)
+	assert.Equal(t, "1", user.ID)
+	assert.Equal(t, 3, next.calls)
+}
+
+// TestUnitVerifyCredentials_DoesNotRetryInvalidCredentials tests that rejected credentials
+// are returned at once and do not count against the breaker.
+func TestUnitVerifyCredentials_DoesNotRetryInvalidCredentials(t *testing.T) {
+	next := &fakeTransport{errs: []error{
+		gateway.ErrInvalidCredentials,
+		gateway.ErrInvalidCredentials,
+	}}
+	gw := newGateway(next, 1)
+
+	_, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrInvalidCredentials)
+	_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrInvalidCredentials)
+	assert.Equal(t, 2, next.calls)
+}
+
+// TestUnitVerifyCredentials_OpenBreakerFailsFast tests that once the breaker opens,
+// calls fail with ErrUnavailable without reaching the transport.
+func TestUnitVerifyCredentials_OpenBreakerFailsFast(t *testing.T) {
+	next := &fakeTransport{errs: []error{
+		gateway.ErrUnavailable,
+		gateway.ErrUnavailable,
+		gateway.ErrUnavailable,
+	}}
+	gw := newGateway(next, 2)
+
+	_, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrUnavailable)
+	require.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, 2, next.calls)
+
+	_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrUnavailable)
+	assert.Equal(t, 2, next.calls)
+}
+
+// TestUnitVerifyCredentials_ClientErrorsKeepBreakerClosed tests that the user service
+// rejecting a request is returned at once and does not count against the breaker.
+func TestUnitVerifyCredentials_ClientErrorsKeepBreakerClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "invalid credentials", err: gateway.ErrInvalidCredentials},
+		{name: "invalid request", err: gateway.ErrInvalidRequest},
+		{name: "forbidden", err: gateway.ErrForbidden},
+		{name: "user not active", err: gateway.ErrUserNotActive},
+		{name: "not found", err: gateway.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &fakeTransport{errs: []error{tt.err, tt.err}}
+			gw := newGateway(next, 1)
+
+			_, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+			require.ErrorIs(t, err, tt.err)
+			_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+			require.ErrorIs(t, err, tt.err)
+			_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+			require.NoError(t, err)
+			assert.Equal(t, 3, next.calls)
+		})
+	}
+}
+
+// TestUnitVerifyCredentials_AttemptTimeout tests that every attempt gets its own deadline,
+// and attempts running into it are retried and count against the breaker.
+func TestUnitVerifyCredentials_AttemptTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	var budgets []time.Duration
+	next := &fakeTransport{call: func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok, "attempt has a deadline")
+		budgets = append(budgets, time.Until(deadline))
+		<-ctx.Done()
+		return gateway.ErrUnavailable
+	}}
+	gw := newGatewayWithTimeout(next, 3, timeout)
+
+	start := time.Now()
+	_, err := gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrUnavailable)
+	assert.Equal(t, 3, next.calls)
+	assert.Less(t, time.Since(start), 3*timeout+time.Second)
+	for i, budget := range budgets {
+		assert.LessOrEqual(t, budget, timeout, "attempt %d", i)
+		assert.Greater(t, budget, timeout/2, "attempt %d", i)
+	}
+
+	_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.ErrorIs(t, err, resilience.ErrCircuitOpen)
+	assert.Equal(t, 3, next.calls)
+}
+
+// TestUnitVerifyCredentials_CallerCancelKeepsBreakerClosed tests that attempts abandoned
+// by the caller neither are retried nor count against the breaker.
+func TestUnitVerifyCredentials_CallerCancelKeepsBreakerClosed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	next := &fakeTransport{call: func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return gateway.ErrUnavailable
+	}}
+	gw := newGateway(next, 1)
+
+	_, err := gw.VerifyCredentials(ctx, "user@example.com", "password")
+	require.ErrorIs(t, err, gateway.ErrUnavailable)
+	assert.Equal(t, 1, next.calls)
+
+	next.call = nil
+	_, err = gw.VerifyCredentials(context.Background(), "user@example.com", "password")
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.calls)
+}
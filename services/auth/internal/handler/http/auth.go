@@ -4,14 +4,17 @@ package authhandler
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
+	"time"
 
+	"github.com/incheat/go-production-backend/pkg/cookie"
 	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 	"github.com/incheat/go-production-backend/pkg/ptr"
 	servergen "github.com/incheat/go-production-backend/services/auth/internal/api/oapi/gen/public/server"
 	"github.com/incheat/go-production-backend/services/auth/internal/constant"
 	chimiddlewareutils "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi/utils"
 	authservice "github.com/incheat/go-production-backend/services/auth/internal/service/auth"
+	"github.com/incheat/go-production-backend/services/auth/pkg/model"
 	"go.opentelemetry.io/otel"
 )
 
@@ -20,12 +23,13 @@ var _ servergen.StrictServerInterface = (*Server)(nil)
 
 // Server is the server for the Auth API.
 type Server struct {
-	service *authservice.Service
+	service       *authservice.Service
+	refreshCookie *cookie.Builder
 }
 
-// New creates a new Server.
-func New(service *authservice.Service) *Server {
-	return &Server{service: service}
+// New creates a new Server setting the refresh token in the refreshCookie cookie.
+func New(service *authservice.Service, refreshCookie *cookie.Builder) *Server {
+	return &Server{service: service, refreshCookie: refreshCookie}
 }
 
 // Login is the server for the Login endpoint.
@@ -33,9 +37,7 @@ func (h *Server) Login(ctx context.Context, request servergen.LoginRequestObject
 
 	logger, ok := correlation.LoggerFromContext(ctx)
 	if !ok {
-		return servergen.Login500JSONResponse{
-			Error: "logger not found",
-		}, errors.New("logger not found")
+		return nil, errors.New("logger not found")
 	}
 
 	logger.Info("Login request received")
@@ -49,22 +51,19 @@ func (h *Server) Login(ctx context.Context, request servergen.LoginRequestObject
 
 	requestMeta, ok := chimiddlewareutils.GetRequestMeta(ctx)
 	if !ok {
-		return servergen.Login500JSONResponse{
-			Error: "request metadata not found",
-		}, errors.New("request metadata not found")
+		return nil, errors.New("request metadata not found")
 	}
 	userAgent := requestMeta.UserAgent
 	ipAddress := requestMeta.IPAddress
 
 	res, err := h.service.LoginWithEmailAndPassword(ctx, email, password, userAgent, ipAddress)
 	if err != nil {
-		return servergen.Login500JSONResponse{
-			Error: err.Error(),
-		}, err
+		// Written by the strict handler's error hook, see StrictHTTPServerOptions.
+		return nil, err
 	}
 
 	accessToken := string(res.AccessToken)
-	setCookie := fmt.Sprintf("refresh_token=%s; HttpOnly; Secure; SameSite=Lax; Path=/%s/%s; Max-Age=%d", res.RefreshToken, constant.APIResponseVersionV1, res.RefreshEndPoint, res.RefreshMaxAgeSec)
+	setCookie := h.refreshCookie.Cookie(string(res.RefreshToken), time.Duration(res.RefreshMaxAgeSec)*time.Second).String()
 
 	return servergen.Login200JSONResponse{
 		Body: servergen.AuthResponse{
@@ -77,11 +76,78 @@ func (h *Server) Login(ctx context.Context, request servergen.LoginRequestObject
 	}, nil
 }
 
-// Logout is the server for the Logout endpoint.
-func (h *Server) Logout(_ context.Context, _ servergen.LogoutRequestObject) (servergen.LogoutResponseObject, error) {
+// Refresh is the server for the Refresh endpoint. The refresh token is read from the
+// cookie by the RefreshCookie middleware.
+func (h *Server) Refresh(ctx context.Context, _ servergen.RefreshRequestObject) (servergen.RefreshResponseObject, error) {
+	tr := otel.Tracer("auth.handler")
+	ctx, span := tr.Start(ctx, "auth.refresh")
+	defer span.End()
+
+	refreshToken, ok := ctx.Value(refreshTokenKey{}).(string)
+	if !ok {
+		return nil, authservice.ErrInvalidRefreshToken
+	}
+
+	requestMeta, ok := chimiddlewareutils.GetRequestMeta(ctx)
+	if !ok {
+		return nil, errors.New("request metadata not found")
+	}
+
+	res, err := h.service.Refresh(ctx, model.RefreshToken(refreshToken), requestMeta.UserAgent, requestMeta.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken := string(res.AccessToken)
+	setCookie := h.refreshCookie.Cookie(string(res.RefreshToken), time.Duration(res.RefreshMaxAgeSec)*time.Second).String()
+
+	return servergen.Refresh200JSONResponse{
+		Body: servergen.AuthResponse{
+			AccessToken: &accessToken,
+		},
+		Headers: servergen.Refresh200ResponseHeaders{
+			VersionId: ptr.To(constant.APIResponseVersionV1),
+			SetCookie: ptr.To(setCookie),
+		},
+	}, nil
+}
+
+// refreshTokenKey is the context key of the refresh token cookie value.
+type refreshTokenKey struct{}
+
+// RefreshCookie is a strict handler middleware passing the refresh token cookie of
+// Refresh and Logout requests to the handler, which does not see the HTTP request.
+func (h *Server) RefreshCookie() servergen.StrictMiddlewareFunc {
+	return func(next servergen.StrictHandlerFunc, operationID string) servergen.StrictHandlerFunc {
+		if operationID != "Refresh" && operationID != "Logout" {
+			return next
+		}
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			if value, ok := h.refreshCookie.Value(r); ok && value != "" {
+				ctx = context.WithValue(ctx, refreshTokenKey{}, value)
+			}
+			return next(ctx, w, r, request)
+		}
+	}
+}
+
+// Logout is the server for the Logout endpoint. It revokes the session of the refresh
+// token cookie, read by the RefreshCookie middleware, and expires the cookie.
+func (h *Server) Logout(ctx context.Context, _ servergen.LogoutRequestObject) (servergen.LogoutResponseObject, error) {
+	tr := otel.Tracer("auth.handler")
+	ctx, span := tr.Start(ctx, "auth.logout")
+	defer span.End()
+
+	if refreshToken, ok := ctx.Value(refreshTokenKey{}).(string); ok {
+		if err := h.service.Logout(ctx, model.RefreshToken(refreshToken)); err != nil {
+			return nil, err
+		}
+	}
+
 	return servergen.Logout204Response{
 		Headers: servergen.Logout204ResponseHeaders{
 			VersionId: ptr.To(constant.APIResponseVersionV1),
+			SetCookie: ptr.To(h.refreshCookie.Expired().String()),
 		},
 	}, nil
 }
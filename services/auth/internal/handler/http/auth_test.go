@@ -0,0 +1,299 @@
+package authhandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/cookie"
+	"github.com/incheat/go-production-backend/pkg/csrf"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	servergen "github.com/incheat/go-production-backend/services/auth/internal/api/oapi/gen/public/server"
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	authhandler "github.com/incheat/go-production-backend/services/auth/internal/handler/http"
+	chimiddlewareutils "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi/utils"
+	"github.com/incheat/go-production-backend/services/auth/internal/repository"
+	memoryrepo "github.com/incheat/go-production-backend/services/auth/internal/repository/memory"
+	authservice "github.com/incheat/go-production-backend/services/auth/internal/service/auth"
+	"github.com/incheat/go-production-backend/services/auth/internal/token"
+	"github.com/incheat/go-production-backend/services/auth/pkg/model"
+	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeUserGateway struct {
+	err error
+}
+
+func (g fakeUserGateway) VerifyCredentials(_ context.Context, email string, _ string) (*usermodel.User, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &usermodel.User{ID: "1", Email: email}, nil
+}
+
+type fakeAccessTokenMaker struct {
+	err error
+}
+
+func (m fakeAccessTokenMaker) CreateToken(string) (model.AccessToken, error) {
+	return "access-token", m.err
+}
+
+type fakeRefreshTokenMaker struct{}
+
+func (fakeRefreshTokenMaker) CreateToken() (model.RefreshToken, error) { return "refresh-token", nil }
+func (fakeRefreshTokenMaker) MaxAge() int                              { return 3600 }
+func (fakeRefreshTokenMaker) RefreshEndPoint() string                  { return "refresh" }
+
+type fakeRefreshTokenRepository struct{}
+
+func (fakeRefreshTokenRepository) GetRefreshTokenSession(context.Context, model.RefreshToken) (*model.RefreshTokenSession, error) {
+	return nil, repository.ErrRefreshTokenNotFound
+}
+
+func (fakeRefreshTokenRepository) SaveRefreshTokenSession(context.Context, *model.RefreshTokenSession) error {
+	return nil
+}
+
+func (fakeRefreshTokenRepository) RotateRefreshTokenSession(context.Context, model.RefreshToken, *model.RefreshTokenSession) error {
+	return repository.ErrRefreshTokenNotFound
+}
+
+func (fakeRefreshTokenRepository) RevokeRefreshTokenFamily(context.Context, string) error {
+	return nil
+}
+
+// newLoginHandler returns the HTTP handler of the auth API for the given failures.
+func newLoginHandler(gatewayErr, tokenErr error) http.Handler {
+	return newHandler(authservice.New(
+		fakeAccessTokenMaker{err: tokenErr},
+		fakeRefreshTokenMaker{},
+		fakeRefreshTokenRepository{},
+		fakeUserGateway{err: gatewayErr},
+	))
+}
+
+// newHandler returns the HTTP handler of the auth API for service, behind the CSRF check
+// of the refresh token cookie as in main.
+func newHandler(service *authservice.Service) http.Handler {
+	refreshCookie, err := cookie.NewBuilder(cookie.Config{
+		Name:     "__Host-refresh_token",
+		SameSite: cookie.SameSiteStrict,
+		Secure:   true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	server := authhandler.New(service, refreshCookie)
+	strict := servergen.NewStrictHandlerWithOptions(server, []servergen.StrictMiddlewareFunc{server.RefreshCookie()}, authhandler.StrictHTTPServerOptions())
+	return servergen.HandlerWithOptions(strict, servergen.ChiServerOptions{
+		Middlewares: []servergen.MiddlewareFunc{
+			func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					ctx := correlation.ContextWithLogger(r.Context(), zap.NewNop())
+					ctx = chimiddlewareutils.WithRequestMeta(ctx, chimiddlewareutils.RequestMeta{UserAgent: "test-agent", IPAddress: "127.0.0.1"})
+					next.ServeHTTP(w, r.WithContext(ctx))
+				})
+			},
+			csrf.Middleware(csrf.Config{Cookies: []string{refreshCookie.Name()}}),
+			apierror.Negotiate(apierror.FormatLegacy),
+		},
+	})
+}
+
+func login(handler http.Handler, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{"email":"user@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestUnitLogin_TranslatesErrors tests that login failures are returned with the status
+// and stable error code of the failure, without leaking internal details.
+func TestUnitLogin_TranslatesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		gatewayErr error
+		tokenErr   error
+		wantStatus int
+		want       apierror.Body
+	}{
+		{
+			name:       "invalid credentials",
+			gatewayErr: gateway.ErrInvalidCredentials,
+			wantStatus: http.StatusUnauthorized,
+			want:       apierror.Body{ErrorCode: apierror.CodeInvalidCredentials, Message: "invalid email or password"},
+		},
+		{
+			name:       "user service unavailable",
+			gatewayErr: fmt.Errorf("%w: connection refused", gateway.ErrUnavailable),
+			wantStatus: http.StatusServiceUnavailable,
+			want:       apierror.Body{ErrorCode: apierror.CodeUnavailable, Message: "service unavailable"},
+		},
+		{
+			name:       "user service rejected request",
+			gatewayErr: fmt.Errorf("%w: email is required", gateway.ErrInvalidRequest),
+			wantStatus: http.StatusBadRequest,
+			want:       apierror.Body{ErrorCode: apierror.CodeInvalidRequest, Message: "invalid request"},
+		},
+		{
+			name:       "token service failure",
+			tokenErr:   errors.New("signing key unavailable"),
+			wantStatus: http.StatusInternalServerError,
+			want:       apierror.Body{ErrorCode: apierror.CodeInternal, Message: "internal error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := login(newLoginHandler(tt.gatewayErr, tt.tokenErr), "")
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var body apierror.Body
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tt.want, body)
+		})
+	}
+}
+
+// TestUnitLogin_ProblemDetails tests that clients accepting problem details get them.
+func TestUnitLogin_ProblemDetails(t *testing.T) {
+	rr := login(newLoginHandler(gateway.ErrInvalidCredentials, nil), apierror.ContentTypeProblem)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, apierror.ContentTypeProblem, rr.Header().Get("Content-Type"))
+
+	var problem apierror.Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, apierror.Problem{
+		Type:      "about:blank",
+		Title:     "Unauthorized",
+		Status:    http.StatusUnauthorized,
+		Detail:    "invalid email or password",
+		Instance:  "/v1/login",
+		ErrorCode: apierror.CodeInvalidCredentials,
+	}, problem)
+}
+
+// TestUnitLoginLogout_RefreshCookie tests that login sets the refresh token cookie as
+// configured and logout expires it.
+func TestUnitLoginLogout_RefreshCookie(t *testing.T) {
+	handler := newLoginHandler(nil, nil)
+
+	rr := login(handler, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "__Host-refresh_token=refresh-token; Path=/; Max-Age=3600; HttpOnly; Secure; SameSite=Strict",
+		rr.Header().Get("Set-Cookie"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/logout", nil))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "__Host-refresh_token=; Path=/; Max-Age=0; HttpOnly; Secure; SameSite=Strict",
+		rr.Header().Get("Set-Cookie"))
+}
+
+// refresh posts to /v1/refresh with the refresh token cookie, if not empty, and the
+// fetch metadata of a browser request from origin.
+func refresh(handler http.Handler, refreshToken, origin, fetchSite string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/refresh", nil)
+	if refreshToken != "" {
+		req.AddCookie(&http.Cookie{Name: "__Host-refresh_token", Value: refreshToken})
+	}
+	req.Header.Set(csrf.HeaderOrigin, origin)
+	req.Header.Set(csrf.HeaderSecFetchSite, fetchSite)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// refreshTokenOf returns the refresh token set by a response.
+func refreshTokenOf(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	c, err := http.ParseSetCookie(rr.Header().Get("Set-Cookie"))
+	require.NoError(t, err)
+	require.Equal(t, "__Host-refresh_token", c.Name)
+	require.NotEmpty(t, c.Value)
+	return c.Value
+}
+
+// TestUnitRefresh_CSRF tests that a cross-origin refresh carrying the cookie is rejected
+// without consuming the token, and a same-origin one rotates the cookie.
+func TestUnitRefresh_CSRF(t *testing.T) {
+	handler := newHandler(authservice.New(
+		fakeAccessTokenMaker{},
+		token.NewOpaqueMaker(32, 3600, "/v1/refresh"),
+		memoryrepo.NewRefreshTokenRepository(),
+		fakeUserGateway{},
+	))
+	rr := login(handler, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	first := refreshTokenOf(t, rr)
+
+	rr = refresh(handler, first, "https://evil.example", "cross-site")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	var body apierror.Body
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, apierror.CodeForbidden, body.ErrorCode)
+	assert.Empty(t, rr.Header().Get("Set-Cookie"))
+
+	rr = refresh(handler, first, "https://example.com", "same-origin")
+	require.Equal(t, http.StatusOK, rr.Code, "the rejected request did not consume the token")
+	second := refreshTokenOf(t, rr)
+	assert.NotEqual(t, first, second)
+	assert.Contains(t, rr.Header().Get("Set-Cookie"), "Max-Age=3600; HttpOnly; Secure; SameSite=Strict")
+	var auth servergen.AuthResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &auth))
+	assert.Equal(t, "access-token", *auth.AccessToken)
+
+	rr = refresh(handler, first, "https://example.com", "same-origin")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "rotated token reused")
+	rr = refresh(handler, second, "https://example.com", "same-origin")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "reuse revoked the session")
+	rr = refresh(handler, "", "https://example.com", "same-origin")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "no cookie")
+}
+
+// TestUnitLogout_RevokesSession tests that logout revokes the session of the refresh
+// token cookie and expires the cookie, and succeeds for unknown tokens.
+func TestUnitLogout_RevokesSession(t *testing.T) {
+	handler := newHandler(authservice.New(
+		fakeAccessTokenMaker{},
+		token.NewOpaqueMaker(32, 3600, "/v1/refresh"),
+		memoryrepo.NewRefreshTokenRepository(),
+		fakeUserGateway{},
+	))
+	rr := login(handler, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	first := refreshTokenOf(t, rr)
+	rr = refresh(handler, first, "https://example.com", "same-origin")
+	require.Equal(t, http.StatusOK, rr.Code)
+	second := refreshTokenOf(t, rr)
+
+	for _, refreshToken := range []string{second, "unknown"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/logout", nil)
+		req.AddCookie(&http.Cookie{Name: "__Host-refresh_token", Value: refreshToken})
+		req.Header.Set(csrf.HeaderSecFetchSite, "same-origin")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Contains(t, rr.Header().Get("Set-Cookie"), "__Host-refresh_token=; Path=/; Max-Age=0")
+	}
+
+	rr = refresh(handler, second, "https://example.com", "same-origin")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "logged out session")
+	rr = refresh(handler, first, "https://example.com", "same-origin")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "earlier token of the session")
+}
@@ -0,0 +1,44 @@
+package authhandler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	servergen "github.com/incheat/go-production-backend/services/auth/internal/api/oapi/gen/public/server"
+	"go.uber.org/zap"
+)
+
+// StrictHTTPServerOptions returns the options of the strict handler. It is the single
+// translation layer of the handler errors: request decoding errors and the errors
+// returned by the handlers are written by apierror.Write, as problem details or in the
+// legacy shape, with the status and error code of their apierror code.
+func StrictHTTPServerOptions() servergen.StrictHTTPServerOptions {
+	return servergen.StrictHTTPServerOptions{
+		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			apierror.Write(w, r, apierror.Wrap(apierror.CodeInvalidRequest, "invalid request body", err))
+		},
+		ResponseErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			logError(r.Context(), err)
+			apierror.Write(w, r, err)
+		},
+	}
+}
+
+// logError logs err at a level matching its code: failures of the service are errors,
+// unavailable dependencies are warnings and rejected requests are info.
+func logError(ctx context.Context, err error) {
+	logger, ok := correlation.LoggerFromContext(ctx)
+	if !ok {
+		return
+	}
+	switch apierror.From(err).Code {
+	case apierror.CodeInternal:
+		logger.Error("Request failed", zap.Error(err))
+	case apierror.CodeUnavailable:
+		logger.Warn("Request failed, dependency unavailable", zap.Error(err))
+	default:
+		logger.Info("Request rejected", zap.Error(err))
+	}
+}
@@ -0,0 +1,101 @@
+// Package authmetrics defines the business metrics of the auth service on
+// OpenTelemetry instruments.
+package authmetrics
+
+import (
+	"context"
+	"time"
+
+	authservice "github.com/incheat/go-production-backend/services/auth/internal/service/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"google.golang.org/grpc/codes"
+)
+
+// _ is a placeholder to ensure that Metrics implements the authservice.Metrics interface.
+var _ authservice.Metrics = (*Metrics)(nil)
+
+// SessionCounter counts the active refresh token sessions.
+type SessionCounter interface {
+	CountActiveSessions(ctx context.Context) (int64, error)
+}
+
+// Metrics records the auth business metrics.
+type Metrics struct {
+	loginAttempts       metric.Int64Counter
+	refreshAttempts     metric.Int64Counter
+	tokensIssued        metric.Int64Counter
+	userGatewayDuration metric.Float64Histogram
+}
+
+// New creates the auth business metrics on meter. The active sessions gauge is read
+// from sessions on every collection.
+func New(meter metric.Meter, sessions SessionCounter) (*Metrics, error) {
+	loginAttempts, err := meter.Int64Counter("auth.login.attempts",
+		metric.WithDescription("Login attempts by outcome"),
+		metric.WithUnit("{attempt}"))
+	if err != nil {
+		return nil, err
+	}
+	refreshAttempts, err := meter.Int64Counter("auth.refresh.attempts",
+		metric.WithDescription("Refresh token rotations and detected reuse by outcome"),
+		metric.WithUnit("{attempt}"))
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.Int64ObservableGauge("auth.sessions.active",
+		metric.WithDescription("Refresh token sessions that have not expired or been revoked"),
+		metric.WithUnit("{session}"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			n, err := sessions.CountActiveSessions(ctx)
+			if err != nil {
+				return err
+			}
+			o.Observe(n)
+			return nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+	tokensIssued, err := meter.Int64Counter("auth.tokens.issued",
+		metric.WithDescription("Tokens returned to clients by type"),
+		metric.WithUnit("{token}"))
+	if err != nil {
+		return nil, err
+	}
+	userGatewayDuration, err := meter.Float64Histogram("auth.user_gateway.duration",
+		metric.WithDescription("Duration of user gateway calls, including retries, by gRPC code"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10))
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{
+		loginAttempts:       loginAttempts,
+		refreshAttempts:     refreshAttempts,
+		tokensIssued:        tokensIssued,
+		userGatewayDuration: userGatewayDuration,
+	}, nil
+}
+
+// LoginAttempt records a login attempt.
+func (m *Metrics) LoginAttempt(outcome authservice.LoginOutcome) {
+	m.loginAttempts.Add(context.Background(), 1, metric.WithAttributes(attribute.String("outcome", string(outcome))))
+}
+
+// RefreshAttempt records a refresh attempt.
+func (m *Metrics) RefreshAttempt(outcome authservice.RefreshOutcome) {
+	m.refreshAttempts.Add(context.Background(), 1, metric.WithAttributes(attribute.String("outcome", string(outcome))))
+}
+
+// TokenIssued records a token returned to a client.
+func (m *Metrics) TokenIssued(tokenType authservice.TokenType) {
+	m.tokensIssued.Add(context.Background(), 1, metric.WithAttributes(attribute.String("token.type", string(tokenType))))
+}
+
+// UserGatewayCall records a call to the user gateway.
+func (m *Metrics) UserGatewayCall(code codes.Code, duration time.Duration) {
+	m.userGatewayDuration.Record(context.Background(), duration.Seconds(),
+		metric.WithAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code))))
+}
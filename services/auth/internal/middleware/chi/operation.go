@@ -0,0 +1,25 @@
+// Package chimiddleware defines the OpenAPI operation resolver for the auth service.
+package chimiddleware
+
+import (
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// OperationResolver returns a function resolving the OpenAPI operation ID of a request,
+// so that middleware can be configured per operation rather than per path.
+func OperationResolver(spec *openapi3.T) (func(r *http.Request) (string, bool), error) {
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+	return func(r *http.Request) (string, bool) {
+		route, _, err := router.FindRoute(r)
+		if err != nil || route.Operation == nil || route.Operation.OperationID == "" {
+			return "", false
+		}
+		return route.Operation.OperationID, true
+	}, nil
+}
@@ -0,0 +1,76 @@
+package chimiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	servergen "github.com/incheat/go-production-backend/services/auth/internal/api/oapi/gen/public/server"
+	middleware "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi"
+)
+
+func TestUnitOperationResolver_RateLimitsLoginPerIP(t *testing.T) {
+	spec, err := servergen.GetSpec()
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	operation, err := middleware.OperationResolver(spec)
+	if err != nil {
+		t.Fatalf("OperationResolver: %v", err)
+	}
+
+	handler := ratelimit.Middleware(ratelimit.HTTPConfig{
+		Limiter:   ratelimit.NewMemoryLimiter(),
+		Rules:     ratelimit.Rules{"Login": {Limit: ratelimit.Limit{Requests: 2, Period: time.Minute}, Key: ratelimit.KeyByIP}},
+		Operation: operation,
+		ClientIP:  func(r *http.Request) string { return r.Header.Get("X-Test-IP") },
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	login := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/login", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-IP", ip)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		rr := login("203.0.113.10")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Fatalf("request %d: expected RateLimit-Remaining %s, got %q", i, wantRemaining, got)
+		}
+	}
+
+	rr := login("203.0.113.10")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("expected Retry-After 30, got %q", got)
+	}
+	if got := rr.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("expected RateLimit-Limit 2, got %q", got)
+	}
+
+	// Another client IP has its own bucket.
+	if rr := login("198.51.100.7"); rr.Code != http.StatusOK {
+		t.Fatalf("expected other IP to be allowed, got %d", rr.Code)
+	}
+
+	// Operations without a rule are not limited and get no headers.
+	req := httptest.NewRequest(http.MethodPost, "/v1/logout", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("expected no rate limit headers on Logout")
+	}
+}
@@ -4,6 +4,7 @@ package chimiddleware
 import (
 	"net/http"
 
+	"github.com/incheat/go-production-backend/pkg/apierror"
 	"go.uber.org/zap"
 )
 
@@ -21,7 +22,7 @@ func ZapRecovery(logger *zap.Logger) func(next http.Handler) http.Handler {
 					)
 
 					// Always return 500 on panic
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					apierror.Write(w, r, apierror.New(apierror.CodeInternal, "internal error"))
 				}
 			}()
 
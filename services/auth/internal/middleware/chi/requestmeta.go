@@ -2,44 +2,33 @@
 package chimiddleware
 
 import (
-	"net"
 	"net/http"
 
+	"github.com/incheat/go-production-backend/pkg/clientip"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 	chimiddlewareutils "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi/utils"
 )
 
-// RequestMeta adds the request metadata to the context.
-func RequestMeta() func(next http.Handler) http.Handler {
+// HeaderJWTSubject is set by the gateway to the subject of the verified access token,
+// after removing any value sent by the client.
+const HeaderJWTSubject = "X-Jwt-Sub"
+
+// RequestMeta adds the request metadata to the context. The client IP is resolved by
+// resolver, so rate limiting, access logs and sessions all see the same address. The
+// subject is only read from requests of a trusted proxy.
+func RequestMeta(resolver *clientip.Resolver) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			meta := chimiddlewareutils.RequestMeta{
+				RequestID: correlation.RequestID(r.Context()),
 				UserAgent: r.UserAgent(),
-				IPAddress: getClientIP(r),
+				IPAddress: resolver.ClientIP(r),
+			}
+			if resolver.Trusted(r) {
+				meta.Subject = r.Header.Get(HeaderJWTSubject)
 			}
 			ctx := chimiddlewareutils.WithRequestMeta(r.Context(), meta)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
-
-func getClientIP(httpRequest *http.Request) string {
-	if httpRequest == nil {
-		return ""
-	}
-	// Try real IP from common proxy headers
-	ipAddress := httpRequest.Header.Get("X-Forwarded-For")
-	if ipAddress == "" {
-		ipAddress = httpRequest.Header.Get("X-Real-IP")
-	}
-	if ipAddress == "" {
-		// fallback: use connection remote address
-		// but strip port (ip:port)
-		host, _, err := net.SplitHostPort(httpRequest.RemoteAddr)
-		if err == nil {
-			ipAddress = host
-		} else {
-			ipAddress = httpRequest.RemoteAddr
-		}
-	}
-	return ipAddress
-}
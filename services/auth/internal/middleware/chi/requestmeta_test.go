@@ -3,11 +3,16 @@ package chimiddleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 
+	"github.com/incheat/go-production-backend/pkg/clientip"
 	chimiddlewareutils "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi/utils"
 )
 
+// trustedProxies trusts the default httptest RemoteAddr, 192.0.2.1.
+var trustedProxies = clientip.NewResolver(clientip.Prefixes{netip.MustParsePrefix("192.0.2.0/24")})
+
 func TestRequestMeta_PopulatesContextFromHeaderAndRequest(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		meta, ok := chimiddlewareutils.GetRequestMeta(r.Context())
@@ -25,7 +30,7 @@ func TestRequestMeta_PopulatesContextFromHeaderAndRequest(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RequestMeta()(next)
+	handler := RequestMeta(trustedProxies)(next)
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.Header.Set("User-Agent", "test-agent/1.0")
@@ -39,24 +44,24 @@ func TestRequestMeta_PopulatesContextFromHeaderAndRequest(t *testing.T) {
 	}
 }
 
-func TestRequestMeta_IPPrefersXForwardedForOverXRealIPAndRemoteAddr(t *testing.T) {
+func TestRequestMeta_IPIgnoresXForwardedForFromUntrustedPeer(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		meta, ok := chimiddlewareutils.GetRequestMeta(r.Context())
 		if !ok {
 			t.Fatal("expected request meta in context")
 		}
-		if meta.IPAddress != "198.51.100.1" {
-			t.Fatalf("expected IPAddress %q, got %q", "198.51.100.1", meta.IPAddress)
+		if meta.IPAddress != "198.51.100.7" {
+			t.Fatalf("expected IPAddress %q, got %q", "198.51.100.7", meta.IPAddress)
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RequestMeta()(next)
+	handler := RequestMeta(trustedProxies)(next)
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
-	req.Header.Set("X-Forwarded-For", "198.51.100.1")
-	req.Header.Set("X-Real-IP", "198.51.100.2") // should be ignored when XFF exists
-	req.RemoteAddr = "192.0.2.9:54321"          // should be ignored when XFF exists
+	req.Header.Set("X-Forwarded-For", "198.51.100.1") // spoofed by the client
+	req.Header.Set("X-Real-IP", "198.51.100.2")       // never trusted
+	req.RemoteAddr = "198.51.100.7:54321"
 
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
@@ -66,23 +71,23 @@ func TestRequestMeta_IPPrefersXForwardedForOverXRealIPAndRemoteAddr(t *testing.T
 	}
 }
 
-func TestRequestMeta_IPFallsBackToXRealIPWhenNoXForwardedFor(t *testing.T) {
+func TestRequestMeta_IPSkipsTrustedProxiesInXForwardedFor(t *testing.T) {
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		meta, ok := chimiddlewareutils.GetRequestMeta(r.Context())
 		if !ok {
 			t.Fatal("expected request meta in context")
 		}
-		if meta.IPAddress != "198.51.100.2" {
-			t.Fatalf("expected IPAddress %q, got %q", "198.51.100.2", meta.IPAddress)
+		if meta.IPAddress != "198.51.100.1" {
+			t.Fatalf("expected IPAddress %q, got %q", "198.51.100.1", meta.IPAddress)
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RequestMeta()(next)
+	handler := RequestMeta(trustedProxies)(next)
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
-	req.Header.Set("X-Real-IP", "198.51.100.2")
-	req.RemoteAddr = "192.0.2.9:54321" // should be ignored when X-Real-IP exists
+	req.Header.Set("X-Forwarded-For", "203.0.113.99, 198.51.100.1, 192.0.2.20")
+	req.RemoteAddr = "192.0.2.9:54321"
 
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
@@ -104,7 +109,7 @@ func TestRequestMeta_IPFallsBackToRemoteAddrAndStripsPort(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RequestMeta()(next)
+	handler := RequestMeta(trustedProxies)(next)
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.RemoteAddr = "192.0.2.9:54321"
@@ -129,7 +134,7 @@ func TestRequestMeta_IPUsesRemoteAddrAsIsWhenSplitHostPortFails(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RequestMeta()(next)
+	handler := RequestMeta(trustedProxies)(next)
 
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req.RemoteAddr = "not-a-hostport"
@@ -141,3 +146,28 @@ func TestRequestMeta_IPUsesRemoteAddrAsIsWhenSplitHostPortFails(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 }
+
+func TestRequestMeta_SubjectOnlyFromTrustedProxy(t *testing.T) {
+	for _, tt := range []struct {
+		remote string
+		want   string
+	}{
+		{remote: "192.0.2.9:54321", want: "user-1"},
+		{remote: "198.51.100.7:54321", want: ""}, // sent by the client itself
+	} {
+		var got string
+		handler := RequestMeta(trustedProxies)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			meta, _ := chimiddlewareutils.GetRequestMeta(r.Context())
+			got = meta.Subject
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(HeaderJWTSubject, "user-1")
+		req.RemoteAddr = tt.remote
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got != tt.want {
+			t.Fatalf("peer %s: expected Subject %q, got %q", tt.remote, tt.want, got)
+		}
+	}
+}
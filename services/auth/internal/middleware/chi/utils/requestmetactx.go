@@ -10,6 +10,8 @@ type RequestMeta struct {
 	RequestID string
 	UserAgent string
 	IPAddress string
+	// Subject is the subject of the access token verified by the gateway, or "".
+	Subject string
 	// Additional metadata: Referer, AcceptLanguage, etc.
 }
 
@@ -2,10 +2,11 @@
 package chimiddleware
 
 import (
-	"encoding/json"
+	"context"
 	"log"
 	"net/http"
 
+	"github.com/incheat/go-production-backend/pkg/apierror"
 	nethttpmiddleware "github.com/oapi-codegen/nethttp-middleware"
 )
 
@@ -17,6 +18,7 @@ type ValidatorConfig struct {
 }
 
 // NewValidatorOptions creates a new validator options.
+// Validation errors are written by apierror.Write, listing the invalid fields.
 // If ProdMode is true, the validator will return a production error message.
 // If ProdMode is false, the validator will return a development error message.
 func NewValidatorOptions(cfg ValidatorConfig) *nethttpmiddleware.Options {
@@ -28,21 +30,16 @@ func NewValidatorOptions(cfg ValidatorConfig) *nethttpmiddleware.Options {
 	}
 
 	return &nethttpmiddleware.Options{
-		ErrorHandler: func(w http.ResponseWriter, message string, statusCode int) {
-			cfg.Logger("validation error (%d): %s", statusCode, message)
+		ErrorHandlerWithOpts: func(_ context.Context, err error, w http.ResponseWriter, r *http.Request, opts nethttpmiddleware.ErrorHandlerOpts) {
+			cfg.Logger("validation error (%d): %s", opts.StatusCode, err)
 
-			errMsg := message
+			apiErr := apierror.FromValidation(err, opts.StatusCode)
 			if cfg.ProdMode {
-				errMsg = cfg.ProdError
-			}
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(statusCode)
-
-			// Best-effort JSON response; fall back to plain text on error.
-			if err := json.NewEncoder(w).Encode(map[string]string{"error": errMsg}); err != nil {
-				http.Error(w, errMsg, statusCode)
+				apiErr.Message = cfg.ProdError
+			} else {
+				apiErr.Message = err.Error()
 			}
+			apierror.Write(w, r, apiErr)
 		},
 	}
 }
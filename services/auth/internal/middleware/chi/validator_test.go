@@ -2,15 +2,38 @@ package chimiddleware_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/incheat/go-production-backend/pkg/apierror"
 	middleware "github.com/incheat/go-production-backend/services/auth/internal/middleware/chi"
+	nethttpmiddleware "github.com/oapi-codegen/nethttp-middleware"
 )
 
+// bodyError returns the error kin-openapi reports for {"email": 123}.
+func bodyError(t *testing.T) error {
+	t.Helper()
+	schema := openapi3.NewObjectSchema().WithProperty("email", openapi3.NewStringSchema())
+	err := schema.VisitJSON(map[string]any{"email": 123.0})
+	if err == nil {
+		t.Fatalf("expected a schema error")
+	}
+	return &openapi3filter.RequestError{RequestBody: &openapi3.RequestBody{}, Reason: "doesn't match schema", Err: err}
+}
+
+// serveError runs the validator error handler for err and returns the response.
+func serveError(opts *nethttpmiddleware.Options, r *http.Request, err error, status int) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	opts.ErrorHandlerWithOpts(r.Context(), err, rr, r, nethttpmiddleware.ErrorHandlerOpts{StatusCode: status})
+	return rr
+}
+
 func TestUnitNewValidatorOptions_ProdMode(t *testing.T) {
 	var logged string
 
@@ -27,26 +50,30 @@ func TestUnitNewValidatorOptions_ProdMode(t *testing.T) {
 		t.Fatalf("expected options to be non-nil")
 	}
 
-	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
 
 	// Simulate a validation error from oapi-codegen
-	opts.ErrorHandler(rr, "detailed dev error message", http.StatusBadRequest)
+	rr := serveError(opts, req, errors.New("detailed dev error message"), http.StatusBadRequest)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
 
-	if got := rr.Header().Get("Content-Type"); !strings.Contains(got, "application/json") {
-		t.Fatalf("expected Content-Type application/json, got %q", got)
+	if got := rr.Header().Get("Content-Type"); got != apierror.ContentTypeProblem {
+		t.Fatalf("expected Content-Type %s, got %q", apierror.ContentTypeProblem, got)
 	}
 
-	var body map[string]string
+	var body apierror.Problem
 	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
 		t.Fatalf("failed to unmarshal response body: %v", err)
 	}
 
-	if body["error"] != "invalid request (prod)" {
-		t.Fatalf("expected error %q, got %q", "invalid request (prod)", body["error"])
+	if body.Detail != "invalid request (prod)" {
+		t.Fatalf("expected detail %q, got %q", "invalid request (prod)", body.Detail)
+	}
+
+	if body.ErrorCode != apierror.CodeInvalidRequest || body.Status != http.StatusBadRequest || body.Instance != "/v1/login" {
+		t.Fatalf("unexpected problem %+v", body)
 	}
 
 	if !strings.Contains(logged, "validation error (400): detailed dev error message") {
@@ -71,22 +98,22 @@ func TestUnitNewValidatorOptions_DevMode(t *testing.T) {
 		t.Fatalf("expected options to be non-nil")
 	}
 
-	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
 
 	msg := "some detailed validation error"
-	opts.ErrorHandler(rr, msg, http.StatusUnprocessableEntity)
+	rr := serveError(opts, req, errors.New(msg), http.StatusUnprocessableEntity)
 
 	if rr.Code != http.StatusUnprocessableEntity {
 		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
 	}
 
-	var body map[string]string
+	var body apierror.Problem
 	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
 		t.Fatalf("failed to unmarshal response body: %v", err)
 	}
 
-	if body["error"] != msg {
-		t.Fatalf("expected error %q, got %q", msg, body["error"])
+	if body.Detail != msg {
+		t.Fatalf("expected detail %q, got %q", msg, body.Detail)
 	}
 
 	if !strings.Contains(logged, "validation error (422): some detailed validation error") {
@@ -107,20 +134,70 @@ func TestUnitNewValidatorOptions_Defaults(t *testing.T) {
 		t.Fatalf("expected options to be non-nil")
 	}
 
-	rr := httptest.NewRecorder()
-	opts.ErrorHandler(rr, "whatever", http.StatusBadRequest)
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	rr := serveError(opts, req, errors.New("whatever"), http.StatusBadRequest)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
 
-	var body map[string]string
+	var body apierror.Problem
 	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
 		t.Fatalf("failed to unmarshal response body: %v", err)
 	}
 
 	// Default ProdError should be "invalid request"
-	if body["error"] != "invalid request" {
-		t.Fatalf("expected default error %q, got %q", "invalid request", body["error"])
+	if body.Detail != "invalid request" {
+		t.Fatalf("expected default detail %q, got %q", "invalid request", body.Detail)
+	}
+}
+
+func TestUnitNewValidatorOptions_InvalidParams(t *testing.T) {
+	opts := middleware.NewValidatorOptions(middleware.ValidatorConfig{ProdMode: true, Logger: func(string, ...any) {}})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rr := serveError(opts, req, bodyError(t), http.StatusBadRequest)
+
+	var body apierror.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v", err)
+	}
+
+	if body.RequestID != "req-1" {
+		t.Fatalf("expected request_id %q, got %q", "req-1", body.RequestID)
+	}
+	if len(body.InvalidParams) != 1 || body.InvalidParams[0].Name != "/email" || body.InvalidParams[0].In != "body" {
+		t.Fatalf("expected /email in body to be reported, got %+v", body.InvalidParams)
+	}
+}
+
+func TestUnitNewValidatorOptions_LegacyFormat(t *testing.T) {
+	opts := middleware.NewValidatorOptions(middleware.ValidatorConfig{ProdMode: true, Logger: func(string, ...any) {}})
+
+	handler := apierror.Negotiate(apierror.FormatLegacy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts.ErrorHandlerWithOpts(r.Context(), bodyError(t), w, r, nethttpmiddleware.ErrorHandlerOpts{StatusCode: http.StatusBadRequest})
+	}))
+
+	// Existing clients get the legacy body.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/login", nil))
+
+	var legacy map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &legacy); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v", err)
+	}
+	if legacy["error_code"] != "invalid_request" || legacy["message"] != "invalid request" {
+		t.Fatalf("unexpected legacy body %v", legacy)
+	}
+
+	// Clients accepting problem details get them.
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/login", nil)
+	req.Header.Set("Accept", "application/json, application/problem+json;q=0.9")
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Type"); got != apierror.ContentTypeProblem {
+		t.Fatalf("expected Content-Type %s, got %q", apierror.ContentTypeProblem, got)
 	}
 }
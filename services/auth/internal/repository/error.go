@@ -8,4 +8,6 @@ var (
 	ErrRefreshTokenAlreadyExists = errors.New("refresh token already exists")
 	// ErrRefreshTokenNotFound is the error for when a refresh token is not found.
 	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenRevoked is the error for when a refresh token was already rotated or revoked.
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
 )
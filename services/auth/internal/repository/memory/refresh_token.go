@@ -4,6 +4,7 @@ package memoryrepo
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/incheat/go-production-backend/services/auth/internal/repository"
 	"github.com/incheat/go-production-backend/services/auth/pkg/model"
@@ -12,13 +13,21 @@ import (
 // RefreshTokenRepository defines a memory refresh token repository.
 type RefreshTokenRepository struct {
 	sync.RWMutex
-	data map[string]*model.RefreshTokenSession
+	data     map[string]*model.RefreshTokenSession
+	families map[string]*family
+}
+
+// family is the index entry of a session: its tokens and the expiry of the latest one.
+type family struct {
+	tokens    []string
+	expiresAt time.Time
 }
 
 // NewRefreshTokenRepository creates a new memory refresh token repository.
 func NewRefreshTokenRepository() *RefreshTokenRepository {
 	return &RefreshTokenRepository{
-		data: make(map[string]*model.RefreshTokenSession),
+		data:     make(map[string]*model.RefreshTokenSession),
+		families: make(map[string]*family),
 	}
 }
 
@@ -31,7 +40,8 @@ func (r *RefreshTokenRepository) GetRefreshTokenSession(_ context.Context, refre
 	if !ok {
 		return nil, repository.ErrRefreshTokenNotFound
 	}
-	return refreshTokenSession, nil
+	session := *refreshTokenSession
+	return &session, nil
 }
 
 // SaveRefreshTokenSession saves a refresh token session.
@@ -45,6 +55,77 @@ func (r *RefreshTokenRepository) SaveRefreshTokenSession(_ context.Context, refr
 		return repository.ErrRefreshTokenAlreadyExists
 	}
 
-	r.data[tokenHash] = refreshTokenSession
+	r.save(refreshTokenSession)
+	return nil
+}
+
+// RotateRefreshTokenSession revokes the session of refreshToken and saves next in its
+// family. It returns repository.ErrRefreshTokenRevoked if the session was already revoked.
+func (r *RefreshTokenRepository) RotateRefreshTokenSession(_ context.Context, refreshToken model.RefreshToken, next *model.RefreshTokenSession) error {
+	r.Lock()
+	defer r.Unlock()
+
+	current, ok := r.data[string(refreshToken)]
+	if !ok {
+		return repository.ErrRefreshTokenNotFound
+	}
+	if !current.RevokedAt.IsZero() {
+		return repository.ErrRefreshTokenRevoked
+	}
+	if _, ok := r.data[string(next.TokenHash)]; ok {
+		return repository.ErrRefreshTokenAlreadyExists
+	}
+
+	revoked := *current
+	revoked.RevokedAt = next.CreatedAt
+	r.data[string(refreshToken)] = &revoked
+	r.save(next)
 	return nil
 }
+
+// RevokeRefreshTokenFamily deletes every session of a family.
+func (r *RefreshTokenRepository) RevokeRefreshTokenFamily(_ context.Context, familyID string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	f, ok := r.families[familyID]
+	if !ok {
+		return nil
+	}
+	for _, token := range f.tokens {
+		delete(r.data, token)
+	}
+	delete(r.families, familyID)
+	return nil
+}
+
+// CountActiveSessions counts the families whose latest session has not expired.
+func (r *RefreshTokenRepository) CountActiveSessions(context.Context) (int64, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	now := time.Now()
+	var n int64
+	for _, f := range r.families {
+		if f.expiresAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// save stores a copy of session and indexes it in its family. The caller holds the lock.
+func (r *RefreshTokenRepository) save(session *model.RefreshTokenSession) {
+	stored := *session
+	r.data[string(session.TokenHash)] = &stored
+
+	f, ok := r.families[session.FamilyID]
+	if !ok {
+		f = &family{}
+		r.families[session.FamilyID] = f
+	}
+	f.tokens = append(f.tokens, string(session.TokenHash))
+	if session.ExpiresAt.After(f.expiresAt) {
+		f.expiresAt = session.ExpiresAt
+	}
+}
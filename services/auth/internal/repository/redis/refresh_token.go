@@ -4,26 +4,77 @@ package redisrepo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
 	"github.com/incheat/go-production-backend/services/auth/internal/constant"
 	"github.com/incheat/go-production-backend/services/auth/internal/repository"
 	"github.com/incheat/go-production-backend/services/auth/pkg/model"
 	"github.com/redis/go-redis/v9"
 )
 
-// RefreshTokenRepository defines a Redis refresh token repository.
+// RefreshTokenRepository defines a Redis refresh token repository. Sessions are
+// stored under prefix by token, the tokens of a family in a set under familyPrefix,
+// and the families in the index, a sorted set scored by their latest expiry.
 type RefreshTokenRepository struct {
-	rdb    *redis.Client
-	prefix string
+	rdb          *redis.Client
+	prefix       string
+	familyPrefix string
+	index        string
 }
 
 // NewRefreshTokenRepository creates a new Redis refresh token repository.
 func NewRefreshTokenRepository(rdb *redis.Client) *RefreshTokenRepository {
 	return &RefreshTokenRepository{
-		rdb:    rdb,
-		prefix: constant.RedisRefreshTokenPrefix, // key prefix in Redis
+		rdb:          rdb,
+		prefix:       constant.RedisRefreshTokenPrefix, // key prefix in Redis
+		familyPrefix: constant.RedisRefreshFamilyPrefix,
+		index:        constant.RedisRefreshSessionIndex,
+	}
+}
+
+// sessionRecord is the stored form of a refresh token session, holding the values
+// that model.RefreshTokenSession redacts when marshaled.
+type sessionRecord struct {
+	ID        string
+	FamilyID  string
+	MemberID  string
+	TokenHash model.RefreshToken
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RevokedAt time.Time
+	UserAgent string
+	IPAddress string
+}
+
+func newSessionRecord(s *model.RefreshTokenSession) sessionRecord {
+	return sessionRecord{
+		ID:        s.ID,
+		FamilyID:  s.FamilyID,
+		MemberID:  s.MemberID,
+		TokenHash: s.TokenHash,
+		ExpiresAt: s.ExpiresAt,
+		CreatedAt: s.CreatedAt,
+		RevokedAt: s.RevokedAt,
+		UserAgent: s.UserAgent.Reveal(),
+		IPAddress: s.IPAddress.Reveal(),
+	}
+}
+
+func (r sessionRecord) session() *model.RefreshTokenSession {
+	return &model.RefreshTokenSession{
+		ID:        r.ID,
+		FamilyID:  r.FamilyID,
+		MemberID:  r.MemberID,
+		TokenHash: r.TokenHash,
+		ExpiresAt: r.ExpiresAt,
+		CreatedAt: r.CreatedAt,
+		RevokedAt: r.RevokedAt,
+		UserAgent: redact.Redacted(r.UserAgent),
+		IPAddress: redact.Redacted(r.IPAddress),
 	}
 }
 
@@ -32,25 +83,18 @@ func (r *RefreshTokenRepository) key(hash string) string {
 	return r.prefix + hash
 }
 
+// familyKey builds the Redis key of the token set of a family.
+func (r *RefreshTokenRepository) familyKey(familyID string) string {
+	return r.familyPrefix + familyID
+}
+
 // GetRefreshTokenSession gets a refresh token session by token hash.
 func (r *RefreshTokenRepository) GetRefreshTokenSession(ctx context.Context, refreshToken model.RefreshToken) (*model.RefreshTokenSession, error) {
-	tokenHash := string(refreshToken)
-	key := r.key(tokenHash)
-
-	data, err := r.rdb.Get(ctx, key).Bytes()
-	if err == redis.Nil {
-		return nil, repository.ErrRefreshTokenNotFound
-	}
+	record, err := r.get(ctx, r.rdb, r.key(string(refreshToken)))
 	if err != nil {
-		return nil, fmt.Errorf("redis GET error: %w", err)
+		return nil, err
 	}
-
-	var session model.RefreshTokenSession
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, fmt.Errorf("json.Unmarshal error: %w", err)
-	}
-
-	return &session, nil
+	return record.session(), nil
 }
 
 // SaveRefreshTokenSession saves a refresh token session.
@@ -67,8 +111,112 @@ func (r *RefreshTokenRepository) SaveRefreshTokenSession(ctx context.Context, se
 		return repository.ErrRefreshTokenAlreadyExists
 	}
 
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return r.save(ctx, pipe, session)
+	})
+	if err != nil {
+		return fmt.Errorf("redis MULTI error: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshTokenSession revokes the session of refreshToken and saves next in its
+// family. It returns repository.ErrRefreshTokenRevoked if the session was already
+// revoked, including by a concurrent rotation of the same token.
+func (r *RefreshTokenRepository) RotateRefreshTokenSession(ctx context.Context, refreshToken model.RefreshToken, next *model.RefreshTokenSession) error {
+	key := r.key(string(refreshToken))
+
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		record, err := r.get(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if !record.RevokedAt.IsZero() {
+			return repository.ErrRefreshTokenRevoked
+		}
+		record.RevokedAt = next.CreatedAt
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("json.Marshal error: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// The revoked session is kept until it expires, to detect its reuse.
+			pipe.Set(ctx, key, data, redis.KeepTTL)
+			return r.save(ctx, pipe, next)
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return repository.ErrRefreshTokenRevoked
+	}
+	return err
+}
+
+// RevokeRefreshTokenFamily deletes every session of a family.
+func (r *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	familyKey := r.familyKey(familyID)
+	tokens, err := r.rdb.SMembers(ctx, familyKey).Result()
+	if err != nil {
+		return fmt.Errorf("redis SMEMBERS error: %w", err)
+	}
+
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, r.key(token))
+	}
+	keys = append(keys, familyKey)
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.ZRem(ctx, r.index, familyID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis MULTI error: %w", err)
+	}
+	return nil
+}
+
+// CountActiveSessions counts the families in the index whose latest session has not
+// expired, pruning the expired ones.
+func (r *RefreshTokenRepository) CountActiveSessions(ctx context.Context) (int64, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	var count *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, r.index, "-inf", now)
+		count = pipe.ZCard(ctx, r.index)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("redis ZCARD error: %w", err)
+	}
+	return count.Val(), nil
+}
+
+// get reads the session record stored at key.
+func (r *RefreshTokenRepository) get(ctx context.Context, c redis.Cmdable, key string) (sessionRecord, error) {
+	var record sessionRecord
+
+	data, err := c.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return record, repository.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return record, fmt.Errorf("redis GET error: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("json.Unmarshal error: %w", err)
+	}
+	return record, nil
+}
+
+// save queues the commands storing session and indexing it in its family.
+func (r *RefreshTokenRepository) save(ctx context.Context, pipe redis.Pipeliner, session *model.RefreshTokenSession) error {
 	// Marshal to JSON
-	data, err := json.Marshal(session)
+	data, err := json.Marshal(newSessionRecord(session))
 	if err != nil {
 		return fmt.Errorf("json.Marshal error: %w", err)
 	}
@@ -79,10 +227,10 @@ func (r *RefreshTokenRepository) SaveRefreshTokenSession(ctx context.Context, se
 		ttl = time.Minute // fallback TTL just in case
 	}
 
-	// Store session with TTL
-	if err := r.rdb.Set(ctx, key, data, ttl).Err(); err != nil {
-		return fmt.Errorf("redis SET error: %w", err)
-	}
-
+	familyKey := r.familyKey(session.FamilyID)
+	pipe.Set(ctx, r.key(string(session.TokenHash)), data, ttl)
+	pipe.SAdd(ctx, familyKey, string(session.TokenHash))
+	pipe.Expire(ctx, familyKey, ttl)
+	pipe.ZAdd(ctx, r.index, redis.Z{Score: float64(session.ExpiresAt.Unix()), Member: session.FamilyID})
 	return nil
 }
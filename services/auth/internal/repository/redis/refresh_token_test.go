@@ -0,0 +1,93 @@
+package redisrepo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/incheat/go-production-backend/services/auth/internal/repository"
+	redisrepo "github.com/incheat/go-production-backend/services/auth/internal/repository/redis"
+	"github.com/incheat/go-production-backend/services/auth/pkg/model"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepository(t *testing.T) (*miniredis.Miniredis, *redisrepo.RefreshTokenRepository) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, redisrepo.NewRefreshTokenRepository(rdb)
+}
+
+func newSession(familyID, token string, expiresIn time.Duration) *model.RefreshTokenSession {
+	now := time.Now()
+	return &model.RefreshTokenSession{
+		ID:        token,
+		FamilyID:  familyID,
+		MemberID:  "user@example.com",
+		TokenHash: model.RefreshToken(token),
+		ExpiresAt: now.Add(expiresIn),
+		CreatedAt: now,
+		UserAgent: redact.Redacted("agent"),
+		IPAddress: redact.Redacted("10.0.0.1"),
+	}
+}
+
+// TestUnitRefreshTokenRepository_Rotate tests that a rotation revokes the presented
+// session and saves the next one in its family, and only one rotation of a token succeeds.
+func TestUnitRefreshTokenRepository_Rotate(t *testing.T) {
+	mr, repo := newRepository(t)
+	ctx := context.Background()
+	require.NoError(t, repo.SaveRefreshTokenSession(ctx, newSession("f1", "t1", time.Hour)))
+	assert.ErrorIs(t, repo.SaveRefreshTokenSession(ctx, newSession("f1", "t1", time.Hour)), repository.ErrRefreshTokenAlreadyExists)
+
+	require.NoError(t, repo.RotateRefreshTokenSession(ctx, "t1", newSession("f1", "t2", time.Hour)))
+
+	rotated, err := repo.GetRefreshTokenSession(ctx, "t1")
+	require.NoError(t, err)
+	assert.False(t, rotated.RevokedAt.IsZero())
+	assert.Equal(t, "10.0.0.1", rotated.IPAddress.Reveal())
+	assert.Greater(t, mr.TTL("refresh_token:t1"), time.Duration(0), "rotated session keeps its TTL")
+	next, err := repo.GetRefreshTokenSession(ctx, "t2")
+	require.NoError(t, err)
+	assert.Equal(t, "f1", next.FamilyID)
+	assert.True(t, next.RevokedAt.IsZero())
+	members, err := mr.SMembers("refresh_family:f1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"t1", "t2"}, members)
+
+	assert.ErrorIs(t, repo.RotateRefreshTokenSession(ctx, "t1", newSession("f1", "t3", time.Hour)), repository.ErrRefreshTokenRevoked)
+	assert.ErrorIs(t, repo.RotateRefreshTokenSession(ctx, "unknown", newSession("f1", "t4", time.Hour)), repository.ErrRefreshTokenNotFound)
+	assert.False(t, mr.Exists("refresh_token:t3"))
+}
+
+// TestUnitRefreshTokenRepository_Sessions tests that the session index counts the
+// families that have not expired or been revoked.
+func TestUnitRefreshTokenRepository_Sessions(t *testing.T) {
+	mr, repo := newRepository(t)
+	ctx := context.Background()
+	require.NoError(t, repo.SaveRefreshTokenSession(ctx, newSession("f1", "a1", time.Hour)))
+	require.NoError(t, repo.RotateRefreshTokenSession(ctx, "a1", newSession("f1", "a2", time.Hour)))
+	require.NoError(t, repo.SaveRefreshTokenSession(ctx, newSession("f2", "b1", time.Hour)))
+	require.NoError(t, repo.SaveRefreshTokenSession(ctx, newSession("f3", "c1", -time.Minute)))
+
+	active, err := repo.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), active, "rotations stay one session, expired sessions are pruned")
+
+	require.NoError(t, repo.RevokeRefreshTokenFamily(ctx, "f1"))
+
+	active, err = repo.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), active)
+	for _, key := range []string{"refresh_token:a1", "refresh_token:a2", "refresh_family:f1"} {
+		assert.False(t, mr.Exists(key), key)
+	}
+	_, err = repo.GetRefreshTokenSession(ctx, "b1")
+	assert.NoError(t, err, "other sessions are kept")
+	assert.NoError(t, repo.RevokeRefreshTokenFamily(ctx, "unknown"))
+}
@@ -3,20 +3,37 @@ package authservice
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/incheat/go-production-backend/services/auth/internal/repository"
 	"github.com/incheat/go-production-backend/services/auth/pkg/model"
 	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
 )
 
+// ErrInvalidRefreshToken is the error for an unknown, expired or revoked refresh token.
+var ErrInvalidRefreshToken = apierror.New(apierror.CodeUnauthorized, "invalid refresh token")
+
 // Service is the service for the auth API.
 type Service struct {
 	accessToken      AccessTokenMaker
 	refreshToken     RefreshTokenMaker
 	refreshTokenRepo RefreshTokenRepository
 	userGateway      UserGateway
+	metrics          Metrics
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithMetrics records the business metrics of the service on m.
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
 }
 
 // AccessTokenMaker is the interface for the access token maker.
@@ -33,7 +50,13 @@ type RefreshTokenMaker interface {
 
 // RefreshTokenRepository is the interface for the refresh token repository.
 type RefreshTokenRepository interface {
+	GetRefreshTokenSession(ctx context.Context, refreshToken model.RefreshToken) (*model.RefreshTokenSession, error)
 	SaveRefreshTokenSession(ctx context.Context, session *model.RefreshTokenSession) error
+	// RotateRefreshTokenSession revokes the session of refreshToken and saves next in its
+	// family. It returns repository.ErrRefreshTokenRevoked if the session was already revoked.
+	RotateRefreshTokenSession(ctx context.Context, refreshToken model.RefreshToken, next *model.RefreshTokenSession) error
+	// RevokeRefreshTokenFamily revokes every session of a family.
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
 }
 
 // UserGateway is the interface for the user gateway.
@@ -42,17 +65,31 @@ type UserGateway interface {
 }
 
 // New creates a new Service.
-func New(accessToken AccessTokenMaker, refreshToken RefreshTokenMaker, refreshTokenRepo RefreshTokenRepository, userGateway UserGateway) *Service {
-	return &Service{accessToken: accessToken, refreshToken: refreshToken, refreshTokenRepo: refreshTokenRepo, userGateway: userGateway}
+func New(accessToken AccessTokenMaker, refreshToken RefreshTokenMaker, refreshTokenRepo RefreshTokenRepository, userGateway UserGateway, opts ...Option) *Service {
+	s := &Service{accessToken: accessToken, refreshToken: refreshToken, refreshTokenRepo: refreshTokenRepo, userGateway: userGateway, metrics: noopMetrics{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // LoginWithEmailAndPassword logs in a user with email and password.
 func (s *Service) LoginWithEmailAndPassword(ctx context.Context, email string, password string, userAgent, ipAddress string) (*LoginResult, error) {
+	result, err := s.login(ctx, email, password, userAgent, ipAddress)
+	s.metrics.LoginAttempt(loginOutcome(err))
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.TokenIssued(TokenAccess)
+	s.metrics.TokenIssued(TokenRefresh)
+	return result, nil
+}
 
-	fmt.Println("Starting to verify user credential")
+func (s *Service) login(ctx context.Context, email string, password string, userAgent, ipAddress string) (*LoginResult, error) {
+	start := time.Now()
 	user, err := s.userGateway.VerifyCredentials(ctx, email, password)
+	s.metrics.UserGatewayCall(gatewayCode(err), time.Since(start))
 	if err != nil {
-		fmt.Println("Error verifying user credential", err)
 		return nil, err
 	}
 
@@ -72,15 +109,17 @@ func (s *Service) LoginWithEmailAndPassword(ctx context.Context, email string, p
 	maxAge := s.refreshToken.MaxAge()
 	refreshEndPoint := s.refreshToken.RefreshEndPoint()
 
+	sessionID := uuid.NewString()
 	refreshTokenSession := &model.RefreshTokenSession{
-		ID:        uuid.NewString(),
+		ID:        sessionID,
+		FamilyID:  sessionID,
 		MemberID:  memberID,
 		TokenHash: refreshToken,
 		ExpiresAt: now.Add(time.Duration(maxAge) * time.Second),
 		CreatedAt: now,
 		RevokedAt: time.Time{}, // not revoked yet, set to zero value
-		UserAgent: userAgent,
-		IPAddress: ipAddress,
+		UserAgent: redact.Redacted(userAgent),
+		IPAddress: redact.Redacted(ipAddress),
 	}
 	err = s.refreshTokenRepo.SaveRefreshTokenSession(ctx, refreshTokenSession)
 	if err != nil {
@@ -94,3 +133,96 @@ func (s *Service) LoginWithEmailAndPassword(ctx context.Context, email string, p
 		RefreshEndPoint:  refreshEndPoint,
 	}, nil
 }
+
+// Refresh rotates a refresh token: it revokes the session of refreshToken and returns
+// new tokens in the same family. A token that was already rotated is presented again
+// only if it leaked, so its reuse revokes the whole family, logging out both the
+// client and whoever else holds a token of it.
+func (s *Service) Refresh(ctx context.Context, refreshToken model.RefreshToken, userAgent, ipAddress string) (*LoginResult, error) {
+	result, reused, err := s.refresh(ctx, refreshToken, userAgent, ipAddress)
+	s.metrics.RefreshAttempt(refreshOutcome(reused, err))
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.TokenIssued(TokenAccess)
+	s.metrics.TokenIssued(TokenRefresh)
+	return result, nil
+}
+
+func (s *Service) refresh(ctx context.Context, refreshToken model.RefreshToken, userAgent, ipAddress string) (*LoginResult, bool, error) {
+	session, err := s.refreshTokenRepo.GetRefreshTokenSession(ctx, refreshToken)
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return nil, false, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !session.RevokedAt.IsZero() {
+		return nil, true, s.revokeFamily(ctx, session.FamilyID)
+	}
+	now := time.Now()
+	if !now.Before(session.ExpiresAt) {
+		return nil, false, ErrInvalidRefreshToken
+	}
+
+	accessToken, err := s.accessToken.CreateToken(session.MemberID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	nextToken, err := s.refreshToken.CreateToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	maxAge := s.refreshToken.MaxAge()
+	next := &model.RefreshTokenSession{
+		ID:        uuid.NewString(),
+		FamilyID:  session.FamilyID,
+		MemberID:  session.MemberID,
+		TokenHash: nextToken,
+		ExpiresAt: now.Add(time.Duration(maxAge) * time.Second),
+		CreatedAt: now,
+		UserAgent: redact.Redacted(userAgent),
+		IPAddress: redact.Redacted(ipAddress),
+	}
+	err = s.refreshTokenRepo.RotateRefreshTokenSession(ctx, refreshToken, next)
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenRevoked):
+		// A concurrent refresh rotated the token first.
+		return nil, true, s.revokeFamily(ctx, session.FamilyID)
+	case errors.Is(err, repository.ErrRefreshTokenNotFound):
+		return nil, false, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, false, err
+	}
+
+	return &LoginResult{
+		AccessToken:      accessToken,
+		RefreshToken:     nextToken,
+		RefreshMaxAgeSec: maxAge,
+		RefreshEndPoint:  s.refreshToken.RefreshEndPoint(),
+	}, false, nil
+}
+
+// Logout revokes the session of refreshToken, including every token it was rotated to.
+// A token that is unknown or already revoked leaves nothing to revoke.
+func (s *Service) Logout(ctx context.Context, refreshToken model.RefreshToken) error {
+	session, err := s.refreshTokenRepo.GetRefreshTokenSession(ctx, refreshToken)
+	if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.refreshTokenRepo.RevokeRefreshTokenFamily(ctx, session.FamilyID)
+}
+
+// revokeFamily revokes a family whose token was reused and returns the error for the
+// refresh that reused it.
+func (s *Service) revokeFamily(ctx context.Context, familyID string) error {
+	if err := s.refreshTokenRepo.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
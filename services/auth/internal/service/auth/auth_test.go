@@ -6,12 +6,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/incheat/go-production-backend/services/auth/internal/gateway"
+	"github.com/incheat/go-production-backend/services/auth/internal/repository"
+	memoryrepo "github.com/incheat/go-production-backend/services/auth/internal/repository/memory"
 	authservice "github.com/incheat/go-production-backend/services/auth/internal/service/auth"
+	"github.com/incheat/go-production-backend/services/auth/internal/token"
 	"github.com/incheat/go-production-backend/services/auth/pkg/model"
 	usermodel "github.com/incheat/go-production-backend/services/user/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 // --- Testify mocks ---
@@ -53,6 +58,29 @@ type MockRefreshTokenRepository struct {
 	mock.Mock
 }
 
+func (m *MockRefreshTokenRepository) GetRefreshTokenSession(
+	ctx context.Context,
+	refreshToken model.RefreshToken,
+) (*model.RefreshTokenSession, error) {
+	args := m.Called(ctx, refreshToken)
+	session, _ := args.Get(0).(*model.RefreshTokenSession)
+	return session, args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RotateRefreshTokenSession(
+	ctx context.Context,
+	refreshToken model.RefreshToken,
+	next *model.RefreshTokenSession,
+) error {
+	args := m.Called(ctx, refreshToken, next)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
 func (m *MockRefreshTokenRepository) SaveRefreshTokenSession(
 	ctx context.Context,
 	session *model.RefreshTokenSession,
@@ -70,6 +98,30 @@ func (m *MockUserGateway) VerifyCredentials(ctx context.Context, email string, p
 	return args.Get(0).(*usermodel.User), args.Error(1)
 }
 
+// recordingMetrics records the business metrics of the service.
+type recordingMetrics struct {
+	logins       []authservice.LoginOutcome
+	refreshes    []authservice.RefreshOutcome
+	tokens       []authservice.TokenType
+	gatewayCodes []codes.Code
+}
+
+func (m *recordingMetrics) LoginAttempt(outcome authservice.LoginOutcome) {
+	m.logins = append(m.logins, outcome)
+}
+
+func (m *recordingMetrics) RefreshAttempt(outcome authservice.RefreshOutcome) {
+	m.refreshes = append(m.refreshes, outcome)
+}
+
+func (m *recordingMetrics) TokenIssued(tokenType authservice.TokenType) {
+	m.tokens = append(m.tokens, tokenType)
+}
+
+func (m *recordingMetrics) UserGatewayCall(code codes.Code, _ time.Duration) {
+	m.gatewayCodes = append(m.gatewayCodes, code)
+}
+
 // TestUnitLoginWithEmailAndPassword_Success tests the happy path for LoginWithEmailAndPassword.
 func TestUnitLoginWithEmailAndPassword_Success(t *testing.T) {
 	ctx := context.Background()
@@ -124,8 +176,8 @@ func TestUnitLoginWithEmailAndPassword_Success(t *testing.T) {
 				// Basic field checks
 				assert.Equal(t, email, sess.MemberID)
 				assert.Equal(t, refreshToken, sess.TokenHash)
-				assert.Equal(t, userAgent, sess.UserAgent)
-				assert.Equal(t, ip, sess.IPAddress)
+				assert.Equal(t, userAgent, sess.UserAgent.Reveal())
+				assert.Equal(t, ip, sess.IPAddress.Reveal())
 
 				// Check time relationship
 				assert.True(t, sess.ExpiresAt.After(sess.CreatedAt))
@@ -283,3 +335,260 @@ func TestUnitLoginWithEmailAndPassword_Errors(t *testing.T) {
 		})
 	}
 }
+
+// TestUnitLoginWithEmailAndPassword_RecordsMetrics tests that every login attempt records
+// its outcome and the user gateway call, and only successful ones record issued tokens.
+func TestUnitLoginWithEmailAndPassword_RecordsMetrics(t *testing.T) {
+	ctx := context.Background()
+	email := "user@example.com"
+
+	tests := []struct {
+		name        string
+		gatewayErr  error
+		tokenErr    error
+		wantOutcome authservice.LoginOutcome
+		wantCode    codes.Code
+		wantTokens  []authservice.TokenType
+	}{
+		{
+			name:        "success",
+			wantOutcome: authservice.LoginSuccess,
+			wantCode:    codes.OK,
+			wantTokens:  []authservice.TokenType{authservice.TokenAccess, authservice.TokenRefresh},
+		},
+		{
+			name:        "bad credentials",
+			gatewayErr:  gateway.ErrInvalidCredentials,
+			wantOutcome: authservice.LoginInvalidCredentials,
+			wantCode:    codes.Unauthenticated,
+		},
+		{
+			name:        "user not active",
+			gatewayErr:  gateway.ErrUserNotActive,
+			wantOutcome: authservice.LoginLocked,
+			wantCode:    codes.PermissionDenied,
+		},
+		{
+			name:        "call forbidden",
+			gatewayErr:  gateway.ErrForbidden,
+			wantOutcome: authservice.LoginError,
+			wantCode:    codes.PermissionDenied,
+		},
+		{
+			name:        "service authorization rejected",
+			gatewayErr:  gateway.ErrUnexpected,
+			wantOutcome: authservice.LoginError,
+			wantCode:    codes.Internal,
+		},
+		{
+			name:        "user service unavailable",
+			gatewayErr:  gateway.ErrUnavailable,
+			wantOutcome: authservice.LoginUnavailable,
+			wantCode:    codes.Unavailable,
+		},
+		{
+			name:        "token error",
+			tokenErr:    errors.New("access error"),
+			wantOutcome: authservice.LoginError,
+			wantCode:    codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessMock := new(MockAccessTokenMaker)
+			refreshMock := new(MockRefreshTokenMaker)
+			repoMock := new(MockRefreshTokenRepository)
+			userGatewayMock := new(MockUserGateway)
+
+			user := &usermodel.User{ID: "123", Email: email}
+			if tt.gatewayErr != nil {
+				user = nil
+			}
+			userGatewayMock.On("VerifyCredentials", mock.Anything, email, "password").Return(user, tt.gatewayErr)
+			accessMock.On("CreateToken", email).Return(model.AccessToken("access-token"), tt.tokenErr)
+			refreshMock.On("CreateToken").Return(model.RefreshToken("refresh-token"), nil)
+			refreshMock.On("MaxAge").Return(3600)
+			refreshMock.On("RefreshEndPoint").Return("/refresh")
+			repoMock.On("SaveRefreshTokenSession", mock.Anything, mock.Anything).Return(nil)
+
+			metrics := &recordingMetrics{}
+			ctrl := authservice.New(accessMock, refreshMock, repoMock, userGatewayMock, authservice.WithMetrics(metrics))
+
+			_, _ = ctrl.LoginWithEmailAndPassword(ctx, email, "password", "agent", "ip")
+
+			assert.Equal(t, []authservice.LoginOutcome{tt.wantOutcome}, metrics.logins)
+			assert.Equal(t, []codes.Code{tt.wantCode}, metrics.gatewayCodes)
+			assert.Equal(t, tt.wantTokens, metrics.tokens)
+		})
+	}
+}
+
+// newRefreshService returns a service on a memory repository with a session logged in
+// for email, and its refresh token.
+func newRefreshService(t *testing.T, email string, opts ...authservice.Option) (*authservice.Service, *memoryrepo.RefreshTokenRepository, model.RefreshToken) {
+	t.Helper()
+	accessMock := new(MockAccessTokenMaker)
+	accessMock.On("CreateToken", email).Return(model.AccessToken("access-token"), nil)
+	userGatewayMock := new(MockUserGateway)
+	userGatewayMock.On("VerifyCredentials", mock.Anything, email, "password").Return(&usermodel.User{ID: "123", Email: email}, nil)
+	repo := memoryrepo.NewRefreshTokenRepository()
+
+	svc := authservice.New(accessMock, token.NewOpaqueMaker(32, 3600, "/v1/refresh"), repo, userGatewayMock, opts...)
+	login, err := svc.LoginWithEmailAndPassword(context.Background(), email, "password", "agent", "ip")
+	require.NoError(t, err)
+	return svc, repo, login.RefreshToken
+}
+
+// TestUnitRefresh_Rotation tests that a refresh revokes the presented token and issues a
+// new one in the same session, and the reuse of a rotated token revokes the session.
+func TestUnitRefresh_Rotation(t *testing.T) {
+	ctx := context.Background()
+	metrics := &recordingMetrics{}
+	svc, repo, first := newRefreshService(t, "user@example.com", authservice.WithMetrics(metrics))
+
+	result, err := svc.Refresh(ctx, first, "agent", "ip")
+	require.NoError(t, err)
+	second := result.RefreshToken
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, model.AccessToken("access-token"), result.AccessToken)
+	assert.Equal(t, 3600, result.RefreshMaxAgeSec)
+
+	rotated, err := repo.GetRefreshTokenSession(ctx, first)
+	require.NoError(t, err)
+	assert.False(t, rotated.RevokedAt.IsZero())
+	current, err := repo.GetRefreshTokenSession(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.FamilyID, current.FamilyID)
+	assert.Equal(t, "user@example.com", current.MemberID)
+
+	_, err = svc.Refresh(ctx, first, "attacker", "ip")
+	assert.ErrorIs(t, err, authservice.ErrInvalidRefreshToken)
+	_, err = svc.Refresh(ctx, second, "agent", "ip")
+	assert.ErrorIs(t, err, authservice.ErrInvalidRefreshToken, "reuse revokes the session")
+
+	active, err := repo.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, active)
+	assert.Equal(t, []authservice.RefreshOutcome{
+		authservice.RefreshRotated, authservice.RefreshReused, authservice.RefreshInvalid,
+	}, metrics.refreshes)
+	assert.Equal(t, []authservice.TokenType{
+		authservice.TokenAccess, authservice.TokenRefresh,
+		authservice.TokenAccess, authservice.TokenRefresh,
+	}, metrics.tokens)
+}
+
+// TestUnitRefresh_Errors tests the refreshes of unknown and expired tokens, and the
+// failures of the repository.
+func TestUnitRefresh_Errors(t *testing.T) {
+	ctx := context.Background()
+	errRedis := errors.New("redis unavailable")
+	session := &model.RefreshTokenSession{
+		ID:        "s1",
+		FamilyID:  "s1",
+		MemberID:  "user@example.com",
+		TokenHash: "refresh-token",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expired := *session
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	revoked := *session
+	revoked.RevokedAt = time.Now()
+
+	tests := []struct {
+		name        string
+		setup       func(repo *MockRefreshTokenRepository)
+		wantErr     error
+		wantOutcome authservice.RefreshOutcome
+	}{
+		{
+			name: "unknown token",
+			setup: func(repo *MockRefreshTokenRepository) {
+				repo.On("GetRefreshTokenSession", mock.Anything, model.RefreshToken("refresh-token")).
+					Return(nil, repository.ErrRefreshTokenNotFound)
+			},
+			wantErr:     authservice.ErrInvalidRefreshToken,
+			wantOutcome: authservice.RefreshInvalid,
+		},
+		{
+			name: "expired token",
+			setup: func(repo *MockRefreshTokenRepository) {
+				repo.On("GetRefreshTokenSession", mock.Anything, mock.Anything).Return(&expired, nil)
+			},
+			wantErr:     authservice.ErrInvalidRefreshToken,
+			wantOutcome: authservice.RefreshInvalid,
+		},
+		{
+			name: "repository error",
+			setup: func(repo *MockRefreshTokenRepository) {
+				repo.On("GetRefreshTokenSession", mock.Anything, mock.Anything).Return(nil, errRedis)
+			},
+			wantErr:     errRedis,
+			wantOutcome: authservice.RefreshError,
+		},
+		{
+			name: "concurrent rotation",
+			setup: func(repo *MockRefreshTokenRepository) {
+				repo.On("GetRefreshTokenSession", mock.Anything, mock.Anything).Return(session, nil)
+				repo.On("RotateRefreshTokenSession", mock.Anything, model.RefreshToken("refresh-token"), mock.MatchedBy(func(next *model.RefreshTokenSession) bool {
+					return next.FamilyID == "s1" && next.MemberID == "user@example.com"
+				})).Return(repository.ErrRefreshTokenRevoked)
+				repo.On("RevokeRefreshTokenFamily", mock.Anything, "s1").Return(nil).Once()
+			},
+			wantErr:     authservice.ErrInvalidRefreshToken,
+			wantOutcome: authservice.RefreshReused,
+		},
+		{
+			name: "revoke error on reuse",
+			setup: func(repo *MockRefreshTokenRepository) {
+				repo.On("GetRefreshTokenSession", mock.Anything, mock.Anything).Return(&revoked, nil)
+				repo.On("RevokeRefreshTokenFamily", mock.Anything, "s1").Return(errRedis).Once()
+			},
+			wantErr:     errRedis,
+			wantOutcome: authservice.RefreshReused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessMock := new(MockAccessTokenMaker)
+			accessMock.On("CreateToken", "user@example.com").Return(model.AccessToken("access-token"), nil)
+			refreshMock := new(MockRefreshTokenMaker)
+			refreshMock.On("CreateToken").Return(model.RefreshToken("next-token"), nil)
+			refreshMock.On("MaxAge").Return(3600)
+			repoMock := new(MockRefreshTokenRepository)
+			tt.setup(repoMock)
+			metrics := &recordingMetrics{}
+
+			svc := authservice.New(accessMock, refreshMock, repoMock, new(MockUserGateway), authservice.WithMetrics(metrics))
+			result, err := svc.Refresh(ctx, "refresh-token", "agent", "ip")
+
+			assert.Nil(t, result)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, []authservice.RefreshOutcome{tt.wantOutcome}, metrics.refreshes)
+			assert.Empty(t, metrics.tokens)
+			repoMock.AssertExpectations(t)
+		})
+	}
+}
+
+// TestUnitLogout_RevokesSession tests that logout revokes the whole session of a refresh
+// token, and unknown tokens are ignored.
+func TestUnitLogout_RevokesSession(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, first := newRefreshService(t, "user@example.com")
+	result, err := svc.Refresh(ctx, first, "agent", "ip")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Logout(ctx, result.RefreshToken))
+
+	for _, refreshToken := range []model.RefreshToken{first, result.RefreshToken} {
+		_, err = repo.GetRefreshTokenSession(ctx, refreshToken)
+		assert.ErrorIs(t, err, repository.ErrRefreshTokenNotFound)
+	}
+	active, err := repo.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, active)
+	assert.NoError(t, svc.Logout(ctx, "unknown"))
+}
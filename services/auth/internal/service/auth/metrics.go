@@ -0,0 +1,112 @@
+// Package authservice defines the business metrics for the auth API.
+package authservice
+
+import (
+	"errors"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"google.golang.org/grpc/codes"
+)
+
+// LoginOutcome is the outcome of a login attempt.
+type LoginOutcome string
+
+const (
+	// LoginSuccess is a login that issued tokens.
+	LoginSuccess LoginOutcome = "success"
+	// LoginInvalidCredentials is a login with an unknown email or a wrong password.
+	LoginInvalidCredentials LoginOutcome = "invalid_credentials"
+	// LoginLocked is a login with valid credentials of a disabled or locked user.
+	LoginLocked LoginOutcome = "locked"
+	// LoginUnavailable is a login that failed because the user service is unavailable.
+	LoginUnavailable LoginOutcome = "unavailable"
+	// LoginError is any other failed login.
+	LoginError LoginOutcome = "error"
+)
+
+// RefreshOutcome is the outcome of a refresh attempt.
+type RefreshOutcome string
+
+const (
+	// RefreshRotated is a refresh that rotated the refresh token and issued tokens.
+	RefreshRotated RefreshOutcome = "rotated"
+	// RefreshReused is a refresh with an already rotated token, which revokes its session.
+	RefreshReused RefreshOutcome = "reused"
+	// RefreshInvalid is a refresh with an unknown or expired token.
+	RefreshInvalid RefreshOutcome = "invalid"
+	// RefreshError is any other failed refresh.
+	RefreshError RefreshOutcome = "error"
+)
+
+// TokenType is the type of an issued token.
+type TokenType string
+
+const (
+	// TokenAccess is a JWT access token.
+	TokenAccess TokenType = "access"
+	// TokenRefresh is an opaque refresh token.
+	TokenRefresh TokenType = "refresh"
+)
+
+// Metrics records the business metrics of the service. Implementations must be safe
+// for concurrent use.
+type Metrics interface {
+	// LoginAttempt records a login attempt.
+	LoginAttempt(outcome LoginOutcome)
+	// RefreshAttempt records a refresh attempt.
+	RefreshAttempt(outcome RefreshOutcome)
+	// TokenIssued records a token returned to a client.
+	TokenIssued(tokenType TokenType)
+	// UserGatewayCall records a call to the user gateway, including its retries.
+	UserGatewayCall(code codes.Code, duration time.Duration)
+}
+
+// noopMetrics is the Metrics of a service created without WithMetrics.
+type noopMetrics struct{}
+
+func (noopMetrics) LoginAttempt(LoginOutcome)                 {}
+func (noopMetrics) RefreshAttempt(RefreshOutcome)             {}
+func (noopMetrics) TokenIssued(TokenType)                     {}
+func (noopMetrics) UserGatewayCall(codes.Code, time.Duration) {}
+
+// loginOutcome returns the outcome of a login that returned err.
+func loginOutcome(err error) LoginOutcome {
+	if err == nil {
+		return LoginSuccess
+	}
+	switch apierror.From(err).Code {
+	case apierror.CodeInvalidCredentials:
+		return LoginInvalidCredentials
+	case apierror.CodeUserNotActive:
+		return LoginLocked
+	case apierror.CodeUnavailable:
+		return LoginUnavailable
+	default:
+		return LoginError
+	}
+}
+
+// refreshOutcome returns the outcome of a refresh that returned err, given whether
+// it detected the reuse of a rotated token.
+func refreshOutcome(reused bool, err error) RefreshOutcome {
+	switch {
+	case reused:
+		return RefreshReused
+	case err == nil:
+		return RefreshRotated
+	case errors.Is(err, ErrInvalidRefreshToken):
+		return RefreshInvalid
+	default:
+		return RefreshError
+	}
+}
+
+// gatewayCode returns the gRPC code of a user gateway call that returned err. The
+// gateway returns coded errors, so the code is the closest to the original status.
+func gatewayCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	return apierror.From(err).Code.GRPCCode()
+}
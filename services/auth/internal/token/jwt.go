@@ -2,26 +2,40 @@ package token
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
 	"github.com/incheat/go-production-backend/services/auth/pkg/model"
 )
 
-// JWTMaker is a JWT maker.
+// JWTMaker is a JWT maker. Its signing key can be rotated at runtime; the previous
+// public key stays in the JWKS so tokens signed before the rotation keep verifying.
 type JWTMaker struct {
+	baseKid  string
+	issuer   string
+	audience string
+	expire   time.Duration
+
+	mu       sync.RWMutex
+	current  signingKey
+	previous *signingKey
+}
+
+// signingKey is a private key and the kid published for it.
+type signingKey struct {
 	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
 	kid        string
-	issuer     string
-	audience   string
-	expire     time.Duration
 }
 
 // New creates a new JWTMaker from a PEM encoded private key.
@@ -32,41 +46,75 @@ func New(privateKeyPEM, kid, issuer, audience string, expire time.Duration) (*JW
 	if kid == "" {
 		return nil, errors.New("JWT kid is empty")
 	}
+	priv, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTMaker{
+		baseKid:  kid,
+		issuer:   issuer,
+		audience: audience,
+		expire:   expire,
+		current:  signingKey{privateKey: priv, kid: kid},
+	}, nil
+}
+
+// Rotate replaces the signing key with privateKeyPEM. The new key is published under
+// the configured kid suffixed with its thumbprint, so verifiers caching the JWKS by kid
+// refetch it. Rotating to the current key is a no-op.
+func (m *JWTMaker) Rotate(privateKeyPEM string) error {
+	priv, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if priv.Equal(m.current.privateKey) {
+		return nil
+	}
+	previous := m.current
+	m.previous = &previous
+	m.current = signingKey{privateKey: priv, kid: m.baseKid + "-" + thumbprint(&priv.PublicKey)}
+	return nil
+}
+
+// signingKey returns the current signing key.
+func (m *JWTMaker) signingKey() signingKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+func parsePrivateKey(privateKeyPEM string) (*rsa.PrivateKey, error) {
 	block, _ := pem.Decode([]byte(privateKeyPEM))
 	if block == nil {
 		return nil, errors.New("failed to decode PEM")
 	}
 
-	var priv *rsa.PrivateKey
 	switch block.Type {
 	case "RSA PRIVATE KEY":
-		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-		if err != nil {
-			return nil, err
-		}
-		priv = key
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
 	case "PRIVATE KEY":
 		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 		if err != nil {
 			return nil, err
 		}
-		var ok bool
-		priv, ok = key.(*rsa.PrivateKey)
+		priv, ok := key.(*rsa.PrivateKey)
 		if !ok {
 			return nil, errors.New("PKCS8 key is not RSA")
 		}
+		return priv, nil
 	default:
 		return nil, errors.New("unsupported PEM block type: " + block.Type)
 	}
+}
 
-	return &JWTMaker{
-		privateKey: priv,
-		publicKey:  &priv.PublicKey,
-		kid:        kid,
-		issuer:     issuer,
-		audience:   audience,
-		expire:     expire,
-	}, nil
+// thumbprint returns a short, stable identifier of pub.
+func thumbprint(pub *rsa.PublicKey) string {
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	return hex.EncodeToString(sum[:4])
 }
 
 // CreateToken creates a new RS256 JWT token for a user.
@@ -81,9 +129,10 @@ func (m *JWTMaker) CreateToken(ID string) (model.AccessToken, error) {
 		// "scope": "user:read order:read auth:read"
 	}
 
+	key := m.signingKey()
 	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	t.Header["kid"] = m.kid
-	tokenStr, err := t.SignedString(m.privateKey)
+	t.Header["kid"] = key.kid
+	tokenStr, err := t.SignedString(key.privateKey)
 	if err != nil {
 		return "", err
 	}
@@ -91,6 +140,31 @@ func (m *JWTMaker) CreateToken(ID string) (model.AccessToken, error) {
 	return accessToken, nil
 }
 
+// CreateServiceToken creates a new RS256 service token identifying the calling service
+// subject towards audience. It is signed with the same key as user tokens and published
+// in the same JWKS, and is marked by the svcauth.ClaimTokenUse claim.
+func (m *JWTMaker) CreateServiceToken(subject, audience string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := jwt.MapClaims{
+		"sub":                 subject,
+		"iss":                 m.issuer,
+		"aud":                 audience,
+		"iat":                 now.Unix(),
+		"exp":                 expiresAt.Unix(),
+		svcauth.ClaimTokenUse: svcauth.TokenUseService,
+	}
+
+	key := m.signingKey()
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = key.kid
+	tokenStr, err := t.SignedString(key.privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenStr, expiresAt, nil
+}
+
 // ---- JWKS ----
 
 type jwks struct {
@@ -106,19 +180,25 @@ type jwkKey struct {
 	E   string `json:"e"`
 }
 
-// JWKSJSON returns the JWKS JSON for the public key.
+// JWKSJSON returns the JWKS JSON for the current public key and, after a rotation, the
+// previous one.
 func (m *JWTMaker) JWKSJSON() ([]byte, error) {
+	m.mu.RLock()
 	j := jwks{
-		Keys: []jwkKey{rsaPublicKeyToJWK(m.publicKey, m.kid)},
+		Keys: []jwkKey{rsaPublicKeyToJWK(&m.current.privateKey.PublicKey, m.current.kid)},
+	}
+	if m.previous != nil {
+		j.Keys = append(j.Keys, rsaPublicKeyToJWK(&m.previous.privateKey.PublicKey, m.previous.kid))
 	}
+	m.mu.RUnlock()
 	return json.Marshal(j)
 }
 
 // JWKSHandler returns the JWKS JSON for the public key.
-func (m *JWTMaker) JWKSHandler(w http.ResponseWriter, _ *http.Request) {
+func (m *JWTMaker) JWKSHandler(w http.ResponseWriter, r *http.Request) {
 	b, err := m.JWKSJSON()
 	if err != nil {
-		http.Error(w, "failed to build jwks", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Wrap(apierror.CodeInternal, "failed to build jwks", err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
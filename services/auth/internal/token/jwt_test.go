@@ -0,0 +1,72 @@
+package token_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/incheat/go-production-backend/services/auth/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeyPEM(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func jwksKids(t *testing.T, m *token.JWTMaker) []string {
+	t.Helper()
+	b, err := m.JWKSJSON()
+	require.NoError(t, err)
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(b, &set))
+	var kids []string
+	for _, k := range set.Keys {
+		kids = append(kids, k.Kid)
+	}
+	return kids
+}
+
+func tokenKid(t *testing.T, m *token.JWTMaker) string {
+	t.Helper()
+	accessToken, err := m.CreateToken("user-1")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(string(accessToken), jwt.MapClaims{})
+	require.NoError(t, err)
+	return parsed.Header["kid"].(string)
+}
+
+// TestUnitRotate_PublishesBothKeys tests that after a rotation new tokens are signed with
+// the new key and the JWKS still publishes the previous one.
+func TestUnitRotate_PublishesBothKeys(t *testing.T) {
+	m, err := token.New(newKeyPEM(t), "kid", "auth", "api", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "kid", tokenKid(t, m))
+	assert.Equal(t, []string{"kid"}, jwksKids(t, m))
+
+	rotated := newKeyPEM(t)
+	require.NoError(t, m.Rotate(rotated))
+	newKid := tokenKid(t, m)
+	assert.True(t, strings.HasPrefix(newKid, "kid-"))
+	assert.Equal(t, []string{newKid, "kid"}, jwksKids(t, m))
+
+	// Rotating to the current key changes nothing.
+	require.NoError(t, m.Rotate(rotated))
+	assert.Equal(t, []string{newKid, "kid"}, jwksKids(t, m))
+
+	assert.Error(t, m.Rotate("not a key"))
+	assert.Equal(t, newKid, tokenKid(t, m))
+}
@@ -1,7 +1,11 @@
 // Package model defines the models for the auth service.
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+)
 
 // AccessToken is a string that represents an access token.
 type AccessToken string
@@ -10,13 +14,16 @@ type AccessToken string
 type RefreshToken string
 
 // RefreshTokenSession is a model for a refresh token session.
+// FamilyID is shared by the sessions rotated from the same login.
+// UserAgent and IPAddress are personal data and print as redact.Mask.
 type RefreshTokenSession struct {
 	ID        string
+	FamilyID  string
 	MemberID  string
 	TokenHash RefreshToken
 	ExpiresAt time.Time
 	CreatedAt time.Time
 	RevokedAt time.Time
-	UserAgent string
-	IPAddress string
+	UserAgent redact.Redacted
+	IPAddress redact.Redacted
 }
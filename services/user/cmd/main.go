@@ -6,190 +6,423 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"net"
+	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-sql-driver/mysql"
 	userpb "github.com/incheat/go-production-backend/api/user/grpc/gen"
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/config"
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/health"
+	"github.com/incheat/go-production-backend/pkg/mtls"
 	obsconfig "github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 	"github.com/incheat/go-production-backend/pkg/obs/logging"
 	obsmetrics "github.com/incheat/go-production-backend/pkg/obs/metrics"
 	"github.com/incheat/go-production-backend/pkg/obs/profiling"
-	obstracing "github.com/incheat/go-production-backend/pkg/obs/tracing"
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+	"github.com/incheat/go-production-backend/pkg/secrets"
+	"github.com/incheat/go-production-backend/pkg/server"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	servergen "github.com/incheat/go-production-backend/services/user/internal/api/oapi/gen/private/server"
 	envconfig "github.com/incheat/go-production-backend/services/user/internal/config/env"
 	"github.com/incheat/go-production-backend/services/user/internal/constant"
 	userhandler "github.com/incheat/go-production-backend/services/user/internal/handler/grpc"
+	userhttphandler "github.com/incheat/go-production-backend/services/user/internal/handler/http"
 	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	usermetrics "github.com/incheat/go-production-backend/services/user/internal/metrics"
+	"github.com/incheat/go-production-backend/services/user/internal/outbox"
+	redisoutbox "github.com/incheat/go-production-backend/services/user/internal/outbox/redis"
 	userrepo "github.com/incheat/go-production-backend/services/user/internal/repository/mysql"
 	userservice "github.com/incheat/go-production-backend/services/user/internal/service/user"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	nethttpmiddleware "github.com/oapi-codegen/nethttp-middleware"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
 
-	cfg, err := envconfig.Load()
+	cfg, loaded, err := envconfig.Load(os.Args[1:])
+	if loaded != nil && loaded.CheckOnly {
+		os.Exit(config.Check(os.Stdout, loaded, err))
+	}
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	telemetryConfig := obsconfig.TelemetryConfig{
-		Resource: obsconfig.ResourceConfig{
-			ServiceName:    constant.ServiceName,
-			Environment:    string(cfg.Env),
-			ServiceVersion: cfg.Version,
+	ctx := context.Background()
+
+	// ------------------------------------------------------------------
+	// Telemetry (MUST be before creating grpcServer)
+	// ------------------------------------------------------------------
+	telemetry, err := server.NewTelemetry(ctx, server.TelemetryConfig{
+		TelemetryConfig: obsconfig.TelemetryConfig{
+			Resource: obsconfig.ResourceConfig{
+				ServiceName:    constant.ServiceName,
+				Environment:    string(cfg.Env),
+				ServiceVersion: cfg.Version,
+			},
+			Logging: obsconfig.LoggingConfig{
+				Level: cfg.Obs.Logging.Level,
+			},
+			OTLP: obsconfig.OTLPConfig{
+				Endpoint: cfg.Obs.OTLP.Endpoint,
+				Insecure: cfg.Obs.OTLP.Insecure,
+				TLS: obsconfig.TLSConfig{
+					CAFile:     cfg.Obs.OTLP.CAFile,
+					CertFile:   cfg.Obs.OTLP.CertFile,
+					KeyFile:    cfg.Obs.OTLP.KeyFile,
+					ServerName: cfg.Obs.OTLP.ServerName,
+				},
+			},
+			Tracing: obsconfig.TracingConfig{
+				SamplingRatio: cfg.Obs.Tracing.SamplingRatio,
+				Rules:         cfg.Obs.Tracing.SamplingRules,
+				SampleErrors:  cfg.Obs.Tracing.SampleErrors,
+				Exporter:      cfg.Obs.Tracing.Exporter,
+				FilePath:      cfg.Obs.Tracing.FilePath,
+			},
+			Metrics: obsconfig.MetricsConfig{
+				Prometheus:     cfg.Obs.Metrics.Prometheus,
+				Exporter:       cfg.Obs.Metrics.Exporter,
+				ExportInterval: cfg.Obs.Metrics.ExportInterval,
+				Exemplars:      cfg.Obs.Metrics.Exemplars,
+			},
+			Redaction: cfg.Obs.Redaction.Fields,
 		},
-		Logging: obsconfig.LoggingConfig{
-			Level: cfg.Obs.Logging.Level,
+		MetricsAddr:   fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port)),
+		ProfilingAddr: fmt.Sprintf(":%d", int(cfg.Obs.Profiling.Port)),
+		AdminToken:    cfg.Obs.Admin.Token,
+		ProfilingTLS: mtls.Config{
+			CertFile: cfg.Obs.Profiling.TLS.CertFile,
+			KeyFile:  cfg.Obs.Profiling.TLS.KeyFile,
+			CAFile:   cfg.Obs.Profiling.TLS.CAFile,
 		},
-		OTLP: obsconfig.OTLPConfig{
-			Endpoint: cfg.Obs.OTLP.Endpoint,
-			Insecure: true,
+		ProfilingAllowedSANs: cfg.Obs.Profiling.TLS.AllowedSANs,
+		Capture: profiling.CaptureConfig{
+			Dir:              cfg.Obs.Profiling.Capture.Dir,
+			LatencyThreshold: cfg.Obs.Profiling.Capture.LatencyThreshold,
+			HeapThreshold:    uint64(max(cfg.Obs.Profiling.Capture.HeapThresholdMB, 0)) << 20,
+			CPUDuration:      cfg.Obs.Profiling.Capture.CPUDuration,
+			Cooldown:         cfg.Obs.Profiling.Capture.Cooldown,
+			Retention:        cfg.Obs.Profiling.Capture.Retention,
+			MaxCaptures:      cfg.Obs.Profiling.Capture.MaxCaptures,
 		},
-		Tracing: obsconfig.TracingConfig{
-			SamplingRatio: cfg.Obs.Tracing.SamplingRatio,
+		Push: profiling.PushConfig{
+			ServerAddress: cfg.Obs.Profiling.Push.Address,
+			AuthToken:     cfg.Obs.Profiling.Push.AuthToken,
+			TenantID:      cfg.Obs.Profiling.Push.TenantID,
 		},
-	}
-
-	// ------------------------------------------------------------------
-	// Logger
-	// ------------------------------------------------------------------
-	logger, err := logging.New(logging.Config{
-		Service: telemetryConfig.Resource.ServiceName,
-		Env:     telemetryConfig.Resource.Environment,
-		Level:   telemetryConfig.Logging.Level,
 	})
 	if err != nil {
-		log.Fatalf("Error creating logger: %v", err)
+		log.Fatalf("Error creating telemetry: %v", err)
 	}
+	logger := telemetry.Logger
 
 	logger.Info("Starting user service", zap.String("env", string(cfg.Env)))
+	logger.Info("Effective configuration", zap.String("file", loaded.File), zap.Any("config", loaded.Redacted()))
 	logger.Info("GRPC server port", zap.Int("port", int(cfg.Server.GrpcPort)))
 
-	// ------------------------------------------------------------------
-	// Context & signal
-	// ------------------------------------------------------------------
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	// Start profiling server
-	profiling.StartServer(ctx, fmt.Sprintf(":%d", int(cfg.Obs.Profiling.Port)), logger)
+	lifecycle := server.New(server.Config{
+		DrainPeriod: cfg.Shutdown.DrainPeriod,
+		StopTimeout: cfg.Shutdown.Timeout,
+	}, logger)
+	lifecycle.Register(telemetry.Components()...)
 
 	// Initialize Prometheus metrics
-	reg := obsmetrics.NewRegistry()
-	obsmetrics.RegisterGRPC(reg)
-	shutdownMetrics := obsmetrics.StartServer(fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port)), reg, logger)
-	if err != nil {
-		logger.Error("Error initializing Prometheus metrics server", zap.Error(err))
-	} else {
-		logger.Info("Prometheus metrics server initialized", zap.String("port", fmt.Sprintf(":%d", int(cfg.Obs.Metrics.Port))))
-	}
-	defer func() {
-		if err := shutdownMetrics(ctx); err != nil {
-			logger.Error("Error shutting down Prometheus metrics server", zap.Error(err))
-		}
-	}()
+	reg := telemetry.Registry
+	obsmetrics.RegisterAuthz(reg)
 
-	// ------------------------------------------------------------------
-	// OpenTelemetry (MUST be before creating grpcServer)
-	// ------------------------------------------------------------------
+	// Secret references (file://, vault://) are polled and applied without a restart
+	secretWatcher := secrets.NewWatcher(envconfig.NewSecretResolver(cfg), cfg.Secrets.ReloadInterval, logger)
+	lifecycle.Register(server.Runner("secret watcher", secretWatcher.Run))
+
+	// Redis is optional: it backs the outbox relay and shared rate limit buckets.
+	var redisClient *redis.Client
+	if cfg.Redis.Host != "" {
+		// The password is read on every new connection, so a rotated password is used
+		// without dropping the pool.
+		redisPassword := secrets.NewValue(cfg.Redis.Password)
+		if ref, ok := loaded.Reference("redis.password"); ok {
+			secretWatcher.Watch("redis password", ref, cfg.Redis.Password, func(password string) error {
+				redisPassword.Set(password)
+				return nil
+			})
+		}
+		redisClient = redis.NewClient(&redis.Options{
+			Addr: cfg.Redis.Host,
+			CredentialsProvider: func() (string, string) {
+				return "", redisPassword.Get()
+			},
+			DB: cfg.Redis.DB,
+		})
+		lifecycle.Register(server.Closer("redis", redisClient.Close))
+	}
 
-	// Initialize OpenTelemetry tracer
-	otelShutdown, err := obstracing.InitTracer(ctx, telemetryConfig)
+	// Initialize MySQL connection. As for Redis, the password is read on every new
+	// connection; existing connections are recycled within ConnMaxLifetime.
+	mysqlPassword := secrets.NewValue(cfg.MySQL.Password)
+	if ref, ok := loaded.Reference("mysql.password"); ok {
+		secretWatcher.Watch("mysql password", ref, cfg.MySQL.Password, func(password string) error {
+			mysqlPassword.Set(password)
+			return nil
+		})
+	}
+	dbConfig := mysql.NewConfig()
+	dbConfig.User = cfg.MySQL.User
+	dbConfig.Net = "tcp"
+	dbConfig.Addr = cfg.MySQL.Host
+	dbConfig.DBName = cfg.MySQL.DBName
+	dbConfig.ParseTime = true
+	if err := dbConfig.Apply(mysql.BeforeConnect(func(_ context.Context, c *mysql.Config) error {
+		c.Passwd = mysqlPassword.Get()
+		return nil
+	})); err != nil {
+		log.Fatalf("Error configuring MySQL connection: %v", err)
+	}
+	logger.Info("Initializing MySQL connection", zap.String("host", cfg.MySQL.Host), zap.String("db_name", cfg.MySQL.DBName))
+	dbConnector, err := mysql.NewConnector(dbConfig)
 	if err != nil {
-		logger.Error("Error initializing OpenTelemetry tracer", zap.Error(err))
-	} else {
-		logger.Info("OpenTelemetry tracer initialized", zap.String("endpoint", cfg.Obs.OTLP.Endpoint))
+		log.Fatalf("Error opening MySQL connection: %v", err)
 	}
-	defer func() {
-		if err := otelShutdown(ctx); err != nil {
-			logger.Error("Error shutting down OpenTelemetry tracer", zap.Error(err))
+	dbConn := sql.OpenDB(dbConnector)
+	dbConn.SetMaxOpenConns(cfg.MySQL.MaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.MySQL.MaxIdleConns)
+	dbConn.SetConnMaxLifetime(time.Duration(cfg.MySQL.ConnMaxLifetime) * time.Second)
+	lifecycle.Register(server.Component{
+		Name: "mysql",
+		Start: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			return dbConn.PingContext(ctx)
+		},
+		Stop: func(context.Context) error {
+			return dbConn.Close()
+		},
+	})
+
+	grpcChain := interceptor.DefaultChain(logger, loggingOptions(cfg)...).
+		Use(profiling.GRPCInterceptor(telemetry.Capturer))
+	// authzCfg is shared by the gRPC server and the internal OpenAPI, nil when disabled.
+	var authzCfg *interceptor.AuthzConfig
+	if cfg.Server.GrpcAuthz.Enabled {
+		authzCfg = &interceptor.AuthzConfig{
+			Policies:     cfg.Server.GrpcAuthz.Policies,
+			DefaultAllow: cfg.Server.GrpcAuthz.DefaultAllow,
+		}
+		if cfg.Server.GrpcAuthz.ServiceToken.JWKSURL != "" {
+			authzCfg.Verifier = svcauth.NewVerifier(svcauth.VerifierConfig{
+				JWKSURL:  cfg.Server.GrpcAuthz.ServiceToken.JWKSURL,
+				Issuer:   cfg.Server.GrpcAuthz.ServiceToken.Issuer,
+				Audience: cfg.Server.GrpcAuthz.ServiceToken.Audience,
+			})
 		}
-	}()
+		grpcChain.Use(grpcchain.ServerInterceptor{
+			Unary:  interceptor.Authorization(*authzCfg, logger),
+			Stream: interceptor.StreamAuthorization(*authzCfg, logger),
+		})
+		logger.Info("gRPC authorization enabled", zap.Any("policies", cfg.Server.GrpcAuthz.Policies))
+	}
+	if cfg.Server.GrpcRateLimit.Enabled {
+		grpcChain.Use(newRateLimitInterceptor(cfg.Server.GrpcRateLimit, redisClient, logger))
+	}
 
-	interceptors := interceptor.DefaultChain(logger)
+	grpcCreds := insecure.NewCredentials()
+	if cfg.Server.GrpcTLS.Enabled {
+		tlsReloader, err := mtls.NewReloader(mtls.Config{
+			CertFile:       cfg.Server.GrpcTLS.CertFile,
+			KeyFile:        cfg.Server.GrpcTLS.KeyFile,
+			CAFile:         cfg.Server.GrpcTLS.CAFile,
+			ReloadInterval: cfg.Server.GrpcTLS.ReloadInterval,
+		}, logger)
+		if err != nil {
+			log.Fatalf("Error loading gRPC TLS files: %v", err)
+		}
+		grpcCreds = credentials.NewTLS(tlsReloader.ServerConfig(cfg.Server.GrpcTLS.AllowedSANs))
 
-	grpcServer := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(interceptors...),
-		grpc.StatsHandler(
-			otelgrpc.NewServerHandler(
-				otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
-			),
-		),
-	)
+		logger.Info("gRPC mutual TLS enabled", zap.Strings("allowed_sans", cfg.Server.GrpcTLS.AllowedSANs))
+		lifecycle.Register(server.Runner("gRPC TLS reloader", tlsReloader.Run))
+	}
+
+	grpcServer := grpc.NewServer(append([]grpc.ServerOption{grpc.Creds(grpcCreds)}, grpcChain.Options()...)...)
 
 	// ----------------------------
 	// gRPC Health Service
 	// ----------------------------
-	healthServer := health.NewServer()
+	healthServer := grpchealth.NewServer()
 
-	// Initially not serving, wait for MySQL to be ready
-	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	// Not serving until every component has started and MySQL answers, and again as soon
+	// as shutdown begins or MySQL goes away, to move traffic from Envoy/K8s elsewhere.
+	healthMonitor := health.New(health.Config{
+		Interval: cfg.Health.Interval,
+		Timeout:  cfg.Health.Timeout,
+	}, logger)
+	healthMonitor.Register(health.Check{Name: "mysql", Checker: health.SQLChecker(dbConn)})
+	if redisClient != nil {
+		// The outbox relay retries and rate limiting falls back to memory, so Redis is reported only.
+		healthMonitor.Register(health.Check{Name: "redis", Checker: health.RedisChecker(redisClient), Optional: true})
+	}
+	healthMonitor.BindGRPC(healthServer, userpb.UserServiceInternal_ServiceDesc.ServiceName)
+	lifecycle.OnReadinessChange(healthMonitor.SetAccepting)
+	lifecycle.Register(server.Runner("health monitor", healthMonitor.Run))
 
 	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
 
-	// Initialize MySQL connection
-	dbDSN := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.DBName)
-	logger.Info("Initializing MySQL connection", zap.String("host", cfg.MySQL.Host), zap.String("db_name", cfg.MySQL.DBName))
-	dbConn, err := sql.Open("mysql", dbDSN)
+	// user components
+	userRepository := userrepo.NewUserRepository(dbConn)
+
+	businessMetrics, err := usermetrics.New(otel.Meter("user.service"))
 	if err != nil {
-		log.Fatalf("Error opening MySQL connection: %v", err)
+		logger.Fatal("Failed to create business metrics", zap.Error(err))
 	}
-	dbConn.SetMaxOpenConns(cfg.MySQL.MaxOpenConns)
-	dbConn.SetMaxIdleConns(cfg.MySQL.MaxIdleConns)
-	dbConn.SetConnMaxLifetime(time.Duration(cfg.MySQL.ConnMaxLifetime) * time.Second)
-	{
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
+	userService := userservice.New(userRepository, userservice.WithMetrics(businessMetrics))
+	userImpl := userhandler.New(userService)
+
+	userpb.RegisterUserServiceInternalServer(grpcServer, userImpl)
 
-		if err := dbConn.PingContext(ctx); err != nil {
-			healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
-			log.Fatalf("Error pinging MySQL: %v", err)
+	lifecycle.Register(server.GRPCServer("grpc server", grpcServer, fmt.Sprintf(":%d", cfg.Server.GrpcPort)))
+
+	// ----------------------------
+	// Internal OpenAPI over HTTP (optional)
+	// ----------------------------
+	if cfg.Server.HTTPPort > 0 {
+		if authzCfg != nil && authzCfg.Verifier == nil {
+			logger.Warn("Internal OpenAPI denies every call: authorization is enabled without USER_SERVICE_TOKEN_JWKS_URL, and HTTP callers have no mTLS identity")
 		}
+		httpServer, err := newInternalHTTPServer(cfg, userService, healthMonitor, telemetry.Capturer, authzCfg, logger)
+		if err != nil {
+			log.Fatalf("Error creating internal HTTP server: %v", err)
+		}
+
+		logger.Info("HTTP server port", zap.Int("port", int(cfg.Server.HTTPPort)))
+		lifecycle.Register(server.HTTPServer("http server", httpServer))
 	}
 
-	// ✅ MySQL OK -> readiness OK
-	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	// ----------------------------
+	// Outbox relay (user-domain events -> Redis Streams)
+	// ----------------------------
+	if cfg.Outbox.Enabled {
+		relay := outbox.NewRelay(
+			userrepo.NewOutboxRepository(dbConn),
+			redisoutbox.NewPublisher(redisClient, cfg.Outbox.Stream, 0),
+			logger,
+			outbox.RelayConfig{
+				PollInterval: cfg.Outbox.PollInterval,
+				BatchSize:    cfg.Outbox.BatchSize,
+				MaxAttempts:  cfg.Outbox.MaxAttempts,
+			},
+		)
 
-	defer func() {
-		// Before closing, declare NOT_SERVING to stop traffic from Envoy/K8s
-		healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		logger.Info("Starting outbox relay", zap.String("redis", cfg.Redis.Host), zap.String("stream", cfg.Outbox.Stream))
+		lifecycle.Register(server.Runner("outbox relay", relay.Run))
+	}
 
-		grpcServer.GracefulStop()
+	if err := lifecycle.Run(ctx); err != nil {
+		logger.Fatal("User service stopped with error", zap.Error(err))
+	}
+	logger.Info("User service stopped")
 
-		if err := dbConn.Close(); err != nil {
-			logger.Warn("Failed to close MySQL connection", zap.Error(err))
-		}
-	}()
+}
 
-	// user components
-	userRepository := userrepo.NewUserRepository(dbConn)
+// newRateLimitInterceptor creates the rate limit interceptor. Buckets are kept in Redis
+// if configured, falling back to process memory while Redis is unreachable.
+func newRateLimitInterceptor(cfg envconfig.RateLimit, redisClient *redis.Client, logger *zap.Logger) grpcchain.ServerInterceptor {
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	if redisClient != nil {
+		limiter = ratelimit.NewFallbackLimiter(
+			ratelimit.NewRedisLimiter(redisClient, "user:ratelimit:"),
+			limiter,
+			func(err error) {
+				logger.Warn("Rate limiter falling back to memory", zap.Error(err))
+			},
+		)
+	}
 
-	userService := userservice.New(userRepository)
-	userImpl := userhandler.New(userService)
+	logger.Info("gRPC rate limiting enabled", zap.Any("rules", cfg.Rules), zap.Bool("redis", redisClient != nil))
+	limitCfg := ratelimit.GRPCConfig{
+		Limiter: limiter,
+		Rules:   cfg.Rules,
+		// The caller verified by the authorization interceptor, which runs first, else the
+		// mTLS identity when authorization is disabled.
+		Subject: func(ctx context.Context) string {
+			if caller, ok := interceptor.CallerFromContext(ctx); ok {
+				return caller
+			}
+			if identity, ok := mtls.IdentityFromContext(ctx); ok {
+				return identity.String()
+			}
+			return ""
+		},
+	}
+	return grpcchain.ServerInterceptor{
+		Unary:  ratelimit.UnaryServerInterceptor(limitCfg),
+		Stream: ratelimit.StreamServerInterceptor(limitCfg),
+	}
+}
 
-	userpb.RegisterUserServiceInternalServer(grpcServer, userImpl)
+// loggingOptions returns the request logging options of the gRPC and HTTP servers.
+func loggingOptions(cfg *envconfig.Config) []logging.Option {
+	return []logging.Option{
+		logging.WithDebugSecret(cfg.Obs.Logging.DebugSecret),
+		logging.WithAccessLog(logging.AccessLogConfig{
+			Enabled:      cfg.Obs.AccessLog.Enabled,
+			Sampling:     cfg.Obs.AccessLog.Sampling,
+			ExcludePaths: cfg.Obs.AccessLog.ExcludePaths,
+		}),
+	}
+}
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GrpcPort))
+// newInternalHTTPServer creates the HTTP server for the internal OpenAPI.
+// Calls are authorized with the gRPC policies when authz is not nil.
+func newInternalHTTPServer(cfg *envconfig.Config, userService *userservice.Service, healthMonitor *health.Monitor, capturer *profiling.Capturer, authz *interceptor.AuthzConfig, logger *zap.Logger) (*http.Server, error) {
+	openAPISpec, err := servergen.GetSpec()
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		return nil, fmt.Errorf("load OpenAPI spec: %w", err)
 	}
 
-	var g errgroup.Group
+	var middlewares []servergen.StrictMiddlewareFunc
+	if authz != nil {
+		middlewares = append(middlewares, interceptor.HTTPAuthorization(*authz, userpb.UserServiceInternal_ServiceDesc.ServiceName, logger))
+	}
+	strict := servergen.NewStrictHandlerWithOptions(userhttphandler.New(userService), middlewares, userhttphandler.StrictHTTPServerOptions())
 
-	g.Go(func() error {
-		return grpcServer.Serve(lis)
-	})
+	apiRouter := chi.NewRouter()
+	apiRouter.Use(correlation.HTTPRequestID())
+	apiRouter.Use(obsmetrics.HTTPRoute())
+	apiRouter.Use(profiling.HTTPMiddleware(capturer))
+	apiRouter.Use(logging.HTTPRequestLogging(logger, loggingOptions(cfg)...))
+	apiRouter.Use(nethttpmiddleware.OapiRequestValidatorWithOptions(openAPISpec, &nethttpmiddleware.Options{
+		ErrorHandlerWithOpts: func(_ context.Context, err error, w http.ResponseWriter, r *http.Request, opts nethttpmiddleware.ErrorHandlerOpts) {
+			apierror.Write(w, r, apierror.FromValidation(err, opts.StatusCode))
+		},
+	}))
 
-	if err := g.Wait(); err != nil {
-		log.Fatal(err)
-	}
+	// Health endpoints are neither traced nor validated against the OpenAPI spec.
+	rootRouter := chi.NewRouter()
+	rootRouter.Method(http.MethodGet, "/livez", healthMonitor.LivezHandler())
+	rootRouter.Method(http.MethodGet, "/readyz", healthMonitor.ReadyzHandler())
+	rootRouter.Mount("/", otelhttp.NewHandler(
+		servergen.HandlerFromMux(strict, apiRouter),
+		constant.SpanNameUserHTTP,
+	))
 
+	return server.NewHTTPServer(
+		fmt.Sprintf(":%d", int(cfg.Server.HTTPPort)),
+		rootRouter,
+		server.HTTPTimeouts{
+			Read:  cfg.Server.ReadTimeout,
+			Write: cfg.Server.WriteTimeout,
+			Idle:  cfg.Server.IdleTimeout,
+		},
+	), nil
 }
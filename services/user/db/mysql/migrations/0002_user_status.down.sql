@@ -0,0 +1,3 @@
+ALTER TABLE users
+  DROP COLUMN updated_at,
+  DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+  ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'active' AFTER password_hash,
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at;
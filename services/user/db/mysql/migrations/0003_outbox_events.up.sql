@@ -0,0 +1,17 @@
+-- Transactional outbox: rows are written in the same transaction as the user change
+-- and published asynchronously by the outbox relay (at-least-once).
+CREATE TABLE outbox_events (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  event_id CHAR(36) NOT NULL,
+  aggregate_type VARCHAR(64) NOT NULL,
+  aggregate_id VARCHAR(64) NOT NULL,
+  event_type VARCHAR(128) NOT NULL,
+  payload JSON NOT NULL,
+  occurred_at TIMESTAMP(6) NOT NULL,
+  published_at TIMESTAMP(6) NULL DEFAULT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  last_error VARCHAR(1024) NULL DEFAULT NULL,
+  UNIQUE KEY uk_outbox_events_event_id (event_id),
+  KEY idx_outbox_events_pending (published_at, id),
+  KEY idx_outbox_events_aggregate (aggregate_type, aggregate_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,4 @@
+ALTER TABLE outbox_events
+  DROP KEY idx_outbox_events_pending,
+  ADD KEY idx_outbox_events_pending (published_at, id),
+  DROP COLUMN dead_lettered_at;
@@ -0,0 +1,6 @@
+-- Events failing max attempts are dead-lettered: they leave the pending set so they no
+-- longer hold back later events of their aggregate, and are kept for inspection and replay.
+ALTER TABLE outbox_events
+  ADD COLUMN dead_lettered_at TIMESTAMP(6) NULL DEFAULT NULL AFTER published_at,
+  DROP KEY idx_outbox_events_pending,
+  ADD KEY idx_outbox_events_pending (published_at, dead_lettered_at, id);
@@ -3,11 +3,58 @@ INSERT INTO users (email, password_hash)
 VALUES (?, ?);
 
 -- name: GetUserByEmail :one
-SELECT id, email, password_hash, created_at
+SELECT id, email, password_hash, status, created_at, updated_at
 FROM users
 WHERE email = ?;
 
+-- name: GetUserByIDForUpdate :one
+SELECT id, email, password_hash, status, created_at, updated_at
+FROM users
+WHERE id = ?
+FOR UPDATE;
+
 -- name: ListUsers :many
-SELECT id, email, password_hash, created_at
+SELECT id, email, password_hash, status, created_at, updated_at
 FROM users
-ORDER BY id;
\ No newline at end of file
+ORDER BY id;
+
+-- name: UpdateUserStatus :exec
+UPDATE users
+SET status = ?
+WHERE id = ?;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = ?
+WHERE id = ?;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET password_hash = sqlc.arg(new_hash)
+WHERE id = sqlc.arg(id) AND password_hash = sqlc.arg(old_hash);
+
+-- name: InsertOutboxEvent :exec
+INSERT INTO outbox_events (event_id, aggregate_type, aggregate_id, event_type, payload, occurred_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListPendingOutboxEvents :many
+SELECT id, event_id, aggregate_type, aggregate_id, event_type, payload, occurred_at, published_at, dead_lettered_at, attempts, last_error
+FROM outbox_events
+WHERE published_at IS NULL AND dead_lettered_at IS NULL
+ORDER BY id
+LIMIT ?;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET published_at = CURRENT_TIMESTAMP(6), last_error = NULL
+WHERE id = ?;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1, last_error = ?
+WHERE id = ?;
+
+-- name: MarkOutboxEventDeadLettered :exec
+UPDATE outbox_events
+SET attempts = attempts + 1, last_error = ?, dead_lettered_at = CURRENT_TIMESTAMP(6)
+WHERE id = ?;
@@ -1,6 +1,17 @@
-// Package envconfig defines the configuration for the auth service.
+// Package envconfig defines the configuration for the user service.
 package envconfig
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	obsconfig "github.com/incheat/go-production-backend/pkg/obs/config"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/incheat/go-production-backend/pkg/ratelimit"
+)
+
 // EnvName is the name of the environment.
 type EnvName string
 
@@ -15,16 +26,127 @@ const (
 
 // Config is the configuration for the application.
 type Config struct {
-	Env     EnvName
-	Version string
-	Server  Server
-	MySQL   MySQL
-	Obs     Obs
+	Env      EnvName `env:"ENV"`
+	Version  string  `env:"USER_VERSION" required:"true"`
+	Secrets  Secrets
+	Server   Server
+	MySQL    MySQL `key:"mysql"`
+	Redis    Redis
+	Outbox   Outbox
+	Shutdown Shutdown
+	Health   Health
+	Obs      Obs
+}
+
+// Secrets is the configuration for resolving secret references. It is declared before
+// the settings that may refer to Vault, so it is loaded first.
+type Secrets struct {
+	Vault Vault
+	// ReloadInterval is how often file and Vault references are polled for rotation.
+	ReloadInterval time.Duration `env:"USER_SECRETS_RELOAD_INTERVAL_SEC" default:"30" unit:"s"`
+}
+
+// Vault is the configuration for vault:// secret references.
+type Vault struct {
+	Address   string `env:"USER_VAULT_ADDR"`
+	Token     string `env:"USER_VAULT_TOKEN" secret:"true"`
+	Namespace string `env:"USER_VAULT_NAMESPACE"`
+}
+
+// Health is the configuration for the dependency health checks.
+type Health struct {
+	// Interval is the time between two rounds of checks.
+	Interval time.Duration `env:"USER_HEALTH_CHECK_INTERVAL_SEC" default:"10" unit:"s"`
+	// Timeout bounds a single check.
+	Timeout time.Duration `env:"USER_HEALTH_CHECK_TIMEOUT_MS" default:"1000" unit:"ms"`
+}
+
+// Shutdown is the configuration for the graceful shutdown.
+type Shutdown struct {
+	// DrainPeriod is how long the health service reports NOT_SERVING before the servers stop.
+	DrainPeriod time.Duration `env:"USER_SHUTDOWN_DRAIN_SEC" default:"5" unit:"s"`
+	// Timeout bounds the time in-flight requests get to finish.
+	Timeout time.Duration `env:"USER_SHUTDOWN_TIMEOUT_SEC" default:"20" unit:"s"`
 }
 
 // Server is the configuration for the server.
 type Server struct {
-	GrpcPort Port
+	GrpcPort Port `env:"USER_GRPC_PORT" required:"true"`
+	// HTTPPort serves the internal OpenAPI alongside gRPC. Zero disables it.
+	HTTPPort     Port          `env:"USER_HTTP_PORT" default:"0"`
+	ReadTimeout  time.Duration `env:"USER_HTTP_READ_TIMEOUT_SEC" default:"10" unit:"s"`
+	WriteTimeout time.Duration `env:"USER_HTTP_WRITE_TIMEOUT_SEC" default:"15" unit:"s"`
+	IdleTimeout  time.Duration `env:"USER_HTTP_IDLE_TIMEOUT_SEC" default:"60" unit:"s"`
+	// GrpcTLS enables mutual TLS on the gRPC server.
+	GrpcTLS TLS
+	// GrpcAuthz enables service-to-service authorization on the gRPC server, and on the
+	// internal OpenAPI with the policies of the gRPC methods of the same name.
+	GrpcAuthz Authz
+	// GrpcRateLimit enables rate limiting on the gRPC server.
+	GrpcRateLimit RateLimit
+}
+
+// RateLimit is the configuration for rate limiting.
+type RateLimit struct {
+	Enabled bool `env:"USER_GRPC_RATE_LIMIT_ENABLED" default:"false"`
+	// Rules are keyed by full gRPC method name.
+	Rules ratelimit.Rules `env:"USER_GRPC_RATE_LIMITS" default:"/user.v1.UserServiceInternal/VerifyUserCredentials=100/1s:subject"`
+}
+
+// Authz is the configuration for service-to-service authorization.
+type Authz struct {
+	Enabled bool `env:"USER_GRPC_AUTHZ_ENABLED" default:"false"`
+	// Policies maps full gRPC method names to the callers allowed to invoke them.
+	Policies Policies `env:"USER_GRPC_AUTHZ_POLICIES"`
+	// DefaultAllow lets any authenticated caller invoke methods without a policy.
+	DefaultAllow bool `env:"USER_GRPC_AUTHZ_DEFAULT_ALLOW" default:"false"`
+	ServiceToken ServiceToken
+}
+
+// Policies maps full gRPC method names to the callers allowed to invoke them.
+type Policies map[string][]string
+
+// UnmarshalText parses "method=caller1,caller2;method2=caller3".
+func (p *Policies) UnmarshalText(text []byte) error {
+	policies := make(Policies)
+	for _, entry := range strings.Split(string(text), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		method, callers, ok := strings.Cut(entry, "=")
+		method = strings.TrimSpace(method)
+		if !ok || !strings.HasPrefix(method, "/") {
+			return fmt.Errorf("invalid entry %q, want /package.Service/Method=caller[,caller]", entry)
+		}
+		for _, caller := range strings.Split(callers, ",") {
+			if caller = strings.TrimSpace(caller); caller != "" {
+				policies[method] = append(policies[method], caller)
+			}
+		}
+	}
+	*p = policies
+	return nil
+}
+
+// ServiceToken is the configuration for verifying service tokens minted by the auth service.
+// An empty JWKSURL accepts mTLS identities only.
+type ServiceToken struct {
+	JWKSURL string `env:"USER_SERVICE_TOKEN_JWKS_URL" key:"jwks_url"`
+	Issuer  string `env:"USER_SERVICE_TOKEN_ISSUER"`
+	// Audience defaults to the service name.
+	Audience string `env:"USER_SERVICE_TOKEN_AUDIENCE"`
+}
+
+// TLS is the configuration for mutual TLS.
+type TLS struct {
+	Enabled  bool   `env:"USER_GRPC_TLS_ENABLED" default:"false"`
+	CertFile string `env:"USER_GRPC_TLS_CERT_FILE"`
+	KeyFile  string `env:"USER_GRPC_TLS_KEY_FILE"`
+	CAFile   string `env:"USER_GRPC_TLS_CA_FILE"`
+	// AllowedSANs restricts the client certificates accepted. Empty accepts any certificate signed by the CA.
+	AllowedSANs    []string      `env:"USER_GRPC_TLS_ALLOWED_SANS" key:"allowed_sans"`
+	ReloadInterval time.Duration `env:"USER_GRPC_TLS_RELOAD_INTERVAL_SEC" default:"30" unit:"s"`
 }
 
 // Port is the port for the server.
@@ -32,45 +154,149 @@ type Port int
 
 // MySQL is the configuration for the MySQL.
 type MySQL struct {
-	User            string
-	Password        string
-	Host            string
-	DBName          string
-	MaxOpenConns    int
-	MaxIdleConns    int
-	ConnMaxLifetime int // seconds
+	User            string `env:"USER_MYSQL_USER"`
+	Password        string `env:"USER_MYSQL_PASSWORD" secret:"true"`
+	Host            string `env:"USER_MYSQL_HOST"`
+	DBName          string `env:"USER_MYSQL_DB_NAME"`
+	MaxOpenConns    int    `env:"USER_MYSQL_MAX_OPEN_CONNS" required:"true"`
+	MaxIdleConns    int    `env:"USER_MYSQL_MAX_IDLE_CONNS" required:"true"`
+	ConnMaxLifetime int    `env:"USER_MYSQL_CONN_MAX_LIFETIME" required:"true"` // seconds
+}
+
+// Redis is the configuration for the Redis.
+type Redis struct {
+	Host     string `env:"USER_REDIS_HOST"`
+	Password string `env:"USER_REDIS_PASSWORD" secret:"true"`
+	DB       int    `env:"USER_REDIS_DB" default:"0"`
+}
+
+// Outbox is the configuration for the outbox relay.
+type Outbox struct {
+	Enabled      bool          `env:"USER_OUTBOX_ENABLED" default:"false"`
+	Stream       string        `env:"USER_OUTBOX_STREAM" default:"user.events"`
+	PollInterval time.Duration `env:"USER_OUTBOX_POLL_INTERVAL_MS" default:"500" unit:"ms"`
+	BatchSize    int           `env:"USER_OUTBOX_BATCH_SIZE" default:"100"`
+	// MaxAttempts is the number of failed publishes after which an event is dead-lettered.
+	MaxAttempts int `env:"USER_OUTBOX_MAX_ATTEMPTS" default:"10"`
 }
 
 // Obs is the configuration for the observability.
 type Obs struct {
+	Admin     Admin
 	Profiling Profiling
 	Logging   Logging
+	AccessLog AccessLog
 	Metrics   Metrics
 	Tracing   Tracing
 	OTLP      OTLP
+	Redaction Redaction
 }
 
 // Profiling is the configuration for the profiling.
 type Profiling struct {
-	Port Port
+	Port Port `env:"PROFILING_PORT" required:"true"`
+	// TLS serves the profiling port over mutual TLS; clients with a certificate signed by
+	// the CA need no admin token.
+	TLS     ProfilingTLS
+	Capture ProfilingCapture
+	Push    ProfilingPush
+}
+
+// ProfilingTLS is the configuration for mutual TLS on the profiling port.
+type ProfilingTLS struct {
+	CertFile string `env:"USER_PROFILING_TLS_CERT_FILE"`
+	KeyFile  string `env:"USER_PROFILING_TLS_KEY_FILE"`
+	CAFile   string `env:"USER_PROFILING_TLS_CA_FILE"`
+	// AllowedSANs restricts the client certificates accepted. Empty accepts any certificate signed by the CA.
+	AllowedSANs []string `env:"USER_PROFILING_TLS_ALLOWED_SANS" key:"allowed_sans"`
+}
+
+// ProfilingCapture is the configuration for the profiles captured on thresholds.
+type ProfilingCapture struct {
+	// Dir receives the captures. Empty disables them.
+	Dir string `env:"USER_PROFILING_CAPTURE_DIR"`
+	// LatencyThreshold captures when a request takes longer. Zero disables it.
+	LatencyThreshold time.Duration `env:"USER_PROFILING_CAPTURE_LATENCY_MS" default:"0" unit:"ms"`
+	// HeapThresholdMB captures when the live heap grows larger. Zero disables it.
+	HeapThresholdMB int           `env:"USER_PROFILING_CAPTURE_HEAP_MB" default:"0"`
+	CPUDuration     time.Duration `env:"USER_PROFILING_CAPTURE_CPU_SEC" default:"10" unit:"s"`
+	Cooldown        time.Duration `env:"USER_PROFILING_CAPTURE_COOLDOWN_SEC" default:"300" unit:"s"`
+	Retention       time.Duration `env:"USER_PROFILING_CAPTURE_RETENTION_HOURS" default:"72" unit:"h"`
+	MaxCaptures     int           `env:"USER_PROFILING_CAPTURE_MAX" default:"20"`
+}
+
+// ProfilingPush is the configuration for pushing profiles to Pyroscope.
+type ProfilingPush struct {
+	// Address is the Pyroscope URL, e.g. http://pyroscope:4040. Empty disables the push.
+	Address   string `env:"USER_PROFILING_PUSH_ADDRESS"`
+	AuthToken string `env:"USER_PROFILING_PUSH_TOKEN" secret:"true"`
+	TenantID  string `env:"USER_PROFILING_PUSH_TENANT_ID" key:"tenant_id"`
 }
 
 // Logging is the configuration for the logging.
 type Logging struct {
-	Level string
+	Level string `env:"USER_LOGGING_LEVEL"`
+	// DebugSecret verifies the tokens forcing debug logging for a single request. Empty disables them.
+	DebugSecret string `env:"USER_DEBUG_LOG_SECRET" secret:"true"`
+}
+
+// AccessLog is the configuration for the access log.
+type AccessLog struct {
+	Enabled bool `env:"USER_ACCESS_LOG_ENABLED" default:"true"`
+	// Sampling is the rate of logged requests per match, first match wins, e.g.
+	// "error=1;/readyz=0.01". Requests no rule matches are always logged.
+	Sampling logging.SampleRules `env:"USER_ACCESS_LOG_SAMPLING" default:"error=1;/grpc.health.v1.Health/*=0.01;/livez=0.01;/readyz=0.01"`
+	// ExcludePaths are never logged: HTTP paths or gRPC methods, "*" suffix for prefixes.
+	ExcludePaths []string `env:"USER_ACCESS_LOG_EXCLUDE_PATHS"`
+}
+
+// Admin is the configuration for the admin endpoints on the profiling listener.
+type Admin struct {
+	// Token is the bearer token of the admin endpoints. Empty disables them.
+	Token string `env:"USER_ADMIN_TOKEN" secret:"true"`
 }
 
 // Metrics is the configuration for the metrics.
 type Metrics struct {
-	Port Port
+	Port Port `env:"PROM_METRICS_PORT" required:"true"`
+	// Prometheus exposes the OpenTelemetry instruments on /metrics.
+	Prometheus bool `env:"USER_METRICS_PROMETHEUS" default:"true"`
+	// Exporter pushes the instruments to the collector: otlp-grpc, otlp-http or none.
+	Exporter       obsconfig.Exporter `env:"USER_METRICS_EXPORTER" default:"none"`
+	ExportInterval time.Duration      `env:"USER_METRICS_EXPORT_INTERVAL_SEC" default:"15" unit:"s"`
+	// Exemplars links histogram buckets to the trace of sampled requests.
+	Exemplars bool `env:"USER_METRICS_EXEMPLARS" default:"true"`
 }
 
 // Tracing is the configuration for the tracing.
 type Tracing struct {
-	SamplingRatio float64
+	SamplingRatio float64 `env:"USER_TRACING_SAMPLING_RATIO" required:"true"`
+	// SamplingRules override the ratio per route, first match wins, e.g. "/livez=0;/v1/*=0.5".
+	SamplingRules obsconfig.SamplingRules `env:"USER_TRACING_SAMPLING_RULES" default:"/grpc.health.v1.Health/*=0;/livez=0;/readyz=0"`
+	// SampleErrors exports spans ending with an error even when their trace is not sampled.
+	SampleErrors bool `env:"USER_TRACING_SAMPLE_ERRORS" default:"true"`
+	// Exporter is one of otlp-grpc, otlp-http, stdout, file or none.
+	Exporter obsconfig.Exporter `env:"USER_TRACING_EXPORTER" default:"otlp-grpc"`
+	// FilePath is where the file exporter appends spans.
+	FilePath string `env:"USER_TRACING_FILE_PATH"`
+}
+
+// Redaction is the configuration for the redaction of logs and spans.
+type Redaction struct {
+	// Fields are redacted from log fields and span attributes, e.g. "email=hash;client_ip=mask".
+	// Passwords, tokens, authorization and cookies are always masked.
+	Fields redact.Policy `env:"USER_REDACT_FIELDS" default:"email=hash;user.email=hash;client_ip=hash;user_agent=mask"`
 }
 
 // OTLP is the configuration for the OpenTelemetry.
 type OTLP struct {
-	Endpoint string
+	Endpoint string `env:"OTEL_ENDPOINT"`
+	// Insecure disables TLS to the collector.
+	Insecure bool `env:"OTLP_INSECURE" default:"true"`
+	// CAFile verifies the collector; empty uses the system roots.
+	CAFile string `env:"OTLP_CA_FILE"`
+	// CertFile and KeyFile are the client certificate, if the collector requires one.
+	CertFile   string `env:"OTLP_CERT_FILE"`
+	KeyFile    string `env:"OTLP_KEY_FILE"`
+	ServerName string `env:"OTLP_SERVER_NAME"`
 }
@@ -3,134 +3,101 @@ package envconfig
 import (
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
-)
-
-// errMissingEnv is the error returned when a required environment variable is missing.
-var errMissingEnv = errors.New("missing env var")
-
-// Load loads the configuration from the environment variables.
-func Load() (*Config, error) {
-	env := getString("ENV") // if missing, it will be ""
-
-	userVersion := getString("USER_VERSION")
-	if userVersion == "" {
-		return nil, fmt.Errorf("USER_VERSION: %w", errMissingEnv)
-	}
 
-	userGrpcPort, err := getIntRequired("USER_GRPC_PORT")
-	if err != nil {
-		return nil, err
-	}
-
-	userMySQLHost := getString("USER_MYSQL_HOST")
-	userMySQLUser := getString("USER_MYSQL_USER")
-	userMySQLPassword := getString("USER_MYSQL_PASSWORD")
-	userMySQLDBName := getString("USER_MYSQL_DB_NAME")
-	userMySQLMaxOpenConns, err := getIntRequired("USER_MYSQL_MAX_OPEN_CONNS")
-	if err != nil {
-		return nil, err
-	}
-	userMySQLMaxIdleConns, err := getIntRequired("USER_MYSQL_MAX_IDLE_CONNS")
-	if err != nil {
-		return nil, err
-	}
-	userMySQLConnMaxLifetime, err := getIntRequired("USER_MYSQL_CONN_MAX_LIFETIME")
-	if err != nil {
-		return nil, err
-	}
+	"github.com/incheat/go-production-backend/pkg/config"
+	"github.com/incheat/go-production-backend/pkg/secrets"
+	"github.com/incheat/go-production-backend/services/user/internal/constant"
+)
 
-	userProfilingPort, err := getIntRequired("PROFILING_PORT")
-	if err != nil {
-		return nil, err
-	}
-	userMetricsPort, err := getIntRequired("PROM_METRICS_PORT")
-	if err != nil {
-		return nil, err
-	}
-	userLoggingLevel := getString("USER_LOGGING_LEVEL")
-	userTracingSamplingRatio, err := getFloat64Required("USER_TRACING_SAMPLING_RATIO")
-	if err != nil {
-		return nil, err
-	}
-	otelEndpoint := getString("OTEL_ENDPOINT")
+// minSecretLength is the minimum length of the admin token and the debug log secret.
+const minSecretLength = 16
 
+// Load loads the configuration from its defaults, the file named by --config or
+// USER_CONFIG_FILE, the environment variables and the command-line args, in increasing
+// order of precedence. Secret settings may be file://, env:// or vault:// references. The
+// error reports every invalid setting at once.
+func Load(args []string) (*Config, *config.Result, error) {
 	cfg := &Config{
-		Env:     EnvName(env),
-		Version: userVersion,
 		Server: Server{
-			GrpcPort: Port(userGrpcPort),
-		},
-		MySQL: MySQL{
-			Host:            userMySQLHost,
-			User:            userMySQLUser,
-			Password:        userMySQLPassword,
-			DBName:          userMySQLDBName,
-			MaxOpenConns:    userMySQLMaxOpenConns,
-			MaxIdleConns:    userMySQLMaxIdleConns,
-			ConnMaxLifetime: userMySQLConnMaxLifetime,
-		},
-		Obs: Obs{
-			Profiling: Profiling{
-				Port: Port(userProfilingPort),
-			},
-			Logging: Logging{
-				Level: userLoggingLevel,
-			},
-			Metrics: Metrics{
-				Port: Port(userMetricsPort),
-			},
-			Tracing: Tracing{
-				SamplingRatio: userTracingSamplingRatio,
-			},
-			OTLP: OTLP{
-				Endpoint: otelEndpoint,
+			GrpcAuthz: Authz{
+				ServiceToken: ServiceToken{
+					Audience: constant.ServiceName,
+				},
 			},
 		},
 	}
 
-	// Optional sanity checks (keep or remove as you like)
-	if err := validate(cfg); err != nil {
-		return nil, err
+	res, err := config.Load(cfg, config.Options{
+		Name:    constant.ServiceName,
+		Args:    args,
+		FileEnv: "USER_CONFIG_FILE",
+		Secrets: NewSecretResolver(cfg),
+	})
+	if res == nil {
+		return nil, nil, err
 	}
 
-	return cfg, nil
+	if err := errors.Join(err, validate(cfg)); err != nil {
+		return nil, res, err
+	}
+	return cfg, res, nil
 }
 
-func getString(name string) string {
-	return strings.TrimSpace(os.Getenv(name))
+// NewSecretResolver returns the resolver of the secret references in cfg. The Vault
+// provider reads cfg.Secrets.Vault on every resolution, so it can be created before cfg
+// is loaded.
+func NewSecretResolver(cfg *Config) *secrets.Resolver {
+	return secrets.NewResolver(
+		secrets.FileProvider{},
+		secrets.EnvProvider{},
+		secrets.NewVaultProvider(func() secrets.VaultConfig {
+			return secrets.VaultConfig{
+				Address:   cfg.Secrets.Vault.Address,
+				Token:     cfg.Secrets.Vault.Token,
+				Namespace: cfg.Secrets.Vault.Namespace,
+			}
+		}, nil),
+	)
 }
 
-func getIntRequired(name string) (int, error) {
-	raw := getString(name)
-	if raw == "" {
-		return 0, fmt.Errorf("%s: %w", name, errMissingEnv)
+func validate(cfg *Config) error {
+	var errs []error
+
+	if cfg.Server.GrpcPort <= 0 || cfg.Server.GrpcPort > 65535 {
+		errs = append(errs, fmt.Errorf("USER_GRPC_PORT: must be between 1 and 65535"))
 	}
-	v, err := strconv.Atoi(raw)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", name, err)
+	if cfg.Server.HTTPPort < 0 || cfg.Server.HTTPPort > 65535 {
+		errs = append(errs, fmt.Errorf("USER_HTTP_PORT: must be between 0 (disabled) and 65535"))
 	}
-	return v, nil
-}
 
-func getFloat64Required(name string) (float64, error) {
-	raw := getString(name)
-	if raw == "" {
-		return 0, fmt.Errorf("%s: %w", name, errMissingEnv)
+	if cfg.Server.GrpcTLS.Enabled {
+		if cfg.Server.GrpcTLS.CertFile == "" || cfg.Server.GrpcTLS.KeyFile == "" || cfg.Server.GrpcTLS.CAFile == "" {
+			errs = append(errs, fmt.Errorf("USER_GRPC_TLS_CERT_FILE, USER_GRPC_TLS_KEY_FILE and USER_GRPC_TLS_CA_FILE are required when USER_GRPC_TLS_ENABLED=true"))
+		}
 	}
-	v, err := strconv.ParseFloat(raw, 64)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", name, err)
+
+	if cfg.Server.GrpcAuthz.Enabled {
+		if !cfg.Server.GrpcTLS.Enabled && cfg.Server.GrpcAuthz.ServiceToken.JWKSURL == "" {
+			errs = append(errs, fmt.Errorf("USER_GRPC_AUTHZ_ENABLED requires USER_GRPC_TLS_ENABLED=true or USER_SERVICE_TOKEN_JWKS_URL"))
+		}
+		if cfg.Server.GrpcAuthz.ServiceToken.JWKSURL != "" && cfg.Server.GrpcAuthz.ServiceToken.Issuer == "" {
+			errs = append(errs, fmt.Errorf("USER_SERVICE_TOKEN_ISSUER is empty (required with USER_SERVICE_TOKEN_JWKS_URL)"))
+		}
 	}
-	return v, nil
-}
 
-func validate(cfg *Config) error {
-	if cfg.Server.GrpcPort <= 0 || cfg.Server.GrpcPort > 65535 {
-		return fmt.Errorf("USER_PUBLIC_PORT: must be between 1 and 65535")
+	if cfg.Outbox.Enabled && cfg.Redis.Host == "" {
+		errs = append(errs, fmt.Errorf("USER_REDIS_HOST is empty (required when USER_OUTBOX_ENABLED=true)"))
+	}
+	if cfg.Outbox.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("USER_OUTBOX_BATCH_SIZE: must be positive"))
+	}
+
+	if token := cfg.Obs.Admin.Token; token != "" && len(token) < minSecretLength {
+		errs = append(errs, fmt.Errorf("USER_ADMIN_TOKEN: must be at least %d characters", minSecretLength))
+	}
+	if secret := cfg.Obs.Logging.DebugSecret; secret != "" && len(secret) < minSecretLength {
+		errs = append(errs, fmt.Errorf("USER_DEBUG_LOG_SECRET: must be at least %d characters", minSecretLength))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -16,4 +16,6 @@ const (
 	DefaultOTELEndpoint = "otel-collector:4317"
 	// ServiceName is the name of the service for the user.
 	ServiceName = "user"
+	// SpanNameUserHTTP is the name of the span for the user internal HTTP server.
+	SpanNameUserHTTP = "user.http"
 )
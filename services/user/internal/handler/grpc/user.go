@@ -3,9 +3,12 @@ package userhandler
 
 import (
 	"context"
+	"errors"
 
 	userpb "github.com/incheat/go-production-backend/api/user/grpc/gen"
 	userservice "github.com/incheat/go-production-backend/services/user/internal/service/user"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -32,7 +35,13 @@ func (s *Server) VerifyUserCredentials(
 
 	user, err := s.service.VerifyUserCredentials(ctx, email, password)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, err.Error())
+		if errors.Is(err, userservice.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if errors.Is(err, userservice.ErrUserNotActive) {
+			return nil, userNotActive(err)
+		}
+		return nil, status.Error(codes.Internal, "internal error")
 	}
 
 	return &userpb.VerifyUserCredentialsResponse{
@@ -41,3 +50,16 @@ func (s *Server) VerifyUserCredentials(
 		Status: user.Status,
 	}, nil
 }
+
+// userNotActive returns the PermissionDenied status of err, tagged with
+// model.ErrorReasonUserNotActive.
+func userNotActive(err error) error {
+	st, detailsErr := status.New(codes.PermissionDenied, err.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason: model.ErrorReasonUserNotActive,
+		Domain: model.ErrorDomain,
+	})
+	if detailsErr != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,21 @@
+package userhandler
+
+import (
+	"net/http"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	servergen "github.com/incheat/go-production-backend/services/user/internal/api/oapi/gen/private/server"
+)
+
+// StrictHTTPServerOptions returns the options of the strict handler, writing request
+// decoding and unexpected handler errors with apierror.Write.
+func StrictHTTPServerOptions() servergen.StrictHTTPServerOptions {
+	return servergen.StrictHTTPServerOptions{
+		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			apierror.Write(w, r, apierror.Wrap(apierror.CodeInvalidRequest, "invalid request body", err))
+		},
+		ResponseErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			apierror.Write(w, r, err)
+		},
+	}
+}
@@ -3,9 +3,12 @@ package userhandler
 
 import (
 	"context"
+	"errors"
 
+	"github.com/incheat/go-production-backend/pkg/ptr"
 	servergen "github.com/incheat/go-production-backend/services/user/internal/api/oapi/gen/private/server"
 	userservice "github.com/incheat/go-production-backend/services/user/internal/service/user"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
@@ -29,8 +32,19 @@ func (s *Server) VerifyUserCredentials(ctx context.Context, request servergen.Ve
 
 	user, err := s.service.VerifyUserCredentials(ctx, email, password)
 	if err != nil {
-		return servergen.VerifyUserCredentials401JSONResponse{
-			Error: err.Error(),
+		if errors.Is(err, userservice.ErrInvalidCredentials) {
+			return servergen.VerifyUserCredentials401JSONResponse{
+				Error: err.Error(),
+			}, nil
+		}
+		if errors.Is(err, userservice.ErrUserNotActive) {
+			return servergen.VerifyUserCredentials403JSONResponse{
+				Error:  err.Error(),
+				Reason: ptr.To(model.ErrorReasonUserNotActive),
+			}, nil
+		}
+		return servergen.VerifyUserCredentials500JSONResponse{
+			Error: "internal error",
 		}, nil
 	}
 
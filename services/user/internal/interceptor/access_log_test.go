@@ -0,0 +1,146 @@
+package interceptor_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestUnitAccessLog_SamplesAndExcludes tests that the default chain writes one access log
+// line per request, following the sampling rules and exclusions.
+func TestUnitAccessLog_SamplesAndExcludes(t *testing.T) {
+	core, recorded := observer.New(zap.InfoLevel)
+	sampling, err := logging.ParseSampleRules("error=1;/grpc.health.v1.Health/*=0;/user.v1.UserServiceInternal/*=1")
+	require.NoError(t, err)
+
+	unary := chainOf(t, interceptor.DefaultChain(zap.New(core), logging.WithAccessLog(logging.AccessLogConfig{
+		Enabled:      true,
+		Sampling:     sampling,
+		ExcludePaths: []string{"/grpc.reflection.*"},
+	})).Unary())
+	call := func(method string, handlerErr error) {
+		t.Helper()
+		_, _ = unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+			logging.SetSubject(ctx, "auth")
+			return nil, handlerErr
+		})
+	}
+
+	call("/grpc.health.v1.Health/Check", nil)                                                           // sampled out
+	call("/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", nil)                              // excluded
+	call("/grpc.health.v1.Health/Check", status.Error(codes.Unavailable, "down"))                       // errors are always logged
+	call("/user.v1.UserServiceInternal/VerifyUserCredentials", status.Error(codes.Unauthenticated, "")) // errors are always logged
+	call("/user.v1.UserServiceInternal/VerifyUserCredentials", nil)
+
+	entries := recorded.FilterMessage(logging.AccessLogMessage).All()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, "Unavailable", entries[0].ContextMap()["grpc_code"])
+
+	fields := entries[2].ContextMap()
+	assert.Equal(t, zap.InfoLevel, entries[2].Level)
+	assert.Equal(t, "grpc", fields["protocol"])
+	assert.Equal(t, "/user.v1.UserServiceInternal/VerifyUserCredentials", fields["method"])
+	assert.Equal(t, "OK", fields["grpc_code"])
+	assert.Equal(t, "auth", fields["subject"])
+	assert.Contains(t, fields, "latency_ms")
+}
+
+// TestUnitHTTPAccessLog tests the access log line of the HTTP middleware: route pattern
+// and path, status, body sizes and resolved client IP, following the sampling rules and
+// exclusions.
+func TestUnitHTTPAccessLog(t *testing.T) {
+	core, recorded := observer.New(zap.InfoLevel)
+	sampling, err := logging.ParseSampleRules("error=1;/livez=0;/v1/*=1")
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(logging.HTTPRequestLogging(zap.New(core),
+		logging.WithAccessLog(logging.AccessLogConfig{
+			Enabled:      true,
+			Sampling:     sampling,
+			ExcludePaths: []string{"/metrics"},
+		}),
+		logging.WithClientIP(func(r *http.Request) string { return r.Header.Get("X-Test-Client-IP") }),
+	))
+	router.Post("/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.SetSubject(r.Context(), "auth")
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"42"}`))
+	})
+	router.Get("/livez", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	router.Get("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	serve := func(method, path, body string) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-Test-Client-IP", "203.0.113.7")
+		r.Header.Set("User-Agent", "test-agent")
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(http.MethodGet, "/metrics", "") // excluded, even failing
+	serve(http.MethodGet, "/livez", "")   // sampled out, but errors are always logged
+	serve(http.MethodPost, "/v1/users/42", `{"name":"gopher"}`)
+	serve(http.MethodGet, "/v1/unknown", "") // 404s are errors
+
+	entries := recorded.FilterMessage(logging.AccessLogMessage).All()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, "/livez", entries[0].ContextMap()["route"])
+	assert.EqualValues(t, http.StatusServiceUnavailable, entries[0].ContextMap()["status"])
+
+	fields := entries[1].ContextMap()
+	assert.Equal(t, zap.InfoLevel, entries[1].Level)
+	assert.Equal(t, "http", fields["protocol"])
+	assert.Equal(t, http.MethodPost, fields["method"])
+	assert.Equal(t, "/v1/users/{id}", fields["route"])
+	assert.Equal(t, "/v1/users/42", fields["path"])
+	assert.EqualValues(t, http.StatusCreated, fields["status"])
+	assert.EqualValues(t, len(`{"name":"gopher"}`), fields["bytes_in"])
+	assert.EqualValues(t, len(`{"id":"42"}`), fields["bytes_out"])
+	assert.Equal(t, "203.0.113.7", fields["client_ip"])
+	assert.Equal(t, "test-agent", fields["user_agent"])
+	assert.Equal(t, "auth", fields["subject"])
+	assert.Contains(t, fields, "latency_ms")
+
+	fields = entries[2].ContextMap()
+	assert.Equal(t, zap.InfoLevel, entries[2].Level, "client errors are not server errors")
+	assert.Equal(t, "/v1/unknown", fields["route"], "the path when no route matched")
+	assert.EqualValues(t, http.StatusNotFound, fields["status"])
+}
+
+// chainOf chains unary interceptors into one.
+func chainOf(t *testing.T, interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	t.Helper()
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, inner := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return ic(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
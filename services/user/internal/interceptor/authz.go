@@ -0,0 +1,173 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/pkg/obs/metrics"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// healthServicePrefix is the prefix of the gRPC health methods, which probes call unauthenticated.
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// Reasons for denials, used in logs and metrics.
+const (
+	denyReasonUnauthenticated = "unauthenticated"
+	denyReasonInvalidToken    = "invalid_token"
+	denyReasonForbidden       = "forbidden"
+)
+
+// callerKey is the context key of the authorized caller.
+type callerKey struct{}
+
+// CallerFromContext returns the most specific name of the caller authorized by
+// Authorization: the service token subject, else the mTLS identity.
+func CallerFromContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok && caller != ""
+}
+
+// TokenVerifier verifies a service token and returns the calling service.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (string, error)
+}
+
+// AuthzConfig is the configuration for the Authorization interceptor.
+type AuthzConfig struct {
+	// Policies maps full method names to the callers allowed to invoke them.
+	Policies map[string][]string
+	// DefaultAllow lets any authenticated caller invoke methods without a policy.
+	DefaultAllow bool
+	// Verifier verifies service tokens. Nil accepts mTLS identities only.
+	Verifier TokenVerifier
+}
+
+// Authorization requires a caller identity and checks it against the per-method policies.
+// The caller is identified by its mTLS peer identity (any SAN or the common name) and/or
+// the subject of a service token in the "authorization" metadata.
+// Denials are svcauth rejections, so the caller can tell them from the method's own
+// errors, and are logged and counted in metrics.AuthzDenialsTotal.
+func Authorization(cfg AuthzConfig, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := check(ctx, cfg, info.FullMethod, svcauth.TokenFromIncomingContext, logger)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthorization is Authorization for streaming requests, checked once when the
+// stream opens.
+func StreamAuthorization(cfg AuthzConfig, logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := check(ss.Context(), cfg, info.FullMethod, svcauth.TokenFromIncomingContext, logger)
+		if err != nil {
+			return err
+		}
+		return handler(srv, grpcchain.ServerStreamWithContext(ctx, ss))
+	}
+}
+
+// check authorizes a call to method, logging and counting denials, and returns ctx
+// with the caller. token returns the service token of the call.
+func check(ctx context.Context, cfg AuthzConfig, method string, token func(context.Context) (string, error), logger *zap.Logger) (context.Context, error) {
+	if strings.HasPrefix(method, healthServicePrefix) {
+		return ctx, nil
+	}
+
+	callers, reason, err := callerNames(ctx, cfg.Verifier, token)
+	if len(callers) > 0 {
+		// The most specific caller name is the subject of the access log line.
+		logging.SetSubject(ctx, callers[0])
+	}
+	if err == nil {
+		reason, err = authorize(cfg, method, callers)
+	}
+	if err != nil {
+		caller := ""
+		if len(callers) > 0 {
+			caller = callers[0]
+		}
+		l := logger
+		if ctxLogger, ok := correlation.LoggerFromContext(ctx); ok {
+			l = ctxLogger
+		}
+		l.Warn("Authorization denied",
+			zap.String("grpc.method", method),
+			zap.String("caller", caller),
+			zap.String("reason", reason),
+			zap.Error(err),
+		)
+		metrics.AuthzDenialsTotal.WithLabelValues(method, caller, reason).Inc()
+		return ctx, err
+	}
+	return context.WithValue(ctx, callerKey{}, callers[0]), nil
+}
+
+// callerNames returns the names the caller is known by.
+func callerNames(ctx context.Context, verifier TokenVerifier, tokenFunc func(context.Context) (string, error)) ([]string, string, error) {
+	var callers []string
+	if identity, ok := mtls.IdentityFromContext(ctx); ok {
+		callers = append(callers, identity.String())
+		callers = append(callers, identity.SANs()...)
+		callers = append(callers, identity.CommonName)
+	}
+
+	if verifier != nil {
+		token, err := tokenFunc(ctx)
+		switch {
+		case err == nil:
+			subject, err := verifier.Verify(ctx, token)
+			if err != nil {
+				return callers, denyReasonInvalidToken, svcauth.Rejection(codes.Unauthenticated, "invalid service token")
+			}
+			// The token subject is the most specific name of the caller.
+			callers = append([]string{subject}, callers...)
+		case !errors.Is(err, svcauth.ErrMissingToken):
+			return callers, denyReasonInvalidToken, svcauth.Rejection(codes.Unauthenticated, "invalid service token")
+		}
+	}
+
+	if len(callers) == 0 {
+		return nil, denyReasonUnauthenticated, svcauth.Rejection(codes.Unauthenticated, "caller identity required")
+	}
+	return callers, "", nil
+}
+
+// authorize checks the callers against the policy of method.
+func authorize(cfg AuthzConfig, method string, callers []string) (string, error) {
+	allowed, ok := cfg.Policies[method]
+	if !ok {
+		if cfg.DefaultAllow {
+			return "", nil
+		}
+		return denyReasonForbidden, svcauth.Rejection(codes.PermissionDenied, "no policy for method")
+	}
+	for _, caller := range callers {
+		if caller != "" && slices.Contains(allowed, caller) {
+			return "", nil
+		}
+	}
+	return denyReasonForbidden, svcauth.Rejection(codes.PermissionDenied, "caller not allowed")
+}
@@ -0,0 +1,43 @@
+package interceptor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	strictnethttp "github.com/oapi-codegen/runtime/strictmiddleware/nethttp"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPAuthorization is Authorization for the internal OpenAPI, as a strict handler
+// middleware so it sees the operation ID. An operation is authorized with the policy of
+// the gRPC method of the same name in service, e.g. VerifyUserCredentials with
+// /user.v1.UserServiceInternal/VerifyUserCredentials, so both transports share one
+// policy. The caller is identified by the service token in the Authorization header.
+// Denials are tagged as svcauth rejections with the svcauth.HeaderErrorReason header.
+func HTTPAuthorization(cfg AuthzConfig, service string, logger *zap.Logger) strictnethttp.StrictHTTPMiddlewareFunc {
+	return func(next strictnethttp.StrictHTTPHandlerFunc, operationID string) strictnethttp.StrictHTTPHandlerFunc {
+		method := "/" + service + "/" + operationID
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			token := func(context.Context) (string, error) { return svcauth.TokenFromRequest(r) }
+			ctx, err := check(ctx, cfg, method, token, logger)
+			if err != nil {
+				svcauth.SetRejection(w.Header())
+				return nil, httpError(err)
+			}
+			return next(ctx, w, r, request)
+		}
+	}
+}
+
+// httpError converts an authorization status error to the matching API error.
+func httpError(err error) error {
+	st := status.Convert(err)
+	if st.Code() == codes.Unauthenticated {
+		return apierror.Wrap(apierror.CodeUnauthorized, st.Message(), err)
+	}
+	return apierror.Wrap(apierror.CodeForbidden, st.Message(), err)
+}
@@ -0,0 +1,129 @@
+package interceptor_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/apierror"
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	"github.com/incheat/go-production-backend/pkg/svcauth"
+	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const verifyMethod = "/user.v1.UserServiceInternal/VerifyUserCredentials"
+
+// fakeVerifier accepts tokens of the form "valid-<subject>".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(_ context.Context, token string) (string, error) {
+	if len(token) > len("valid-") && token[:len("valid-")] == "valid-" {
+		return token[len("valid-"):], nil
+	}
+	return "", errors.New("bad token")
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// TestUnitAuthorization_Policies tests that callers are identified by service token or mTLS
+// identity and checked against the per-method policies.
+func TestUnitAuthorization_Policies(t *testing.T) {
+	authz := interceptor.Authorization(interceptor.AuthzConfig{
+		Policies: map[string][]string{verifyMethod: {"auth"}},
+		Verifier: fakeVerifier{},
+	}, zap.NewNop())
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		method     string
+		wantCode   codes.Code
+		wantCaller string
+	}{
+		{name: "no identity", ctx: context.Background(), method: verifyMethod, wantCode: codes.Unauthenticated},
+		{name: "invalid token", ctx: withToken("forged"), method: verifyMethod, wantCode: codes.Unauthenticated},
+		{name: "allowed token", ctx: withToken("valid-auth"), method: verifyMethod, wantCode: codes.OK, wantCaller: "auth"},
+		{name: "other service token", ctx: withToken("valid-billing"), method: verifyMethod, wantCode: codes.PermissionDenied},
+		{
+			name:       "allowed mTLS identity",
+			ctx:        mtls.ContextWithIdentity(context.Background(), mtls.Identity{CommonName: "auth", URIs: []string{"spiffe://test/auth"}}),
+			method:     verifyMethod,
+			wantCode:   codes.OK,
+			wantCaller: "spiffe://test/auth",
+		},
+		{name: "method without policy", ctx: withToken("valid-auth"), method: "/user.v1.UserServiceInternal/Other", wantCode: codes.PermissionDenied},
+		{name: "health check", ctx: context.Background(), method: "/grpc.health.v1.Health/Check", wantCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			_, err := authz(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, _ any) (any, error) {
+				called = true
+				caller, ok := interceptor.CallerFromContext(ctx)
+				assert.Equal(t, tt.wantCaller, caller)
+				assert.Equal(t, tt.wantCaller != "", ok)
+				return nil, nil
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode != codes.OK, svcauth.IsRejection(err), "denials are rejections")
+			assert.Equal(t, tt.wantCode == codes.OK, called)
+		})
+	}
+}
+
+// TestUnitHTTPAuthorization tests that internal OpenAPI operations are authorized with the
+// policy of the gRPC method of the same name, from the service token of the request.
+func TestUnitHTTPAuthorization(t *testing.T) {
+	authz := interceptor.HTTPAuthorization(interceptor.AuthzConfig{
+		Policies: map[string][]string{verifyMethod: {"auth"}},
+		Verifier: fakeVerifier{},
+	}, "user.v1.UserServiceInternal", zap.NewNop())
+
+	tests := []struct {
+		name      string
+		operation string
+		token     string
+		wantCode  apierror.Code
+	}{
+		{name: "no token", operation: "VerifyUserCredentials", wantCode: apierror.CodeUnauthorized},
+		{name: "invalid token", operation: "VerifyUserCredentials", token: "forged", wantCode: apierror.CodeUnauthorized},
+		{name: "allowed token", operation: "VerifyUserCredentials", token: "valid-auth"},
+		{name: "other service token", operation: "VerifyUserCredentials", token: "valid-billing", wantCode: apierror.CodeForbidden},
+		{name: "operation without policy", operation: "Other", token: "valid-auth", wantCode: apierror.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/internal/users/verify", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			called := false
+			handler := authz(func(context.Context, http.ResponseWriter, *http.Request, any) (any, error) {
+				called = true
+				return nil, nil
+			}, tt.operation)
+
+			w := httptest.NewRecorder()
+			_, err := handler(r.Context(), w, r, nil)
+			assert.Equal(t, tt.wantCode != "", svcauth.IsRejectionResponse(w.Header()), "denials are rejections")
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.wantCode, apierror.From(err).Code)
+			}
+			assert.Equal(t, tt.wantCode == "", called)
+		})
+	}
+}
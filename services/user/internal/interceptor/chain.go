@@ -2,22 +2,33 @@
 package interceptor
 
 import (
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
 	"github.com/incheat/go-production-backend/pkg/obs/logging"
-	"github.com/incheat/go-production-backend/pkg/obs/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
 )
 
-// DefaultChain returns a default chain of interceptors for the user service.
+// DefaultChain returns a default chain of interceptors for the user service, for unary
+// and streaming requests alike. Its otelgrpc stats handler records the spans and the
+// rpc.server.* metrics, including the messages per RPC of streams; health checks are
+// not recorded. loggingOpts configure the request logging, e.g. per-request debug logging.
 func DefaultChain(
 	logger *zap.Logger,
-) []grpc.UnaryServerInterceptor {
-	return []grpc.UnaryServerInterceptor{
-		Recovery(),
-		logging.GRPCRequestLogging(logger),
-		metrics.PromGRPCMetrics(),
-		// ZapTraceUnaryInterceptor(logger),
-		// Logging(logger),
-		// PromMetrics(),
-	}
+	loggingOpts ...logging.Option,
+) *grpcchain.Server {
+	return new(grpcchain.Server).
+		StatsHandler(otelgrpc.NewServerHandler(
+			otelgrpc.WithFilter(filters.Not(filters.HealthCheck())),
+		)).
+		Use(
+			grpcchain.ServerInterceptor{Unary: Recovery(logger), Stream: StreamRecovery(logger)},
+			grpcchain.ServerInterceptor{Unary: PeerIdentity(), Stream: StreamPeerIdentity()},
+			correlation.GRPCRequestID(),
+			grpcchain.ServerInterceptor{
+				Unary:  logging.GRPCRequestLogging(logger, loggingOpts...),
+				Stream: logging.GRPCStreamLogging(logger, loggingOpts...),
+			},
+		)
 }
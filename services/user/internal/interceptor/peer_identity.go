@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/incheat/go-production-backend/pkg/grpcchain"
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerIdentity puts the identity of an mTLS-authenticated client into ctx.
+// Requests over plaintext or without a verified client certificate pass through unchanged.
+func PeerIdentity() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(withPeerIdentity(ctx), req)
+	}
+}
+
+// StreamPeerIdentity is PeerIdentity for streaming requests.
+func StreamPeerIdentity() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		_ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, grpcchain.ServerStreamWithContext(withPeerIdentity(ss.Context()), ss))
+	}
+}
+
+// withPeerIdentity puts the mTLS identity of the peer of ctx, if any, into ctx.
+func withPeerIdentity(ctx context.Context) context.Context {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if identity, ok := mtls.IdentityFromConnectionState(info.State); ok {
+				return mtls.ContextWithIdentity(ctx, identity)
+			}
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,161 @@
+package interceptor_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/incheat/go-production-backend/pkg/mtls"
+	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// writeCert issues a certificate and writes the CA, certificate and key into dir.
+func (ca *testCA) writeCert(t *testing.T, dir string, name string, dnsNames []string, uri string, usage x509.ExtKeyUsage) mtls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     dnsNames,
+	}
+	if uri != "" {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cfg := mtls.Config{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.CAFile, ca.pem, 0o600))
+	return cfg
+}
+
+// startServer starts a gRPC health server requiring mTLS and reports the identities seen by handlers.
+func startServer(t *testing.T, cfg mtls.Config, allowedSANs []string) (string, <-chan mtls.Identity) {
+	t.Helper()
+	reloader, err := mtls.NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	seen := make(chan mtls.Identity, 1)
+	capture := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if identity, ok := mtls.IdentityFromContext(ctx); ok {
+			seen <- identity
+		}
+		return handler(ctx, req)
+	}
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.ServerConfig(allowedSANs))),
+		grpc.ChainUnaryInterceptor(interceptor.PeerIdentity(), capture),
+	)
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String(), seen
+}
+
+func check(t *testing.T, addr string, cfg mtls.Config) error {
+	t.Helper()
+	reloader, err := mtls.NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(reloader.ClientConfig("user"))))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	return err
+}
+
+// TestUnitPeerIdentity_AllowedClient tests that an allowed client certificate is accepted
+// and its identity is put into the handler context.
+func TestUnitPeerIdentity_AllowedClient(t *testing.T) {
+	ca := newTestCA(t)
+	serverCfg := ca.writeCert(t, t.TempDir(), "user", []string{"user"}, "", x509.ExtKeyUsageServerAuth)
+	clientCfg := ca.writeCert(t, t.TempDir(), "auth", []string{"auth"}, "spiffe://test/auth", x509.ExtKeyUsageClientAuth)
+
+	addr, seen := startServer(t, serverCfg, []string{"spiffe://test/auth"})
+
+	require.NoError(t, check(t, addr, clientCfg))
+	identity := <-seen
+	assert.Equal(t, "spiffe://test/auth", identity.String())
+	assert.Equal(t, []string{"auth"}, identity.DNSNames)
+}
+
+// TestUnitPeerIdentity_RejectsClientOutsideAllowlist tests that a client certificate signed
+// by the CA but without an allowed SAN is rejected during the handshake.
+func TestUnitPeerIdentity_RejectsClientOutsideAllowlist(t *testing.T) {
+	ca := newTestCA(t)
+	serverCfg := ca.writeCert(t, t.TempDir(), "user", []string{"user"}, "", x509.ExtKeyUsageServerAuth)
+	clientCfg := ca.writeCert(t, t.TempDir(), "other", []string{"other"}, "spiffe://test/other", x509.ExtKeyUsageClientAuth)
+
+	addr, seen := startServer(t, serverCfg, []string{"spiffe://test/auth"})
+
+	require.Error(t, check(t, addr, clientCfg))
+	assert.Empty(t, seen)
+}
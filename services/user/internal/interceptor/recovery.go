@@ -2,16 +2,19 @@ package interceptor
 
 import (
 	"context"
-	"log"
-	"runtime/debug"
+	"fmt"
+	"runtime"
 
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// Recovery recovers from panics and logs them using Zap.
-func Recovery() grpc.UnaryServerInterceptor {
+// Recovery recovers from panics and logs them using Zap. The panic value is masked
+// unless the runtime raised it, see panicValue.
+func Recovery(logger *zap.Logger) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
@@ -20,10 +23,46 @@ func Recovery() grpc.UnaryServerInterceptor {
 	) (resp any, err error) {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("panic in %s: %v\n%s", info.FullMethod, r, debug.Stack())
-				err = status.Error(codes.Internal, "internal server error")
+				err = recovered(logger, info.FullMethod, r)
 			}
 		}()
 		return handler(ctx, req)
 	}
 }
+
+// StreamRecovery is Recovery for streaming requests.
+func StreamRecovery(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(logger *zap.Logger, method string, r any) error {
+	logger.Error("Panic recovered",
+		zap.String("grpc.method", method),
+		zap.String("panic", panicValue(r)),
+		zap.String("panic.type", fmt.Sprintf("%T", r)),
+		zap.Stack("stack"),
+	)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// panicValue returns the message of runtime errors, which hold no request data, and
+// redact.Mask for any other value: it can be anything the handler had, under no field
+// name the redaction policy could match. The stack still locates the panic.
+func panicValue(r any) string {
+	if err, ok := r.(runtime.Error); ok {
+		return err.Error()
+	}
+	return redact.Mask
+}
@@ -0,0 +1,57 @@
+package interceptor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/obs/redact"
+	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestUnitRecovery_MasksPanicValue tests that a recovered panic is logged with its value
+// masked, unless the runtime raised it.
+func TestUnitRecovery_MasksPanicValue(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserServiceInternal/VerifyCredentials"}
+
+	for name, tt := range map[string]struct {
+		handler   grpc.UnaryHandler
+		wantValue string
+		wantType  string
+	}{
+		"request data": {
+			handler: func(context.Context, any) (any, error) {
+				panic(struct{ Email, Password string }{"user@example.com", "hunter2"})
+			},
+			wantValue: redact.Mask,
+			wantType:  "struct { Email string; Password string }",
+		},
+		"runtime error": {
+			handler: func(_ context.Context, req any) (any, error) {
+				return req.([]int)[3], nil
+			},
+			wantValue: "runtime error: index out of range [3] with length 0",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			core, recorded := observer.New(zap.ErrorLevel)
+
+			_, err := interceptor.Recovery(zap.New(core))(context.Background(), []int{}, info, tt.handler)
+
+			assert.Equal(t, codes.Internal, status.Code(err))
+			require.Equal(t, 1, recorded.Len())
+			fields := recorded.All()[0].ContextMap()
+			assert.Equal(t, tt.wantValue, fields["panic"])
+			if tt.wantType != "" {
+				assert.Equal(t, tt.wantType, fields["panic.type"])
+			}
+			assert.NotContains(t, fields["stack"], "hunter2")
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package interceptor_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/incheat/go-production-backend/pkg/obs/logging"
+	"github.com/incheat/go-production-backend/services/user/internal/interceptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const exportMethod = "/user.v1.UserServiceInternal/ExportUsers"
+
+// TestUnitDefaultChain_Stream tests that streaming requests get the context logger and one
+// access log line counting their messages.
+func TestUnitDefaultChain_Stream(t *testing.T) {
+	core, recorded := observer.New(zap.InfoLevel)
+	stream := streamChainOf(t, interceptor.DefaultChain(zap.New(core),
+		logging.WithAccessLog(logging.AccessLogConfig{Enabled: true})).Stream())
+
+	err := stream(nil, &fakeStream{ctx: context.Background(), toRecv: 2}, &grpc.StreamServerInfo{FullMethod: exportMethod},
+		func(_ any, ss grpc.ServerStream) error {
+			_, ok := correlation.LoggerFromContext(ss.Context())
+			assert.True(t, ok, "the stream context carries the request logger")
+			received := 0
+			for ss.RecvMsg(nil) == nil {
+				received++
+			}
+			assert.Equal(t, 2, received)
+			for range 3 {
+				require.NoError(t, ss.SendMsg(nil))
+			}
+			return nil
+		})
+	require.NoError(t, err)
+
+	entries := recorded.FilterMessage(logging.AccessLogMessage).All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, exportMethod, fields["method"])
+	assert.Equal(t, "OK", fields["grpc_code"])
+	assert.Equal(t, int64(2), fields["msgs_in"])
+	assert.Equal(t, int64(3), fields["msgs_out"])
+}
+
+// TestUnitDefaultChain_StreamRecovery tests that a panicking stream handler fails the
+// stream with Internal instead of crashing the process.
+func TestUnitDefaultChain_StreamRecovery(t *testing.T) {
+	stream := streamChainOf(t, interceptor.DefaultChain(zap.NewNop()).Stream())
+
+	err := stream(nil, &fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: exportMethod},
+		func(any, grpc.ServerStream) error {
+			panic("boom")
+		})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+// TestUnitStreamAuthorization_DeniesUnauthenticated tests that streams are authorized like
+// unary requests.
+func TestUnitStreamAuthorization_DeniesUnauthenticated(t *testing.T) {
+	authz := interceptor.StreamAuthorization(interceptor.AuthzConfig{
+		Policies: map[string][]string{exportMethod: {"auth"}},
+		Verifier: fakeVerifier{},
+	}, zap.NewNop())
+	called := false
+	handler := func(any, grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	info := &grpc.StreamServerInfo{FullMethod: exportMethod}
+
+	err := authz(nil, &fakeStream{ctx: context.Background()}, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.False(t, called)
+
+	require.NoError(t, authz(nil, &fakeStream{ctx: withToken("valid-auth")}, info, handler))
+	assert.True(t, called)
+}
+
+// fakeStream is a server stream receiving toRecv messages.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	toRecv int
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+
+func (s *fakeStream) RecvMsg(any) error {
+	if s.toRecv == 0 {
+		return io.EOF
+	}
+	s.toRecv--
+	return nil
+}
+
+func (s *fakeStream) SendMsg(any) error { return nil }
+
+func (s *fakeStream) SetHeader(metadata.MD) error { return nil }
+
+// streamChainOf chains stream interceptors into one.
+func streamChainOf(t *testing.T, interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	t.Helper()
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, inner := interceptors[i], next
+			next = func(srv any, ss grpc.ServerStream) error {
+				return ic(srv, ss, info, inner)
+			}
+		}
+		return next(srv, ss)
+	}
+}
@@ -0,0 +1,36 @@
+// Package usermetrics defines the business metrics of the user service on
+// OpenTelemetry instruments.
+package usermetrics
+
+import (
+	"context"
+	"time"
+
+	userservice "github.com/incheat/go-production-backend/services/user/internal/service/user"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// _ is a placeholder to ensure that Metrics implements the userservice.Metrics interface.
+var _ userservice.Metrics = (*Metrics)(nil)
+
+// Metrics records the user business metrics.
+type Metrics struct {
+	passwordCheckDuration metric.Float64Histogram
+}
+
+// New creates the user business metrics on meter.
+func New(meter metric.Meter) (*Metrics, error) {
+	passwordCheckDuration, err := meter.Float64Histogram("user.password_hash.duration",
+		metric.WithDescription("Duration of checking passwords against their stored hash"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.0001, 0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1))
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{passwordCheckDuration: passwordCheckDuration}, nil
+}
+
+// PasswordChecked records the duration of a password check.
+func (m *Metrics) PasswordChecked(duration time.Duration) {
+	m.passwordCheckDuration.Record(context.Background(), duration.Seconds())
+}
@@ -0,0 +1,43 @@
+// Package outbox defines the transactional outbox relay for the user service.
+package outbox
+
+import (
+	"context"
+
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+)
+
+// Record is an event stored in the outbox, waiting to be published.
+type Record struct {
+	// ID is the position of the record in the outbox. Records are relayed in ID order.
+	ID       int64
+	Attempts int
+	Event    model.Event
+}
+
+// Store is the interface for the outbox storage.
+type Store interface {
+	// PendingEvents returns up to limit unpublished records, oldest first.
+	PendingEvents(ctx context.Context, limit int) ([]Record, error)
+	// MarkPublished marks a record as published.
+	MarkPublished(ctx context.Context, id int64) error
+	// MarkFailed records a failed publish attempt.
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	// MarkDeadLettered records the last failed publish attempt of a record and removes
+	// it from the pending records, keeping it for inspection.
+	MarkDeadLettered(ctx context.Context, id int64, reason string) error
+}
+
+// Locker is implemented by stores that can elect a single active relay
+// across replicas. Only the holder of the lock relays events, which keeps
+// per-aggregate ordering intact when the service is scaled out.
+type Locker interface {
+	TryLock(ctx context.Context) (unlock func(), ok bool, err error)
+}
+
+// Publisher is the interface for publishing events to a message broker.
+// Implementations must use Event.ID as an idempotency key so that a retried
+// publish does not produce a duplicate message.
+type Publisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
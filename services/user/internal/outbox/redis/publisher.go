@@ -0,0 +1,71 @@
+// Package redisoutbox defines the Redis Streams publisher for outbox events.
+package redisoutbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// dedupeKeyPrefix is the prefix for the idempotency keys in Redis.
+	dedupeKeyPrefix = "outbox:published:"
+	// defaultDedupeTTL is how long a published event ID is remembered.
+	defaultDedupeTTL = 24 * time.Hour
+)
+
+// publishScript adds the event to the stream only if its ID has not been published yet.
+// KEYS[1] = stream, KEYS[2] = dedupe key
+// ARGV[1] = dedupe TTL (seconds), ARGV[2..] = stream fields
+var publishScript = redis.NewScript(`
+if redis.call('SET', KEYS[2], '1', 'NX', 'EX', ARGV[1]) then
+	return redis.call('XADD', KEYS[1], '*',
+		'event_id', ARGV[2],
+		'type', ARGV[3],
+		'aggregate_type', ARGV[4],
+		'aggregate_id', ARGV[5],
+		'occurred_at', ARGV[6],
+		'payload', ARGV[7])
+end
+return false
+`)
+
+// Publisher publishes events to a Redis stream.
+// A single stream preserves the order of events written for the same user.
+type Publisher struct {
+	rdb       *redis.Client
+	stream    string
+	dedupeTTL time.Duration
+}
+
+// NewPublisher creates a new Redis Streams publisher.
+func NewPublisher(rdb *redis.Client, stream string, dedupeTTL time.Duration) *Publisher {
+	if dedupeTTL <= 0 {
+		dedupeTTL = defaultDedupeTTL
+	}
+	return &Publisher{rdb: rdb, stream: stream, dedupeTTL: dedupeTTL}
+}
+
+// Publish publishes an event. Publishing the same event ID twice is a no-op.
+func (p *Publisher) Publish(ctx context.Context, event model.Event) error {
+	keys := []string{p.stream, dedupeKeyPrefix + event.ID}
+	args := []any{
+		strconv.Itoa(int(p.dedupeTTL.Seconds())),
+		event.ID,
+		string(event.Type),
+		event.AggregateType,
+		event.AggregateID,
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		string(event.Payload),
+	}
+
+	err := publishScript.Run(ctx, p.rdb, keys, args...).Err()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("redis XADD error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package redisoutbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisoutbox "github.com/incheat/go-production-backend/services/user/internal/outbox/redis"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stream = "user-events"
+
+func newPublisher(t *testing.T) (*miniredis.Miniredis, *redis.Client, *redisoutbox.Publisher) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb, redisoutbox.NewPublisher(rdb, stream, time.Hour)
+}
+
+func newEvent(id string) model.Event {
+	return model.Event{
+		ID:            id,
+		Type:          model.EventUserCreated,
+		AggregateType: model.AggregateTypeUser,
+		AggregateID:   "1",
+		Payload:       json.RawMessage(`{"user_id":"1"}`),
+		OccurredAt:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// TestUnitPublisher_Dedupe tests that publishing an event ID twice adds a single stream
+// entry carrying the event, and remembers the ID for the dedupe TTL.
+func TestUnitPublisher_Dedupe(t *testing.T) {
+	mr, rdb, publisher := newPublisher(t)
+	ctx := context.Background()
+
+	require.NoError(t, publisher.Publish(ctx, newEvent("e1")))
+	require.NoError(t, publisher.Publish(ctx, newEvent("e1")))
+	require.NoError(t, publisher.Publish(ctx, newEvent("e2")))
+
+	entries, err := rdb.XRange(ctx, stream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, map[string]any{
+		"event_id":       "e1",
+		"type":           "user.created",
+		"aggregate_type": "user",
+		"aggregate_id":   "1",
+		"occurred_at":    "2024-01-02T03:04:05Z",
+		"payload":        `{"user_id":"1"}`,
+	}, entries[0].Values)
+	assert.Equal(t, "e2", entries[1].Values["event_id"])
+	assert.Equal(t, time.Hour, mr.TTL("outbox:published:e1"))
+
+	mr.FastForward(time.Hour)
+	require.NoError(t, publisher.Publish(ctx, newEvent("e1")))
+	n, err := rdb.XLen(ctx, stream).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n, "an ID is published again once its dedupe key expired")
+}
+
+// TestUnitPublisher_Error tests that a failing Redis is reported.
+func TestUnitPublisher_Error(t *testing.T) {
+	mr, _, publisher := newPublisher(t)
+	mr.Close()
+
+	assert.Error(t, publisher.Publish(context.Background(), newEvent("e1")))
+}
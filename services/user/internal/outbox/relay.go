@@ -0,0 +1,138 @@
+// Package outbox defines the relay that publishes outbox events.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = 500 * time.Millisecond
+	defaultBatchSize    = 100
+	defaultMaxAttempts  = 10
+	maxErrorLength      = 1024
+)
+
+// RelayConfig is the configuration for the relay.
+type RelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// MaxAttempts is the number of publish attempts after which an event is
+	// dead-lettered, so that a poison event does not hold back its aggregate forever.
+	MaxAttempts int
+}
+
+// Relay publishes pending outbox records with at-least-once delivery.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	logger    *zap.Logger
+	cfg       RelayConfig
+}
+
+// NewRelay creates a new Relay.
+func NewRelay(store Store, publisher Publisher, logger *zap.Logger, cfg RelayConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	return &Relay{store: store, publisher: publisher, logger: logger, cfg: cfg}
+}
+
+// Run relays events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Warn("outbox relay iteration failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes one batch of pending records and returns how many were published.
+//
+// Records are published in outbox order. When a record fails, the remaining
+// records of the same aggregate are skipped until the next iteration so that
+// consumers never observe a user's events out of order. A record failing its
+// last attempt is dead-lettered instead, and the records after it are relayed.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	if locker, ok := r.store.(Locker); ok {
+		unlock, held, err := locker.TryLock(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if !held {
+			return 0, nil
+		}
+		defer unlock()
+	}
+
+	records, err := r.store.PendingEvents(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := make(map[string]struct{})
+	for _, rec := range records {
+		aggregate := rec.Event.AggregateType + "/" + rec.Event.AggregateID
+		if _, skip := blocked[aggregate]; skip {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, rec.Event); err != nil {
+			fields := []zap.Field{
+				zap.Int64("outbox_id", rec.ID),
+				zap.String("event_id", rec.Event.ID),
+				zap.String("event_type", string(rec.Event.Type)),
+				zap.Int("attempts", rec.Attempts+1),
+				zap.Error(err),
+			}
+			reason := truncate(err.Error(), maxErrorLength)
+			if rec.Attempts+1 >= r.cfg.MaxAttempts {
+				r.logger.Error("outbox event dead-lettered", fields...)
+				if err := r.store.MarkDeadLettered(ctx, rec.ID, reason); err != nil {
+					return published, err
+				}
+				continue
+			}
+			blocked[aggregate] = struct{}{}
+			r.logger.Warn("failed to publish outbox event", fields...)
+			if err := r.store.MarkFailed(ctx, rec.ID, reason); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		// If this fails the record is published again on the next iteration;
+		// the publisher deduplicates on the event ID.
+		if err := r.store.MarkPublished(ctx, rec.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
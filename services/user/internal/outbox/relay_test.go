@@ -0,0 +1,130 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/incheat/go-production-backend/services/user/internal/outbox"
+	memoryrepo "github.com/incheat/go-production-backend/services/user/internal/repository/memory"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakePublisher records published events and fails the events listed in failOnce once.
+type fakePublisher struct {
+	published []model.Event
+	failOnce  map[string]bool
+}
+
+func (p *fakePublisher) Publish(_ context.Context, event model.Event) error {
+	if p.failOnce[event.ID] {
+		delete(p.failOnce, event.ID)
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func eventTypes(events []model.Event) []string {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		out = append(out, e.AggregateID+":"+string(e.Type))
+	}
+	return out
+}
+
+// TestUnitRelayOnce_PreservesPerUserOrderOnFailure tests that a failed event holds back
+// later events of the same user but not events of other users.
+func TestUnitRelayOnce_PreservesPerUserOrderOnFailure(t *testing.T) {
+	ctx := context.Background()
+	store := memoryrepo.NewOutboxRepository()
+	users := memoryrepo.NewUserRepository(store)
+
+	alice := &model.User{PasswordHash: "password"}
+	require.NoError(t, users.CreateUser(ctx, "alice@example.com", alice))
+	bob := &model.User{PasswordHash: "password"}
+	require.NoError(t, users.CreateUser(ctx, "bob@example.com", bob))
+	require.NoError(t, users.UpdateUserStatus(ctx, alice.ID, model.UserStatusLocked))
+
+	pending, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+
+	publisher := &fakePublisher{failOnce: map[string]bool{pending[0].Event.ID: true}}
+	relay := outbox.NewRelay(store, publisher, zap.NewNop(), outbox.RelayConfig{})
+
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{bob.ID + ":user.created"}, eventTypes(publisher.published))
+
+	n, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{
+		bob.ID + ":user.created",
+		alice.ID + ":user.created",
+		alice.ID + ":user.status_changed",
+	}, eventTypes(publisher.published))
+
+	pending, err = store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// failingPublisher fails every publish of the events in fail.
+type failingPublisher struct {
+	fakePublisher
+	fail map[string]bool
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, event model.Event) error {
+	if p.fail[event.ID] {
+		return errors.New("payload rejected")
+	}
+	return p.fakePublisher.Publish(ctx, event)
+}
+
+// TestUnitRelayOnce_DeadLettersPoisonEvent tests that an event failing every attempt is
+// dead-lettered after MaxAttempts and stops holding back later events of its user.
+func TestUnitRelayOnce_DeadLettersPoisonEvent(t *testing.T) {
+	ctx := context.Background()
+	store := memoryrepo.NewOutboxRepository()
+	users := memoryrepo.NewUserRepository(store)
+
+	alice := &model.User{PasswordHash: "password"}
+	require.NoError(t, users.CreateUser(ctx, "alice@example.com", alice))
+	require.NoError(t, users.UpdateUserStatus(ctx, alice.ID, model.UserStatusLocked))
+
+	pending, err := store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	poison := pending[0].Event.ID
+
+	publisher := &failingPublisher{fail: map[string]bool{poison: true}}
+	relay := outbox.NewRelay(store, publisher, zap.NewNop(), outbox.RelayConfig{MaxAttempts: 3})
+
+	for range 2 {
+		n, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	}
+	assert.Empty(t, store.DeadLettered())
+
+	n, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{alice.ID + ":user.status_changed"}, eventTypes(publisher.published))
+
+	dead := store.DeadLettered()
+	require.Len(t, dead, 1)
+	assert.Equal(t, poison, dead[0].Event.ID)
+	assert.Equal(t, 3, dead[0].Attempts)
+
+	pending, err = store.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -0,0 +1,98 @@
+// Package userrepo defines the memory outbox repository for the user service.
+package userrepo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/incheat/go-production-backend/services/user/internal/outbox"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+)
+
+// _ is a placeholder to ensure that OutboxRepository implements the outbox.Store interface.
+var _ outbox.Store = (*OutboxRepository)(nil)
+
+// OutboxRepository defines a memory outbox repository.
+type OutboxRepository struct {
+	sync.Mutex
+	records      []outbox.Record
+	published    map[int64]bool
+	deadLettered map[int64]bool
+}
+
+// NewOutboxRepository creates a new memory outbox repository.
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{
+		published:    make(map[int64]bool),
+		deadLettered: make(map[int64]bool),
+	}
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first.
+func (r *OutboxRepository) PendingEvents(_ context.Context, limit int) ([]outbox.Record, error) {
+	r.Lock()
+	defer r.Unlock()
+	pending := make([]outbox.Record, 0, limit)
+	for _, rec := range r.records {
+		if len(pending) == limit {
+			break
+		}
+		if !r.published[rec.ID] && !r.deadLettered[rec.ID] {
+			pending = append(pending, rec)
+		}
+	}
+	return pending, nil
+}
+
+// MarkPublished marks an event as published.
+func (r *OutboxRepository) MarkPublished(_ context.Context, id int64) error {
+	r.Lock()
+	defer r.Unlock()
+	r.published[id] = true
+	return nil
+}
+
+// MarkFailed records a failed publish attempt.
+func (r *OutboxRepository) MarkFailed(_ context.Context, id int64, _ string) error {
+	r.Lock()
+	defer r.Unlock()
+	for i := range r.records {
+		if r.records[i].ID == id {
+			r.records[i].Attempts++
+		}
+	}
+	return nil
+}
+
+// MarkDeadLettered records the last failed publish attempt and dead-letters the event.
+func (r *OutboxRepository) MarkDeadLettered(ctx context.Context, id int64, reason string) error {
+	if err := r.MarkFailed(ctx, id, reason); err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.deadLettered[id] = true
+	return nil
+}
+
+// DeadLettered returns the dead-lettered records.
+func (r *OutboxRepository) DeadLettered() []outbox.Record {
+	r.Lock()
+	defer r.Unlock()
+	var records []outbox.Record
+	for _, rec := range r.records {
+		if r.deadLettered[rec.ID] {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+func (r *OutboxRepository) append(event model.Event) {
+	r.Lock()
+	defer r.Unlock()
+	r.records = append(r.records, outbox.Record{
+		ID:    int64(len(r.records) + 1),
+		Event: event,
+	})
+}
@@ -3,6 +3,7 @@ package userrepo
 
 import (
 	"context"
+	"strconv"
 	"sync"
 
 	"github.com/incheat/go-production-backend/services/user/internal/repository"
@@ -12,20 +13,24 @@ import (
 // UserRepository defines a memory user repository.
 type UserRepository struct {
 	sync.RWMutex
-	data map[string]*model.User
+	data   map[string]*model.User
+	outbox *OutboxRepository
 }
 
 // NewUserRepository creates a new memory user repository.
-func NewUserRepository() *UserRepository {
+// Events are recorded in outbox, which may be nil if no relay is running.
+func NewUserRepository(outbox *OutboxRepository) *UserRepository {
 	user := &model.User{
 		ID:           "1",
 		Email:        "test@example.com",
-		PasswordHash: "password",
+		PasswordHash: "$2a$10$9GZ6c/5Tc8QvbjQL5VL6JOiXPQ8TwzFuVXprRDfUVBqpauTbGtCIq", // bcrypt of "password"
+		Status:       model.UserStatusActive,
 	}
 	return &UserRepository{
 		data: map[string]*model.User{
 			user.Email: user,
 		},
+		outbox: outbox,
 	}
 }
 
@@ -49,6 +54,89 @@ func (r *UserRepository) CreateUser(_ context.Context, email string, user *model
 		return repository.ErrUserAlreadyExists
 	}
 
+	user.ID = strconv.Itoa(len(r.data) + 1)
+	user.Email = email
+	if user.Status == "" {
+		user.Status = model.UserStatusActive
+	}
 	r.data[email] = user
+
+	return r.record(model.EventUserCreated, user.ID, model.UserCreatedPayload{
+		UserID: user.ID,
+		Email:  user.Email,
+		Status: user.Status,
+	})
+}
+
+// UpdateUserStatus updates a user's status.
+func (r *UserRepository) UpdateUserStatus(_ context.Context, id string, status string) error {
+	r.Lock()
+	defer r.Unlock()
+	user, ok := r.findByID(id)
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	if user.Status == status {
+		return nil
+	}
+
+	oldStatus := user.Status
+	user.Status = status
+	return r.record(model.EventUserStatusChanged, id, model.UserStatusChangedPayload{
+		UserID:    id,
+		OldStatus: oldStatus,
+		NewStatus: status,
+	})
+}
+
+// UpdateUserPassword updates a user's password hash.
+func (r *UserRepository) UpdateUserPassword(_ context.Context, id string, passwordHash string) error {
+	r.Lock()
+	defer r.Unlock()
+	user, ok := r.findByID(id)
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+
+	user.PasswordHash = passwordHash
+	return r.record(model.EventUserPasswordChanged, id, model.UserPasswordChangedPayload{
+		UserID: id,
+	})
+}
+
+// RehashUserPassword replaces a user's password hash with newHash, a new hash of the same
+// password, unless it changed from oldHash since it was read. No event is recorded.
+func (r *UserRepository) RehashUserPassword(_ context.Context, id string, oldHash string, newHash string) error {
+	r.Lock()
+	defer r.Unlock()
+	user, ok := r.findByID(id)
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+
+	if user.PasswordHash == oldHash {
+		user.PasswordHash = newHash
+	}
+	return nil
+}
+
+func (r *UserRepository) findByID(id string) (*model.User, bool) {
+	for _, user := range r.data {
+		if user.ID == id {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+func (r *UserRepository) record(eventType model.EventType, userID string, payload any) error {
+	if r.outbox == nil {
+		return nil
+	}
+	event, err := model.NewUserEvent(eventType, userID, payload)
+	if err != nil {
+		return err
+	}
+	r.outbox.append(event)
 	return nil
 }
@@ -0,0 +1,106 @@
+// Package userrepo defines the MySQL outbox repository for the user service.
+package userrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	db "github.com/incheat/go-production-backend/services/user/internal/db/mysql/gen"
+	"github.com/incheat/go-production-backend/services/user/internal/outbox"
+	"github.com/incheat/go-production-backend/services/user/pkg/model"
+)
+
+// outboxLockName is the MySQL advisory lock held by the active outbox relay.
+const outboxLockName = "user_outbox_relay"
+
+// _ is a placeholder to ensure that OutboxRepository implements the outbox interfaces.
+var (
+	_ outbox.Store  = (*OutboxRepository)(nil)
+	_ outbox.Locker = (*OutboxRepository)(nil)
+)
+
+// OutboxRepository defines a MySQL outbox repository.
+type OutboxRepository struct {
+	db      *sql.DB
+	queries *db.Queries
+}
+
+// NewOutboxRepository creates a new MySQL outbox repository.
+func NewOutboxRepository(dbConn *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db:      dbConn,
+		queries: db.New(dbConn),
+	}
+}
+
+// PendingEvents returns up to limit unpublished events, oldest first.
+func (r *OutboxRepository) PendingEvents(ctx context.Context, limit int) ([]outbox.Record, error) {
+	rows, err := r.queries.ListPendingOutboxEvents(ctx, int32(limit)) //nolint:gosec // limit is a small batch size
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]outbox.Record, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, outbox.Record{
+			ID:       row.ID,
+			Attempts: int(row.Attempts),
+			Event: model.Event{
+				ID:            row.EventID,
+				Type:          model.EventType(row.EventType),
+				AggregateType: row.AggregateType,
+				AggregateID:   row.AggregateID,
+				Payload:       row.Payload,
+				OccurredAt:    row.OccurredAt,
+			},
+		})
+	}
+	return records, nil
+}
+
+// MarkPublished marks an event as published.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	return r.queries.MarkOutboxEventPublished(ctx, id)
+}
+
+// MarkFailed records a failed publish attempt.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return r.queries.MarkOutboxEventFailed(ctx, db.MarkOutboxEventFailedParams{
+		LastError: sql.NullString{String: reason, Valid: reason != ""},
+		ID:        id,
+	})
+}
+
+// MarkDeadLettered records the last failed publish attempt and dead-letters the event.
+func (r *OutboxRepository) MarkDeadLettered(ctx context.Context, id int64, reason string) error {
+	return r.queries.MarkOutboxEventDeadLettered(ctx, db.MarkOutboxEventDeadLetteredParams{
+		LastError: sql.NullString{String: reason, Valid: reason != ""},
+		ID:        id,
+	})
+}
+
+// TryLock acquires the relay lock without waiting.
+// MySQL advisory locks belong to a session, so the lock is taken and released on a dedicated connection.
+func (r *OutboxRepository) TryLock(ctx context.Context) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("outbox lock conn: %w", err)
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", outboxLockName).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("outbox GET_LOCK: %w", err)
+	}
+	if acquired.Int64 != 1 {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", outboxLockName)
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
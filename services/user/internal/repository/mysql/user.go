@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/go-sql-driver/mysql"
@@ -15,12 +16,14 @@ import (
 
 // UserRepository defines a memory user repository.
 type UserRepository struct {
+	db      *sql.DB
 	queries *db.Queries
 }
 
 // NewUserRepository creates a new user repository.
 func NewUserRepository(dbConn *sql.DB) *UserRepository {
 	return &UserRepository{
+		db:      dbConn,
 		queries: db.New(dbConn),
 	}
 }
@@ -39,34 +42,173 @@ func (r *UserRepository) GetUserByEmail(
 		return nil, err
 	}
 
-	return &model.User{
-		ID:           strconv.FormatInt(u.ID, 10),
-		Email:        u.Email,
-		PasswordHash: u.PasswordHash,
-	}, nil
+	return toModel(u), nil
 }
 
-// CreateUser creates a new user.
+// CreateUser creates a new user and records a user.created event in the same transaction.
 func (r *UserRepository) CreateUser(
 	ctx context.Context,
 	email string,
 	user *model.User,
 ) error {
 
-	_, err := r.queries.CreateUser(ctx, db.CreateUserParams{
-		Email:        email,
-		PasswordHash: user.PasswordHash,
+	return r.withTx(ctx, func(q *db.Queries) error {
+		res, err := q.CreateUser(ctx, db.CreateUserParams{
+			Email:        email,
+			PasswordHash: user.PasswordHash,
+		})
+		if err != nil {
+			if isDuplicateKeyError(err) {
+				return repository.ErrUserAlreadyExists
+			}
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		user.ID = strconv.FormatInt(id, 10)
+		user.Email = email
+		if user.Status == "" {
+			user.Status = model.UserStatusActive
+		}
+
+		return insertEvent(ctx, q, model.EventUserCreated, user.ID, model.UserCreatedPayload{
+			UserID: user.ID,
+			Email:  user.Email,
+			Status: user.Status,
+		})
 	})
+}
+
+// UpdateUserStatus updates a user's status and records a user.status_changed event
+// in the same transaction. Setting the current status again is a no-op.
+func (r *UserRepository) UpdateUserStatus(ctx context.Context, id string, status string) error {
+	userID, err := parseID(id)
 	if err != nil {
-		if isDuplicateKeyError(err) {
-			return repository.ErrUserAlreadyExists
+		return err
+	}
+
+	return r.withTx(ctx, func(q *db.Queries) error {
+		u, err := q.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return repository.ErrUserNotFound
+			}
+			return err
 		}
+		if u.Status == status {
+			return nil
+		}
+
+		if err := q.UpdateUserStatus(ctx, db.UpdateUserStatusParams{Status: status, ID: userID}); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, q, model.EventUserStatusChanged, id, model.UserStatusChangedPayload{
+			UserID:    id,
+			OldStatus: u.Status,
+			NewStatus: status,
+		})
+	})
+}
+
+// UpdateUserPassword updates a user's password hash and records a user.password_changed
+// event in the same transaction.
+func (r *UserRepository) UpdateUserPassword(ctx context.Context, id string, passwordHash string) error {
+	userID, err := parseID(id)
+	if err != nil {
 		return err
 	}
 
+	return r.withTx(ctx, func(q *db.Queries) error {
+		if _, err := q.GetUserByIDForUpdate(ctx, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return repository.ErrUserNotFound
+			}
+			return err
+		}
+
+		if err := q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: passwordHash, ID: userID}); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, q, model.EventUserPasswordChanged, id, model.UserPasswordChangedPayload{
+			UserID: id,
+		})
+	})
+}
+
+// RehashUserPassword replaces a user's password hash with newHash, a new hash of the same
+// password, unless it changed from oldHash since it was read. The password itself is
+// unchanged, so no event is recorded.
+func (r *UserRepository) RehashUserPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	userID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	return r.queries.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+		NewHash: newHash,
+		ID:      userID,
+		OldHash: oldHash,
+	})
+}
+
+// withTx runs fn in a transaction and commits it if fn succeeds.
+func (r *UserRepository) withTx(ctx context.Context, fn func(q *db.Queries) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	if err := fn(r.queries.WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
 	return nil
 }
 
+func insertEvent(ctx context.Context, q *db.Queries, eventType model.EventType, userID string, payload any) error {
+	event, err := model.NewUserEvent(eventType, userID, payload)
+	if err != nil {
+		return err
+	}
+
+	return q.InsertOutboxEvent(ctx, db.InsertOutboxEventParams{
+		EventID:       event.ID,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		EventType:     string(event.Type),
+		Payload:       event.Payload,
+		OccurredAt:    event.OccurredAt,
+	})
+}
+
+func toModel(u db.User) *model.User {
+	return &model.User{
+		ID:           strconv.FormatInt(u.ID, 10),
+		Email:        u.Email,
+		PasswordHash: u.PasswordHash,
+		Status:       u.Status,
+		CreatedAt:    u.CreatedAt,
+		UpdatedAt:    u.UpdatedAt,
+	}
+}
+
+func parseID(id string) (int64, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, repository.ErrUserNotFound
+	}
+	return userID, nil
+}
+
 func isDuplicateKeyError(err error) bool {
 	var mysqlErr *mysql.MySQLError
 	if errors.As(err, &mysqlErr) {
@@ -0,0 +1,17 @@
+// Package userservice defines the business metrics for the user service.
+package userservice
+
+import "time"
+
+// Metrics records the business metrics of the service. Implementations must be safe
+// for concurrent use.
+type Metrics interface {
+	// PasswordChecked records the duration of verifying a password against its stored
+	// hash. Comparisons for unknown emails are not recorded.
+	PasswordChecked(duration time.Duration)
+}
+
+// noopMetrics is the Metrics of a service created without WithMetrics.
+type noopMetrics struct{}
+
+func (noopMetrics) PasswordChecked(time.Duration) {}
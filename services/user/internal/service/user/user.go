@@ -3,9 +3,16 @@ package userservice
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/incheat/go-production-backend/pkg/obs/correlation"
+	"github.com/incheat/go-production-backend/services/user/internal/repository"
 	"github.com/incheat/go-production-backend/services/user/pkg/model"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // ErrUserNotFound is returned when a user is not found.
@@ -14,30 +21,163 @@ var ErrUserNotFound = errors.New("user not found")
 // ErrUserAlreadyExists is returned when a user already exists.
 var ErrUserAlreadyExists = errors.New("user already exists")
 
+// ErrInvalidCredentials is returned when the email or password is wrong.
+// Unknown emails and wrong passwords are deliberately indistinguishable.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrUserNotActive is returned when the credentials are valid but the user is disabled or
+// locked. It is only returned after the password matched, so it reveals nothing about
+// the status of accounts to callers without their password.
+var ErrUserNotActive = errors.New("user is not active")
+
+// ErrInvalidStatus is returned when a user status is not supported.
+var ErrInvalidStatus = errors.New("invalid user status")
+
 // Service is the controller for the auth API.
 type Service struct {
-	userRepo Repository
+	userRepo     Repository
+	metrics      Metrics
+	passwordCost int
+	// dummyHash is compared against for unknown emails, so they take as long as a wrong
+	// password.
+	dummyHash []byte
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithMetrics records the business metrics of the service on m.
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
+}
+
+// WithPasswordCost sets the bcrypt cost of new password hashes; bcrypt.DefaultCost by
+// default. Existing hashes keep the cost they were created with.
+func WithPasswordCost(cost int) Option {
+	return func(s *Service) {
+		s.passwordCost = cost
+	}
 }
 
 // Repository is the interface for the member repository.
+// Implementations record the matching domain event in the outbox atomically with every change.
 type Repository interface {
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	CreateUser(ctx context.Context, email string, user *model.User) error
+	UpdateUserStatus(ctx context.Context, id string, status string) error
+	UpdateUserPassword(ctx context.Context, id string, passwordHash string) error
+	// RehashUserPassword replaces the password hash of a user with newHash, a new hash of
+	// the same password, unless it changed from oldHash meanwhile. It records no event.
+	RehashUserPassword(ctx context.Context, id string, oldHash string, newHash string) error
 }
 
 // New creates a new Service.
-func New(userRepo Repository) *Service {
-	return &Service{userRepo: userRepo}
+func New(userRepo Repository, opts ...Option) *Service {
+	s := &Service{userRepo: userRepo, metrics: noopMetrics{}, passwordCost: bcrypt.DefaultCost}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// The password is random and never matched; only the time to compare matters.
+	s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte(time.Now().String()), s.passwordCost)
+	return s
 }
 
-// VerifyUserCredentials verifies a user's credentials.
+// VerifyUserCredentials verifies a user's credentials and that the user may log in.
 func (s *Service) VerifyUserCredentials(ctx context.Context, email string, password string) (*model.User, error) {
 	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	match, legacy := s.checkPassword(user.PasswordHash, password)
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+	if legacy {
+		s.upgradePasswordHash(ctx, user, password)
+	}
+	if user.Status != model.UserStatusActive {
+		return nil, ErrUserNotActive
+	}
+	return user, nil
+}
+
+// checkPassword reports whether password matches the stored hash, and whether the hash
+// is a legacy one. Users created before passwords were hashed with bcrypt still store the
+// plain password; it is compared in constant time, after a bcrypt comparison all the same
+// so that legacy users take as long to check as the others.
+func (s *Service) checkPassword(hash string, password string) (match bool, legacy bool) {
+	start := time.Now()
+	defer func() { s.metrics.PasswordChecked(time.Since(start)) }()
+
+	if _, err := bcrypt.Cost([]byte(hash)); err == nil {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, false
+	}
+	_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+	return hash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1, true
+}
+
+// upgradePasswordHash replaces the legacy hash of user with the bcrypt hash of its
+// password, which just matched. A failure leaves the legacy hash for the next login to
+// upgrade, so it is logged and does not fail the login.
+func (s *Service) upgradePasswordHash(ctx context.Context, user *model.User, password string) {
+	hash, err := s.hashPassword(password)
+	if err == nil {
+		err = s.userRepo.RehashUserPassword(ctx, user.ID, user.PasswordHash, hash)
+	}
+	if err != nil {
+		if logger, ok := correlation.LoggerFromContext(ctx); ok {
+			logger.Warn("Upgrading legacy password hash failed", zap.String("user.id", user.ID), zap.Error(err))
+		}
+	}
+}
+
+// hashPassword returns the bcrypt hash of password.
+func (s *Service) hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CreateUser creates a new active user.
+func (s *Service) CreateUser(ctx context.Context, email string, password string) (*model.User, error) {
+	hash, err := s.hashPassword(password)
 	if err != nil {
 		return nil, err
 	}
-	if user.PasswordHash != password {
-		return nil, errors.New("invalid credentials")
+	user := &model.User{
+		Email:        email,
+		PasswordHash: hash,
+		Status:       model.UserStatusActive,
+	}
+	if err := s.userRepo.CreateUser(ctx, email, user); err != nil {
+		return nil, err
 	}
 	return user, nil
 }
+
+// UpdateUserStatus changes a user's status.
+func (s *Service) UpdateUserStatus(ctx context.Context, id string, status string) error {
+	switch status {
+	case model.UserStatusActive, model.UserStatusDisabled, model.UserStatusLocked:
+	default:
+		return ErrInvalidStatus
+	}
+	return s.userRepo.UpdateUserStatus(ctx, id, status)
+}
+
+// ChangePassword replaces a user's password.
+func (s *Service) ChangePassword(ctx context.Context, id string, newPassword string) error {
+	hash, err := s.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.UpdateUserPassword(ctx, id, hash)
+}
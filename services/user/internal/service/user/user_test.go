@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/incheat/go-production-backend/services/user/internal/repository"
 	userservice "github.com/incheat/go-production-backend/services/user/internal/service/user"
 	"github.com/incheat/go-production-backend/services/user/pkg/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // --- Testify mocks ---
@@ -29,6 +32,34 @@ func (m *MockUserRepository) CreateUser(ctx context.Context, email string, user
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdateUserStatus(ctx context.Context, id string, status string) error {
+	args := m.Called(ctx, id, status)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateUserPassword(ctx context.Context, id string, passwordHash string) error {
+	args := m.Called(ctx, id, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RehashUserPassword(ctx context.Context, id string, oldHash string, newHash string) error {
+	args := m.Called(ctx, id, oldHash, newHash)
+	return args.Error(0)
+}
+
+// hashPassword returns a cheap bcrypt hash of password.
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+// newService creates a service hashing passwords at the minimum cost.
+func newService(repo userservice.Repository, opts ...userservice.Option) *userservice.Service {
+	return userservice.New(repo, append([]userservice.Option{userservice.WithPasswordCost(bcrypt.MinCost)}, opts...)...)
+}
+
 // TestUnitVerifyUserCredentials_Success tests the happy path for VerifyUserCredentials.
 func TestUnitVerifyUserCredentials_Success(t *testing.T) {
 	ctx := context.Background()
@@ -39,7 +70,8 @@ func TestUnitVerifyUserCredentials_Success(t *testing.T) {
 
 	expectedUser := &model.User{
 		Email:        email,
-		PasswordHash: password,
+		PasswordHash: hashPassword(t, password),
+		Status:       model.UserStatusActive,
 	}
 
 	repoMock.
@@ -47,7 +79,7 @@ func TestUnitVerifyUserCredentials_Success(t *testing.T) {
 		Return(expectedUser, nil).
 		Once()
 
-	svc := userservice.New(repoMock)
+	svc := newService(repoMock)
 
 	got, err := svc.VerifyUserCredentials(ctx, email, password)
 	require.NoError(t, err)
@@ -58,6 +90,7 @@ func TestUnitVerifyUserCredentials_Success(t *testing.T) {
 }
 
 // TestUnitVerifyUserCredentials_Errors tests the error cases for VerifyUserCredentials.
+// Users that are not active are only told apart once their password matched.
 func TestUnitVerifyUserCredentials_Errors(t *testing.T) {
 	ctx := context.Background()
 	email := "user@example.com"
@@ -85,12 +118,62 @@ func TestUnitVerifyUserCredentials_Errors(t *testing.T) {
 					On("GetUserByEmail", mock.Anything, email).
 					Return(&model.User{
 						Email:        email,
-						PasswordHash: "different-password",
+						PasswordHash: hashPassword(t, "different-password"),
 					}, nil).
 					Once()
 			},
 			wantErr: "invalid credentials",
 		},
+		{
+			name: "locked user",
+			setupMocks: func(repo *MockUserRepository) {
+				repo.
+					On("GetUserByEmail", mock.Anything, email).
+					Return(&model.User{Email: email, PasswordHash: hashPassword(t, password), Status: model.UserStatusLocked}, nil).
+					Once()
+			},
+			wantErr: "user is not active",
+		},
+		{
+			name: "disabled user",
+			setupMocks: func(repo *MockUserRepository) {
+				repo.
+					On("GetUserByEmail", mock.Anything, email).
+					Return(&model.User{Email: email, PasswordHash: hashPassword(t, password), Status: model.UserStatusDisabled}, nil).
+					Once()
+			},
+			wantErr: "user is not active",
+		},
+		{
+			name: "locked user with wrong password",
+			setupMocks: func(repo *MockUserRepository) {
+				repo.
+					On("GetUserByEmail", mock.Anything, email).
+					Return(&model.User{Email: email, PasswordHash: hashPassword(t, "different-password"), Status: model.UserStatusLocked}, nil).
+					Once()
+			},
+			wantErr: "invalid credentials",
+		},
+		{
+			name: "wrong legacy password",
+			setupMocks: func(repo *MockUserRepository) {
+				repo.
+					On("GetUserByEmail", mock.Anything, email).
+					Return(&model.User{Email: email, PasswordHash: "different-password", Status: model.UserStatusActive}, nil).
+					Once()
+			},
+			wantErr: "invalid credentials",
+		},
+		{
+			name: "empty password hash",
+			setupMocks: func(repo *MockUserRepository) {
+				repo.
+					On("GetUserByEmail", mock.Anything, email).
+					Return(&model.User{Email: email, Status: model.UserStatusActive}, nil).
+					Once()
+			},
+			wantErr: "invalid credentials",
+		},
 	}
 
 	for _, tt := range tests {
@@ -98,7 +181,7 @@ func TestUnitVerifyUserCredentials_Errors(t *testing.T) {
 			repoMock := new(MockUserRepository)
 			tt.setupMocks(repoMock)
 
-			svc := userservice.New(repoMock)
+			svc := newService(repoMock)
 
 			got, err := svc.VerifyUserCredentials(ctx, email, password)
 			require.Error(t, err)
@@ -109,3 +192,117 @@ func TestUnitVerifyUserCredentials_Errors(t *testing.T) {
 		})
 	}
 }
+
+// TestUnitVerifyUserCredentials_UpgradesLegacyHash tests that a legacy plain text password
+// logs in and is replaced by its bcrypt hash, and a failed upgrade does not fail the login.
+func TestUnitVerifyUserCredentials_UpgradesLegacyHash(t *testing.T) {
+	ctx := context.Background()
+	email := "user@example.com"
+	password := "password"
+
+	for _, rehashErr := range []error{nil, errors.New("db error")} {
+		repoMock := new(MockUserRepository)
+		repoMock.
+			On("GetUserByEmail", mock.Anything, email).
+			Return(&model.User{ID: "1", Email: email, PasswordHash: password, Status: model.UserStatusActive}, nil).
+			Once()
+		var rehashed string
+		repoMock.
+			On("RehashUserPassword", mock.Anything, "1", password, mock.Anything).
+			Run(func(args mock.Arguments) { rehashed = args.String(3) }).
+			Return(rehashErr).
+			Once()
+
+		got, err := newService(repoMock).VerifyUserCredentials(ctx, email, password)
+		require.NoError(t, err)
+		assert.Equal(t, "1", got.ID)
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(rehashed), []byte(password)))
+
+		repoMock.AssertExpectations(t)
+	}
+}
+
+// TestUnitCreateUser_Success tests that CreateUser stores an active user with the bcrypt
+// hash of the password.
+func TestUnitCreateUser_Success(t *testing.T) {
+	ctx := context.Background()
+	email := "new@example.com"
+
+	repoMock := new(MockUserRepository)
+	repoMock.
+		On("CreateUser", mock.Anything, email, mock.MatchedBy(func(u *model.User) bool {
+			return u.Email == email && u.Status == model.UserStatusActive &&
+				bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("password")) == nil
+		})).
+		Return(nil).
+		Once()
+
+	svc := newService(repoMock)
+
+	got, err := svc.CreateUser(ctx, email, "password")
+	require.NoError(t, err)
+	assert.Equal(t, model.UserStatusActive, got.Status)
+
+	repoMock.AssertExpectations(t)
+}
+
+// TestUnitChangePassword_StoresHash tests that ChangePassword stores the bcrypt hash of the
+// new password, which then verifies.
+func TestUnitChangePassword_StoresHash(t *testing.T) {
+	repoMock := new(MockUserRepository)
+	var stored string
+	repoMock.
+		On("UpdateUserPassword", mock.Anything, "1", mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.String(2) }).
+		Return(nil).
+		Once()
+	svc := newService(repoMock)
+
+	require.NoError(t, svc.ChangePassword(context.Background(), "1", "new-password"))
+	assert.NotEqual(t, "new-password", stored)
+
+	repoMock.On("GetUserByEmail", mock.Anything, "user@example.com").Return(&model.User{PasswordHash: stored, Status: model.UserStatusActive}, nil)
+	_, err := svc.VerifyUserCredentials(context.Background(), "user@example.com", "new-password")
+	require.NoError(t, err)
+}
+
+// TestUnitUpdateUserStatus_RejectsUnknownStatus tests that unknown statuses never reach the repository.
+func TestUnitUpdateUserStatus_RejectsUnknownStatus(t *testing.T) {
+	repoMock := new(MockUserRepository)
+	svc := newService(repoMock)
+
+	err := svc.UpdateUserStatus(context.Background(), "1", "banana")
+	require.ErrorIs(t, err, userservice.ErrInvalidStatus)
+
+	repoMock.AssertNotCalled(t, "UpdateUserStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// countingMetrics counts the password checks of the service.
+type countingMetrics struct {
+	passwordChecks int
+}
+
+func (m *countingMetrics) PasswordChecked(time.Duration) {
+	m.passwordChecks++
+}
+
+// TestUnitVerifyUserCredentials_RecordsPasswordCheck tests that a password check is recorded
+// for known users, whether the password matches or not, and not for unknown emails.
+func TestUnitVerifyUserCredentials_RecordsPasswordCheck(t *testing.T) {
+	ctx := context.Background()
+	repoMock := new(MockUserRepository)
+	repoMock.On("GetUserByEmail", mock.Anything, "user@example.com").Return(&model.User{PasswordHash: hashPassword(t, "password"), Status: model.UserStatusActive}, nil)
+	repoMock.On("GetUserByEmail", mock.Anything, "ghost@example.com").Return(nil, repository.ErrUserNotFound)
+
+	metrics := &countingMetrics{}
+	svc := newService(repoMock, userservice.WithMetrics(metrics))
+
+	_, err := svc.VerifyUserCredentials(ctx, "user@example.com", "password")
+	require.NoError(t, err)
+	_, err = svc.VerifyUserCredentials(ctx, "user@example.com", "wrong")
+	require.ErrorIs(t, err, userservice.ErrInvalidCredentials)
+	_, err = svc.VerifyUserCredentials(ctx, "ghost@example.com", "password")
+	require.ErrorIs(t, err, userservice.ErrInvalidCredentials)
+
+	assert.Equal(t, 2, metrics.passwordChecks)
+}
@@ -0,0 +1,72 @@
+// Package model defines the domain events for the user service.
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType is the type of a user-domain event.
+type EventType string
+
+const (
+	// EventUserCreated is emitted when a user is created.
+	EventUserCreated EventType = "user.created"
+	// EventUserStatusChanged is emitted when a user's status changes.
+	EventUserStatusChanged EventType = "user.status_changed"
+	// EventUserPasswordChanged is emitted when a user's password changes.
+	EventUserPasswordChanged EventType = "user.password_changed"
+)
+
+// AggregateTypeUser is the aggregate type of user events.
+const AggregateTypeUser = "user"
+
+// Event is a user-domain event.
+// ID is the idempotency key: consumers must treat two events with the same ID as one.
+// Events that share an AggregateID are delivered in the order they were written.
+type Event struct {
+	ID            string          `json:"id"`
+	Type          EventType       `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// UserCreatedPayload is the payload of EventUserCreated.
+type UserCreatedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+// UserStatusChangedPayload is the payload of EventUserStatusChanged.
+type UserStatusChangedPayload struct {
+	UserID    string `json:"user_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+}
+
+// UserPasswordChangedPayload is the payload of EventUserPasswordChanged.
+// It never carries the password or its hash.
+type UserPasswordChangedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// NewUserEvent creates a new user event with a fresh idempotency key.
+func NewUserEvent(eventType EventType, userID string, payload any) (Event, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.NewString(),
+		Type:          eventType,
+		AggregateType: AggregateTypeUser,
+		AggregateID:   userID,
+		Payload:       raw,
+		OccurredAt:    time.Now().UTC(),
+	}, nil
+}
@@ -3,6 +3,26 @@ package model
 
 import "time"
 
+const (
+	// UserStatusActive is the status of a user that can log in.
+	UserStatusActive = "active"
+	// UserStatusDisabled is the status of a user that has been disabled.
+	UserStatusDisabled = "disabled"
+	// UserStatusLocked is the status of a user that has been locked out.
+	UserStatusLocked = "locked"
+)
+
+// Error reasons of the user service, in the google.rpc.ErrorInfo of gRPC statuses and
+// the reason field of HTTP error bodies, for the errors a caller must tell apart from
+// others sharing their status.
+const (
+	// ErrorDomain is the domain of the google.rpc.ErrorInfo of the user service.
+	ErrorDomain = "user"
+	// ErrorReasonUserNotActive is the reason of a login with valid credentials of a user
+	// whose status is not UserStatusActive.
+	ErrorReasonUserNotActive = "USER_NOT_ACTIVE"
+)
+
 // User is a model for a user.
 type User struct {
 	ID           string
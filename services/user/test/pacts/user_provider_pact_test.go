@@ -17,6 +17,7 @@ import (
 	"github.com/pact-foundation/pact-go/v2/models"
 	"github.com/pact-foundation/pact-go/v2/provider"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // -------------------------------------------------------------------
@@ -29,11 +30,15 @@ type fakeUserRepo struct {
 }
 
 func (f *fakeUserRepo) GetUserByEmail(_ context.Context, email string) (*model.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(f.password), bcrypt.MinCost)
+	if err != nil {
+		return nil, err
+	}
 	user := &model.User{
 		ID:           "8a26b19d-8a33-4ece-87b1-7b7c2fb9e0ad",
 		Email:        f.email,
 		Status:       "active",
-		PasswordHash: f.password,
+		PasswordHash: string(hash),
 		UpdatedAt:    time.Now(),
 		CreatedAt:    time.Now(),
 	}
@@ -47,6 +52,18 @@ func (f *fakeUserRepo) CreateUser(_ context.Context, _ string, _ *model.User) er
 	return nil
 }
 
+func (f *fakeUserRepo) UpdateUserStatus(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (f *fakeUserRepo) UpdateUserPassword(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (f *fakeUserRepo) RehashUserPassword(_ context.Context, _ string, _ string, _ string) error {
+	return nil
+}
+
 // -------------------------------------------------------------------
 // Provider Pact Test (matches consumer pact with 200 + 401 interactions)
 // -------------------------------------------------------------------
@@ -61,7 +78,7 @@ func TestUserServiceProviderPact(t *testing.T) {
 	repo := &fakeUserRepo{}
 
 	// Real service + real HTTP handlers/router (adjust ctor signatures if needed)
-	service := userservice.New(repo)
+	service := userservice.New(repo, userservice.WithPasswordCost(bcrypt.MinCost))
 	userImpl := userhandler.New(service)
 
 	// Real HTTP server, ephemeral port, no goroutine management